@@ -0,0 +1,5 @@
+-- Remove rating uncertainty columns from companies table
+ALTER TABLE companies
+    DROP COLUMN IF EXISTS rating_volatility,
+    DROP COLUMN IF EXISTS rating_deviation,
+    DROP COLUMN IF EXISTS rating;
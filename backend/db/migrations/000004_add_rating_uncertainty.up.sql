@@ -0,0 +1,12 @@
+-- Store the precise rating alongside the rounded elo_rating, plus the
+-- uncertainty tracked by Glicko-2
+ALTER TABLE companies
+    ADD COLUMN rating DOUBLE PRECISION,
+    ADD COLUMN rating_deviation DOUBLE PRECISION NOT NULL DEFAULT 350,
+    ADD COLUMN rating_volatility DOUBLE PRECISION NOT NULL DEFAULT 0.06;
+
+UPDATE companies SET rating = elo_rating;
+
+ALTER TABLE companies
+    ALTER COLUMN rating SET NOT NULL,
+    ALTER COLUMN rating SET DEFAULT 1500;
@@ -0,0 +1,3 @@
+-- Remove rating history tables
+DROP TABLE IF EXISTS rating_snapshots;
+DROP TABLE IF EXISTS rating_history;
@@ -0,0 +1,37 @@
+-- Rating history: one row per company per rating change
+CREATE TABLE IF NOT EXISTS rating_history (
+    id BIGSERIAL PRIMARY KEY,
+    company_id INTEGER NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+    vote_id INTEGER REFERENCES votes(id) ON DELETE CASCADE,
+    rating DOUBLE PRECISION NOT NULL,
+    elo_rating INTEGER NOT NULL,
+    rating_deviation DOUBLE PRECISION NOT NULL,
+    rank INTEGER NOT NULL,
+    wins INTEGER NOT NULL,
+    losses INTEGER NOT NULL,
+    total_votes INTEGER NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_rating_history_company_created ON rating_history(company_id, created_at);
+
+-- Daily snapshots: end-of-day state and rank of every company
+CREATE TABLE IF NOT EXISTS rating_snapshots (
+    company_id INTEGER NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    rating DOUBLE PRECISION NOT NULL,
+    elo_rating INTEGER NOT NULL,
+    rating_deviation DOUBLE PRECISION NOT NULL,
+    rank INTEGER NOT NULL,
+    wins INTEGER NOT NULL,
+    losses INTEGER NOT NULL,
+    total_votes INTEGER NOT NULL,
+    PRIMARY KEY (company_id, day)
+);
+
+-- Baseline history row so charts start from the current state
+INSERT INTO rating_history (company_id, rating, elo_rating, rating_deviation, rank, wins, losses, total_votes)
+SELECT id, rating, elo_rating, rating_deviation,
+       RANK() OVER (ORDER BY elo_rating DESC),
+       wins, losses, total_votes
+FROM companies;
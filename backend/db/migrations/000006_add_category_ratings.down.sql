@@ -0,0 +1,6 @@
+-- Remove per-category ratings
+DROP TRIGGER IF EXISTS companies_ensure_category_rating ON companies;
+DROP FUNCTION IF EXISTS ensure_company_category_rating();
+DROP TABLE IF EXISTS company_category_ratings;
+DROP INDEX IF EXISTS idx_votes_category;
+ALTER TABLE votes DROP COLUMN IF EXISTS category;
@@ -0,0 +1,39 @@
+-- Category the matchup was served in (NULL for the global "all" matchup)
+ALTER TABLE votes ADD COLUMN category VARCHAR(100);
+
+CREATE INDEX IF NOT EXISTS idx_votes_category ON votes(category);
+
+-- Independent rating of each company within each category it competes in
+CREATE TABLE IF NOT EXISTS company_category_ratings (
+    company_id INTEGER NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+    category VARCHAR(100) NOT NULL,
+    rating DOUBLE PRECISION NOT NULL DEFAULT 1500,
+    elo_rating INTEGER NOT NULL DEFAULT 1500,
+    rating_deviation DOUBLE PRECISION NOT NULL DEFAULT 350,
+    rating_volatility DOUBLE PRECISION NOT NULL DEFAULT 0.06,
+    total_votes INTEGER NOT NULL DEFAULT 0,
+    wins INTEGER NOT NULL DEFAULT 0,
+    losses INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (company_id, category)
+);
+
+CREATE INDEX IF NOT EXISTS idx_category_ratings_elo ON company_category_ratings(category, elo_rating DESC);
+
+INSERT INTO company_category_ratings (company_id, category)
+SELECT id, category FROM companies
+ON CONFLICT DO NOTHING;
+
+-- Keep a category rating row for every company's current category
+CREATE OR REPLACE FUNCTION ensure_company_category_rating() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO company_category_ratings (company_id, category)
+    VALUES (NEW.id, NEW.category)
+    ON CONFLICT DO NOTHING;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER companies_ensure_category_rating
+AFTER INSERT OR UPDATE OF category ON companies
+FOR EACH ROW EXECUTE FUNCTION ensure_company_category_rating();
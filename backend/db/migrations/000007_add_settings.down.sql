@@ -0,0 +1,2 @@
+-- Remove runtime settings
+DROP TABLE IF EXISTS settings;
@@ -0,0 +1,2 @@
+-- Remove spent matchup tokens
+DROP TABLE IF EXISTS used_matchup_tokens;
@@ -0,0 +1,9 @@
+-- Matchup tokens that have already been spent on a vote. Rows only need to
+-- outlive the token itself; expired tokens are rejected on their own.
+CREATE TABLE IF NOT EXISTS used_matchup_tokens (
+    id VARCHAR(64) PRIMARY KEY,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_used_matchup_tokens_expires_at ON used_matchup_tokens(expires_at);
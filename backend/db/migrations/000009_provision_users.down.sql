@@ -0,0 +1,20 @@
+-- Restore the raw subject on votes
+ALTER TABLE votes ADD COLUMN user_subject VARCHAR(255);
+
+UPDATE votes v
+SET user_subject = u.subject
+FROM users u
+WHERE u.id = v.user_id;
+
+DROP INDEX IF EXISTS idx_votes_user_id;
+ALTER TABLE votes DROP COLUMN user_id;
+ALTER TABLE votes RENAME COLUMN user_subject TO user_id;
+
+CREATE INDEX IF NOT EXISTS idx_votes_user_id ON votes(user_id);
+
+-- Users without an email cannot satisfy the original schema
+DELETE FROM users WHERE email IS NULL;
+ALTER TABLE users DROP COLUMN updated_at;
+ALTER TABLE users DROP COLUMN avatar_url;
+ALTER TABLE users DROP COLUMN subject;
+ALTER TABLE users ALTER COLUMN email SET NOT NULL;
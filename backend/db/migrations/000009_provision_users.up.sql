@@ -0,0 +1,27 @@
+-- Users are provisioned from identity provider tokens, which carry a
+-- subject but not necessarily an email address
+ALTER TABLE users ALTER COLUMN email DROP NOT NULL;
+ALTER TABLE users ADD COLUMN subject VARCHAR(255) UNIQUE;
+ALTER TABLE users ADD COLUMN avatar_url TEXT;
+ALTER TABLE users ADD COLUMN updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP;
+
+-- Provision a user for every subject that has already voted
+INSERT INTO users (subject, name)
+SELECT DISTINCT user_id, 'user-' || SUBSTRING(MD5(user_id) FOR 8)
+FROM votes
+WHERE user_id IS NOT NULL AND user_id != ''
+ON CONFLICT (subject) DO NOTHING;
+
+-- Replace the raw subject on votes with a reference to the user
+ALTER TABLE votes ADD COLUMN user_ref INTEGER REFERENCES users(id) ON DELETE SET NULL;
+
+UPDATE votes v
+SET user_ref = u.id
+FROM users u
+WHERE u.subject = v.user_id;
+
+DROP INDEX IF EXISTS idx_votes_user_id;
+ALTER TABLE votes DROP COLUMN user_id;
+ALTER TABLE votes RENAME COLUMN user_ref TO user_id;
+
+CREATE INDEX IF NOT EXISTS idx_votes_user_id ON votes(user_id);
@@ -0,0 +1,8 @@
+-- Remove session claims
+DROP TABLE IF EXISTS claimed_sessions;
+
+DROP INDEX IF EXISTS idx_comments_session_id;
+DROP INDEX IF EXISTS idx_ratings_session_id;
+
+ALTER TABLE company_comments DROP COLUMN IF EXISTS user_id;
+ALTER TABLE company_ratings DROP COLUMN IF EXISTS user_id;
@@ -0,0 +1,14 @@
+-- Attribute ratings and comments to signed-in users, like votes
+ALTER TABLE company_ratings ADD COLUMN user_id INTEGER REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE company_comments ADD COLUMN user_id INTEGER REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_ratings_session_id ON company_ratings(session_id);
+CREATE INDEX IF NOT EXISTS idx_comments_session_id ON company_comments(session_id);
+
+-- Anonymous sessions that have been merged into a user account. A session
+-- can only ever be claimed by one user.
+CREATE TABLE IF NOT EXISTS claimed_sessions (
+    session_id VARCHAR(255) PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    claimed_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,2 @@
+-- Remove anonymous sessions
+DROP TABLE IF EXISTS sessions;
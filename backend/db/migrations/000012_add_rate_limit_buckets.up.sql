@@ -0,0 +1,10 @@
+-- Token buckets shared by every replica when rate limits are kept in
+-- Postgres. Losing them on a crash only resets the limits, so the table is
+-- not WAL-logged.
+CREATE UNLOGGED TABLE IF NOT EXISTS rate_limit_buckets (
+    key TEXT PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_limit_buckets_updated_at ON rate_limit_buckets(updated_at);
@@ -0,0 +1,4 @@
+-- Remove vote statuses and per-pair vote tracking
+DROP TABLE IF EXISTS voter_pair_votes;
+DROP INDEX IF EXISTS idx_votes_status;
+ALTER TABLE votes DROP COLUMN IF EXISTS status;
@@ -0,0 +1,20 @@
+-- Votes that are recorded but do not change ratings, such as repeats inside
+-- the cooldown window, are kept with a status other than 'counted' so that
+-- analytics can still see them.
+ALTER TABLE votes ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'counted';
+
+CREATE INDEX IF NOT EXISTS idx_votes_status ON votes(status);
+
+-- The last vote of each voter on each unordered pair of companies, where the
+-- voter is a user or an anonymous session. company_low is the smaller id.
+CREATE TABLE IF NOT EXISTS voter_pair_votes (
+    voter VARCHAR(80) NOT NULL,
+    company_low INTEGER NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+    company_high INTEGER NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+    winner_id INTEGER NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+    voted_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (voter, company_low, company_high),
+    CHECK (company_low < company_high)
+);
+
+CREATE INDEX IF NOT EXISTS idx_voter_pair_votes_voted_at ON voter_pair_votes(voted_at);
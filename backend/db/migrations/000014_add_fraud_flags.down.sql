@@ -0,0 +1,5 @@
+-- Remove fraud flags; votes excluded as fraud are counted again after a recompute
+DROP INDEX IF EXISTS idx_votes_created_at;
+DROP TABLE IF EXISTS fraud_flag_votes;
+DROP TABLE IF EXISTS fraud_flags;
+UPDATE votes SET status = 'counted' WHERE status = 'fraud';
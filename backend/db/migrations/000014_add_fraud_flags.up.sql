@@ -0,0 +1,37 @@
+-- Suspicious voting patterns found by the fraud analyzer, awaiting review.
+-- A flag is about a session, a company or both, and covers the votes in
+-- fraud_flag_votes. Confirming a flag sets its votes' status to 'fraud',
+-- which excludes them from ratings.
+CREATE TABLE IF NOT EXISTS fraud_flags (
+    id SERIAL PRIMARY KEY,
+    kind VARCHAR(40) NOT NULL,
+    session_id VARCHAR(255),
+    company_id INTEGER REFERENCES companies(id) ON DELETE CASCADE,
+    details TEXT NOT NULL DEFAULT '',
+    vote_count INTEGER NOT NULL DEFAULT 0,
+    window_start TIMESTAMP WITH TIME ZONE,
+    window_end TIMESTAMP WITH TIME ZONE,
+    status VARCHAR(20) NOT NULL DEFAULT 'open',
+    review_note TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    reviewed_at TIMESTAMP WITH TIME ZONE
+);
+
+-- At most one open flag per pattern and subject; new suspicious votes are
+-- added to it until it is reviewed
+CREATE UNIQUE INDEX IF NOT EXISTS idx_fraud_flags_open_subject
+    ON fraud_flags(kind, COALESCE(session_id, ''), COALESCE(company_id, 0))
+    WHERE status = 'open';
+CREATE INDEX IF NOT EXISTS idx_fraud_flags_status ON fraud_flags(status, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS fraud_flag_votes (
+    flag_id INTEGER NOT NULL REFERENCES fraud_flags(id) ON DELETE CASCADE,
+    vote_id INTEGER NOT NULL REFERENCES votes(id) ON DELETE CASCADE,
+    PRIMARY KEY (flag_id, vote_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_fraud_flag_votes_vote_id ON fraud_flag_votes(vote_id);
+
+-- The analyzer scans recent votes
+CREATE INDEX IF NOT EXISTS idx_votes_created_at ON votes(created_at);
@@ -0,0 +1,2 @@
+-- Remove vote rating deltas
+DROP TABLE IF EXISTS vote_rating_deltas;
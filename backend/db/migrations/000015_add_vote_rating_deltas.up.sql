@@ -0,0 +1,16 @@
+-- How much each counted vote moved the ratings of its two companies,
+-- globally (category '') and within the vote's category. UndoVote subtracts
+-- these to retract a vote; rows are dropped once the undo window has passed.
+CREATE TABLE IF NOT EXISTS vote_rating_deltas (
+    vote_id INTEGER NOT NULL REFERENCES votes(id) ON DELETE CASCADE,
+    company_id INTEGER NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+    category VARCHAR(100) NOT NULL DEFAULT '',
+    won BOOLEAN NOT NULL,
+    rating_delta DOUBLE PRECISION NOT NULL,
+    deviation_delta DOUBLE PRECISION NOT NULL,
+    volatility_delta DOUBLE PRECISION NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (vote_id, company_id, category)
+);
+
+CREATE INDEX IF NOT EXISTS idx_vote_rating_deltas_created_at ON vote_rating_deltas(created_at);
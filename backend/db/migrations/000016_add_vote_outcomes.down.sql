@@ -0,0 +1,9 @@
+-- Remove vote outcomes. Draws and skips are kept but no longer counted, since
+-- they would otherwise replay as wins; recompute ratings afterwards.
+UPDATE votes SET status = outcome WHERE outcome <> 'win';
+ALTER TABLE votes DROP COLUMN IF EXISTS outcome;
+ALTER TABLE companies
+    DROP COLUMN IF EXISTS draws,
+    DROP COLUMN IF EXISTS skips;
+DELETE FROM voter_pair_votes WHERE winner_id IS NULL;
+ALTER TABLE voter_pair_votes ALTER COLUMN winner_id SET NOT NULL;
@@ -0,0 +1,13 @@
+-- A vote is a win for winner_id over loser_id, a draw between the two, or a
+-- skip that records the matchup was shown without changing any rating. For
+-- draws and skips winner_id and loser_id are only the two companies.
+ALTER TABLE votes ADD COLUMN IF NOT EXISTS outcome VARCHAR(10) NOT NULL DEFAULT 'win'
+    CHECK (outcome IN ('win', 'draw', 'skip'));
+
+-- Draws are included in total_votes; skips are not
+ALTER TABLE companies
+    ADD COLUMN IF NOT EXISTS draws INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS skips INTEGER NOT NULL DEFAULT 0;
+
+-- A draw has no winner
+ALTER TABLE voter_pair_votes ALTER COLUMN winner_id DROP NOT NULL;
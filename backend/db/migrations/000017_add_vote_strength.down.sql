@@ -0,0 +1,2 @@
+-- Remove vote strength
+ALTER TABLE votes DROP COLUMN IF EXISTS strength;
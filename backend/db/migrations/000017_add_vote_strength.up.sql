@@ -0,0 +1,4 @@
+-- How clearly the voter preferred the winner. The rating update of a win is
+-- scaled by it; draws and skips are always 'normal'.
+ALTER TABLE votes ADD COLUMN IF NOT EXISTS strength VARCHAR(10) NOT NULL DEFAULT 'normal'
+    CHECK (strength IN ('slight', 'normal', 'strong'));
@@ -0,0 +1,4 @@
+-- Remove rankings
+DROP INDEX IF EXISTS idx_votes_ranking_id;
+ALTER TABLE votes DROP COLUMN IF EXISTS ranking_id;
+DROP TABLE IF EXISTS rankings;
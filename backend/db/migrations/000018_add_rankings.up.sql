@@ -0,0 +1,12 @@
+-- A ranking orders a set of companies served together, best first. It is
+-- counted as the pairwise votes it implies, which point back to it.
+CREATE TABLE IF NOT EXISTS rankings (
+    id SERIAL PRIMARY KEY,
+    company_ids INTEGER[] NOT NULL,
+    category VARCHAR(100),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE votes ADD COLUMN IF NOT EXISTS ranking_id INTEGER REFERENCES rankings(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_votes_ranking_id ON votes(ranking_id);
@@ -0,0 +1,4 @@
+-- Remove vote pair indexes
+DROP INDEX IF EXISTS idx_votes_loser_winner;
+DROP INDEX IF EXISTS idx_votes_winner_loser;
+DROP INDEX IF EXISTS idx_votes_pair;
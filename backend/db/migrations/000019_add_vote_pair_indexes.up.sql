@@ -0,0 +1,8 @@
+-- Head-to-head lookups find the votes between two companies in either
+-- direction, newest first
+CREATE INDEX IF NOT EXISTS idx_votes_pair ON votes(LEAST(winner_id, loser_id), GREATEST(winner_id, loser_id), created_at DESC)
+    WHERE status = 'counted';
+
+-- Rival lookups group a company's votes by opponent, from either side
+CREATE INDEX IF NOT EXISTS idx_votes_winner_loser ON votes(winner_id, loser_id) WHERE status = 'counted';
+CREATE INDEX IF NOT EXISTS idx_votes_loser_winner ON votes(loser_id, winner_id) WHERE status = 'counted';
@@ -0,0 +1,2 @@
+-- Remove rating decays
+DROP TABLE IF EXISTS rating_decays;
@@ -0,0 +1,13 @@
+-- Decays pull the rating of a company without recent votes back toward the
+-- default rating and widen its deviation. Each one is kept with the
+-- parameters it was applied with, so that recomputes replay it.
+CREATE TABLE IF NOT EXISTS rating_decays (
+    id SERIAL PRIMARY KEY,
+    company_id INTEGER NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+    rate DOUBLE PRECISION NOT NULL,
+    deviation_increase DOUBLE PRECISION NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_rating_decays_company_created ON rating_decays(company_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_rating_decays_created_at ON rating_decays(created_at);
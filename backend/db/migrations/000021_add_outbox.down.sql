@@ -0,0 +1,3 @@
+-- Remove the outbox
+DROP TABLE IF EXISTS outbox_offsets;
+DROP TABLE IF EXISTS outbox_events;
@@ -0,0 +1,4 @@
+-- Remove webhooks
+DROP TABLE IF EXISTS webhook_company_ranks;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhook_subscriptions;
@@ -0,0 +1,2 @@
+-- Remove the company activity rollup
+DROP TABLE IF EXISTS company_activity_hourly;
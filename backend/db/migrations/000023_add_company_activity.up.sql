@@ -0,0 +1,15 @@
+-- Hourly rollup of each company's counted votes and rating changes, so
+-- leaderboards over a time window read a few rows per company instead of
+-- the votes log. The rollup job rebuilds recent hours, and recomputes
+-- rebuild the hours whose history they rewrite.
+CREATE TABLE IF NOT EXISTS company_activity_hourly (
+    company_id INTEGER NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+    hour TIMESTAMP WITH TIME ZONE NOT NULL,
+    wins INTEGER NOT NULL DEFAULT 0,
+    losses INTEGER NOT NULL DEFAULT 0,
+    draws INTEGER NOT NULL DEFAULT 0,
+    rating_change DOUBLE PRECISION NOT NULL DEFAULT 0,
+    PRIMARY KEY (company_id, hour)
+);
+
+CREATE INDEX IF NOT EXISTS idx_company_activity_hourly_hour ON company_activity_hourly(hour);
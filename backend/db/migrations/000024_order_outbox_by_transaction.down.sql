@@ -0,0 +1,5 @@
+-- Order the outbox by id again
+ALTER TABLE outbox_offsets DROP COLUMN IF EXISTS last_position;
+DROP INDEX IF EXISTS idx_outbox_events_position;
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS position;
+DROP TABLE IF EXISTS outbox_aggregates;
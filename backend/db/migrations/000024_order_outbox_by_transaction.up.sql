@@ -0,0 +1,20 @@
+-- Order the outbox by the transactions that wrote it instead of locking it
+-- for every writer. An event's position is the id of its transaction, raised
+-- to the position of its aggregate's previous event, which is kept locked
+-- until the writer commits. The relay reads only positions below the oldest
+-- transaction still running, and every event committed later is positioned
+-- at or above it.
+CREATE TABLE IF NOT EXISTS outbox_aggregates (
+    aggregate_type VARCHAR(50) NOT NULL,
+    aggregate_id INTEGER NOT NULL,
+    last_position BIGINT NOT NULL,
+    PRIMARY KEY (aggregate_type, aggregate_id)
+);
+
+-- Events recorded before keep position 0, so they are delivered first
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS position BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE outbox_events ALTER COLUMN position DROP DEFAULT;
+CREATE INDEX IF NOT EXISTS idx_outbox_events_position ON outbox_events(position, id);
+
+-- Sinks follow the outbox by position, then id
+ALTER TABLE outbox_offsets ADD COLUMN IF NOT EXISTS last_position BIGINT NOT NULL DEFAULT 0;
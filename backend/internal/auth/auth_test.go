@@ -0,0 +1,220 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testIssuer   = "https://issuer.example/"
+	testAudience = "https://api.example"
+)
+
+// testProvider is an identity provider serving its signing keys over an
+// httptest JWKS endpoint.
+type testProvider struct {
+	server  *httptest.Server
+	rsaKey  *rsa.PrivateKey
+	ecKey   *ecdsa.PrivateKey
+	fetches atomic.Int32
+	// While blocking is set, JWKS requests wait until release is closed
+	blocking atomic.Bool
+	release  chan struct{}
+}
+
+func newTestProvider(t *testing.T) *testProvider {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &testProvider{rsaKey: rsaKey, ecKey: ecKey, release: make(chan struct{})}
+
+	encode := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	set := map[string]any{"keys": []map[string]string{
+		{
+			"kty": "RSA", "kid": "rsa", "use": "sig",
+			"n": encode(rsaKey.N.Bytes()),
+			"e": encode(big.NewInt(int64(rsaKey.E)).Bytes()),
+		},
+		{
+			"kty": "EC", "kid": "ec", "use": "sig", "crv": "P-256",
+			"x": encode(ecKey.X.Bytes()),
+			"y": encode(ecKey.Y.Bytes()),
+		},
+	}}
+	p.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.fetches.Add(1)
+		if p.blocking.Load() {
+			<-p.release
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(set)
+	}))
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+func validClaims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":   testIssuer,
+		"aud":   testAudience,
+		"sub":   "auth0|123",
+		"email": "user@example.com",
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}
+}
+
+func (p *testProvider) sign(t *testing.T, method jwt.SigningMethod, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	var key any
+	switch method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		key = p.rsaKey
+	case *jwt.SigningMethodECDSA:
+		key = p.ecKey
+	case *jwt.SigningMethodHMAC:
+		key = []byte("shared-secret")
+	default:
+		key = jwt.UnsafeAllowNoneSignatureType
+	}
+	raw, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestVerify(t *testing.T) {
+	p := newTestProvider(t)
+	verifier, err := NewVerifier(Config{JWKSURL: p.server.URL, Issuer: testIssuer, Audience: testAudience})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	with := func(key string, value any) jwt.MapClaims {
+		claims := validClaims()
+		claims[key] = value
+		return claims
+	}
+	// want is the error a token is rejected with, or nil if it is accepted
+	tests := []struct {
+		name   string
+		method jwt.SigningMethod
+		kid    string
+		claims jwt.MapClaims
+		want   error
+	}{
+		{name: "valid RSA", method: jwt.SigningMethodRS256, kid: "rsa", claims: validClaims()},
+		{name: "valid EC", method: jwt.SigningMethodES256, kid: "ec", claims: validClaims()},
+		{name: "expired", method: jwt.SigningMethodRS256, kid: "rsa", claims: with("exp", time.Now().Add(-time.Hour).Unix()), want: jwt.ErrTokenExpired},
+		{name: "wrong issuer", method: jwt.SigningMethodRS256, kid: "rsa", claims: with("iss", "https://other.example/"), want: jwt.ErrTokenInvalidIssuer},
+		{name: "wrong audience", method: jwt.SigningMethodES256, kid: "ec", claims: with("aud", "https://other.example"), want: jwt.ErrTokenInvalidAudience},
+		{name: "unknown kid", method: jwt.SigningMethodRS256, kid: "rotated-out", claims: validClaims(), want: ErrUnknownKey},
+		{name: "HMAC", method: jwt.SigningMethodHS256, kid: "rsa", claims: validClaims(), want: jwt.ErrTokenSignatureInvalid},
+		{name: "none", method: jwt.SigningMethodNone, kid: "rsa", claims: validClaims(), want: jwt.ErrTokenSignatureInvalid},
+		{name: "RSA-PSS", method: jwt.SigningMethodPS256, kid: "rsa", claims: validClaims(), want: jwt.ErrTokenSignatureInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := p.sign(t, tt.method, tt.kid, tt.claims)
+			identity, err := verifier.Verify(context.Background(), raw)
+			if tt.want != nil {
+				if !errors.Is(err, tt.want) {
+					t.Fatalf("got %v, want %v", err, tt.want)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if identity.Subject != "auth0|123" || identity.Email != "user@example.com" {
+				t.Fatalf("unexpected identity %+v", identity)
+			}
+		})
+	}
+}
+
+func TestUnknownKeyFetchIsRateLimited(t *testing.T) {
+	p := newTestProvider(t)
+	jwks := NewJWKS(p.server.URL)
+	ctx := context.Background()
+
+	if _, err := jwks.Key(ctx, "rsa"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := jwks.Key(ctx, "forged"); !errors.Is(err, ErrUnknownKey) {
+			t.Fatalf("got %v, want ErrUnknownKey", err)
+		}
+	}
+	if n := p.fetches.Load(); n != 1 {
+		t.Fatalf("fetched the key set %d times, want 1", n)
+	}
+}
+
+// TestKeyDoesNotWaitForFetch checks that a slow refetch for one key id does
+// not hold up keys that are already cached.
+func TestKeyDoesNotWaitForFetch(t *testing.T) {
+	p := newTestProvider(t)
+	jwks := NewJWKS(p.server.URL)
+	ctx := context.Background()
+
+	if _, err := jwks.Key(ctx, "rsa"); err != nil {
+		t.Fatal(err)
+	}
+	p.blocking.Store(true)
+	jwks.mu.Lock()
+	jwks.lastAttempt = time.Time{}
+	jwks.mu.Unlock()
+
+	refetched := make(chan error, 1)
+	go func() {
+		_, err := jwks.Key(ctx, "rotated-in")
+		refetched <- err
+	}()
+	for p.fetches.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := jwks.Key(ctx, "ec")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("cached key lookup waited for the fetch")
+	}
+
+	close(p.release)
+	if err := <-refetched; !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("got %v, want ErrUnknownKey", err)
+	}
+}
@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"connectrpc.com/connect"
+)
+
+// Interceptor authenticates callers from the Authorization header. Requests
+// without a bearer token proceed anonymously; requests with an invalid one
+// are rejected so that a broken login is not silently treated as anonymous.
+type Interceptor struct {
+	verifier *Verifier
+}
+
+// NewInterceptor creates an interceptor that verifies tokens with verifier.
+func NewInterceptor(verifier *Verifier) *Interceptor {
+	return &Interceptor{verifier: verifier}
+}
+
+// WrapUnary implements connect.Interceptor.
+func (i *Interceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		if req.Spec().IsClient {
+			return next(ctx, req)
+		}
+		ctx, err := i.authenticate(ctx, req.Header())
+		if err != nil {
+			return nil, err
+		}
+		return next(ctx, req)
+	}
+}
+
+// WrapStreamingClient implements connect.Interceptor.
+func (i *Interceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+// WrapStreamingHandler implements connect.Interceptor.
+func (i *Interceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		ctx, err := i.authenticate(ctx, conn.RequestHeader())
+		if err != nil {
+			return err
+		}
+		return next(ctx, conn)
+	}
+}
+
+func (i *Interceptor) authenticate(ctx context.Context, header http.Header) (context.Context, error) {
+	raw, ok := strings.CutPrefix(header.Get("Authorization"), "Bearer ")
+	if !ok || raw == "" {
+		return ctx, nil
+	}
+
+	identity, err := i.verifier.Verify(ctx, raw)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeUnauthenticated, err)
+	}
+	return WithIdentity(ctx, identity), nil
+}
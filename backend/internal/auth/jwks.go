@@ -0,0 +1,212 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// jwksRefreshInterval is how long fetched keys are trusted before the
+	// key set is fetched again.
+	jwksRefreshInterval = time.Hour
+	// jwksMinRefreshInterval limits how often an unknown key id can trigger
+	// a refetch, so forged tokens cannot hammer the identity provider.
+	jwksMinRefreshInterval = time.Minute
+)
+
+// ErrUnknownKey is returned when a token is signed with a key that is not in
+// the key set.
+var ErrUnknownKey = errors.New("signing key not found in key set")
+
+// JWKS is a cached JSON Web Key Set fetched from an identity provider.
+type JWKS struct {
+	url    string
+	client *http.Client
+
+	mu          sync.Mutex
+	keys        map[string]crypto.PublicKey
+	fetchedAt   time.Time
+	lastAttempt time.Time
+	// fetching is closed when the fetch in progress, if any, completes
+	fetching chan struct{}
+}
+
+// NewJWKS creates a key set backed by url. Keys are fetched on first use.
+func NewJWKS(url string) *JWKS {
+	return &JWKS{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Key returns the public key with the given key id, refetching the key set
+// if it is stale or does not contain kid.
+func (j *JWKS) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	j.mu.Lock()
+	key, ok := j.keys[kid]
+	if ok && time.Since(j.fetchedAt) <= jwksRefreshInterval {
+		j.mu.Unlock()
+		return key, nil
+	}
+	fetching := j.fetching
+	if fetching == nil && time.Since(j.lastAttempt) >= jwksMinRefreshInterval {
+		j.lastAttempt = time.Now()
+		j.fetching = make(chan struct{})
+		j.mu.Unlock()
+		return j.refresh(ctx, kid, key, ok)
+	}
+	j.mu.Unlock()
+
+	// Another request is fetching the key set; wait for it rather than
+	// rejecting a token signed with a key it is about to bring in
+	if fetching != nil {
+		select {
+		case <-fetching:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		j.mu.Lock()
+		key, ok = j.keys[kid]
+		j.mu.Unlock()
+	}
+	if ok {
+		return key, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+// refresh fetches the key set and swaps it in. The lock is not held while
+// fetching, so a slow provider does not hold up tokens signed with cached
+// keys. cached is the stale key for kid, if there was one.
+func (j *JWKS) refresh(ctx context.Context, kid string, cached crypto.PublicKey, hasCached bool) (crypto.PublicKey, error) {
+	keys, err := j.fetch(ctx)
+
+	j.mu.Lock()
+	if err == nil {
+		j.keys = keys
+		j.fetchedAt = time.Now()
+	}
+	close(j.fetching)
+	j.fetching = nil
+	j.mu.Unlock()
+
+	if err != nil {
+		// Keep serving known keys if the provider is briefly unavailable
+		if hasCached {
+			return cached, nil
+		}
+		return nil, err
+	}
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+// jsonWebKey is the subset of RFC 7517 fields needed for RSA and EC
+// signature keys.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (j *JWKS) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build JWKS request: %w", err)
+	}
+
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS: unexpected status %s", resp.Status)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			// Skip keys we cannot use rather than rejecting the whole set
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	return keys, nil
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key parameter: %w", err)
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -8,25 +8,58 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type ClaimedSession struct {
+	SessionID string             `json:"session_id"`
+	UserID    int32              `json:"user_id"`
+	ClaimedAt pgtype.Timestamptz `json:"claimed_at"`
+}
+
 type Company struct {
-	ID            int32              `json:"id"`
-	Name          string             `json:"name"`
-	Slug          string             `json:"slug"`
-	LogoUrl       *string            `json:"logo_url"`
-	Description   *string            `json:"description"`
-	Website       *string            `json:"website"`
-	Category      string             `json:"category"`
-	Tags          []string           `json:"tags"`
-	FoundedYear   *int32             `json:"founded_year"`
-	HqLocation    *string            `json:"hq_location"`
-	EmployeeRange *string            `json:"employee_range"`
-	FundingStage  *string            `json:"funding_stage"`
-	EloRating     int32              `json:"elo_rating"`
-	TotalVotes    int32              `json:"total_votes"`
-	Wins          int32              `json:"wins"`
-	Losses        int32              `json:"losses"`
-	CreatedAt     pgtype.Timestamptz `json:"created_at"`
-	UpdatedAt     pgtype.Timestamptz `json:"updated_at"`
+	ID               int32              `json:"id"`
+	Name             string             `json:"name"`
+	Slug             string             `json:"slug"`
+	LogoUrl          *string            `json:"logo_url"`
+	Description      *string            `json:"description"`
+	Website          *string            `json:"website"`
+	Category         string             `json:"category"`
+	Tags             []string           `json:"tags"`
+	FoundedYear      *int32             `json:"founded_year"`
+	HqLocation       *string            `json:"hq_location"`
+	EmployeeRange    *string            `json:"employee_range"`
+	FundingStage     *string            `json:"funding_stage"`
+	EloRating        int32              `json:"elo_rating"`
+	TotalVotes       int32              `json:"total_votes"`
+	Wins             int32              `json:"wins"`
+	Losses           int32              `json:"losses"`
+	CreatedAt        pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt        pgtype.Timestamptz `json:"updated_at"`
+	Rating           float64            `json:"rating"`
+	RatingDeviation  float64            `json:"rating_deviation"`
+	RatingVolatility float64            `json:"rating_volatility"`
+	Draws            int32              `json:"draws"`
+	Skips            int32              `json:"skips"`
+}
+
+type CompanyActivityHourly struct {
+	CompanyID    int32              `json:"company_id"`
+	Hour         pgtype.Timestamptz `json:"hour"`
+	Wins         int32              `json:"wins"`
+	Losses       int32              `json:"losses"`
+	Draws        int32              `json:"draws"`
+	RatingChange float64            `json:"rating_change"`
+}
+
+type CompanyCategoryRating struct {
+	CompanyID        int32              `json:"company_id"`
+	Category         string             `json:"category"`
+	Rating           float64            `json:"rating"`
+	EloRating        int32              `json:"elo_rating"`
+	RatingDeviation  float64            `json:"rating_deviation"`
+	RatingVolatility float64            `json:"rating_volatility"`
+	TotalVotes       int32              `json:"total_votes"`
+	Wins             int32              `json:"wins"`
+	Losses           int32              `json:"losses"`
+	UpdatedAt        pgtype.Timestamptz `json:"updated_at"`
 }
 
 type CompanyComment struct {
@@ -37,6 +70,7 @@ type CompanyComment struct {
 	SessionID         *string            `json:"session_id"`
 	Upvotes           int32              `json:"upvotes"`
 	CreatedAt         pgtype.Timestamptz `json:"created_at"`
+	UserID            *int32             `json:"user_id"`
 }
 
 type CompanyRating struct {
@@ -46,13 +80,127 @@ type CompanyRating struct {
 	Score     int32              `json:"score"`
 	SessionID *string            `json:"session_id"`
 	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	UserID    *int32             `json:"user_id"`
+}
+
+type FraudFlag struct {
+	ID          int32              `json:"id"`
+	Kind        string             `json:"kind"`
+	SessionID   *string            `json:"session_id"`
+	CompanyID   *int32             `json:"company_id"`
+	Details     string             `json:"details"`
+	VoteCount   int32              `json:"vote_count"`
+	WindowStart pgtype.Timestamptz `json:"window_start"`
+	WindowEnd   pgtype.Timestamptz `json:"window_end"`
+	Status      string             `json:"status"`
+	ReviewNote  *string            `json:"review_note"`
+	CreatedAt   pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt   pgtype.Timestamptz `json:"updated_at"`
+	ReviewedAt  pgtype.Timestamptz `json:"reviewed_at"`
+}
+
+type FraudFlagVote struct {
+	FlagID int32 `json:"flag_id"`
+	VoteID int32 `json:"vote_id"`
+}
+
+type OutboxAggregate struct {
+	AggregateType string `json:"aggregate_type"`
+	AggregateID   int32  `json:"aggregate_id"`
+	LastPosition  int64  `json:"last_position"`
+}
+
+type OutboxEvent struct {
+	ID            int64              `json:"id"`
+	AggregateType string             `json:"aggregate_type"`
+	AggregateID   int32              `json:"aggregate_id"`
+	Kind          string             `json:"kind"`
+	Payload       []byte             `json:"payload"`
+	CreatedAt     pgtype.Timestamptz `json:"created_at"`
+	Position      int64              `json:"position"`
+}
+
+type OutboxOffset struct {
+	Sink         string             `json:"sink"`
+	LastEventID  int64              `json:"last_event_id"`
+	UpdatedAt    pgtype.Timestamptz `json:"updated_at"`
+	LastPosition int64              `json:"last_position"`
+}
+
+type Ranking struct {
+	ID         int32              `json:"id"`
+	CompanyIds []int32            `json:"company_ids"`
+	Category   *string            `json:"category"`
+	CreatedAt  pgtype.Timestamptz `json:"created_at"`
+}
+
+type RateLimitBucket struct {
+	Key       string             `json:"key"`
+	Tokens    float64            `json:"tokens"`
+	UpdatedAt pgtype.Timestamptz `json:"updated_at"`
+}
+
+type RatingDecay struct {
+	ID                int32              `json:"id"`
+	CompanyID         int32              `json:"company_id"`
+	Rate              float64            `json:"rate"`
+	DeviationIncrease float64            `json:"deviation_increase"`
+	CreatedAt         pgtype.Timestamptz `json:"created_at"`
+}
+
+type RatingHistory struct {
+	ID              int64              `json:"id"`
+	CompanyID       int32              `json:"company_id"`
+	VoteID          *int32             `json:"vote_id"`
+	Rating          float64            `json:"rating"`
+	EloRating       int32              `json:"elo_rating"`
+	RatingDeviation float64            `json:"rating_deviation"`
+	Rank            int32              `json:"rank"`
+	Wins            int32              `json:"wins"`
+	Losses          int32              `json:"losses"`
+	TotalVotes      int32              `json:"total_votes"`
+	CreatedAt       pgtype.Timestamptz `json:"created_at"`
+}
+
+type RatingSnapshot struct {
+	CompanyID       int32       `json:"company_id"`
+	Day             pgtype.Date `json:"day"`
+	Rating          float64     `json:"rating"`
+	EloRating       int32       `json:"elo_rating"`
+	RatingDeviation float64     `json:"rating_deviation"`
+	Rank            int32       `json:"rank"`
+	Wins            int32       `json:"wins"`
+	Losses          int32       `json:"losses"`
+	TotalVotes      int32       `json:"total_votes"`
+}
+
+type Session struct {
+	ID        string             `json:"id"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+	RevokedAt pgtype.Timestamptz `json:"revoked_at"`
+}
+
+type Setting struct {
+	Key       string             `json:"key"`
+	Value     string             `json:"value"`
+	UpdatedAt pgtype.Timestamptz `json:"updated_at"`
+}
+
+type UsedMatchupToken struct {
+	ID        string             `json:"id"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+	UsedAt    pgtype.Timestamptz `json:"used_at"`
 }
 
 type User struct {
 	ID        int32              `json:"id"`
 	Name      string             `json:"name"`
-	Email     string             `json:"email"`
+	Email     *string            `json:"email"`
 	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	Subject   *string            `json:"subject"`
+	AvatarUrl *string            `json:"avatar_url"`
+	UpdatedAt pgtype.Timestamptz `json:"updated_at"`
 }
 
 type Vote struct {
@@ -61,5 +209,63 @@ type Vote struct {
 	LoserID   int32              `json:"loser_id"`
 	SessionID *string            `json:"session_id"`
 	CreatedAt pgtype.Timestamptz `json:"created_at"`
-	UserID    *string            `json:"user_id"`
+	Category  *string            `json:"category"`
+	UserID    *int32             `json:"user_id"`
+	Status    string             `json:"status"`
+	Outcome   string             `json:"outcome"`
+	Strength  string             `json:"strength"`
+	RankingID *int32             `json:"ranking_id"`
+}
+
+type VoteRatingDelta struct {
+	VoteID          int32              `json:"vote_id"`
+	CompanyID       int32              `json:"company_id"`
+	Category        string             `json:"category"`
+	Won             bool               `json:"won"`
+	RatingDelta     float64            `json:"rating_delta"`
+	DeviationDelta  float64            `json:"deviation_delta"`
+	VolatilityDelta float64            `json:"volatility_delta"`
+	CreatedAt       pgtype.Timestamptz `json:"created_at"`
+}
+
+type VoterPairVote struct {
+	Voter       string             `json:"voter"`
+	CompanyLow  int32              `json:"company_low"`
+	CompanyHigh int32              `json:"company_high"`
+	WinnerID    *int32             `json:"winner_id"`
+	VotedAt     pgtype.Timestamptz `json:"voted_at"`
+}
+
+type WebhookCompanyRank struct {
+	CompanyID int32              `json:"company_id"`
+	Rank      int32              `json:"rank"`
+	UpdatedAt pgtype.Timestamptz `json:"updated_at"`
+}
+
+type WebhookDelivery struct {
+	ID             int64              `json:"id"`
+	SubscriptionID int32              `json:"subscription_id"`
+	EventID        string             `json:"event_id"`
+	EventType      string             `json:"event_type"`
+	Payload        []byte             `json:"payload"`
+	Status         string             `json:"status"`
+	Attempts       int32              `json:"attempts"`
+	NextAttemptAt  pgtype.Timestamptz `json:"next_attempt_at"`
+	LastAttemptAt  pgtype.Timestamptz `json:"last_attempt_at"`
+	ResponseStatus *int32             `json:"response_status"`
+	LastError      *string            `json:"last_error"`
+	CreatedAt      pgtype.Timestamptz `json:"created_at"`
+	DeliveredAt    pgtype.Timestamptz `json:"delivered_at"`
+}
+
+type WebhookSubscription struct {
+	ID                      int32              `json:"id"`
+	Url                     string             `json:"url"`
+	Secret                  string             `json:"secret"`
+	PreviousSecret          *string            `json:"previous_secret"`
+	PreviousSecretExpiresAt pgtype.Timestamptz `json:"previous_secret_expires_at"`
+	EventTypes              []string           `json:"event_types"`
+	Description             *string            `json:"description"`
+	CreatedAt               pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt               pgtype.Timestamptz `json:"updated_at"`
 }
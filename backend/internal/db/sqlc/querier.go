@@ -6,39 +6,238 @@ package sqlc
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type Querier interface {
+	// Adds votes to a flag and widens its vote count and time window to match.
+	AddFraudFlagVotes(ctx context.Context, arg AddFraudFlagVotesParams) error
+	// Returns the position of the next event of an aggregate: the id of the
+	// current transaction, or the position of the aggregate's previous event if
+	// that is later. The aggregate stays locked until the transaction ends, so
+	// its events are positioned in the order they commit.
+	ClaimOutboxPosition(ctx context.Context, arg ClaimOutboxPositionParams) (int64, error)
+	// Records the claim and returns the user that owns the session, which is an
+	// earlier claimant if the session has already been claimed.
+	ClaimSession(ctx context.Context, arg ClaimSessionParams) (int32, error)
+	ClaimSessionComments(ctx context.Context, arg ClaimSessionCommentsParams) (int64, error)
+	ClaimSessionRatings(ctx context.Context, arg ClaimSessionRatingsParams) (int64, error)
+	ClaimSessionVotes(ctx context.Context, arg ClaimSessionVotesParams) (int64, error)
+	// Leases due deliveries until lease_until, so no other instance attempts
+	// them meanwhile; a delivery whose attempt is never recorded is retried
+	// once the lease runs out.
+	ClaimWebhookDeliveries(ctx context.Context, arg ClaimWebhookDeliveriesParams) ([]ClaimWebhookDeliveriesRow, error)
 	CompanyExists(ctx context.Context, id int32) (bool, error)
 	CountComments(ctx context.Context) (int64, error)
 	CountCompanies(ctx context.Context) (int64, error)
 	CountCompaniesByCategory(ctx context.Context, category string) (int64, error)
+	CountFraudFlags(ctx context.Context, status *string) (int64, error)
 	CountRatings(ctx context.Context) (int64, error)
+	CountTrendingCompanies(ctx context.Context, arg CountTrendingCompaniesParams) (int64, error)
+	CountUserVotes(ctx context.Context, userID *int32) (int64, error)
 	CountUsersWithVotes(ctx context.Context) (int64, error)
+	CountVoteOutcomes(ctx context.Context) (CountVoteOutcomesRow, error)
+	// Counted votes that rated their matchup. Skips are not part of it, as they
+	// are not part of a company's total_votes.
 	CountVotes(ctx context.Context) (int64, error)
+	CountWebhookDeliveries(ctx context.Context, arg CountWebhookDeliveriesParams) (int64, error)
 	CreateComment(ctx context.Context, arg CreateCommentParams) (CompanyComment, error)
+	CreateOutboxEvent(ctx context.Context, arg CreateOutboxEventParams) error
+	// Records the order a voter put a set of companies in, best first.
+	CreateRanking(ctx context.Context, arg CreateRankingParams) (Ranking, error)
 	CreateRating(ctx context.Context, arg CreateRatingParams) (CompanyRating, error)
+	CreateRatingDecay(ctx context.Context, arg CreateRatingDecayParams) error
+	CreateSession(ctx context.Context, arg CreateSessionParams) (Session, error)
+	// Provisions the user for a token subject. The email is only stored if no
+	// other user has it yet, and an existing user's profile is left untouched.
+	CreateUserFromIdentity(ctx context.Context, arg CreateUserFromIdentityParams) (User, error)
+	// Stamps the vote with the time it is recorded rather than the start of the
+	// transaction, so votes on the same company, which are recorded under its
+	// row lock, are ordered as they were applied.
 	CreateVote(ctx context.Context, arg CreateVoteParams) (CreateVoteRow, error)
+	CreateVoteRatingDelta(ctx context.Context, arg CreateVoteRatingDeltaParams) error
+	// Queues an event for every subscription to its type. Queuing the same
+	// event again is a no-op.
+	CreateWebhookDeliveries(ctx context.Context, arg CreateWebhookDeliveriesParams) (int64, error)
+	CreateWebhookSubscription(ctx context.Context, arg CreateWebhookSubscriptionParams) (WebhookSubscription, error)
+	DecayCompanyRating(ctx context.Context, arg DecayCompanyRatingParams) error
+	DeleteCompanyActivitySince(ctx context.Context, hour pgtype.Timestamptz) error
+	DeleteExpiredMatchupTokens(ctx context.Context) (int64, error)
+	DeleteExpiredSessions(ctx context.Context) (int64, error)
+	// Buckets untouched for a day have refilled under every policy.
+	DeleteIdleRateLimitBuckets(ctx context.Context) (int64, error)
+	// Drops finished deliveries from the log once they are older than the cutoff.
+	DeleteOldWebhookDeliveries(ctx context.Context, createdAt pgtype.Timestamptz) (int64, error)
+	DeletePairVote(ctx context.Context, arg DeletePairVoteParams) error
+	DeletePairVotesBefore(ctx context.Context, votedAt pgtype.Timestamptz) (int64, error)
+	DeleteRatingHistory(ctx context.Context) error
+	DeleteRatingHistorySince(ctx context.Context, createdAt pgtype.Timestamptz) error
+	DeleteRatingSnapshots(ctx context.Context) error
+	DeleteVoteRatingDeltasBefore(ctx context.Context, createdAt pgtype.Timestamptz) (int64, error)
+	DeleteWebhookSubscription(ctx context.Context, id int32) (int64, error)
+	// Starts a sink seen for the first time at the end of the outbox: after
+	// every event positioned below the oldest running transaction.
+	EnsureOutboxOffset(ctx context.Context, sink string) error
+	// Marks the flag's counted votes as fraud. Returns how many were marked and
+	// when the earliest of them was cast.
+	ExcludeFraudFlagVotes(ctx context.Context, flagID int32) (ExcludeFraudFlagVotesRow, error)
+	// Returns the votes among vote_ids that no flag of the kind covers yet.
+	FilterUnflaggedVotes(ctx context.Context, arg FilterUnflaggedVotesParams) ([]int32, error)
+	// Sessions that cast at least min_votes counted votes within one bucket of
+	// bucket_seconds since the given time.
+	FindHighRateSessions(ctx context.Context, arg FindHighRateSessionsParams) ([]FindHighRateSessionsRow, error)
+	// Sessions that picked the same company in at least min_share of the
+	// matchups it appeared in since the given time, over at least min_votes
+	// matchups. vote_ids are the votes the company won.
+	FindOneSidedSessions(ctx context.Context, arg FindOneSidedSessionsParams) ([]FindOneSidedSessionsRow, error)
+	// Companies that won at least min_wins counted votes since window_start and
+	// more than baseline_multiplier times as many as in the baseline period
+	// before it.
+	FindWinBursts(ctx context.Context, arg FindWinBurstsParams) ([]FindWinBurstsRow, error)
 	GetAggregatedRatings(ctx context.Context, companyID int32) ([]GetAggregatedRatingsRow, error)
 	GetCategories(ctx context.Context) ([]GetCategoriesRow, error)
+	// Companies in a category ordered by their category rating, with their
+	// global rank alongside.
+	GetCategoryLeaderboard(ctx context.Context, arg GetCategoryLeaderboardParams) ([]GetCategoryLeaderboardRow, error)
+	GetCommentByID(ctx context.Context, id int32) (CompanyComment, error)
 	GetCompanyByID(ctx context.Context, id int32) (Company, error)
 	GetCompanyBySlug(ctx context.Context, slug string) (Company, error)
+	GetCompanyCategoryStanding(ctx context.Context, arg GetCompanyCategoryStandingParams) (GetCompanyCategoryStandingRow, error)
 	GetCompanyComments(ctx context.Context, companyID int32) ([]CompanyComment, error)
-	GetCompanyEloRating(ctx context.Context, id int32) (int32, error)
 	GetCompanyIDBySlug(ctx context.Context, slug string) (int32, error)
 	GetCompanyRank(ctx context.Context, eloRating int32) (int32, error)
+	GetDailyRatingSnapshots(ctx context.Context, arg GetDailyRatingSnapshotsParams) ([]GetDailyRatingSnapshotsRow, error)
+	GetFraudFlagForUpdate(ctx context.Context, id int32) (FraudFlag, error)
+	// Counted results between two companies, from company_a's side.
+	GetHeadToHeadRecord(ctx context.Context, arg GetHeadToHeadRecordParams) (GetHeadToHeadRecordRow, error)
+	// Counted results between two companies per week, from company_a's side.
+	GetHeadToHeadTrend(ctx context.Context, arg GetHeadToHeadTrendParams) ([]GetHeadToHeadTrendRow, error)
+	// Last recorded state of a company within each hour of the range.
+	GetHourlyRatingHistory(ctx context.Context, arg GetHourlyRatingHistoryParams) ([]GetHourlyRatingHistoryRow, error)
+	GetLatestCompanyActivityHour(ctx context.Context) (pgtype.Timestamptz, error)
 	GetLeaderboard(ctx context.Context, arg GetLeaderboardParams) ([]Company, error)
-	GetLeaderboardByCategory(ctx context.Context, arg GetLeaderboardByCategoryParams) ([]Company, error)
-	GetRandomMatchup(ctx context.Context) ([]Company, error)
-	GetRandomMatchupByCategory(ctx context.Context, category string) ([]Company, error)
+	// Returns the tokens the bucket holds after refilling, without taking any.
+	GetRateLimitTokens(ctx context.Context, arg GetRateLimitTokensParams) (float64, error)
+	GetSession(ctx context.Context, id string) (Session, error)
+	GetSetting(ctx context.Context, key string) (string, error)
+	// Companies with counted votes in [window_start, window_end), by hour,
+	// ordered by rating gained, win rate or number of votes in the window.
+	// Draws count as half a win.
+	GetTrendingLeaderboard(ctx context.Context, arg GetTrendingLeaderboardParams) ([]GetTrendingLeaderboardRow, error)
+	GetUserByID(ctx context.Context, id int32) (User, error)
+	GetUserBySubject(ctx context.Context, subject *string) (User, error)
 	GetUserLeaderboard(ctx context.Context, arg GetUserLeaderboardParams) ([]GetUserLeaderboardRow, error)
+	GetVoteForUpdate(ctx context.Context, id int32) (Vote, error)
+	// Last daily snapshot of a company within each week of the range.
+	GetWeeklyRatingSnapshots(ctx context.Context, arg GetWeeklyRatingSnapshotsParams) ([]GetWeeklyRatingSnapshotsRow, error)
+	InsertRatingHistory(ctx context.Context, arg []InsertRatingHistoryParams) (int64, error)
+	// Candidates within a category, rated by their category rating.
+	ListCategoryMatchupCandidates(ctx context.Context, category string) ([]ListCategoryMatchupCandidatesRow, error)
 	ListCompanies(ctx context.Context) ([]Company, error)
 	ListCompaniesByCategory(ctx context.Context, category string) ([]Company, error)
+	// Lists the companies whose current rank differs from the rank last
+	// announced to webhooks. Companies never announced have previous rank 0.
+	ListCompanyRankChanges(ctx context.Context) ([]ListCompanyRankChangesRow, error)
+	ListCompanyRatingStates(ctx context.Context) ([]ListCompanyRatingStatesRow, error)
+	// Companies without a counted vote since inactive_since that have not
+	// decayed since decayed_before.
+	ListDecayCandidates(ctx context.Context, arg ListDecayCandidatesParams) ([]int32, error)
+	ListFraudFlags(ctx context.Context, arg ListFraudFlagsParams) ([]FraudFlag, error)
+	ListHeadToHeadVotes(ctx context.Context, arg ListHeadToHeadVotesParams) ([]ListHeadToHeadVotesRow, error)
+	ListMatchupCandidates(ctx context.Context) ([]ListMatchupCandidatesRow, error)
+	// The events after the given position and id, in delivery order. Only
+	// positions below the oldest running transaction are read: every event
+	// committed later is positioned at or above it, so none is skipped.
+	ListOutboxEventsAfter(ctx context.Context, arg ListOutboxEventsAfterParams) ([]OutboxEvent, error)
+	ListRatingDecaysForReplay(ctx context.Context) ([]RatingDecay, error)
+	// Opponents a company met most often in counted votes, from its side.
+	ListRivals(ctx context.Context, arg ListRivalsParams) ([]ListRivalsRow, error)
+	// A page of the counted votes without a timestamp after after_id, which
+	// replay after every dated vote.
+	ListUndatedVotesForReplay(ctx context.Context, arg ListUndatedVotesForReplayParams) ([]ListUndatedVotesForReplayRow, error)
+	// Lists the votes whose rating changes are kept for undo.
+	ListVoteRatingDeltaVoteIDs(ctx context.Context) ([]int32, error)
+	ListVoteRatingDeltas(ctx context.Context, voteID int32) ([]VoteRatingDelta, error)
+	// A page of the counted votes in replay order, after the vote at
+	// after_created_at and after_id. Votes without a timestamp are listed by
+	// ListUndatedVotesForReplay.
+	ListVotesForReplay(ctx context.Context, arg ListVotesForReplayParams) ([]ListVotesForReplayRow, error)
+	ListWebhookDeliveries(ctx context.Context, arg ListWebhookDeliveriesParams) ([]WebhookDelivery, error)
+	ListWebhookSubscriptions(ctx context.Context) ([]WebhookSubscription, error)
+	// Same lock ordering as LockCompaniesForUpdate; must be called after it.
+	LockCategoryRatingsForUpdate(ctx context.Context, arg LockCategoryRatingsForUpdateParams) ([]CompanyCategoryRating, error)
+	// Blocks concurrent votes (which take row locks on companies) until the
+	// surrounding transaction ends.
+	LockCompaniesExclusive(ctx context.Context) error
+	// Locks the given companies in ascending id order so that concurrent
+	// transactions touching the same rows always acquire locks in the same order.
+	LockCompaniesForUpdate(ctx context.Context, ids []int32) ([]LockCompaniesForUpdateRow, error)
+	// Blocks other rollups until the surrounding transaction ends.
+	LockCompanyActivity(ctx context.Context) error
+	// Returns nothing while another instance is delivering to the sink.
+	LockOutboxOffset(ctx context.Context, sink string) (LockOutboxOffsetRow, error)
+	// Blocks other decay runs until the surrounding transaction ends, so two
+	// runs do not both decay the same company.
+	LockRatingDecays(ctx context.Context) error
+	// Sends payload to every connection listening on channel once the
+	// surrounding transaction commits.
+	NotifyEvent(ctx context.Context, arg NotifyEventParams) error
+	// Records that a matchup between the given companies was skipped. Skips
+	// change no rating and are not part of total_votes.
+	RecordCompanySkips(ctx context.Context, ids []int32) error
+	// Records a vote on an unordered pair unless the voter's last vote on the
+	// pair was cast after cooldown_start. Returns 0 rows affected for repeats.
+	RecordPairVote(ctx context.Context, arg RecordPairVoteParams) (int64, error)
+	// Appends the current state and rank of the given companies to their history.
+	RecordRatingHistory(ctx context.Context, arg RecordRatingHistoryParams) error
+	RecordWebhookAttempt(ctx context.Context, arg RecordWebhookAttemptParams) error
+	ResetCategoryRatings(ctx context.Context) error
+	RevertCategoryRating(ctx context.Context, arg RevertCategoryRatingParams) error
+	// Subtracts a vote's rating delta from the company and takes the vote off
+	// its record.
+	RevertCompanyRating(ctx context.Context, arg RevertCompanyRatingParams) error
+	RevertCompanySkips(ctx context.Context, ids []int32) error
+	ReviewFraudFlag(ctx context.Context, arg ReviewFraudFlagParams) (FraudFlag, error)
+	// Revokes every session that cast one of the flag's votes.
+	RevokeFraudFlagSessions(ctx context.Context, flagID int32) (int64, error)
+	RevokeSession(ctx context.Context, id string) (int64, error)
+	// Aggregates counted votes and rating history from since, which must be on
+	// the hour, into hourly buckets. The buckets from since on must have been
+	// deleted first. A rating change is measured from the company's previous
+	// history row, which may lie before since.
+	RollUpCompanyActivity(ctx context.Context, since pgtype.Timestamptz) error
+	// Replaces the secret, keeping the old one valid until previous_secret_expires_at.
+	RotateWebhookSecret(ctx context.Context, arg RotateWebhookSecretParams) (WebhookSubscription, error)
 	SearchCompanies(ctx context.Context, name string) ([]Company, error)
 	SearchCompaniesByCategory(ctx context.Context, arg SearchCompaniesByCategoryParams) ([]Company, error)
+	SetCategoryRatingState(ctx context.Context, arg SetCategoryRatingStateParams) error
+	SetCompanyRatingState(ctx context.Context, arg SetCompanyRatingStateParams) error
+	SetOutboxOffset(ctx context.Context, arg SetOutboxOffsetParams) error
+	SetSetting(ctx context.Context, arg SetSettingParams) error
+	// Replaces what a vote changed, as a recompute replayed it.
+	SetVoteRatingDelta(ctx context.Context, arg SetVoteRatingDeltaParams) error
+	SetVoteStatus(ctx context.Context, arg SetVoteStatusParams) error
+	SetWebhookCompanyRanks(ctx context.Context, arg SetWebhookCompanyRanksParams) error
+	// Stores each company's last recorded state on or before the given day,
+	// ranked against every other company at that point in time.
+	SnapshotRatingsForDay(ctx context.Context, day pgtype.Date) error
+	// Refills the bucket for the time since it was last updated and takes one
+	// token. Returns no rows when the bucket holds less than one token.
+	TakeRateLimitToken(ctx context.Context, arg TakeRateLimitTokenParams) (float64, error)
+	UpdateCategoryRatingAfterDraw(ctx context.Context, arg UpdateCategoryRatingAfterDrawParams) error
+	UpdateCategoryRatingAfterLoss(ctx context.Context, arg UpdateCategoryRatingAfterLossParams) error
+	UpdateCategoryRatingAfterWin(ctx context.Context, arg UpdateCategoryRatingAfterWinParams) error
+	UpdateCompanyAfterDraw(ctx context.Context, arg UpdateCompanyAfterDrawParams) error
 	UpdateCompanyAfterLoss(ctx context.Context, arg UpdateCompanyAfterLossParams) error
 	UpdateCompanyAfterWin(ctx context.Context, arg UpdateCompanyAfterWinParams) error
+	UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) (User, error)
+	// Returns the open flag of the kind for the subject, creating it if needed.
+	UpsertOpenFraudFlag(ctx context.Context, arg UpsertOpenFraudFlagParams) (int32, error)
 	UpvoteComment(ctx context.Context, id int32) (CompanyComment, error)
+	// Returns 0 rows affected if the token has already been used.
+	UseMatchupToken(ctx context.Context, arg UseMatchupTokenParams) (int64, error)
+	WebhookSubscriptionExists(ctx context.Context, id int32) (bool, error)
 }
 
 var _ Querier = (*Queries)(nil)
@@ -1,28 +1,32 @@
 -- name: GetCompanyBySlug :one
 SELECT id, name, slug, logo_url, description, website, category, tags,
        founded_year, hq_location, employee_range, funding_stage,
-       elo_rating, total_votes, wins, losses, created_at, updated_at
+       elo_rating, total_votes, wins, losses, created_at, updated_at,
+       rating, rating_deviation, rating_volatility, draws, skips
 FROM companies
 WHERE slug = $1;
 
 -- name: GetCompanyByID :one
 SELECT id, name, slug, logo_url, description, website, category, tags,
        founded_year, hq_location, employee_range, funding_stage,
-       elo_rating, total_votes, wins, losses, created_at, updated_at
+       elo_rating, total_votes, wins, losses, created_at, updated_at,
+       rating, rating_deviation, rating_volatility, draws, skips
 FROM companies
 WHERE id = $1;
 
 -- name: ListCompanies :many
 SELECT id, name, slug, logo_url, description, website, category, tags,
        founded_year, hq_location, employee_range, funding_stage,
-       elo_rating, total_votes, wins, losses, created_at, updated_at
+       elo_rating, total_votes, wins, losses, created_at, updated_at,
+       rating, rating_deviation, rating_volatility, draws, skips
 FROM companies
 ORDER BY elo_rating DESC, total_votes DESC;
 
 -- name: ListCompaniesByCategory :many
 SELECT id, name, slug, logo_url, description, website, category, tags,
        founded_year, hq_location, employee_range, funding_stage,
-       elo_rating, total_votes, wins, losses, created_at, updated_at
+       elo_rating, total_votes, wins, losses, created_at, updated_at,
+       rating, rating_deviation, rating_volatility, draws, skips
 FROM companies
 WHERE category = $1
 ORDER BY elo_rating DESC, total_votes DESC;
@@ -30,7 +34,8 @@ ORDER BY elo_rating DESC, total_votes DESC;
 -- name: SearchCompanies :many
 SELECT id, name, slug, logo_url, description, website, category, tags,
        founded_year, hq_location, employee_range, funding_stage,
-       elo_rating, total_votes, wins, losses, created_at, updated_at
+       elo_rating, total_votes, wins, losses, created_at, updated_at,
+       rating, rating_deviation, rating_volatility, draws, skips
 FROM companies
 WHERE LOWER(name) LIKE $1 OR LOWER(description) LIKE $1
 ORDER BY elo_rating DESC, total_votes DESC;
@@ -38,62 +43,88 @@ ORDER BY elo_rating DESC, total_votes DESC;
 -- name: SearchCompaniesByCategory :many
 SELECT id, name, slug, logo_url, description, website, category, tags,
        founded_year, hq_location, employee_range, funding_stage,
-       elo_rating, total_votes, wins, losses, created_at, updated_at
+       elo_rating, total_votes, wins, losses, created_at, updated_at,
+       rating, rating_deviation, rating_volatility, draws, skips
 FROM companies
 WHERE category = $1 AND (LOWER(name) LIKE $2 OR LOWER(description) LIKE $2)
 ORDER BY elo_rating DESC, total_votes DESC;
 
--- name: GetRandomMatchup :many
-SELECT id, name, slug, logo_url, description, website, category, tags,
-       founded_year, hq_location, employee_range, funding_stage,
-       elo_rating, total_votes, wins, losses, created_at, updated_at
-FROM companies
-ORDER BY RANDOM()
-LIMIT 2;
+-- name: ListMatchupCandidates :many
+SELECT id, rating, rating_deviation, rating_volatility, total_votes
+FROM companies;
 
--- name: GetRandomMatchupByCategory :many
-SELECT id, name, slug, logo_url, description, website, category, tags,
-       founded_year, hq_location, employee_range, funding_stage,
-       elo_rating, total_votes, wins, losses, created_at, updated_at
-FROM companies
-WHERE category = $1
-ORDER BY RANDOM()
-LIMIT 2;
+-- name: ListCategoryMatchupCandidates :many
+-- Candidates within a category, rated by their category rating.
+SELECT ccr.company_id, ccr.rating, ccr.rating_deviation, ccr.rating_volatility, ccr.total_votes
+FROM company_category_ratings ccr
+JOIN companies c ON c.id = ccr.company_id AND c.category = ccr.category
+WHERE ccr.category = $1;
 
--- name: GetCompanyEloRating :one
-SELECT elo_rating FROM companies WHERE id = $1;
+-- name: LockCompaniesForUpdate :many
+-- Locks the given companies in ascending id order so that concurrent
+-- transactions touching the same rows always acquire locks in the same order.
+SELECT id, category, rating, rating_deviation, rating_volatility
+FROM companies
+WHERE id = ANY(@ids::int[])
+ORDER BY id
+FOR UPDATE;
 
 -- name: UpdateCompanyAfterWin :exec
 UPDATE companies 
-SET elo_rating = $2, total_votes = total_votes + 1, wins = wins + 1, updated_at = NOW()
-WHERE id = $1;
+SET rating = @rating::float8, elo_rating = ROUND(@rating::float8)::int,
+    rating_deviation = @rating_deviation, rating_volatility = @rating_volatility,
+    total_votes = total_votes + 1, wins = wins + 1, updated_at = NOW()
+WHERE id = @id;
 
 -- name: UpdateCompanyAfterLoss :exec
 UPDATE companies 
-SET elo_rating = $2, total_votes = total_votes + 1, losses = losses + 1, updated_at = NOW()
-WHERE id = $1;
+SET rating = @rating::float8, elo_rating = ROUND(@rating::float8)::int,
+    rating_deviation = @rating_deviation, rating_volatility = @rating_volatility,
+    total_votes = total_votes + 1, losses = losses + 1, updated_at = NOW()
+WHERE id = @id;
+
+-- name: UpdateCompanyAfterDraw :exec
+UPDATE companies
+SET rating = @rating::float8, elo_rating = ROUND(@rating::float8)::int,
+    rating_deviation = @rating_deviation, rating_volatility = @rating_volatility,
+    total_votes = total_votes + 1, draws = draws + 1, updated_at = NOW()
+WHERE id = @id;
+
+-- name: RecordCompanySkips :exec
+-- Records that a matchup between the given companies was skipped. Skips
+-- change no rating and are not part of total_votes.
+UPDATE companies SET skips = skips + 1, updated_at = NOW()
+WHERE id = ANY(@ids::int[]);
 
 -- name: CreateVote :one
-INSERT INTO votes (winner_id, loser_id, session_id, user_id)
-VALUES ($1, $2, $3, $4)
-RETURNING id, winner_id, loser_id, session_id, user_id, created_at;
+-- Stamps the vote with the time it is recorded rather than the start of the
+-- transaction, so votes on the same company, which are recorded under its
+-- row lock, are ordered as they were applied.
+INSERT INTO votes (winner_id, loser_id, session_id, user_id, category, status, outcome, strength,
+                   ranking_id, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, clock_timestamp())
+RETURNING id, winner_id, loser_id, session_id, user_id, category, status, outcome, strength,
+          ranking_id, created_at;
 
 -- name: GetLeaderboard :many
 SELECT id, name, slug, logo_url, description, website, category, tags,
        founded_year, hq_location, employee_range, funding_stage,
-       elo_rating, total_votes, wins, losses, created_at, updated_at
+       elo_rating, total_votes, wins, losses, created_at, updated_at,
+       rating, rating_deviation, rating_volatility, draws, skips
 FROM companies
 ORDER BY elo_rating DESC, total_votes DESC
 LIMIT $1 OFFSET $2;
 
--- name: GetLeaderboardByCategory :many
-SELECT id, name, slug, logo_url, description, website, category, tags,
-       founded_year, hq_location, employee_range, funding_stage,
-       elo_rating, total_votes, wins, losses, created_at, updated_at
-FROM companies
-WHERE category = $1
-ORDER BY elo_rating DESC, total_votes DESC
-LIMIT $2 OFFSET $3;
+-- name: GetCategoryLeaderboard :many
+-- Companies in a category ordered by their category rating, with their
+-- global rank alongside.
+SELECT sqlc.embed(c), sqlc.embed(ccr),
+       (SELECT COUNT(*) + 1 FROM companies r WHERE r.elo_rating > c.elo_rating)::int AS global_rank
+FROM companies c
+JOIN company_category_ratings ccr ON ccr.company_id = c.id AND ccr.category = c.category
+WHERE c.category = @category
+ORDER BY ccr.elo_rating DESC, ccr.total_votes DESC
+LIMIT @page_limit OFFSET @page_offset;
 
 -- name: CountCompanies :one
 SELECT COUNT(*) FROM companies;
@@ -105,9 +136,9 @@ SELECT COUNT(*) FROM companies WHERE category = $1;
 SELECT COUNT(*) + 1 FROM companies WHERE elo_rating > $1;
 
 -- name: CreateRating :one
-INSERT INTO company_ratings (company_id, criterion, score, session_id)
-VALUES ($1, $2, $3, $4)
-RETURNING id, company_id, criterion, score, session_id, created_at;
+INSERT INTO company_ratings (company_id, criterion, score, session_id, user_id)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, company_id, criterion, score, session_id, created_at, user_id;
 
 -- name: GetAggregatedRatings :many
 SELECT criterion, AVG(score)::float as average_score, COUNT(*) as total_ratings
@@ -116,12 +147,12 @@ WHERE company_id = $1
 GROUP BY criterion;
 
 -- name: CreateComment :one
-INSERT INTO company_comments (company_id, content, is_current_employee, session_id)
-VALUES ($1, $2, $3, $4)
-RETURNING id, company_id, content, is_current_employee, session_id, upvotes, created_at;
+INSERT INTO company_comments (company_id, content, is_current_employee, session_id, user_id)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, company_id, content, is_current_employee, session_id, upvotes, created_at, user_id;
 
 -- name: GetCompanyComments :many
-SELECT id, company_id, content, is_current_employee, session_id, upvotes, created_at
+SELECT id, company_id, content, is_current_employee, session_id, upvotes, created_at, user_id
 FROM company_comments
 WHERE company_id = $1
 ORDER BY upvotes DESC, created_at DESC
@@ -131,7 +162,7 @@ LIMIT 100;
 UPDATE company_comments
 SET upvotes = upvotes + 1
 WHERE id = $1
-RETURNING id, company_id, content, is_current_employee, session_id, upvotes, created_at;
+RETURNING id, company_id, content, is_current_employee, session_id, upvotes, created_at, user_id;
 
 -- name: GetCategories :many
 SELECT category, COUNT(*) as count
@@ -140,7 +171,15 @@ GROUP BY category
 ORDER BY count DESC;
 
 -- name: CountVotes :one
-SELECT COUNT(*) FROM votes;
+-- Counted votes that rated their matchup. Skips are not part of it, as they
+-- are not part of a company's total_votes.
+SELECT COUNT(*) FROM votes WHERE status = 'counted' AND outcome <> 'skip';
+
+-- name: CountVoteOutcomes :one
+SELECT COUNT(*) FILTER (WHERE outcome = 'draw') AS draws,
+       COUNT(*) FILTER (WHERE outcome = 'skip') AS skips
+FROM votes
+WHERE status = 'counted';
 
 -- name: CountRatings :one
 SELECT COUNT(*) FROM company_ratings;
@@ -155,12 +194,842 @@ SELECT EXISTS(SELECT 1 FROM companies WHERE id = $1);
 SELECT id FROM companies WHERE slug = $1;
 
 -- name: GetUserLeaderboard :many
-SELECT user_id, COUNT(*) as total_votes
-FROM votes
-WHERE user_id IS NOT NULL AND user_id != ''
-GROUP BY user_id
-ORDER BY total_votes DESC
+SELECT u.id, u.name, u.avatar_url, COUNT(*) as total_votes
+FROM votes v
+JOIN users u ON u.id = v.user_id
+WHERE v.status = 'counted'
+GROUP BY u.id
+ORDER BY total_votes DESC, u.id
 LIMIT $1 OFFSET $2;
 
 -- name: CountUsersWithVotes :one
-SELECT COUNT(DISTINCT user_id) FROM votes WHERE user_id IS NOT NULL AND user_id != '';
+SELECT COUNT(DISTINCT user_id) FROM votes WHERE user_id IS NOT NULL AND status = 'counted';
+
+-- name: LockCompaniesExclusive :exec
+-- Blocks concurrent votes (which take row locks on companies) until the
+-- surrounding transaction ends.
+LOCK TABLE companies IN EXCLUSIVE MODE;
+
+-- name: ListCompanyRatingStates :many
+SELECT id, name, slug, rating, rating_deviation, rating_volatility,
+       wins, losses, draws, skips, total_votes, created_at
+FROM companies
+ORDER BY id;
+
+-- name: ListVotesForReplay :many
+-- A page of the counted votes in replay order, after the vote at
+-- after_created_at and after_id. Votes without a timestamp are listed by
+-- ListUndatedVotesForReplay.
+SELECT id, winner_id, loser_id, outcome, strength, category, created_at
+FROM votes
+WHERE status = 'counted' AND created_at >= @after_created_at
+  AND (created_at, id) > (@after_created_at, @after_id::int)
+ORDER BY created_at, id
+LIMIT @page_limit;
+
+-- name: ListUndatedVotesForReplay :many
+-- A page of the counted votes without a timestamp after after_id, which
+-- replay after every dated vote.
+SELECT id, winner_id, loser_id, outcome, strength, category, created_at
+FROM votes
+WHERE status = 'counted' AND created_at IS NULL AND id > @after_id::int
+ORDER BY id
+LIMIT @page_limit;
+
+-- name: SetCompanyRatingState :exec
+UPDATE companies
+SET rating = @rating::float8, elo_rating = ROUND(@rating::float8)::int,
+    rating_deviation = @rating_deviation, rating_volatility = @rating_volatility,
+    wins = @wins, losses = @losses, draws = @draws, skips = @skips,
+    total_votes = @total_votes, updated_at = NOW()
+WHERE id = @id;
+
+-- name: RecordRatingHistory :exec
+-- Appends the current state and rank of the given companies to their history.
+INSERT INTO rating_history (company_id, vote_id, rating, elo_rating, rating_deviation,
+                            rank, wins, losses, total_votes)
+SELECT c.id, @vote_id, c.rating, c.elo_rating, c.rating_deviation,
+       (SELECT COUNT(*) + 1 FROM companies r WHERE r.elo_rating > c.elo_rating),
+       c.wins, c.losses, c.total_votes
+FROM companies c
+WHERE c.id = ANY(@company_ids::int[]);
+
+-- name: InsertRatingHistory :copyfrom
+INSERT INTO rating_history (company_id, vote_id, rating, elo_rating, rating_deviation,
+                            rank, wins, losses, total_votes, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10);
+
+-- name: DeleteRatingHistory :exec
+DELETE FROM rating_history;
+
+-- name: SnapshotRatingsForDay :exec
+-- Stores each company's last recorded state on or before the given day,
+-- ranked against every other company at that point in time.
+INSERT INTO rating_snapshots (company_id, day, rating, elo_rating, rating_deviation,
+                              rank, wins, losses, total_votes)
+SELECT h.company_id, sqlc.arg(day)::date, h.rating, h.elo_rating, h.rating_deviation,
+       RANK() OVER (ORDER BY h.elo_rating DESC),
+       h.wins, h.losses, h.total_votes
+FROM companies c
+CROSS JOIN LATERAL (
+    SELECT rh.company_id, rh.rating, rh.elo_rating, rh.rating_deviation, rh.wins, rh.losses, rh.total_votes
+    FROM rating_history rh
+    WHERE rh.company_id = c.id AND rh.created_at < sqlc.arg(day)::date + 1
+    ORDER BY rh.created_at DESC, rh.id DESC
+    LIMIT 1
+) h
+ON CONFLICT (company_id, day) DO UPDATE
+SET rating = EXCLUDED.rating, elo_rating = EXCLUDED.elo_rating,
+    rating_deviation = EXCLUDED.rating_deviation, rank = EXCLUDED.rank,
+    wins = EXCLUDED.wins, losses = EXCLUDED.losses, total_votes = EXCLUDED.total_votes;
+
+-- name: DeleteRatingSnapshots :exec
+DELETE FROM rating_snapshots;
+
+-- name: GetHourlyRatingHistory :many
+-- Last recorded state of a company within each hour of the range.
+SELECT DISTINCT ON (bucket)
+       date_trunc('hour', created_at)::timestamptz AS bucket,
+       rating, elo_rating, rating_deviation, rank, wins, losses, total_votes
+FROM rating_history
+WHERE company_id = @company_id AND created_at >= @from_time AND created_at < @to_time
+ORDER BY bucket, created_at DESC, id DESC;
+
+-- name: GetDailyRatingSnapshots :many
+SELECT day, rating, elo_rating, rating_deviation, rank, wins, losses, total_votes
+FROM rating_snapshots
+WHERE company_id = @company_id AND day >= @from_day::date AND day <= @to_day::date
+ORDER BY day;
+
+-- name: GetWeeklyRatingSnapshots :many
+-- Last daily snapshot of a company within each week of the range.
+SELECT DISTINCT ON (week)
+       date_trunc('week', day)::date AS week,
+       rating, elo_rating, rating_deviation, rank, wins, losses, total_votes
+FROM rating_snapshots
+WHERE company_id = @company_id AND day >= @from_day::date AND day <= @to_day::date
+ORDER BY week, day DESC;
+
+-- name: LockCategoryRatingsForUpdate :many
+-- Same lock ordering as LockCompaniesForUpdate; must be called after it.
+SELECT company_id, category, rating, elo_rating, rating_deviation, rating_volatility,
+       total_votes, wins, losses, updated_at
+FROM company_category_ratings
+WHERE category = @category AND company_id = ANY(@company_ids::int[])
+ORDER BY company_id
+FOR UPDATE;
+
+-- name: UpdateCategoryRatingAfterWin :exec
+UPDATE company_category_ratings
+SET rating = @rating::float8, elo_rating = ROUND(@rating::float8)::int,
+    rating_deviation = @rating_deviation, rating_volatility = @rating_volatility,
+    total_votes = total_votes + 1, wins = wins + 1, updated_at = NOW()
+WHERE company_id = @company_id AND category = @category;
+
+-- name: UpdateCategoryRatingAfterLoss :exec
+UPDATE company_category_ratings
+SET rating = @rating::float8, elo_rating = ROUND(@rating::float8)::int,
+    rating_deviation = @rating_deviation, rating_volatility = @rating_volatility,
+    total_votes = total_votes + 1, losses = losses + 1, updated_at = NOW()
+WHERE company_id = @company_id AND category = @category;
+
+-- name: UpdateCategoryRatingAfterDraw :exec
+UPDATE company_category_ratings
+SET rating = @rating::float8, elo_rating = ROUND(@rating::float8)::int,
+    rating_deviation = @rating_deviation, rating_volatility = @rating_volatility,
+    total_votes = total_votes + 1, updated_at = NOW()
+WHERE company_id = @company_id AND category = @category;
+
+-- name: GetCompanyCategoryStanding :one
+SELECT sqlc.embed(ccr),
+       (SELECT COUNT(*) + 1 FROM company_category_ratings r
+        WHERE r.category = ccr.category AND r.elo_rating > ccr.elo_rating)::int AS category_rank
+FROM company_category_ratings ccr
+WHERE ccr.company_id = @company_id AND ccr.category = @category;
+
+-- name: ResetCategoryRatings :exec
+UPDATE company_category_ratings
+SET rating = 1500, elo_rating = 1500, rating_deviation = 350, rating_volatility = 0.06,
+    total_votes = 0, wins = 0, losses = 0, updated_at = NOW();
+
+-- name: SetCategoryRatingState :exec
+INSERT INTO company_category_ratings (company_id, category, rating, elo_rating,
+                                      rating_deviation, rating_volatility,
+                                      wins, losses, total_votes)
+VALUES (@company_id, @category, @rating::float8, ROUND(@rating::float8)::int,
+        @rating_deviation, @rating_volatility, @wins, @losses, @total_votes)
+ON CONFLICT (company_id, category) DO UPDATE
+SET rating = EXCLUDED.rating, elo_rating = EXCLUDED.elo_rating,
+    rating_deviation = EXCLUDED.rating_deviation, rating_volatility = EXCLUDED.rating_volatility,
+    wins = EXCLUDED.wins, losses = EXCLUDED.losses, total_votes = EXCLUDED.total_votes,
+    updated_at = NOW();
+
+-- name: GetSetting :one
+SELECT value FROM settings WHERE key = $1;
+
+-- name: SetSetting :exec
+INSERT INTO settings (key, value)
+VALUES ($1, $2)
+ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updated_at = NOW();
+
+-- name: UseMatchupToken :execrows
+-- Returns 0 rows affected if the token has already been used.
+INSERT INTO used_matchup_tokens (id, expires_at)
+VALUES ($1, $2)
+ON CONFLICT (id) DO NOTHING;
+
+-- name: DeleteExpiredMatchupTokens :execrows
+DELETE FROM used_matchup_tokens WHERE expires_at < NOW();
+
+-- name: GetUserBySubject :one
+SELECT id, name, email, created_at, subject, avatar_url, updated_at
+FROM users
+WHERE subject = $1;
+
+-- name: GetUserByID :one
+SELECT id, name, email, created_at, subject, avatar_url, updated_at
+FROM users
+WHERE id = $1;
+
+-- name: CreateUserFromIdentity :one
+-- Provisions the user for a token subject. The email is only stored if no
+-- other user has it yet, and an existing user's profile is left untouched.
+INSERT INTO users (subject, name, email, avatar_url)
+VALUES (@subject::text, @name,
+        CASE WHEN NOT EXISTS (SELECT 1 FROM users u WHERE u.email = sqlc.narg(email)) THEN sqlc.narg(email) END,
+        sqlc.narg(avatar_url))
+ON CONFLICT (subject) DO UPDATE SET subject = EXCLUDED.subject
+RETURNING id, name, email, created_at, subject, avatar_url, updated_at;
+
+-- name: UpdateUserProfile :one
+UPDATE users
+SET name = $1, avatar_url = $2, updated_at = NOW()
+WHERE id = $3
+RETURNING id, name, email, created_at, subject, avatar_url, updated_at;
+
+-- name: CountUserVotes :one
+SELECT COUNT(*) FROM votes WHERE user_id = $1 AND status = 'counted';
+
+-- name: ClaimSession :one
+-- Records the claim and returns the user that owns the session, which is an
+-- earlier claimant if the session has already been claimed.
+INSERT INTO claimed_sessions (session_id, user_id)
+VALUES ($1, $2)
+ON CONFLICT (session_id) DO UPDATE SET session_id = EXCLUDED.session_id
+RETURNING user_id;
+
+-- name: ClaimSessionVotes :execrows
+UPDATE votes SET user_id = @user_id
+WHERE session_id = @session_id AND user_id IS NULL;
+
+-- name: ClaimSessionRatings :execrows
+UPDATE company_ratings SET user_id = @user_id
+WHERE session_id = @session_id AND user_id IS NULL;
+
+-- name: ClaimSessionComments :execrows
+UPDATE company_comments SET user_id = @user_id
+WHERE session_id = @session_id AND user_id IS NULL;
+
+-- name: CreateSession :one
+INSERT INTO sessions (id, expires_at)
+VALUES ($1, $2)
+RETURNING id, created_at, expires_at, revoked_at;
+
+-- name: GetSession :one
+SELECT id, created_at, expires_at, revoked_at
+FROM sessions
+WHERE id = $1;
+
+-- name: RevokeSession :execrows
+UPDATE sessions SET revoked_at = NOW()
+WHERE id = $1 AND revoked_at IS NULL;
+
+-- name: DeleteExpiredSessions :execrows
+DELETE FROM sessions WHERE expires_at < NOW();
+
+-- name: TakeRateLimitToken :one
+-- Refills the bucket for the time since it was last updated and takes one
+-- token. Returns no rows when the bucket holds less than one token.
+INSERT INTO rate_limit_buckets AS b (key, tokens, updated_at)
+VALUES (@key, sqlc.arg(burst)::double precision - 1, NOW())
+ON CONFLICT (key) DO UPDATE
+SET tokens = LEAST(@burst::double precision, b.tokens + EXTRACT(EPOCH FROM NOW() - b.updated_at)::double precision * @rate::double precision) - 1,
+    updated_at = NOW()
+WHERE LEAST(@burst::double precision, b.tokens + EXTRACT(EPOCH FROM NOW() - b.updated_at)::double precision * @rate::double precision) >= 1
+RETURNING tokens;
+
+-- name: GetRateLimitTokens :one
+-- Returns the tokens the bucket holds after refilling, without taking any.
+SELECT LEAST(@burst::double precision, tokens + EXTRACT(EPOCH FROM NOW() - updated_at)::double precision * @rate::double precision)::double precision AS tokens
+FROM rate_limit_buckets
+WHERE key = @key;
+
+-- name: DeleteIdleRateLimitBuckets :execrows
+-- Buckets untouched for a day have refilled under every policy.
+DELETE FROM rate_limit_buckets WHERE updated_at < NOW() - INTERVAL '1 day';
+
+-- name: RecordPairVote :execrows
+-- Records a vote on an unordered pair unless the voter's last vote on the
+-- pair was cast after cooldown_start. Returns 0 rows affected for repeats.
+INSERT INTO voter_pair_votes AS p (voter, company_low, company_high, winner_id, voted_at)
+VALUES (@voter, @company_low, @company_high, @winner_id, @voted_at)
+ON CONFLICT (voter, company_low, company_high) DO UPDATE
+SET winner_id = EXCLUDED.winner_id, voted_at = EXCLUDED.voted_at
+WHERE p.voted_at <= @cooldown_start;
+
+-- name: DeletePairVotesBefore :execrows
+DELETE FROM voter_pair_votes WHERE voted_at < $1;
+
+-- name: DeleteRatingHistorySince :exec
+DELETE FROM rating_history WHERE created_at >= $1;
+
+-- name: FindWinBursts :many
+-- Companies that won at least min_wins counted votes since window_start and
+-- more than baseline_multiplier times as many as in the baseline period
+-- before it.
+WITH recent AS (
+    SELECT v.winner_id, COUNT(*) AS wins, array_agg(v.id ORDER BY v.id)::int[] AS vote_ids
+    FROM votes v
+    WHERE v.status = 'counted' AND v.outcome = 'win' AND v.created_at >= @window_start
+    GROUP BY v.winner_id
+), baseline AS (
+    SELECT v.winner_id, COUNT(*) AS wins
+    FROM votes v
+    WHERE v.status = 'counted' AND v.outcome = 'win' AND v.created_at >= @baseline_start AND v.created_at < @window_start
+    GROUP BY v.winner_id
+)
+SELECT r.winner_id, r.wins, COALESCE(b.wins, 0)::bigint AS baseline_wins, r.vote_ids
+FROM recent r
+LEFT JOIN baseline b ON b.winner_id = r.winner_id
+WHERE r.wins >= @min_wins::bigint
+  AND r.wins > sqlc.arg(baseline_multiplier)::float8 * COALESCE(b.wins, 0);
+
+-- name: FindOneSidedSessions :many
+-- Sessions that picked the same company in at least min_share of the
+-- matchups it appeared in since the given time, over at least min_votes
+-- matchups. vote_ids are the votes the company won.
+SELECT v.session_id::text AS session_id, p.company_id::int AS company_id,
+       COUNT(*) AS appearances,
+       COUNT(*) FILTER (WHERE v.winner_id = p.company_id) AS wins,
+       (array_agg(v.id ORDER BY v.id) FILTER (WHERE v.winner_id = p.company_id))::int[] AS vote_ids
+FROM votes v
+CROSS JOIN LATERAL (VALUES (v.winner_id), (v.loser_id)) AS p(company_id)
+WHERE v.status = 'counted' AND v.outcome = 'win' AND v.session_id IS NOT NULL AND v.created_at >= @since
+GROUP BY v.session_id, p.company_id
+HAVING COUNT(*) >= @min_votes::bigint
+   AND COUNT(*) FILTER (WHERE v.winner_id = p.company_id) >= sqlc.arg(min_share)::float8 * COUNT(*);
+
+-- name: FindHighRateSessions :many
+-- Sessions that cast at least min_votes counted votes within one bucket of
+-- bucket_seconds since the given time.
+SELECT session_id::text AS session_id,
+       to_timestamp(floor(EXTRACT(EPOCH FROM created_at) / @bucket_seconds::float8) * @bucket_seconds::float8)::timestamptz AS bucket_start,
+       COUNT(*) AS votes,
+       array_agg(id ORDER BY id)::int[] AS vote_ids
+FROM votes
+WHERE status = 'counted' AND session_id IS NOT NULL AND created_at >= @since
+GROUP BY 1, 2
+HAVING COUNT(*) >= @min_votes::bigint;
+
+-- name: FilterUnflaggedVotes :many
+-- Returns the votes among vote_ids that no flag of the kind covers yet.
+SELECT t.vote_id::int AS vote_id
+FROM unnest(@vote_ids::int[]) AS t(vote_id)
+WHERE NOT EXISTS (
+    SELECT 1 FROM fraud_flag_votes fv
+    JOIN fraud_flags f ON f.id = fv.flag_id
+    WHERE fv.vote_id = t.vote_id AND f.kind = @kind
+)
+ORDER BY t.vote_id;
+
+-- name: UpsertOpenFraudFlag :one
+-- Returns the open flag of the kind for the subject, creating it if needed.
+INSERT INTO fraud_flags (kind, session_id, company_id, details)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (kind, COALESCE(session_id, ''), COALESCE(company_id, 0)) WHERE status = 'open'
+DO UPDATE SET details = EXCLUDED.details, updated_at = NOW()
+RETURNING id;
+
+-- name: AddFraudFlagVotes :exec
+-- Adds votes to a flag and widens its vote count and time window to match.
+WITH added AS (
+    INSERT INTO fraud_flag_votes (flag_id, vote_id)
+    SELECT @flag_id::int, unnest(@vote_ids::int[])
+    ON CONFLICT DO NOTHING
+    RETURNING vote_id
+), added_votes AS (
+    SELECT COUNT(*) AS n, MIN(v.created_at) AS first_at, MAX(v.created_at) AS last_at
+    FROM added a
+    JOIN votes v ON v.id = a.vote_id
+)
+UPDATE fraud_flags f
+SET vote_count = f.vote_count + av.n,
+    window_start = LEAST(f.window_start, av.first_at),
+    window_end = GREATEST(f.window_end, av.last_at),
+    updated_at = NOW()
+FROM added_votes av
+WHERE f.id = @flag_id::int;
+
+-- name: ListFraudFlags :many
+SELECT id, kind, session_id, company_id, details, vote_count, window_start, window_end,
+       status, review_note, created_at, updated_at, reviewed_at
+FROM fraud_flags
+WHERE sqlc.narg(status)::text IS NULL OR status = sqlc.narg(status)
+ORDER BY created_at DESC, id DESC
+LIMIT @page_limit OFFSET @page_offset;
+
+-- name: CountFraudFlags :one
+SELECT COUNT(*) FROM fraud_flags
+WHERE sqlc.narg(status)::text IS NULL OR status = sqlc.narg(status);
+
+-- name: GetFraudFlagForUpdate :one
+SELECT id, kind, session_id, company_id, details, vote_count, window_start, window_end,
+       status, review_note, created_at, updated_at, reviewed_at
+FROM fraud_flags
+WHERE id = $1
+FOR UPDATE;
+
+-- name: ReviewFraudFlag :one
+UPDATE fraud_flags
+SET status = $2, review_note = $3, reviewed_at = NOW(), updated_at = NOW()
+WHERE id = $1
+RETURNING id, kind, session_id, company_id, details, vote_count, window_start, window_end,
+          status, review_note, created_at, updated_at, reviewed_at;
+
+-- name: ExcludeFraudFlagVotes :one
+-- Marks the flag's counted votes as fraud. Returns how many were marked and
+-- when the earliest of them was cast.
+WITH excluded AS (
+    UPDATE votes SET status = 'fraud'
+    WHERE id IN (SELECT vote_id FROM fraud_flag_votes WHERE flag_id = $1)
+      AND status = 'counted'
+    RETURNING created_at
+)
+SELECT COUNT(*) AS votes_excluded, MIN(created_at)::timestamptz AS first_excluded_at
+FROM excluded;
+
+-- name: RevokeFraudFlagSessions :execrows
+-- Revokes every session that cast one of the flag's votes.
+UPDATE sessions SET revoked_at = NOW()
+WHERE revoked_at IS NULL AND id IN (
+    SELECT v.session_id
+    FROM fraud_flag_votes fv
+    JOIN votes v ON v.id = fv.vote_id
+    WHERE fv.flag_id = $1
+);
+
+-- name: CreateVoteRatingDelta :exec
+INSERT INTO vote_rating_deltas (vote_id, company_id, category, won,
+                                rating_delta, deviation_delta, volatility_delta)
+VALUES ($1, $2, $3, $4, $5, $6, $7);
+
+-- name: ListVoteRatingDeltas :many
+SELECT vote_id, company_id, category, won, rating_delta, deviation_delta, volatility_delta, created_at
+FROM vote_rating_deltas
+WHERE vote_id = $1;
+
+-- name: DeleteVoteRatingDeltasBefore :execrows
+DELETE FROM vote_rating_deltas WHERE created_at < $1;
+
+-- name: ListVoteRatingDeltaVoteIDs :many
+-- Lists the votes whose rating changes are kept for undo.
+SELECT DISTINCT vote_id FROM vote_rating_deltas ORDER BY vote_id;
+
+-- name: SetVoteRatingDelta :exec
+-- Replaces what a vote changed, as a recompute replayed it.
+UPDATE vote_rating_deltas
+SET rating_delta = @rating_delta, deviation_delta = @deviation_delta,
+    volatility_delta = @volatility_delta
+WHERE vote_id = @vote_id AND company_id = @company_id AND category = @category;
+
+-- name: GetVoteForUpdate :one
+SELECT id, winner_id, loser_id, session_id, created_at, category, user_id, status, outcome,
+       strength, ranking_id
+FROM votes
+WHERE id = $1
+FOR UPDATE;
+
+-- name: SetVoteStatus :exec
+UPDATE votes SET status = $2 WHERE id = $1;
+
+-- name: RevertCompanyRating :exec
+-- Subtracts a vote's rating delta from the company and takes the vote off
+-- its record.
+UPDATE companies
+SET rating = rating - @rating_delta::float8, elo_rating = ROUND(rating - @rating_delta::float8)::int,
+    rating_deviation = rating_deviation - @deviation_delta::float8,
+    rating_volatility = rating_volatility - @volatility_delta::float8,
+    total_votes = total_votes - 1,
+    wins = wins - CASE WHEN @won::bool THEN 1 ELSE 0 END,
+    losses = losses - CASE WHEN @won::bool OR @drawn::bool THEN 0 ELSE 1 END,
+    draws = draws - CASE WHEN @drawn::bool THEN 1 ELSE 0 END,
+    updated_at = NOW()
+WHERE id = @id;
+
+-- name: RevertCategoryRating :exec
+UPDATE company_category_ratings
+SET rating = rating - @rating_delta::float8, elo_rating = ROUND(rating - @rating_delta::float8)::int,
+    rating_deviation = rating_deviation - @deviation_delta::float8,
+    rating_volatility = rating_volatility - @volatility_delta::float8,
+    total_votes = total_votes - 1,
+    wins = wins - CASE WHEN @won::bool THEN 1 ELSE 0 END,
+    losses = losses - CASE WHEN @won::bool OR @drawn::bool THEN 0 ELSE 1 END,
+    updated_at = NOW()
+WHERE company_id = @company_id AND category = @category;
+
+-- name: DeletePairVote :exec
+DELETE FROM voter_pair_votes
+WHERE voter = $1 AND company_low = $2 AND company_high = $3;
+
+-- name: RevertCompanySkips :exec
+UPDATE companies SET skips = skips - 1, updated_at = NOW()
+WHERE id = ANY(@ids::int[]);
+
+-- name: CreateRanking :one
+-- Records the order a voter put a set of companies in, best first.
+INSERT INTO rankings (company_ids, category)
+VALUES ($1, $2)
+RETURNING id, company_ids, category, created_at;
+
+-- name: GetHeadToHeadRecord :one
+-- Counted results between two companies, from company_a's side.
+SELECT COUNT(*) FILTER (WHERE outcome = 'win' AND winner_id = @company_a::int) AS a_wins,
+       COUNT(*) FILTER (WHERE outcome = 'win' AND winner_id = @company_b::int) AS b_wins,
+       COUNT(*) FILTER (WHERE outcome = 'draw') AS draws
+FROM votes
+WHERE status = 'counted'
+  AND LEAST(winner_id, loser_id) = LEAST(@company_a::int, @company_b::int)
+  AND GREATEST(winner_id, loser_id) = GREATEST(@company_a::int, @company_b::int);
+
+-- name: ListHeadToHeadVotes :many
+SELECT id, winner_id, loser_id, outcome, created_at
+FROM votes
+WHERE status = 'counted' AND outcome <> 'skip'
+  AND LEAST(winner_id, loser_id) = LEAST(@company_a::int, @company_b::int)
+  AND GREATEST(winner_id, loser_id) = GREATEST(@company_a::int, @company_b::int)
+ORDER BY created_at DESC
+LIMIT @page_limit;
+
+-- name: GetHeadToHeadTrend :many
+-- Counted results between two companies per week, from company_a's side.
+SELECT date_trunc('week', created_at)::timestamptz AS week,
+       COUNT(*) FILTER (WHERE outcome = 'win' AND winner_id = @company_a::int) AS a_wins,
+       COUNT(*) FILTER (WHERE outcome = 'win' AND winner_id = @company_b::int) AS b_wins,
+       COUNT(*) FILTER (WHERE outcome = 'draw') AS draws
+FROM votes
+WHERE status = 'counted' AND outcome <> 'skip'
+  AND LEAST(winner_id, loser_id) = LEAST(@company_a::int, @company_b::int)
+  AND GREATEST(winner_id, loser_id) = GREATEST(@company_a::int, @company_b::int)
+GROUP BY week
+ORDER BY week;
+
+-- name: ListRivals :many
+-- Opponents a company met most often in counted votes, from its side.
+SELECT (CASE WHEN winner_id = @company_id::int THEN loser_id ELSE winner_id END)::int AS opponent_id,
+       COUNT(*) AS matchups,
+       COUNT(*) FILTER (WHERE outcome = 'win' AND winner_id = @company_id::int) AS wins,
+       COUNT(*) FILTER (WHERE outcome = 'win' AND loser_id = @company_id::int) AS losses,
+       COUNT(*) FILTER (WHERE outcome = 'draw') AS draws,
+       MAX(created_at)::timestamptz AS last_met_at
+FROM votes
+WHERE status = 'counted' AND outcome <> 'skip'
+  AND (winner_id = @company_id::int OR loser_id = @company_id::int)
+GROUP BY 1
+ORDER BY matchups DESC, last_met_at DESC
+LIMIT @page_limit;
+
+-- name: LockRatingDecays :exec
+-- Blocks other decay runs until the surrounding transaction ends, so two
+-- runs do not both decay the same company.
+LOCK TABLE rating_decays IN EXCLUSIVE MODE;
+
+-- name: ListDecayCandidates :many
+-- Companies without a counted vote since inactive_since that have not
+-- decayed since decayed_before.
+SELECT c.id
+FROM companies c
+WHERE c.created_at < @inactive_since
+  AND NOT EXISTS (
+    SELECT 1 FROM votes v
+    WHERE v.status = 'counted' AND v.outcome <> 'skip'
+      AND (v.winner_id = c.id OR v.loser_id = c.id)
+      AND v.created_at >= @inactive_since
+  )
+  AND NOT EXISTS (
+    SELECT 1 FROM rating_decays d
+    WHERE d.company_id = c.id AND d.created_at >= @decayed_before
+  )
+ORDER BY c.id;
+
+-- name: DecayCompanyRating :exec
+UPDATE companies
+SET rating = @rating::float8, elo_rating = ROUND(@rating::float8)::int,
+    rating_deviation = @rating_deviation, updated_at = NOW()
+WHERE id = @id;
+
+-- name: CreateRatingDecay :exec
+INSERT INTO rating_decays (company_id, rate, deviation_increase)
+VALUES ($1, $2, $3);
+
+-- name: ListRatingDecaysForReplay :many
+SELECT id, company_id, rate, deviation_increase, created_at
+FROM rating_decays
+ORDER BY created_at, id;
+
+-- name: NotifyEvent :exec
+-- Sends payload to every connection listening on channel once the
+-- surrounding transaction commits.
+SELECT pg_notify(@channel::text, @payload::text);
+
+-- name: ClaimOutboxPosition :one
+-- Returns the position of the next event of an aggregate: the id of the
+-- current transaction, or the position of the aggregate's previous event if
+-- that is later. The aggregate stays locked until the transaction ends, so
+-- its events are positioned in the order they commit.
+INSERT INTO outbox_aggregates (aggregate_type, aggregate_id, last_position)
+VALUES (@aggregate_type, @aggregate_id, pg_current_xact_id()::text::bigint)
+ON CONFLICT (aggregate_type, aggregate_id) DO UPDATE
+SET last_position = GREATEST(outbox_aggregates.last_position, EXCLUDED.last_position)
+RETURNING last_position;
+
+-- name: CreateOutboxEvent :exec
+INSERT INTO outbox_events (aggregate_type, aggregate_id, kind, payload, position)
+VALUES ($1, $2, $3, $4, $5);
+
+-- name: ListOutboxEventsAfter :many
+-- The events after the given position and id, in delivery order. Only
+-- positions below the oldest running transaction are read: every event
+-- committed later is positioned at or above it, so none is skipped.
+SELECT id, aggregate_type, aggregate_id, kind, payload, created_at, position
+FROM outbox_events
+WHERE (position, id) > (@after_position::bigint, @after_id::bigint)
+  AND position < pg_snapshot_xmin(pg_current_snapshot())::text::bigint
+ORDER BY position, id
+LIMIT @page_limit;
+
+-- name: EnsureOutboxOffset :exec
+-- Starts a sink seen for the first time at the end of the outbox: after
+-- every event positioned below the oldest running transaction.
+INSERT INTO outbox_offsets (sink, last_position, last_event_id)
+SELECT @sink::text, pg_snapshot_xmin(pg_current_snapshot())::text::bigint - 1,
+       COALESCE(MAX(id), 0)::bigint
+FROM outbox_events
+ON CONFLICT (sink) DO NOTHING;
+
+-- name: LockOutboxOffset :one
+-- Returns nothing while another instance is delivering to the sink.
+SELECT last_position, last_event_id FROM outbox_offsets
+WHERE sink = $1
+FOR UPDATE SKIP LOCKED;
+
+-- name: SetOutboxOffset :exec
+INSERT INTO outbox_offsets (sink, last_position, last_event_id)
+VALUES ($1, $2, $3)
+ON CONFLICT (sink) DO UPDATE
+SET last_position = EXCLUDED.last_position, last_event_id = EXCLUDED.last_event_id,
+    updated_at = NOW();
+
+-- name: CreateWebhookSubscription :one
+INSERT INTO webhook_subscriptions (url, secret, event_types, description)
+VALUES ($1, $2, $3, $4)
+RETURNING id, url, secret, previous_secret, previous_secret_expires_at, event_types,
+          description, created_at, updated_at;
+
+-- name: ListWebhookSubscriptions :many
+SELECT id, url, secret, previous_secret, previous_secret_expires_at, event_types,
+       description, created_at, updated_at
+FROM webhook_subscriptions
+ORDER BY id;
+
+-- name: WebhookSubscriptionExists :one
+SELECT EXISTS(SELECT 1 FROM webhook_subscriptions WHERE id = $1);
+
+-- name: DeleteWebhookSubscription :execrows
+DELETE FROM webhook_subscriptions WHERE id = $1;
+
+-- name: RotateWebhookSecret :one
+-- Replaces the secret, keeping the old one valid until previous_secret_expires_at.
+UPDATE webhook_subscriptions
+SET previous_secret = secret, previous_secret_expires_at = @previous_secret_expires_at,
+    secret = @secret, updated_at = NOW()
+WHERE id = @id
+RETURNING id, url, secret, previous_secret, previous_secret_expires_at, event_types,
+          description, created_at, updated_at;
+
+-- name: CreateWebhookDeliveries :execrows
+-- Queues an event for every subscription to its type. Queuing the same
+-- event again is a no-op.
+INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload)
+SELECT s.id, @event_id::text, @event_type::text, @payload::jsonb
+FROM webhook_subscriptions s
+WHERE cardinality(s.event_types) = 0 OR @event_type::text = ANY(s.event_types)
+ON CONFLICT (subscription_id, event_id) DO NOTHING;
+
+-- name: ClaimWebhookDeliveries :many
+-- Leases due deliveries until lease_until, so no other instance attempts
+-- them meanwhile; a delivery whose attempt is never recorded is retried
+-- once the lease runs out.
+WITH claimed AS (
+  UPDATE webhook_deliveries
+  SET next_attempt_at = @lease_until
+  WHERE id IN (
+    SELECT d.id FROM webhook_deliveries d
+    WHERE d.status = 'pending' AND d.next_attempt_at <= NOW()
+    ORDER BY d.next_attempt_at, d.id
+    LIMIT @batch_size
+    FOR UPDATE SKIP LOCKED
+  )
+  RETURNING id, subscription_id, event_id, event_type, payload, attempts
+)
+SELECT c.id, c.subscription_id, c.event_id, c.event_type, c.payload, c.attempts,
+       s.url, s.secret, s.previous_secret, s.previous_secret_expires_at
+FROM claimed c
+JOIN webhook_subscriptions s ON s.id = c.subscription_id
+ORDER BY c.id;
+
+-- name: RecordWebhookAttempt :exec
+UPDATE webhook_deliveries
+SET status = @status, attempts = attempts + 1, last_attempt_at = NOW(),
+    next_attempt_at = @next_attempt_at,
+    response_status = sqlc.narg(response_status), last_error = sqlc.narg(last_error),
+    delivered_at = CASE WHEN @status = 'delivered' THEN NOW() END
+WHERE id = @id;
+
+-- name: ListWebhookDeliveries :many
+SELECT id, subscription_id, event_id, event_type, payload, status, attempts,
+       next_attempt_at, last_attempt_at, response_status, last_error, created_at, delivered_at
+FROM webhook_deliveries
+WHERE subscription_id = @subscription_id
+  AND (sqlc.narg(status)::text IS NULL OR status = sqlc.narg(status))
+ORDER BY id DESC
+LIMIT @page_limit OFFSET @page_offset;
+
+-- name: CountWebhookDeliveries :one
+SELECT COUNT(*) FROM webhook_deliveries
+WHERE subscription_id = @subscription_id
+  AND (sqlc.narg(status)::text IS NULL OR status = sqlc.narg(status));
+
+-- name: DeleteOldWebhookDeliveries :execrows
+-- Drops finished deliveries from the log once they are older than the cutoff.
+DELETE FROM webhook_deliveries
+WHERE status <> 'pending' AND created_at < $1;
+
+-- name: ListCompanyRankChanges :many
+-- Lists the companies whose current rank differs from the rank last
+-- announced to webhooks. Companies never announced have previous rank 0.
+SELECT c.id AS company_id, c.name, c.slug, c.elo_rating,
+       ranked.rank::int AS rank, COALESCE(w.rank, 0)::int AS previous_rank
+FROM (
+  SELECT id, RANK() OVER (ORDER BY elo_rating DESC) AS rank FROM companies
+) ranked
+JOIN companies c ON c.id = ranked.id
+LEFT JOIN webhook_company_ranks w ON w.company_id = c.id
+WHERE w.rank IS DISTINCT FROM ranked.rank
+ORDER BY c.id;
+
+-- name: SetWebhookCompanyRanks :exec
+INSERT INTO webhook_company_ranks (company_id, rank)
+SELECT unnest(@company_ids::int[]), unnest(@ranks::int[])
+ON CONFLICT (company_id) DO UPDATE SET rank = EXCLUDED.rank, updated_at = NOW();
+
+-- name: GetCommentByID :one
+SELECT id, company_id, content, is_current_employee, session_id, upvotes, created_at, user_id
+FROM company_comments
+WHERE id = $1;
+
+-- name: LockCompanyActivity :exec
+-- Blocks other rollups until the surrounding transaction ends.
+LOCK TABLE company_activity_hourly IN EXCLUSIVE MODE;
+
+-- name: GetLatestCompanyActivityHour :one
+SELECT MAX(hour)::timestamptz AS latest_hour FROM company_activity_hourly;
+
+-- name: DeleteCompanyActivitySince :exec
+DELETE FROM company_activity_hourly WHERE hour >= $1;
+
+-- name: RollUpCompanyActivity :exec
+-- Aggregates counted votes and rating history from since, which must be on
+-- the hour, into hourly buckets. The buckets from since on must have been
+-- deleted first. A rating change is measured from the company's previous
+-- history row, which may lie before since.
+INSERT INTO company_activity_hourly (company_id, hour, wins, losses, draws, rating_change)
+SELECT company_id, hour, SUM(wins)::int, SUM(losses)::int, SUM(draws)::int, SUM(rating_change)::float8
+FROM (
+  SELECT v.winner_id AS company_id,
+         date_bin('1 hour', v.created_at, TIMESTAMPTZ '2000-01-01 00:00:00+00') AS hour,
+         COUNT(*) FILTER (WHERE v.outcome = 'win') AS wins,
+         0 AS losses,
+         COUNT(*) FILTER (WHERE v.outcome = 'draw') AS draws,
+         0::float8 AS rating_change
+  FROM votes v
+  WHERE v.status = 'counted' AND v.outcome <> 'skip' AND v.created_at >= @since
+  GROUP BY 1, 2
+  UNION ALL
+  SELECT v.loser_id,
+         date_bin('1 hour', v.created_at, TIMESTAMPTZ '2000-01-01 00:00:00+00'),
+         0,
+         COUNT(*) FILTER (WHERE v.outcome = 'win'),
+         COUNT(*) FILTER (WHERE v.outcome = 'draw'),
+         0::float8
+  FROM votes v
+  WHERE v.status = 'counted' AND v.outcome <> 'skip' AND v.created_at >= @since
+  GROUP BY 1, 2
+  UNION ALL
+  SELECT h.company_id,
+         date_bin('1 hour', h.created_at, TIMESTAMPTZ '2000-01-01 00:00:00+00'),
+         0, 0, 0,
+         SUM(h.rating - COALESCE(h.previous_rating, h.rating))
+  FROM (
+    SELECT company_id, created_at, rating,
+           LAG(rating) OVER (PARTITION BY company_id ORDER BY created_at, id) AS previous_rating
+    FROM rating_history
+    WHERE created_at >= @since
+       OR id IN (
+         SELECT last.id
+         FROM companies c
+         CROSS JOIN LATERAL (
+           SELECT rh.id FROM rating_history rh
+           WHERE rh.company_id = c.id AND rh.created_at < @since
+           ORDER BY rh.created_at DESC, rh.id DESC
+           LIMIT 1
+         ) last
+       )
+  ) h
+  WHERE h.created_at >= @since
+  GROUP BY 1, 2
+) activity
+GROUP BY company_id, hour;
+
+-- name: GetTrendingLeaderboard :many
+-- Companies with counted votes in [window_start, window_end), by hour,
+-- ordered by rating gained, win rate or number of votes in the window.
+-- Draws count as half a win.
+SELECT sqlc.embed(c), a.wins, a.losses, a.draws, a.votes, a.rating_change,
+       ((a.wins + 0.5 * a.draws) / NULLIF(a.votes, 0))::float8 AS win_rate,
+       (SELECT COUNT(*) + 1 FROM companies r WHERE r.elo_rating > c.elo_rating)::int AS global_rank
+FROM (
+  SELECT company_id, SUM(wins)::int AS wins, SUM(losses)::int AS losses, SUM(draws)::int AS draws,
+         SUM(wins + losses + draws)::int AS votes, SUM(rating_change)::float8 AS rating_change
+  FROM company_activity_hourly
+  WHERE hour >= @window_start AND hour < @window_end
+  GROUP BY company_id
+) a
+JOIN companies c ON c.id = a.company_id
+WHERE a.votes >= @min_votes::int
+  AND (sqlc.narg(category)::text IS NULL OR c.category = sqlc.narg(category))
+ORDER BY
+  CASE WHEN @sort::text = 'win_rate' THEN (a.wins + 0.5 * a.draws) / NULLIF(a.votes, 0) END DESC,
+  CASE WHEN @sort::text = 'votes' THEN a.votes END DESC,
+  a.rating_change DESC, a.votes DESC, c.elo_rating DESC, c.id
+LIMIT @page_limit OFFSET @page_offset;
+
+-- name: CountTrendingCompanies :one
+SELECT COUNT(*) FROM (
+  SELECT company_id
+  FROM company_activity_hourly
+  WHERE hour >= @window_start AND hour < @window_end
+  GROUP BY company_id
+  HAVING SUM(wins + losses + draws) >= @min_votes::int
+) a
+JOIN companies c ON c.id = a.company_id
+WHERE sqlc.narg(category)::text IS NULL OR c.category = sqlc.narg(category);
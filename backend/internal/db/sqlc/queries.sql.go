@@ -11,6 +11,208 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const addFraudFlagVotes = `-- name: AddFraudFlagVotes :exec
+WITH added AS (
+    INSERT INTO fraud_flag_votes (flag_id, vote_id)
+    SELECT $1::int, unnest($2::int[])
+    ON CONFLICT DO NOTHING
+    RETURNING vote_id
+), added_votes AS (
+    SELECT COUNT(*) AS n, MIN(v.created_at) AS first_at, MAX(v.created_at) AS last_at
+    FROM added a
+    JOIN votes v ON v.id = a.vote_id
+)
+UPDATE fraud_flags f
+SET vote_count = f.vote_count + av.n,
+    window_start = LEAST(f.window_start, av.first_at),
+    window_end = GREATEST(f.window_end, av.last_at),
+    updated_at = NOW()
+FROM added_votes av
+WHERE f.id = $1::int
+`
+
+type AddFraudFlagVotesParams struct {
+	FlagID  int32   `json:"flag_id"`
+	VoteIds []int32 `json:"vote_ids"`
+}
+
+// Adds votes to a flag and widens its vote count and time window to match.
+func (q *Queries) AddFraudFlagVotes(ctx context.Context, arg AddFraudFlagVotesParams) error {
+	_, err := q.db.Exec(ctx, addFraudFlagVotes, arg.FlagID, arg.VoteIds)
+	return err
+}
+
+const claimOutboxPosition = `-- name: ClaimOutboxPosition :one
+INSERT INTO outbox_aggregates (aggregate_type, aggregate_id, last_position)
+VALUES ($1, $2, pg_current_xact_id()::text::bigint)
+ON CONFLICT (aggregate_type, aggregate_id) DO UPDATE
+SET last_position = GREATEST(outbox_aggregates.last_position, EXCLUDED.last_position)
+RETURNING last_position
+`
+
+type ClaimOutboxPositionParams struct {
+	AggregateType string `json:"aggregate_type"`
+	AggregateID   int32  `json:"aggregate_id"`
+}
+
+// Returns the position of the next event of an aggregate: the id of the
+// current transaction, or the position of the aggregate's previous event if
+// that is later. The aggregate stays locked until the transaction ends, so
+// its events are positioned in the order they commit.
+func (q *Queries) ClaimOutboxPosition(ctx context.Context, arg ClaimOutboxPositionParams) (int64, error) {
+	row := q.db.QueryRow(ctx, claimOutboxPosition, arg.AggregateType, arg.AggregateID)
+	var last_position int64
+	err := row.Scan(&last_position)
+	return last_position, err
+}
+
+const claimSession = `-- name: ClaimSession :one
+INSERT INTO claimed_sessions (session_id, user_id)
+VALUES ($1, $2)
+ON CONFLICT (session_id) DO UPDATE SET session_id = EXCLUDED.session_id
+RETURNING user_id
+`
+
+type ClaimSessionParams struct {
+	SessionID string `json:"session_id"`
+	UserID    int32  `json:"user_id"`
+}
+
+// Records the claim and returns the user that owns the session, which is an
+// earlier claimant if the session has already been claimed.
+func (q *Queries) ClaimSession(ctx context.Context, arg ClaimSessionParams) (int32, error) {
+	row := q.db.QueryRow(ctx, claimSession, arg.SessionID, arg.UserID)
+	var user_id int32
+	err := row.Scan(&user_id)
+	return user_id, err
+}
+
+const claimSessionComments = `-- name: ClaimSessionComments :execrows
+UPDATE company_comments SET user_id = $1
+WHERE session_id = $2 AND user_id IS NULL
+`
+
+type ClaimSessionCommentsParams struct {
+	UserID    *int32  `json:"user_id"`
+	SessionID *string `json:"session_id"`
+}
+
+func (q *Queries) ClaimSessionComments(ctx context.Context, arg ClaimSessionCommentsParams) (int64, error) {
+	result, err := q.db.Exec(ctx, claimSessionComments, arg.UserID, arg.SessionID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const claimSessionRatings = `-- name: ClaimSessionRatings :execrows
+UPDATE company_ratings SET user_id = $1
+WHERE session_id = $2 AND user_id IS NULL
+`
+
+type ClaimSessionRatingsParams struct {
+	UserID    *int32  `json:"user_id"`
+	SessionID *string `json:"session_id"`
+}
+
+func (q *Queries) ClaimSessionRatings(ctx context.Context, arg ClaimSessionRatingsParams) (int64, error) {
+	result, err := q.db.Exec(ctx, claimSessionRatings, arg.UserID, arg.SessionID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const claimSessionVotes = `-- name: ClaimSessionVotes :execrows
+UPDATE votes SET user_id = $1
+WHERE session_id = $2 AND user_id IS NULL
+`
+
+type ClaimSessionVotesParams struct {
+	UserID    *int32  `json:"user_id"`
+	SessionID *string `json:"session_id"`
+}
+
+func (q *Queries) ClaimSessionVotes(ctx context.Context, arg ClaimSessionVotesParams) (int64, error) {
+	result, err := q.db.Exec(ctx, claimSessionVotes, arg.UserID, arg.SessionID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const claimWebhookDeliveries = `-- name: ClaimWebhookDeliveries :many
+WITH claimed AS (
+  UPDATE webhook_deliveries
+  SET next_attempt_at = $1
+  WHERE id IN (
+    SELECT d.id FROM webhook_deliveries d
+    WHERE d.status = 'pending' AND d.next_attempt_at <= NOW()
+    ORDER BY d.next_attempt_at, d.id
+    LIMIT $2
+    FOR UPDATE SKIP LOCKED
+  )
+  RETURNING id, subscription_id, event_id, event_type, payload, attempts
+)
+SELECT c.id, c.subscription_id, c.event_id, c.event_type, c.payload, c.attempts,
+       s.url, s.secret, s.previous_secret, s.previous_secret_expires_at
+FROM claimed c
+JOIN webhook_subscriptions s ON s.id = c.subscription_id
+ORDER BY c.id
+`
+
+type ClaimWebhookDeliveriesParams struct {
+	LeaseUntil pgtype.Timestamptz `json:"lease_until"`
+	BatchSize  int32              `json:"batch_size"`
+}
+
+type ClaimWebhookDeliveriesRow struct {
+	ID                      int64              `json:"id"`
+	SubscriptionID          int32              `json:"subscription_id"`
+	EventID                 string             `json:"event_id"`
+	EventType               string             `json:"event_type"`
+	Payload                 []byte             `json:"payload"`
+	Attempts                int32              `json:"attempts"`
+	Url                     string             `json:"url"`
+	Secret                  string             `json:"secret"`
+	PreviousSecret          *string            `json:"previous_secret"`
+	PreviousSecretExpiresAt pgtype.Timestamptz `json:"previous_secret_expires_at"`
+}
+
+// Leases due deliveries until lease_until, so no other instance attempts
+// them meanwhile; a delivery whose attempt is never recorded is retried
+// once the lease runs out.
+func (q *Queries) ClaimWebhookDeliveries(ctx context.Context, arg ClaimWebhookDeliveriesParams) ([]ClaimWebhookDeliveriesRow, error) {
+	rows, err := q.db.Query(ctx, claimWebhookDeliveries, arg.LeaseUntil, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ClaimWebhookDeliveriesRow{}
+	for rows.Next() {
+		var i ClaimWebhookDeliveriesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.SubscriptionID,
+			&i.EventID,
+			&i.EventType,
+			&i.Payload,
+			&i.Attempts,
+			&i.Url,
+			&i.Secret,
+			&i.PreviousSecret,
+			&i.PreviousSecretExpiresAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const companyExists = `-- name: CompanyExists :one
 SELECT EXISTS(SELECT 1 FROM companies WHERE id = $1)
 `
@@ -55,6 +257,18 @@ func (q *Queries) CountCompaniesByCategory(ctx context.Context, category string)
 	return count, err
 }
 
+const countFraudFlags = `-- name: CountFraudFlags :one
+SELECT COUNT(*) FROM fraud_flags
+WHERE $1::text IS NULL OR status = $1
+`
+
+func (q *Queries) CountFraudFlags(ctx context.Context, status *string) (int64, error) {
+	row := q.db.QueryRow(ctx, countFraudFlags, status)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const countRatings = `-- name: CountRatings :one
 SELECT COUNT(*) FROM company_ratings
 `
@@ -66,8 +280,50 @@ func (q *Queries) CountRatings(ctx context.Context) (int64, error) {
 	return count, err
 }
 
+const countTrendingCompanies = `-- name: CountTrendingCompanies :one
+SELECT COUNT(*) FROM (
+  SELECT company_id
+  FROM company_activity_hourly
+  WHERE hour >= $1 AND hour < $2
+  GROUP BY company_id
+  HAVING SUM(wins + losses + draws) >= $3::int
+) a
+JOIN companies c ON c.id = a.company_id
+WHERE $4::text IS NULL OR c.category = $4
+`
+
+type CountTrendingCompaniesParams struct {
+	WindowStart pgtype.Timestamptz `json:"window_start"`
+	WindowEnd   pgtype.Timestamptz `json:"window_end"`
+	MinVotes    int32              `json:"min_votes"`
+	Category    *string            `json:"category"`
+}
+
+func (q *Queries) CountTrendingCompanies(ctx context.Context, arg CountTrendingCompaniesParams) (int64, error) {
+	row := q.db.QueryRow(ctx, countTrendingCompanies,
+		arg.WindowStart,
+		arg.WindowEnd,
+		arg.MinVotes,
+		arg.Category,
+	)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countUserVotes = `-- name: CountUserVotes :one
+SELECT COUNT(*) FROM votes WHERE user_id = $1 AND status = 'counted'
+`
+
+func (q *Queries) CountUserVotes(ctx context.Context, userID *int32) (int64, error) {
+	row := q.db.QueryRow(ctx, countUserVotes, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const countUsersWithVotes = `-- name: CountUsersWithVotes :one
-SELECT COUNT(DISTINCT user_id) FROM votes WHERE user_id IS NOT NULL AND user_id != ''
+SELECT COUNT(DISTINCT user_id) FROM votes WHERE user_id IS NOT NULL AND status = 'counted'
 `
 
 func (q *Queries) CountUsersWithVotes(ctx context.Context) (int64, error) {
@@ -77,10 +333,31 @@ func (q *Queries) CountUsersWithVotes(ctx context.Context) (int64, error) {
 	return count, err
 }
 
+const countVoteOutcomes = `-- name: CountVoteOutcomes :one
+SELECT COUNT(*) FILTER (WHERE outcome = 'draw') AS draws,
+       COUNT(*) FILTER (WHERE outcome = 'skip') AS skips
+FROM votes
+WHERE status = 'counted'
+`
+
+type CountVoteOutcomesRow struct {
+	Draws int64 `json:"draws"`
+	Skips int64 `json:"skips"`
+}
+
+func (q *Queries) CountVoteOutcomes(ctx context.Context) (CountVoteOutcomesRow, error) {
+	row := q.db.QueryRow(ctx, countVoteOutcomes)
+	var i CountVoteOutcomesRow
+	err := row.Scan(&i.Draws, &i.Skips)
+	return i, err
+}
+
 const countVotes = `-- name: CountVotes :one
-SELECT COUNT(*) FROM votes
+SELECT COUNT(*) FROM votes WHERE status = 'counted' AND outcome <> 'skip'
 `
 
+// Counted votes that rated their matchup. Skips are not part of it, as they
+// are not part of a company's total_votes.
 func (q *Queries) CountVotes(ctx context.Context) (int64, error) {
 	row := q.db.QueryRow(ctx, countVotes)
 	var count int64
@@ -88,10 +365,28 @@ func (q *Queries) CountVotes(ctx context.Context) (int64, error) {
 	return count, err
 }
 
+const countWebhookDeliveries = `-- name: CountWebhookDeliveries :one
+SELECT COUNT(*) FROM webhook_deliveries
+WHERE subscription_id = $1
+  AND ($2::text IS NULL OR status = $2)
+`
+
+type CountWebhookDeliveriesParams struct {
+	SubscriptionID int32   `json:"subscription_id"`
+	Status         *string `json:"status"`
+}
+
+func (q *Queries) CountWebhookDeliveries(ctx context.Context, arg CountWebhookDeliveriesParams) (int64, error) {
+	row := q.db.QueryRow(ctx, countWebhookDeliveries, arg.SubscriptionID, arg.Status)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createComment = `-- name: CreateComment :one
-INSERT INTO company_comments (company_id, content, is_current_employee, session_id)
-VALUES ($1, $2, $3, $4)
-RETURNING id, company_id, content, is_current_employee, session_id, upvotes, created_at
+INSERT INTO company_comments (company_id, content, is_current_employee, session_id, user_id)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, company_id, content, is_current_employee, session_id, upvotes, created_at, user_id
 `
 
 type CreateCommentParams struct {
@@ -99,6 +394,7 @@ type CreateCommentParams struct {
 	Content           string  `json:"content"`
 	IsCurrentEmployee *bool   `json:"is_current_employee"`
 	SessionID         *string `json:"session_id"`
+	UserID            *int32  `json:"user_id"`
 }
 
 func (q *Queries) CreateComment(ctx context.Context, arg CreateCommentParams) (CompanyComment, error) {
@@ -107,6 +403,7 @@ func (q *Queries) CreateComment(ctx context.Context, arg CreateCommentParams) (C
 		arg.Content,
 		arg.IsCurrentEmployee,
 		arg.SessionID,
+		arg.UserID,
 	)
 	var i CompanyComment
 	err := row.Scan(
@@ -117,14 +414,63 @@ func (q *Queries) CreateComment(ctx context.Context, arg CreateCommentParams) (C
 		&i.SessionID,
 		&i.Upvotes,
 		&i.CreatedAt,
+		&i.UserID,
+	)
+	return i, err
+}
+
+const createOutboxEvent = `-- name: CreateOutboxEvent :exec
+INSERT INTO outbox_events (aggregate_type, aggregate_id, kind, payload, position)
+VALUES ($1, $2, $3, $4, $5)
+`
+
+type CreateOutboxEventParams struct {
+	AggregateType string `json:"aggregate_type"`
+	AggregateID   int32  `json:"aggregate_id"`
+	Kind          string `json:"kind"`
+	Payload       []byte `json:"payload"`
+	Position      int64  `json:"position"`
+}
+
+func (q *Queries) CreateOutboxEvent(ctx context.Context, arg CreateOutboxEventParams) error {
+	_, err := q.db.Exec(ctx, createOutboxEvent,
+		arg.AggregateType,
+		arg.AggregateID,
+		arg.Kind,
+		arg.Payload,
+		arg.Position,
+	)
+	return err
+}
+
+const createRanking = `-- name: CreateRanking :one
+INSERT INTO rankings (company_ids, category)
+VALUES ($1, $2)
+RETURNING id, company_ids, category, created_at
+`
+
+type CreateRankingParams struct {
+	CompanyIds []int32 `json:"company_ids"`
+	Category   *string `json:"category"`
+}
+
+// Records the order a voter put a set of companies in, best first.
+func (q *Queries) CreateRanking(ctx context.Context, arg CreateRankingParams) (Ranking, error) {
+	row := q.db.QueryRow(ctx, createRanking, arg.CompanyIds, arg.Category)
+	var i Ranking
+	err := row.Scan(
+		&i.ID,
+		&i.CompanyIds,
+		&i.Category,
+		&i.CreatedAt,
 	)
 	return i, err
 }
 
 const createRating = `-- name: CreateRating :one
-INSERT INTO company_ratings (company_id, criterion, score, session_id)
-VALUES ($1, $2, $3, $4)
-RETURNING id, company_id, criterion, score, session_id, created_at
+INSERT INTO company_ratings (company_id, criterion, score, session_id, user_id)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, company_id, criterion, score, session_id, created_at, user_id
 `
 
 type CreateRatingParams struct {
@@ -132,6 +478,7 @@ type CreateRatingParams struct {
 	Criterion string  `json:"criterion"`
 	Score     int32   `json:"score"`
 	SessionID *string `json:"session_id"`
+	UserID    *int32  `json:"user_id"`
 }
 
 func (q *Queries) CreateRating(ctx context.Context, arg CreateRatingParams) (CompanyRating, error) {
@@ -140,6 +487,7 @@ func (q *Queries) CreateRating(ctx context.Context, arg CreateRatingParams) (Com
 		arg.Criterion,
 		arg.Score,
 		arg.SessionID,
+		arg.UserID,
 	)
 	var i CompanyRating
 	err := row.Scan(
@@ -149,21 +497,106 @@ func (q *Queries) CreateRating(ctx context.Context, arg CreateRatingParams) (Com
 		&i.Score,
 		&i.SessionID,
 		&i.CreatedAt,
+		&i.UserID,
+	)
+	return i, err
+}
+
+const createRatingDecay = `-- name: CreateRatingDecay :exec
+INSERT INTO rating_decays (company_id, rate, deviation_increase)
+VALUES ($1, $2, $3)
+`
+
+type CreateRatingDecayParams struct {
+	CompanyID         int32   `json:"company_id"`
+	Rate              float64 `json:"rate"`
+	DeviationIncrease float64 `json:"deviation_increase"`
+}
+
+func (q *Queries) CreateRatingDecay(ctx context.Context, arg CreateRatingDecayParams) error {
+	_, err := q.db.Exec(ctx, createRatingDecay, arg.CompanyID, arg.Rate, arg.DeviationIncrease)
+	return err
+}
+
+const createSession = `-- name: CreateSession :one
+INSERT INTO sessions (id, expires_at)
+VALUES ($1, $2)
+RETURNING id, created_at, expires_at, revoked_at
+`
+
+type CreateSessionParams struct {
+	ID        string             `json:"id"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+}
+
+func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) (Session, error) {
+	row := q.db.QueryRow(ctx, createSession, arg.ID, arg.ExpiresAt)
+	var i Session
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+	)
+	return i, err
+}
+
+const createUserFromIdentity = `-- name: CreateUserFromIdentity :one
+INSERT INTO users (subject, name, email, avatar_url)
+VALUES ($1::text, $2,
+        CASE WHEN NOT EXISTS (SELECT 1 FROM users u WHERE u.email = $3) THEN $3 END,
+        $4)
+ON CONFLICT (subject) DO UPDATE SET subject = EXCLUDED.subject
+RETURNING id, name, email, created_at, subject, avatar_url, updated_at
+`
+
+type CreateUserFromIdentityParams struct {
+	Subject   string  `json:"subject"`
+	Name      string  `json:"name"`
+	Email     *string `json:"email"`
+	AvatarUrl *string `json:"avatar_url"`
+}
+
+// Provisions the user for a token subject. The email is only stored if no
+// other user has it yet, and an existing user's profile is left untouched.
+func (q *Queries) CreateUserFromIdentity(ctx context.Context, arg CreateUserFromIdentityParams) (User, error) {
+	row := q.db.QueryRow(ctx, createUserFromIdentity,
+		arg.Subject,
+		arg.Name,
+		arg.Email,
+		arg.AvatarUrl,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Email,
+		&i.CreatedAt,
+		&i.Subject,
+		&i.AvatarUrl,
+		&i.UpdatedAt,
 	)
 	return i, err
 }
 
 const createVote = `-- name: CreateVote :one
-INSERT INTO votes (winner_id, loser_id, session_id, user_id)
-VALUES ($1, $2, $3, $4)
-RETURNING id, winner_id, loser_id, session_id, user_id, created_at
+INSERT INTO votes (winner_id, loser_id, session_id, user_id, category, status, outcome, strength,
+                   ranking_id, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, clock_timestamp())
+RETURNING id, winner_id, loser_id, session_id, user_id, category, status, outcome, strength,
+          ranking_id, created_at
 `
 
 type CreateVoteParams struct {
 	WinnerID  int32   `json:"winner_id"`
 	LoserID   int32   `json:"loser_id"`
 	SessionID *string `json:"session_id"`
-	UserID    *string `json:"user_id"`
+	UserID    *int32  `json:"user_id"`
+	Category  *string `json:"category"`
+	Status    string  `json:"status"`
+	Outcome   string  `json:"outcome"`
+	Strength  string  `json:"strength"`
+	RankingID *int32  `json:"ranking_id"`
 }
 
 type CreateVoteRow struct {
@@ -171,16 +604,29 @@ type CreateVoteRow struct {
 	WinnerID  int32              `json:"winner_id"`
 	LoserID   int32              `json:"loser_id"`
 	SessionID *string            `json:"session_id"`
-	UserID    *string            `json:"user_id"`
+	UserID    *int32             `json:"user_id"`
+	Category  *string            `json:"category"`
+	Status    string             `json:"status"`
+	Outcome   string             `json:"outcome"`
+	Strength  string             `json:"strength"`
+	RankingID *int32             `json:"ranking_id"`
 	CreatedAt pgtype.Timestamptz `json:"created_at"`
 }
 
+// Stamps the vote with the time it is recorded rather than the start of the
+// transaction, so votes on the same company, which are recorded under its
+// row lock, are ordered as they were applied.
 func (q *Queries) CreateVote(ctx context.Context, arg CreateVoteParams) (CreateVoteRow, error) {
 	row := q.db.QueryRow(ctx, createVote,
 		arg.WinnerID,
 		arg.LoserID,
 		arg.SessionID,
 		arg.UserID,
+		arg.Category,
+		arg.Status,
+		arg.Outcome,
+		arg.Strength,
+		arg.RankingID,
 	)
 	var i CreateVoteRow
 	err := row.Scan(
@@ -189,168 +635,2152 @@ func (q *Queries) CreateVote(ctx context.Context, arg CreateVoteParams) (CreateV
 		&i.LoserID,
 		&i.SessionID,
 		&i.UserID,
+		&i.Category,
+		&i.Status,
+		&i.Outcome,
+		&i.Strength,
+		&i.RankingID,
 		&i.CreatedAt,
 	)
 	return i, err
 }
 
-const getAggregatedRatings = `-- name: GetAggregatedRatings :many
-SELECT criterion, AVG(score)::float as average_score, COUNT(*) as total_ratings
-FROM company_ratings
-WHERE company_id = $1
-GROUP BY criterion
+const createVoteRatingDelta = `-- name: CreateVoteRatingDelta :exec
+INSERT INTO vote_rating_deltas (vote_id, company_id, category, won,
+                                rating_delta, deviation_delta, volatility_delta)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
 `
 
-type GetAggregatedRatingsRow struct {
-	Criterion    string  `json:"criterion"`
-	AverageScore float64 `json:"average_score"`
-	TotalRatings int64   `json:"total_ratings"`
+type CreateVoteRatingDeltaParams struct {
+	VoteID          int32   `json:"vote_id"`
+	CompanyID       int32   `json:"company_id"`
+	Category        string  `json:"category"`
+	Won             bool    `json:"won"`
+	RatingDelta     float64 `json:"rating_delta"`
+	DeviationDelta  float64 `json:"deviation_delta"`
+	VolatilityDelta float64 `json:"volatility_delta"`
 }
 
-func (q *Queries) GetAggregatedRatings(ctx context.Context, companyID int32) ([]GetAggregatedRatingsRow, error) {
-	rows, err := q.db.Query(ctx, getAggregatedRatings, companyID)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	items := []GetAggregatedRatingsRow{}
-	for rows.Next() {
-		var i GetAggregatedRatingsRow
-		if err := rows.Scan(&i.Criterion, &i.AverageScore, &i.TotalRatings); err != nil {
-			return nil, err
-		}
-		items = append(items, i)
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-	return items, nil
+func (q *Queries) CreateVoteRatingDelta(ctx context.Context, arg CreateVoteRatingDeltaParams) error {
+	_, err := q.db.Exec(ctx, createVoteRatingDelta,
+		arg.VoteID,
+		arg.CompanyID,
+		arg.Category,
+		arg.Won,
+		arg.RatingDelta,
+		arg.DeviationDelta,
+		arg.VolatilityDelta,
+	)
+	return err
 }
 
-const getCategories = `-- name: GetCategories :many
-SELECT category, COUNT(*) as count
-FROM companies
-GROUP BY category
-ORDER BY count DESC
+const createWebhookDeliveries = `-- name: CreateWebhookDeliveries :execrows
+INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload)
+SELECT s.id, $1::text, $2::text, $3::jsonb
+FROM webhook_subscriptions s
+WHERE cardinality(s.event_types) = 0 OR $2::text = ANY(s.event_types)
+ON CONFLICT (subscription_id, event_id) DO NOTHING
 `
 
-type GetCategoriesRow struct {
-	Category string `json:"category"`
-	Count    int64  `json:"count"`
+type CreateWebhookDeliveriesParams struct {
+	EventID   string `json:"event_id"`
+	EventType string `json:"event_type"`
+	Payload   []byte `json:"payload"`
 }
 
-func (q *Queries) GetCategories(ctx context.Context) ([]GetCategoriesRow, error) {
-	rows, err := q.db.Query(ctx, getCategories)
+// Queues an event for every subscription to its type. Queuing the same
+// event again is a no-op.
+func (q *Queries) CreateWebhookDeliveries(ctx context.Context, arg CreateWebhookDeliveriesParams) (int64, error) {
+	result, err := q.db.Exec(ctx, createWebhookDeliveries, arg.EventID, arg.EventType, arg.Payload)
 	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	items := []GetCategoriesRow{}
-	for rows.Next() {
-		var i GetCategoriesRow
-		if err := rows.Scan(&i.Category, &i.Count); err != nil {
-			return nil, err
-		}
-		items = append(items, i)
+		return 0, err
 	}
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-	return items, nil
+	return result.RowsAffected(), nil
 }
 
-const getCompanyByID = `-- name: GetCompanyByID :one
-SELECT id, name, slug, logo_url, description, website, category, tags,
-       founded_year, hq_location, employee_range, funding_stage,
-       elo_rating, total_votes, wins, losses, created_at, updated_at
-FROM companies
-WHERE id = $1
+const createWebhookSubscription = `-- name: CreateWebhookSubscription :one
+INSERT INTO webhook_subscriptions (url, secret, event_types, description)
+VALUES ($1, $2, $3, $4)
+RETURNING id, url, secret, previous_secret, previous_secret_expires_at, event_types,
+          description, created_at, updated_at
 `
 
-func (q *Queries) GetCompanyByID(ctx context.Context, id int32) (Company, error) {
-	row := q.db.QueryRow(ctx, getCompanyByID, id)
-	var i Company
+type CreateWebhookSubscriptionParams struct {
+	Url         string   `json:"url"`
+	Secret      string   `json:"secret"`
+	EventTypes  []string `json:"event_types"`
+	Description *string  `json:"description"`
+}
+
+func (q *Queries) CreateWebhookSubscription(ctx context.Context, arg CreateWebhookSubscriptionParams) (WebhookSubscription, error) {
+	row := q.db.QueryRow(ctx, createWebhookSubscription,
+		arg.Url,
+		arg.Secret,
+		arg.EventTypes,
+		arg.Description,
+	)
+	var i WebhookSubscription
 	err := row.Scan(
 		&i.ID,
-		&i.Name,
-		&i.Slug,
-		&i.LogoUrl,
+		&i.Url,
+		&i.Secret,
+		&i.PreviousSecret,
+		&i.PreviousSecretExpiresAt,
+		&i.EventTypes,
 		&i.Description,
-		&i.Website,
-		&i.Category,
-		&i.Tags,
-		&i.FoundedYear,
-		&i.HqLocation,
-		&i.EmployeeRange,
-		&i.FundingStage,
-		&i.EloRating,
-		&i.TotalVotes,
-		&i.Wins,
-		&i.Losses,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 	)
 	return i, err
 }
 
-const getCompanyBySlug = `-- name: GetCompanyBySlug :one
-SELECT id, name, slug, logo_url, description, website, category, tags,
+const decayCompanyRating = `-- name: DecayCompanyRating :exec
+UPDATE companies
+SET rating = $1::float8, elo_rating = ROUND($1::float8)::int,
+    rating_deviation = $2, updated_at = NOW()
+WHERE id = $3
+`
+
+type DecayCompanyRatingParams struct {
+	Rating          float64 `json:"rating"`
+	RatingDeviation float64 `json:"rating_deviation"`
+	ID              int32   `json:"id"`
+}
+
+func (q *Queries) DecayCompanyRating(ctx context.Context, arg DecayCompanyRatingParams) error {
+	_, err := q.db.Exec(ctx, decayCompanyRating, arg.Rating, arg.RatingDeviation, arg.ID)
+	return err
+}
+
+const deleteCompanyActivitySince = `-- name: DeleteCompanyActivitySince :exec
+DELETE FROM company_activity_hourly WHERE hour >= $1
+`
+
+func (q *Queries) DeleteCompanyActivitySince(ctx context.Context, hour pgtype.Timestamptz) error {
+	_, err := q.db.Exec(ctx, deleteCompanyActivitySince, hour)
+	return err
+}
+
+const deleteExpiredMatchupTokens = `-- name: DeleteExpiredMatchupTokens :execrows
+DELETE FROM used_matchup_tokens WHERE expires_at < NOW()
+`
+
+func (q *Queries) DeleteExpiredMatchupTokens(ctx context.Context) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteExpiredMatchupTokens)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteExpiredSessions = `-- name: DeleteExpiredSessions :execrows
+DELETE FROM sessions WHERE expires_at < NOW()
+`
+
+func (q *Queries) DeleteExpiredSessions(ctx context.Context) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteExpiredSessions)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteIdleRateLimitBuckets = `-- name: DeleteIdleRateLimitBuckets :execrows
+DELETE FROM rate_limit_buckets WHERE updated_at < NOW() - INTERVAL '1 day'
+`
+
+// Buckets untouched for a day have refilled under every policy.
+func (q *Queries) DeleteIdleRateLimitBuckets(ctx context.Context) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteIdleRateLimitBuckets)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteOldWebhookDeliveries = `-- name: DeleteOldWebhookDeliveries :execrows
+DELETE FROM webhook_deliveries
+WHERE status <> 'pending' AND created_at < $1
+`
+
+// Drops finished deliveries from the log once they are older than the cutoff.
+func (q *Queries) DeleteOldWebhookDeliveries(ctx context.Context, createdAt pgtype.Timestamptz) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteOldWebhookDeliveries, createdAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deletePairVote = `-- name: DeletePairVote :exec
+DELETE FROM voter_pair_votes
+WHERE voter = $1 AND company_low = $2 AND company_high = $3
+`
+
+type DeletePairVoteParams struct {
+	Voter       string `json:"voter"`
+	CompanyLow  int32  `json:"company_low"`
+	CompanyHigh int32  `json:"company_high"`
+}
+
+func (q *Queries) DeletePairVote(ctx context.Context, arg DeletePairVoteParams) error {
+	_, err := q.db.Exec(ctx, deletePairVote, arg.Voter, arg.CompanyLow, arg.CompanyHigh)
+	return err
+}
+
+const deletePairVotesBefore = `-- name: DeletePairVotesBefore :execrows
+DELETE FROM voter_pair_votes WHERE voted_at < $1
+`
+
+func (q *Queries) DeletePairVotesBefore(ctx context.Context, votedAt pgtype.Timestamptz) (int64, error) {
+	result, err := q.db.Exec(ctx, deletePairVotesBefore, votedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteRatingHistory = `-- name: DeleteRatingHistory :exec
+DELETE FROM rating_history
+`
+
+func (q *Queries) DeleteRatingHistory(ctx context.Context) error {
+	_, err := q.db.Exec(ctx, deleteRatingHistory)
+	return err
+}
+
+const deleteRatingHistorySince = `-- name: DeleteRatingHistorySince :exec
+DELETE FROM rating_history WHERE created_at >= $1
+`
+
+func (q *Queries) DeleteRatingHistorySince(ctx context.Context, createdAt pgtype.Timestamptz) error {
+	_, err := q.db.Exec(ctx, deleteRatingHistorySince, createdAt)
+	return err
+}
+
+const deleteRatingSnapshots = `-- name: DeleteRatingSnapshots :exec
+DELETE FROM rating_snapshots
+`
+
+func (q *Queries) DeleteRatingSnapshots(ctx context.Context) error {
+	_, err := q.db.Exec(ctx, deleteRatingSnapshots)
+	return err
+}
+
+const deleteVoteRatingDeltasBefore = `-- name: DeleteVoteRatingDeltasBefore :execrows
+DELETE FROM vote_rating_deltas WHERE created_at < $1
+`
+
+func (q *Queries) DeleteVoteRatingDeltasBefore(ctx context.Context, createdAt pgtype.Timestamptz) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteVoteRatingDeltasBefore, createdAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteWebhookSubscription = `-- name: DeleteWebhookSubscription :execrows
+DELETE FROM webhook_subscriptions WHERE id = $1
+`
+
+func (q *Queries) DeleteWebhookSubscription(ctx context.Context, id int32) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteWebhookSubscription, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const ensureOutboxOffset = `-- name: EnsureOutboxOffset :exec
+INSERT INTO outbox_offsets (sink, last_position, last_event_id)
+SELECT $1::text, pg_snapshot_xmin(pg_current_snapshot())::text::bigint - 1,
+       COALESCE(MAX(id), 0)::bigint
+FROM outbox_events
+ON CONFLICT (sink) DO NOTHING
+`
+
+// Starts a sink seen for the first time at the end of the outbox: after
+// every event positioned below the oldest running transaction.
+func (q *Queries) EnsureOutboxOffset(ctx context.Context, sink string) error {
+	_, err := q.db.Exec(ctx, ensureOutboxOffset, sink)
+	return err
+}
+
+const excludeFraudFlagVotes = `-- name: ExcludeFraudFlagVotes :one
+WITH excluded AS (
+    UPDATE votes SET status = 'fraud'
+    WHERE id IN (SELECT vote_id FROM fraud_flag_votes WHERE flag_id = $1)
+      AND status = 'counted'
+    RETURNING created_at
+)
+SELECT COUNT(*) AS votes_excluded, MIN(created_at)::timestamptz AS first_excluded_at
+FROM excluded
+`
+
+type ExcludeFraudFlagVotesRow struct {
+	VotesExcluded   int64              `json:"votes_excluded"`
+	FirstExcludedAt pgtype.Timestamptz `json:"first_excluded_at"`
+}
+
+// Marks the flag's counted votes as fraud. Returns how many were marked and
+// when the earliest of them was cast.
+func (q *Queries) ExcludeFraudFlagVotes(ctx context.Context, flagID int32) (ExcludeFraudFlagVotesRow, error) {
+	row := q.db.QueryRow(ctx, excludeFraudFlagVotes, flagID)
+	var i ExcludeFraudFlagVotesRow
+	err := row.Scan(&i.VotesExcluded, &i.FirstExcludedAt)
+	return i, err
+}
+
+const filterUnflaggedVotes = `-- name: FilterUnflaggedVotes :many
+SELECT t.vote_id::int AS vote_id
+FROM unnest($1::int[]) AS t(vote_id)
+WHERE NOT EXISTS (
+    SELECT 1 FROM fraud_flag_votes fv
+    JOIN fraud_flags f ON f.id = fv.flag_id
+    WHERE fv.vote_id = t.vote_id AND f.kind = $2
+)
+ORDER BY t.vote_id
+`
+
+type FilterUnflaggedVotesParams struct {
+	VoteIds []int32 `json:"vote_ids"`
+	Kind    string  `json:"kind"`
+}
+
+// Returns the votes among vote_ids that no flag of the kind covers yet.
+func (q *Queries) FilterUnflaggedVotes(ctx context.Context, arg FilterUnflaggedVotesParams) ([]int32, error) {
+	rows, err := q.db.Query(ctx, filterUnflaggedVotes, arg.VoteIds, arg.Kind)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []int32{}
+	for rows.Next() {
+		var vote_id int32
+		if err := rows.Scan(&vote_id); err != nil {
+			return nil, err
+		}
+		items = append(items, vote_id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const findHighRateSessions = `-- name: FindHighRateSessions :many
+SELECT session_id::text AS session_id,
+       to_timestamp(floor(EXTRACT(EPOCH FROM created_at) / $1::float8) * $1::float8)::timestamptz AS bucket_start,
+       COUNT(*) AS votes,
+       array_agg(id ORDER BY id)::int[] AS vote_ids
+FROM votes
+WHERE status = 'counted' AND session_id IS NOT NULL AND created_at >= $2
+GROUP BY 1, 2
+HAVING COUNT(*) >= $3::bigint
+`
+
+type FindHighRateSessionsParams struct {
+	BucketSeconds float64            `json:"bucket_seconds"`
+	Since         pgtype.Timestamptz `json:"since"`
+	MinVotes      int64              `json:"min_votes"`
+}
+
+type FindHighRateSessionsRow struct {
+	SessionID   string             `json:"session_id"`
+	BucketStart pgtype.Timestamptz `json:"bucket_start"`
+	Votes       int64              `json:"votes"`
+	VoteIds     []int32            `json:"vote_ids"`
+}
+
+// Sessions that cast at least min_votes counted votes within one bucket of
+// bucket_seconds since the given time.
+func (q *Queries) FindHighRateSessions(ctx context.Context, arg FindHighRateSessionsParams) ([]FindHighRateSessionsRow, error) {
+	rows, err := q.db.Query(ctx, findHighRateSessions, arg.BucketSeconds, arg.Since, arg.MinVotes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []FindHighRateSessionsRow{}
+	for rows.Next() {
+		var i FindHighRateSessionsRow
+		if err := rows.Scan(
+			&i.SessionID,
+			&i.BucketStart,
+			&i.Votes,
+			&i.VoteIds,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const findOneSidedSessions = `-- name: FindOneSidedSessions :many
+SELECT v.session_id::text AS session_id, p.company_id::int AS company_id,
+       COUNT(*) AS appearances,
+       COUNT(*) FILTER (WHERE v.winner_id = p.company_id) AS wins,
+       (array_agg(v.id ORDER BY v.id) FILTER (WHERE v.winner_id = p.company_id))::int[] AS vote_ids
+FROM votes v
+CROSS JOIN LATERAL (VALUES (v.winner_id), (v.loser_id)) AS p(company_id)
+WHERE v.status = 'counted' AND v.outcome = 'win' AND v.session_id IS NOT NULL AND v.created_at >= $1
+GROUP BY v.session_id, p.company_id
+HAVING COUNT(*) >= $2::bigint
+   AND COUNT(*) FILTER (WHERE v.winner_id = p.company_id) >= $3::float8 * COUNT(*)
+`
+
+type FindOneSidedSessionsParams struct {
+	Since    pgtype.Timestamptz `json:"since"`
+	MinVotes int64              `json:"min_votes"`
+	MinShare float64            `json:"min_share"`
+}
+
+type FindOneSidedSessionsRow struct {
+	SessionID   string  `json:"session_id"`
+	CompanyID   int32   `json:"company_id"`
+	Appearances int64   `json:"appearances"`
+	Wins        int64   `json:"wins"`
+	VoteIds     []int32 `json:"vote_ids"`
+}
+
+// Sessions that picked the same company in at least min_share of the
+// matchups it appeared in since the given time, over at least min_votes
+// matchups. vote_ids are the votes the company won.
+func (q *Queries) FindOneSidedSessions(ctx context.Context, arg FindOneSidedSessionsParams) ([]FindOneSidedSessionsRow, error) {
+	rows, err := q.db.Query(ctx, findOneSidedSessions, arg.Since, arg.MinVotes, arg.MinShare)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []FindOneSidedSessionsRow{}
+	for rows.Next() {
+		var i FindOneSidedSessionsRow
+		if err := rows.Scan(
+			&i.SessionID,
+			&i.CompanyID,
+			&i.Appearances,
+			&i.Wins,
+			&i.VoteIds,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const findWinBursts = `-- name: FindWinBursts :many
+WITH recent AS (
+    SELECT v.winner_id, COUNT(*) AS wins, array_agg(v.id ORDER BY v.id)::int[] AS vote_ids
+    FROM votes v
+    WHERE v.status = 'counted' AND v.outcome = 'win' AND v.created_at >= $3
+    GROUP BY v.winner_id
+), baseline AS (
+    SELECT v.winner_id, COUNT(*) AS wins
+    FROM votes v
+    WHERE v.status = 'counted' AND v.outcome = 'win' AND v.created_at >= $4 AND v.created_at < $3
+    GROUP BY v.winner_id
+)
+SELECT r.winner_id, r.wins, COALESCE(b.wins, 0)::bigint AS baseline_wins, r.vote_ids
+FROM recent r
+LEFT JOIN baseline b ON b.winner_id = r.winner_id
+WHERE r.wins >= $1::bigint
+  AND r.wins > $2::float8 * COALESCE(b.wins, 0)
+`
+
+type FindWinBurstsParams struct {
+	MinWins            int64              `json:"min_wins"`
+	BaselineMultiplier float64            `json:"baseline_multiplier"`
+	WindowStart        pgtype.Timestamptz `json:"window_start"`
+	BaselineStart      pgtype.Timestamptz `json:"baseline_start"`
+}
+
+type FindWinBurstsRow struct {
+	WinnerID     int32   `json:"winner_id"`
+	Wins         int64   `json:"wins"`
+	BaselineWins int64   `json:"baseline_wins"`
+	VoteIds      []int32 `json:"vote_ids"`
+}
+
+// Companies that won at least min_wins counted votes since window_start and
+// more than baseline_multiplier times as many as in the baseline period
+// before it.
+func (q *Queries) FindWinBursts(ctx context.Context, arg FindWinBurstsParams) ([]FindWinBurstsRow, error) {
+	rows, err := q.db.Query(ctx, findWinBursts,
+		arg.MinWins,
+		arg.BaselineMultiplier,
+		arg.WindowStart,
+		arg.BaselineStart,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []FindWinBurstsRow{}
+	for rows.Next() {
+		var i FindWinBurstsRow
+		if err := rows.Scan(
+			&i.WinnerID,
+			&i.Wins,
+			&i.BaselineWins,
+			&i.VoteIds,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getAggregatedRatings = `-- name: GetAggregatedRatings :many
+SELECT criterion, AVG(score)::float as average_score, COUNT(*) as total_ratings
+FROM company_ratings
+WHERE company_id = $1
+GROUP BY criterion
+`
+
+type GetAggregatedRatingsRow struct {
+	Criterion    string  `json:"criterion"`
+	AverageScore float64 `json:"average_score"`
+	TotalRatings int64   `json:"total_ratings"`
+}
+
+func (q *Queries) GetAggregatedRatings(ctx context.Context, companyID int32) ([]GetAggregatedRatingsRow, error) {
+	rows, err := q.db.Query(ctx, getAggregatedRatings, companyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetAggregatedRatingsRow{}
+	for rows.Next() {
+		var i GetAggregatedRatingsRow
+		if err := rows.Scan(&i.Criterion, &i.AverageScore, &i.TotalRatings); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getCategories = `-- name: GetCategories :many
+SELECT category, COUNT(*) as count
+FROM companies
+GROUP BY category
+ORDER BY count DESC
+`
+
+type GetCategoriesRow struct {
+	Category string `json:"category"`
+	Count    int64  `json:"count"`
+}
+
+func (q *Queries) GetCategories(ctx context.Context) ([]GetCategoriesRow, error) {
+	rows, err := q.db.Query(ctx, getCategories)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetCategoriesRow{}
+	for rows.Next() {
+		var i GetCategoriesRow
+		if err := rows.Scan(&i.Category, &i.Count); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getCategoryLeaderboard = `-- name: GetCategoryLeaderboard :many
+SELECT c.id, c.name, c.slug, c.logo_url, c.description, c.website, c.category, c.tags, c.founded_year, c.hq_location, c.employee_range, c.funding_stage, c.elo_rating, c.total_votes, c.wins, c.losses, c.created_at, c.updated_at, c.rating, c.rating_deviation, c.rating_volatility, c.draws, c.skips, ccr.company_id, ccr.category, ccr.rating, ccr.elo_rating, ccr.rating_deviation, ccr.rating_volatility, ccr.total_votes, ccr.wins, ccr.losses, ccr.updated_at,
+       (SELECT COUNT(*) + 1 FROM companies r WHERE r.elo_rating > c.elo_rating)::int AS global_rank
+FROM companies c
+JOIN company_category_ratings ccr ON ccr.company_id = c.id AND ccr.category = c.category
+WHERE c.category = $1
+ORDER BY ccr.elo_rating DESC, ccr.total_votes DESC
+LIMIT $3 OFFSET $2
+`
+
+type GetCategoryLeaderboardParams struct {
+	Category   string `json:"category"`
+	PageOffset int32  `json:"page_offset"`
+	PageLimit  int32  `json:"page_limit"`
+}
+
+type GetCategoryLeaderboardRow struct {
+	Company               Company               `json:"company"`
+	CompanyCategoryRating CompanyCategoryRating `json:"company_category_rating"`
+	GlobalRank            int32                 `json:"global_rank"`
+}
+
+// Companies in a category ordered by their category rating, with their
+// global rank alongside.
+func (q *Queries) GetCategoryLeaderboard(ctx context.Context, arg GetCategoryLeaderboardParams) ([]GetCategoryLeaderboardRow, error) {
+	rows, err := q.db.Query(ctx, getCategoryLeaderboard, arg.Category, arg.PageOffset, arg.PageLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetCategoryLeaderboardRow{}
+	for rows.Next() {
+		var i GetCategoryLeaderboardRow
+		if err := rows.Scan(
+			&i.Company.ID,
+			&i.Company.Name,
+			&i.Company.Slug,
+			&i.Company.LogoUrl,
+			&i.Company.Description,
+			&i.Company.Website,
+			&i.Company.Category,
+			&i.Company.Tags,
+			&i.Company.FoundedYear,
+			&i.Company.HqLocation,
+			&i.Company.EmployeeRange,
+			&i.Company.FundingStage,
+			&i.Company.EloRating,
+			&i.Company.TotalVotes,
+			&i.Company.Wins,
+			&i.Company.Losses,
+			&i.Company.CreatedAt,
+			&i.Company.UpdatedAt,
+			&i.Company.Rating,
+			&i.Company.RatingDeviation,
+			&i.Company.RatingVolatility,
+			&i.Company.Draws,
+			&i.Company.Skips,
+			&i.CompanyCategoryRating.CompanyID,
+			&i.CompanyCategoryRating.Category,
+			&i.CompanyCategoryRating.Rating,
+			&i.CompanyCategoryRating.EloRating,
+			&i.CompanyCategoryRating.RatingDeviation,
+			&i.CompanyCategoryRating.RatingVolatility,
+			&i.CompanyCategoryRating.TotalVotes,
+			&i.CompanyCategoryRating.Wins,
+			&i.CompanyCategoryRating.Losses,
+			&i.CompanyCategoryRating.UpdatedAt,
+			&i.GlobalRank,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getCommentByID = `-- name: GetCommentByID :one
+SELECT id, company_id, content, is_current_employee, session_id, upvotes, created_at, user_id
+FROM company_comments
+WHERE id = $1
+`
+
+func (q *Queries) GetCommentByID(ctx context.Context, id int32) (CompanyComment, error) {
+	row := q.db.QueryRow(ctx, getCommentByID, id)
+	var i CompanyComment
+	err := row.Scan(
+		&i.ID,
+		&i.CompanyID,
+		&i.Content,
+		&i.IsCurrentEmployee,
+		&i.SessionID,
+		&i.Upvotes,
+		&i.CreatedAt,
+		&i.UserID,
+	)
+	return i, err
+}
+
+const getCompanyByID = `-- name: GetCompanyByID :one
+SELECT id, name, slug, logo_url, description, website, category, tags,
+       founded_year, hq_location, employee_range, funding_stage,
+       elo_rating, total_votes, wins, losses, created_at, updated_at,
+       rating, rating_deviation, rating_volatility, draws, skips
+FROM companies
+WHERE id = $1
+`
+
+func (q *Queries) GetCompanyByID(ctx context.Context, id int32) (Company, error) {
+	row := q.db.QueryRow(ctx, getCompanyByID, id)
+	var i Company
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Slug,
+		&i.LogoUrl,
+		&i.Description,
+		&i.Website,
+		&i.Category,
+		&i.Tags,
+		&i.FoundedYear,
+		&i.HqLocation,
+		&i.EmployeeRange,
+		&i.FundingStage,
+		&i.EloRating,
+		&i.TotalVotes,
+		&i.Wins,
+		&i.Losses,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Rating,
+		&i.RatingDeviation,
+		&i.RatingVolatility,
+		&i.Draws,
+		&i.Skips,
+	)
+	return i, err
+}
+
+const getCompanyBySlug = `-- name: GetCompanyBySlug :one
+SELECT id, name, slug, logo_url, description, website, category, tags,
+       founded_year, hq_location, employee_range, funding_stage,
+       elo_rating, total_votes, wins, losses, created_at, updated_at,
+       rating, rating_deviation, rating_volatility, draws, skips
+FROM companies
+WHERE slug = $1
+`
+
+func (q *Queries) GetCompanyBySlug(ctx context.Context, slug string) (Company, error) {
+	row := q.db.QueryRow(ctx, getCompanyBySlug, slug)
+	var i Company
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Slug,
+		&i.LogoUrl,
+		&i.Description,
+		&i.Website,
+		&i.Category,
+		&i.Tags,
+		&i.FoundedYear,
+		&i.HqLocation,
+		&i.EmployeeRange,
+		&i.FundingStage,
+		&i.EloRating,
+		&i.TotalVotes,
+		&i.Wins,
+		&i.Losses,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Rating,
+		&i.RatingDeviation,
+		&i.RatingVolatility,
+		&i.Draws,
+		&i.Skips,
+	)
+	return i, err
+}
+
+const getCompanyCategoryStanding = `-- name: GetCompanyCategoryStanding :one
+SELECT ccr.company_id, ccr.category, ccr.rating, ccr.elo_rating, ccr.rating_deviation, ccr.rating_volatility, ccr.total_votes, ccr.wins, ccr.losses, ccr.updated_at,
+       (SELECT COUNT(*) + 1 FROM company_category_ratings r
+        WHERE r.category = ccr.category AND r.elo_rating > ccr.elo_rating)::int AS category_rank
+FROM company_category_ratings ccr
+WHERE ccr.company_id = $1 AND ccr.category = $2
+`
+
+type GetCompanyCategoryStandingParams struct {
+	CompanyID int32  `json:"company_id"`
+	Category  string `json:"category"`
+}
+
+type GetCompanyCategoryStandingRow struct {
+	CompanyCategoryRating CompanyCategoryRating `json:"company_category_rating"`
+	CategoryRank          int32                 `json:"category_rank"`
+}
+
+func (q *Queries) GetCompanyCategoryStanding(ctx context.Context, arg GetCompanyCategoryStandingParams) (GetCompanyCategoryStandingRow, error) {
+	row := q.db.QueryRow(ctx, getCompanyCategoryStanding, arg.CompanyID, arg.Category)
+	var i GetCompanyCategoryStandingRow
+	err := row.Scan(
+		&i.CompanyCategoryRating.CompanyID,
+		&i.CompanyCategoryRating.Category,
+		&i.CompanyCategoryRating.Rating,
+		&i.CompanyCategoryRating.EloRating,
+		&i.CompanyCategoryRating.RatingDeviation,
+		&i.CompanyCategoryRating.RatingVolatility,
+		&i.CompanyCategoryRating.TotalVotes,
+		&i.CompanyCategoryRating.Wins,
+		&i.CompanyCategoryRating.Losses,
+		&i.CompanyCategoryRating.UpdatedAt,
+		&i.CategoryRank,
+	)
+	return i, err
+}
+
+const getCompanyComments = `-- name: GetCompanyComments :many
+SELECT id, company_id, content, is_current_employee, session_id, upvotes, created_at, user_id
+FROM company_comments
+WHERE company_id = $1
+ORDER BY upvotes DESC, created_at DESC
+LIMIT 100
+`
+
+func (q *Queries) GetCompanyComments(ctx context.Context, companyID int32) ([]CompanyComment, error) {
+	rows, err := q.db.Query(ctx, getCompanyComments, companyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []CompanyComment{}
+	for rows.Next() {
+		var i CompanyComment
+		if err := rows.Scan(
+			&i.ID,
+			&i.CompanyID,
+			&i.Content,
+			&i.IsCurrentEmployee,
+			&i.SessionID,
+			&i.Upvotes,
+			&i.CreatedAt,
+			&i.UserID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getCompanyIDBySlug = `-- name: GetCompanyIDBySlug :one
+SELECT id FROM companies WHERE slug = $1
+`
+
+func (q *Queries) GetCompanyIDBySlug(ctx context.Context, slug string) (int32, error) {
+	row := q.db.QueryRow(ctx, getCompanyIDBySlug, slug)
+	var id int32
+	err := row.Scan(&id)
+	return id, err
+}
+
+const getCompanyRank = `-- name: GetCompanyRank :one
+SELECT COUNT(*) + 1 FROM companies WHERE elo_rating > $1
+`
+
+func (q *Queries) GetCompanyRank(ctx context.Context, eloRating int32) (int32, error) {
+	row := q.db.QueryRow(ctx, getCompanyRank, eloRating)
+	var column_1 int32
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const getDailyRatingSnapshots = `-- name: GetDailyRatingSnapshots :many
+SELECT day, rating, elo_rating, rating_deviation, rank, wins, losses, total_votes
+FROM rating_snapshots
+WHERE company_id = $1 AND day >= $2::date AND day <= $3::date
+ORDER BY day
+`
+
+type GetDailyRatingSnapshotsParams struct {
+	CompanyID int32       `json:"company_id"`
+	FromDay   pgtype.Date `json:"from_day"`
+	ToDay     pgtype.Date `json:"to_day"`
+}
+
+type GetDailyRatingSnapshotsRow struct {
+	Day             pgtype.Date `json:"day"`
+	Rating          float64     `json:"rating"`
+	EloRating       int32       `json:"elo_rating"`
+	RatingDeviation float64     `json:"rating_deviation"`
+	Rank            int32       `json:"rank"`
+	Wins            int32       `json:"wins"`
+	Losses          int32       `json:"losses"`
+	TotalVotes      int32       `json:"total_votes"`
+}
+
+func (q *Queries) GetDailyRatingSnapshots(ctx context.Context, arg GetDailyRatingSnapshotsParams) ([]GetDailyRatingSnapshotsRow, error) {
+	rows, err := q.db.Query(ctx, getDailyRatingSnapshots, arg.CompanyID, arg.FromDay, arg.ToDay)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetDailyRatingSnapshotsRow{}
+	for rows.Next() {
+		var i GetDailyRatingSnapshotsRow
+		if err := rows.Scan(
+			&i.Day,
+			&i.Rating,
+			&i.EloRating,
+			&i.RatingDeviation,
+			&i.Rank,
+			&i.Wins,
+			&i.Losses,
+			&i.TotalVotes,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFraudFlagForUpdate = `-- name: GetFraudFlagForUpdate :one
+SELECT id, kind, session_id, company_id, details, vote_count, window_start, window_end,
+       status, review_note, created_at, updated_at, reviewed_at
+FROM fraud_flags
+WHERE id = $1
+FOR UPDATE
+`
+
+func (q *Queries) GetFraudFlagForUpdate(ctx context.Context, id int32) (FraudFlag, error) {
+	row := q.db.QueryRow(ctx, getFraudFlagForUpdate, id)
+	var i FraudFlag
+	err := row.Scan(
+		&i.ID,
+		&i.Kind,
+		&i.SessionID,
+		&i.CompanyID,
+		&i.Details,
+		&i.VoteCount,
+		&i.WindowStart,
+		&i.WindowEnd,
+		&i.Status,
+		&i.ReviewNote,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.ReviewedAt,
+	)
+	return i, err
+}
+
+const getHeadToHeadRecord = `-- name: GetHeadToHeadRecord :one
+SELECT COUNT(*) FILTER (WHERE outcome = 'win' AND winner_id = $1::int) AS a_wins,
+       COUNT(*) FILTER (WHERE outcome = 'win' AND winner_id = $2::int) AS b_wins,
+       COUNT(*) FILTER (WHERE outcome = 'draw') AS draws
+FROM votes
+WHERE status = 'counted'
+  AND LEAST(winner_id, loser_id) = LEAST($1::int, $2::int)
+  AND GREATEST(winner_id, loser_id) = GREATEST($1::int, $2::int)
+`
+
+type GetHeadToHeadRecordParams struct {
+	CompanyA int32 `json:"company_a"`
+	CompanyB int32 `json:"company_b"`
+}
+
+type GetHeadToHeadRecordRow struct {
+	AWins int64 `json:"a_wins"`
+	BWins int64 `json:"b_wins"`
+	Draws int64 `json:"draws"`
+}
+
+// Counted results between two companies, from company_a's side.
+func (q *Queries) GetHeadToHeadRecord(ctx context.Context, arg GetHeadToHeadRecordParams) (GetHeadToHeadRecordRow, error) {
+	row := q.db.QueryRow(ctx, getHeadToHeadRecord, arg.CompanyA, arg.CompanyB)
+	var i GetHeadToHeadRecordRow
+	err := row.Scan(&i.AWins, &i.BWins, &i.Draws)
+	return i, err
+}
+
+const getHeadToHeadTrend = `-- name: GetHeadToHeadTrend :many
+SELECT date_trunc('week', created_at)::timestamptz AS week,
+       COUNT(*) FILTER (WHERE outcome = 'win' AND winner_id = $1::int) AS a_wins,
+       COUNT(*) FILTER (WHERE outcome = 'win' AND winner_id = $2::int) AS b_wins,
+       COUNT(*) FILTER (WHERE outcome = 'draw') AS draws
+FROM votes
+WHERE status = 'counted' AND outcome <> 'skip'
+  AND LEAST(winner_id, loser_id) = LEAST($1::int, $2::int)
+  AND GREATEST(winner_id, loser_id) = GREATEST($1::int, $2::int)
+GROUP BY week
+ORDER BY week
+`
+
+type GetHeadToHeadTrendParams struct {
+	CompanyA int32 `json:"company_a"`
+	CompanyB int32 `json:"company_b"`
+}
+
+type GetHeadToHeadTrendRow struct {
+	Week  pgtype.Timestamptz `json:"week"`
+	AWins int64              `json:"a_wins"`
+	BWins int64              `json:"b_wins"`
+	Draws int64              `json:"draws"`
+}
+
+// Counted results between two companies per week, from company_a's side.
+func (q *Queries) GetHeadToHeadTrend(ctx context.Context, arg GetHeadToHeadTrendParams) ([]GetHeadToHeadTrendRow, error) {
+	rows, err := q.db.Query(ctx, getHeadToHeadTrend, arg.CompanyA, arg.CompanyB)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetHeadToHeadTrendRow{}
+	for rows.Next() {
+		var i GetHeadToHeadTrendRow
+		if err := rows.Scan(
+			&i.Week,
+			&i.AWins,
+			&i.BWins,
+			&i.Draws,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getHourlyRatingHistory = `-- name: GetHourlyRatingHistory :many
+SELECT DISTINCT ON (bucket)
+       date_trunc('hour', created_at)::timestamptz AS bucket,
+       rating, elo_rating, rating_deviation, rank, wins, losses, total_votes
+FROM rating_history
+WHERE company_id = $1 AND created_at >= $2 AND created_at < $3
+ORDER BY bucket, created_at DESC, id DESC
+`
+
+type GetHourlyRatingHistoryParams struct {
+	CompanyID int32              `json:"company_id"`
+	FromTime  pgtype.Timestamptz `json:"from_time"`
+	ToTime    pgtype.Timestamptz `json:"to_time"`
+}
+
+type GetHourlyRatingHistoryRow struct {
+	Bucket          pgtype.Timestamptz `json:"bucket"`
+	Rating          float64            `json:"rating"`
+	EloRating       int32              `json:"elo_rating"`
+	RatingDeviation float64            `json:"rating_deviation"`
+	Rank            int32              `json:"rank"`
+	Wins            int32              `json:"wins"`
+	Losses          int32              `json:"losses"`
+	TotalVotes      int32              `json:"total_votes"`
+}
+
+// Last recorded state of a company within each hour of the range.
+func (q *Queries) GetHourlyRatingHistory(ctx context.Context, arg GetHourlyRatingHistoryParams) ([]GetHourlyRatingHistoryRow, error) {
+	rows, err := q.db.Query(ctx, getHourlyRatingHistory, arg.CompanyID, arg.FromTime, arg.ToTime)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetHourlyRatingHistoryRow{}
+	for rows.Next() {
+		var i GetHourlyRatingHistoryRow
+		if err := rows.Scan(
+			&i.Bucket,
+			&i.Rating,
+			&i.EloRating,
+			&i.RatingDeviation,
+			&i.Rank,
+			&i.Wins,
+			&i.Losses,
+			&i.TotalVotes,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getLatestCompanyActivityHour = `-- name: GetLatestCompanyActivityHour :one
+SELECT MAX(hour)::timestamptz AS latest_hour FROM company_activity_hourly
+`
+
+func (q *Queries) GetLatestCompanyActivityHour(ctx context.Context) (pgtype.Timestamptz, error) {
+	row := q.db.QueryRow(ctx, getLatestCompanyActivityHour)
+	var latest_hour pgtype.Timestamptz
+	err := row.Scan(&latest_hour)
+	return latest_hour, err
+}
+
+const getLeaderboard = `-- name: GetLeaderboard :many
+SELECT id, name, slug, logo_url, description, website, category, tags,
+       founded_year, hq_location, employee_range, funding_stage,
+       elo_rating, total_votes, wins, losses, created_at, updated_at,
+       rating, rating_deviation, rating_volatility, draws, skips
+FROM companies
+ORDER BY elo_rating DESC, total_votes DESC
+LIMIT $1 OFFSET $2
+`
+
+type GetLeaderboardParams struct {
+	Limit  int32 `json:"limit"`
+	Offset int32 `json:"offset"`
+}
+
+func (q *Queries) GetLeaderboard(ctx context.Context, arg GetLeaderboardParams) ([]Company, error) {
+	rows, err := q.db.Query(ctx, getLeaderboard, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Company{}
+	for rows.Next() {
+		var i Company
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Slug,
+			&i.LogoUrl,
+			&i.Description,
+			&i.Website,
+			&i.Category,
+			&i.Tags,
+			&i.FoundedYear,
+			&i.HqLocation,
+			&i.EmployeeRange,
+			&i.FundingStage,
+			&i.EloRating,
+			&i.TotalVotes,
+			&i.Wins,
+			&i.Losses,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Rating,
+			&i.RatingDeviation,
+			&i.RatingVolatility,
+			&i.Draws,
+			&i.Skips,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRateLimitTokens = `-- name: GetRateLimitTokens :one
+SELECT LEAST($1::double precision, tokens + EXTRACT(EPOCH FROM NOW() - updated_at)::double precision * $2::double precision)::double precision AS tokens
+FROM rate_limit_buckets
+WHERE key = $3
+`
+
+type GetRateLimitTokensParams struct {
+	Burst float64 `json:"burst"`
+	Rate  float64 `json:"rate"`
+	Key   string  `json:"key"`
+}
+
+// Returns the tokens the bucket holds after refilling, without taking any.
+func (q *Queries) GetRateLimitTokens(ctx context.Context, arg GetRateLimitTokensParams) (float64, error) {
+	row := q.db.QueryRow(ctx, getRateLimitTokens, arg.Burst, arg.Rate, arg.Key)
+	var tokens float64
+	err := row.Scan(&tokens)
+	return tokens, err
+}
+
+const getSession = `-- name: GetSession :one
+SELECT id, created_at, expires_at, revoked_at
+FROM sessions
+WHERE id = $1
+`
+
+func (q *Queries) GetSession(ctx context.Context, id string) (Session, error) {
+	row := q.db.QueryRow(ctx, getSession, id)
+	var i Session
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+	)
+	return i, err
+}
+
+const getSetting = `-- name: GetSetting :one
+SELECT value FROM settings WHERE key = $1
+`
+
+func (q *Queries) GetSetting(ctx context.Context, key string) (string, error) {
+	row := q.db.QueryRow(ctx, getSetting, key)
+	var value string
+	err := row.Scan(&value)
+	return value, err
+}
+
+const getTrendingLeaderboard = `-- name: GetTrendingLeaderboard :many
+SELECT c.id, c.name, c.slug, c.logo_url, c.description, c.website, c.category, c.tags, c.founded_year, c.hq_location, c.employee_range, c.funding_stage, c.elo_rating, c.total_votes, c.wins, c.losses, c.created_at, c.updated_at, c.rating, c.rating_deviation, c.rating_volatility, c.draws, c.skips, a.wins, a.losses, a.draws, a.votes, a.rating_change,
+       ((a.wins + 0.5 * a.draws) / NULLIF(a.votes, 0))::float8 AS win_rate,
+       (SELECT COUNT(*) + 1 FROM companies r WHERE r.elo_rating > c.elo_rating)::int AS global_rank
+FROM (
+  SELECT company_id, SUM(wins)::int AS wins, SUM(losses)::int AS losses, SUM(draws)::int AS draws,
+         SUM(wins + losses + draws)::int AS votes, SUM(rating_change)::float8 AS rating_change
+  FROM company_activity_hourly
+  WHERE hour >= $1 AND hour < $2
+  GROUP BY company_id
+) a
+JOIN companies c ON c.id = a.company_id
+WHERE a.votes >= $3::int
+  AND ($4::text IS NULL OR c.category = $4)
+ORDER BY
+  CASE WHEN $5::text = 'win_rate' THEN (a.wins + 0.5 * a.draws) / NULLIF(a.votes, 0) END DESC,
+  CASE WHEN $5::text = 'votes' THEN a.votes END DESC,
+  a.rating_change DESC, a.votes DESC, c.elo_rating DESC, c.id
+LIMIT $7 OFFSET $6
+`
+
+type GetTrendingLeaderboardParams struct {
+	WindowStart pgtype.Timestamptz `json:"window_start"`
+	WindowEnd   pgtype.Timestamptz `json:"window_end"`
+	MinVotes    int32              `json:"min_votes"`
+	Category    *string            `json:"category"`
+	Sort        string             `json:"sort"`
+	PageOffset  int32              `json:"page_offset"`
+	PageLimit   int32              `json:"page_limit"`
+}
+
+type GetTrendingLeaderboardRow struct {
+	Company      Company `json:"company"`
+	Wins         int32   `json:"wins"`
+	Losses       int32   `json:"losses"`
+	Draws        int32   `json:"draws"`
+	Votes        int32   `json:"votes"`
+	RatingChange float64 `json:"rating_change"`
+	WinRate      float64 `json:"win_rate"`
+	GlobalRank   int32   `json:"global_rank"`
+}
+
+// Companies with counted votes in [window_start, window_end), by hour,
+// ordered by rating gained, win rate or number of votes in the window.
+// Draws count as half a win.
+func (q *Queries) GetTrendingLeaderboard(ctx context.Context, arg GetTrendingLeaderboardParams) ([]GetTrendingLeaderboardRow, error) {
+	rows, err := q.db.Query(ctx, getTrendingLeaderboard,
+		arg.WindowStart,
+		arg.WindowEnd,
+		arg.MinVotes,
+		arg.Category,
+		arg.Sort,
+		arg.PageOffset,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetTrendingLeaderboardRow{}
+	for rows.Next() {
+		var i GetTrendingLeaderboardRow
+		if err := rows.Scan(
+			&i.Company.ID,
+			&i.Company.Name,
+			&i.Company.Slug,
+			&i.Company.LogoUrl,
+			&i.Company.Description,
+			&i.Company.Website,
+			&i.Company.Category,
+			&i.Company.Tags,
+			&i.Company.FoundedYear,
+			&i.Company.HqLocation,
+			&i.Company.EmployeeRange,
+			&i.Company.FundingStage,
+			&i.Company.EloRating,
+			&i.Company.TotalVotes,
+			&i.Company.Wins,
+			&i.Company.Losses,
+			&i.Company.CreatedAt,
+			&i.Company.UpdatedAt,
+			&i.Company.Rating,
+			&i.Company.RatingDeviation,
+			&i.Company.RatingVolatility,
+			&i.Company.Draws,
+			&i.Company.Skips,
+			&i.Wins,
+			&i.Losses,
+			&i.Draws,
+			&i.Votes,
+			&i.RatingChange,
+			&i.WinRate,
+			&i.GlobalRank,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, name, email, created_at, subject, avatar_url, updated_at
+FROM users
+WHERE id = $1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id int32) (User, error) {
+	row := q.db.QueryRow(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Email,
+		&i.CreatedAt,
+		&i.Subject,
+		&i.AvatarUrl,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getUserBySubject = `-- name: GetUserBySubject :one
+SELECT id, name, email, created_at, subject, avatar_url, updated_at
+FROM users
+WHERE subject = $1
+`
+
+func (q *Queries) GetUserBySubject(ctx context.Context, subject *string) (User, error) {
+	row := q.db.QueryRow(ctx, getUserBySubject, subject)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Email,
+		&i.CreatedAt,
+		&i.Subject,
+		&i.AvatarUrl,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getUserLeaderboard = `-- name: GetUserLeaderboard :many
+SELECT u.id, u.name, u.avatar_url, COUNT(*) as total_votes
+FROM votes v
+JOIN users u ON u.id = v.user_id
+WHERE v.status = 'counted'
+GROUP BY u.id
+ORDER BY total_votes DESC, u.id
+LIMIT $1 OFFSET $2
+`
+
+type GetUserLeaderboardParams struct {
+	Limit  int32 `json:"limit"`
+	Offset int32 `json:"offset"`
+}
+
+type GetUserLeaderboardRow struct {
+	ID         int32   `json:"id"`
+	Name       string  `json:"name"`
+	AvatarUrl  *string `json:"avatar_url"`
+	TotalVotes int64   `json:"total_votes"`
+}
+
+func (q *Queries) GetUserLeaderboard(ctx context.Context, arg GetUserLeaderboardParams) ([]GetUserLeaderboardRow, error) {
+	rows, err := q.db.Query(ctx, getUserLeaderboard, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetUserLeaderboardRow{}
+	for rows.Next() {
+		var i GetUserLeaderboardRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.AvatarUrl,
+			&i.TotalVotes,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getVoteForUpdate = `-- name: GetVoteForUpdate :one
+SELECT id, winner_id, loser_id, session_id, created_at, category, user_id, status, outcome,
+       strength, ranking_id
+FROM votes
+WHERE id = $1
+FOR UPDATE
+`
+
+func (q *Queries) GetVoteForUpdate(ctx context.Context, id int32) (Vote, error) {
+	row := q.db.QueryRow(ctx, getVoteForUpdate, id)
+	var i Vote
+	err := row.Scan(
+		&i.ID,
+		&i.WinnerID,
+		&i.LoserID,
+		&i.SessionID,
+		&i.CreatedAt,
+		&i.Category,
+		&i.UserID,
+		&i.Status,
+		&i.Outcome,
+		&i.Strength,
+		&i.RankingID,
+	)
+	return i, err
+}
+
+const getWeeklyRatingSnapshots = `-- name: GetWeeklyRatingSnapshots :many
+SELECT DISTINCT ON (week)
+       date_trunc('week', day)::date AS week,
+       rating, elo_rating, rating_deviation, rank, wins, losses, total_votes
+FROM rating_snapshots
+WHERE company_id = $1 AND day >= $2::date AND day <= $3::date
+ORDER BY week, day DESC
+`
+
+type GetWeeklyRatingSnapshotsParams struct {
+	CompanyID int32       `json:"company_id"`
+	FromDay   pgtype.Date `json:"from_day"`
+	ToDay     pgtype.Date `json:"to_day"`
+}
+
+type GetWeeklyRatingSnapshotsRow struct {
+	Week            pgtype.Date `json:"week"`
+	Rating          float64     `json:"rating"`
+	EloRating       int32       `json:"elo_rating"`
+	RatingDeviation float64     `json:"rating_deviation"`
+	Rank            int32       `json:"rank"`
+	Wins            int32       `json:"wins"`
+	Losses          int32       `json:"losses"`
+	TotalVotes      int32       `json:"total_votes"`
+}
+
+// Last daily snapshot of a company within each week of the range.
+func (q *Queries) GetWeeklyRatingSnapshots(ctx context.Context, arg GetWeeklyRatingSnapshotsParams) ([]GetWeeklyRatingSnapshotsRow, error) {
+	rows, err := q.db.Query(ctx, getWeeklyRatingSnapshots, arg.CompanyID, arg.FromDay, arg.ToDay)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetWeeklyRatingSnapshotsRow{}
+	for rows.Next() {
+		var i GetWeeklyRatingSnapshotsRow
+		if err := rows.Scan(
+			&i.Week,
+			&i.Rating,
+			&i.EloRating,
+			&i.RatingDeviation,
+			&i.Rank,
+			&i.Wins,
+			&i.Losses,
+			&i.TotalVotes,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+type InsertRatingHistoryParams struct {
+	CompanyID       int32              `json:"company_id"`
+	VoteID          *int32             `json:"vote_id"`
+	Rating          float64            `json:"rating"`
+	EloRating       int32              `json:"elo_rating"`
+	RatingDeviation float64            `json:"rating_deviation"`
+	Rank            int32              `json:"rank"`
+	Wins            int32              `json:"wins"`
+	Losses          int32              `json:"losses"`
+	TotalVotes      int32              `json:"total_votes"`
+	CreatedAt       pgtype.Timestamptz `json:"created_at"`
+}
+
+const listCategoryMatchupCandidates = `-- name: ListCategoryMatchupCandidates :many
+SELECT ccr.company_id, ccr.rating, ccr.rating_deviation, ccr.rating_volatility, ccr.total_votes
+FROM company_category_ratings ccr
+JOIN companies c ON c.id = ccr.company_id AND c.category = ccr.category
+WHERE ccr.category = $1
+`
+
+type ListCategoryMatchupCandidatesRow struct {
+	CompanyID        int32   `json:"company_id"`
+	Rating           float64 `json:"rating"`
+	RatingDeviation  float64 `json:"rating_deviation"`
+	RatingVolatility float64 `json:"rating_volatility"`
+	TotalVotes       int32   `json:"total_votes"`
+}
+
+// Candidates within a category, rated by their category rating.
+func (q *Queries) ListCategoryMatchupCandidates(ctx context.Context, category string) ([]ListCategoryMatchupCandidatesRow, error) {
+	rows, err := q.db.Query(ctx, listCategoryMatchupCandidates, category)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListCategoryMatchupCandidatesRow{}
+	for rows.Next() {
+		var i ListCategoryMatchupCandidatesRow
+		if err := rows.Scan(
+			&i.CompanyID,
+			&i.Rating,
+			&i.RatingDeviation,
+			&i.RatingVolatility,
+			&i.TotalVotes,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listCompanies = `-- name: ListCompanies :many
+SELECT id, name, slug, logo_url, description, website, category, tags,
+       founded_year, hq_location, employee_range, funding_stage,
+       elo_rating, total_votes, wins, losses, created_at, updated_at,
+       rating, rating_deviation, rating_volatility, draws, skips
+FROM companies
+ORDER BY elo_rating DESC, total_votes DESC
+`
+
+func (q *Queries) ListCompanies(ctx context.Context) ([]Company, error) {
+	rows, err := q.db.Query(ctx, listCompanies)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Company{}
+	for rows.Next() {
+		var i Company
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Slug,
+			&i.LogoUrl,
+			&i.Description,
+			&i.Website,
+			&i.Category,
+			&i.Tags,
+			&i.FoundedYear,
+			&i.HqLocation,
+			&i.EmployeeRange,
+			&i.FundingStage,
+			&i.EloRating,
+			&i.TotalVotes,
+			&i.Wins,
+			&i.Losses,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Rating,
+			&i.RatingDeviation,
+			&i.RatingVolatility,
+			&i.Draws,
+			&i.Skips,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listCompaniesByCategory = `-- name: ListCompaniesByCategory :many
+SELECT id, name, slug, logo_url, description, website, category, tags,
        founded_year, hq_location, employee_range, funding_stage,
-       elo_rating, total_votes, wins, losses, created_at, updated_at
+       elo_rating, total_votes, wins, losses, created_at, updated_at,
+       rating, rating_deviation, rating_volatility, draws, skips
+FROM companies
+WHERE category = $1
+ORDER BY elo_rating DESC, total_votes DESC
+`
+
+func (q *Queries) ListCompaniesByCategory(ctx context.Context, category string) ([]Company, error) {
+	rows, err := q.db.Query(ctx, listCompaniesByCategory, category)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Company{}
+	for rows.Next() {
+		var i Company
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Slug,
+			&i.LogoUrl,
+			&i.Description,
+			&i.Website,
+			&i.Category,
+			&i.Tags,
+			&i.FoundedYear,
+			&i.HqLocation,
+			&i.EmployeeRange,
+			&i.FundingStage,
+			&i.EloRating,
+			&i.TotalVotes,
+			&i.Wins,
+			&i.Losses,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Rating,
+			&i.RatingDeviation,
+			&i.RatingVolatility,
+			&i.Draws,
+			&i.Skips,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listCompanyRankChanges = `-- name: ListCompanyRankChanges :many
+SELECT c.id AS company_id, c.name, c.slug, c.elo_rating,
+       ranked.rank::int AS rank, COALESCE(w.rank, 0)::int AS previous_rank
+FROM (
+  SELECT id, RANK() OVER (ORDER BY elo_rating DESC) AS rank FROM companies
+) ranked
+JOIN companies c ON c.id = ranked.id
+LEFT JOIN webhook_company_ranks w ON w.company_id = c.id
+WHERE w.rank IS DISTINCT FROM ranked.rank
+ORDER BY c.id
+`
+
+type ListCompanyRankChangesRow struct {
+	CompanyID    int32  `json:"company_id"`
+	Name         string `json:"name"`
+	Slug         string `json:"slug"`
+	EloRating    int32  `json:"elo_rating"`
+	Rank         int32  `json:"rank"`
+	PreviousRank int32  `json:"previous_rank"`
+}
+
+// Lists the companies whose current rank differs from the rank last
+// announced to webhooks. Companies never announced have previous rank 0.
+func (q *Queries) ListCompanyRankChanges(ctx context.Context) ([]ListCompanyRankChangesRow, error) {
+	rows, err := q.db.Query(ctx, listCompanyRankChanges)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListCompanyRankChangesRow{}
+	for rows.Next() {
+		var i ListCompanyRankChangesRow
+		if err := rows.Scan(
+			&i.CompanyID,
+			&i.Name,
+			&i.Slug,
+			&i.EloRating,
+			&i.Rank,
+			&i.PreviousRank,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listCompanyRatingStates = `-- name: ListCompanyRatingStates :many
+SELECT id, name, slug, rating, rating_deviation, rating_volatility,
+       wins, losses, draws, skips, total_votes, created_at
 FROM companies
-WHERE slug = $1
+ORDER BY id
 `
 
-func (q *Queries) GetCompanyBySlug(ctx context.Context, slug string) (Company, error) {
-	row := q.db.QueryRow(ctx, getCompanyBySlug, slug)
-	var i Company
-	err := row.Scan(
-		&i.ID,
-		&i.Name,
-		&i.Slug,
-		&i.LogoUrl,
-		&i.Description,
-		&i.Website,
-		&i.Category,
-		&i.Tags,
-		&i.FoundedYear,
-		&i.HqLocation,
-		&i.EmployeeRange,
-		&i.FundingStage,
-		&i.EloRating,
-		&i.TotalVotes,
-		&i.Wins,
-		&i.Losses,
-		&i.CreatedAt,
-		&i.UpdatedAt,
-	)
-	return i, err
+type ListCompanyRatingStatesRow struct {
+	ID               int32              `json:"id"`
+	Name             string             `json:"name"`
+	Slug             string             `json:"slug"`
+	Rating           float64            `json:"rating"`
+	RatingDeviation  float64            `json:"rating_deviation"`
+	RatingVolatility float64            `json:"rating_volatility"`
+	Wins             int32              `json:"wins"`
+	Losses           int32              `json:"losses"`
+	Draws            int32              `json:"draws"`
+	Skips            int32              `json:"skips"`
+	TotalVotes       int32              `json:"total_votes"`
+	CreatedAt        pgtype.Timestamptz `json:"created_at"`
+}
+
+func (q *Queries) ListCompanyRatingStates(ctx context.Context) ([]ListCompanyRatingStatesRow, error) {
+	rows, err := q.db.Query(ctx, listCompanyRatingStates)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListCompanyRatingStatesRow{}
+	for rows.Next() {
+		var i ListCompanyRatingStatesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Slug,
+			&i.Rating,
+			&i.RatingDeviation,
+			&i.RatingVolatility,
+			&i.Wins,
+			&i.Losses,
+			&i.Draws,
+			&i.Skips,
+			&i.TotalVotes,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listDecayCandidates = `-- name: ListDecayCandidates :many
+SELECT c.id
+FROM companies c
+WHERE c.created_at < $1
+  AND NOT EXISTS (
+    SELECT 1 FROM votes v
+    WHERE v.status = 'counted' AND v.outcome <> 'skip'
+      AND (v.winner_id = c.id OR v.loser_id = c.id)
+      AND v.created_at >= $1
+  )
+  AND NOT EXISTS (
+    SELECT 1 FROM rating_decays d
+    WHERE d.company_id = c.id AND d.created_at >= $2
+  )
+ORDER BY c.id
+`
+
+type ListDecayCandidatesParams struct {
+	InactiveSince pgtype.Timestamptz `json:"inactive_since"`
+	DecayedBefore pgtype.Timestamptz `json:"decayed_before"`
+}
+
+// Companies without a counted vote since inactive_since that have not
+// decayed since decayed_before.
+func (q *Queries) ListDecayCandidates(ctx context.Context, arg ListDecayCandidatesParams) ([]int32, error) {
+	rows, err := q.db.Query(ctx, listDecayCandidates, arg.InactiveSince, arg.DecayedBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []int32{}
+	for rows.Next() {
+		var id int32
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFraudFlags = `-- name: ListFraudFlags :many
+SELECT id, kind, session_id, company_id, details, vote_count, window_start, window_end,
+       status, review_note, created_at, updated_at, reviewed_at
+FROM fraud_flags
+WHERE $1::text IS NULL OR status = $1
+ORDER BY created_at DESC, id DESC
+LIMIT $3 OFFSET $2
+`
+
+type ListFraudFlagsParams struct {
+	Status     *string `json:"status"`
+	PageOffset int32   `json:"page_offset"`
+	PageLimit  int32   `json:"page_limit"`
+}
+
+func (q *Queries) ListFraudFlags(ctx context.Context, arg ListFraudFlagsParams) ([]FraudFlag, error) {
+	rows, err := q.db.Query(ctx, listFraudFlags, arg.Status, arg.PageOffset, arg.PageLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []FraudFlag{}
+	for rows.Next() {
+		var i FraudFlag
+		if err := rows.Scan(
+			&i.ID,
+			&i.Kind,
+			&i.SessionID,
+			&i.CompanyID,
+			&i.Details,
+			&i.VoteCount,
+			&i.WindowStart,
+			&i.WindowEnd,
+			&i.Status,
+			&i.ReviewNote,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.ReviewedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listHeadToHeadVotes = `-- name: ListHeadToHeadVotes :many
+SELECT id, winner_id, loser_id, outcome, created_at
+FROM votes
+WHERE status = 'counted' AND outcome <> 'skip'
+  AND LEAST(winner_id, loser_id) = LEAST($1::int, $2::int)
+  AND GREATEST(winner_id, loser_id) = GREATEST($1::int, $2::int)
+ORDER BY created_at DESC
+LIMIT $3
+`
+
+type ListHeadToHeadVotesParams struct {
+	CompanyA  int32 `json:"company_a"`
+	CompanyB  int32 `json:"company_b"`
+	PageLimit int32 `json:"page_limit"`
+}
+
+type ListHeadToHeadVotesRow struct {
+	ID        int32              `json:"id"`
+	WinnerID  int32              `json:"winner_id"`
+	LoserID   int32              `json:"loser_id"`
+	Outcome   string             `json:"outcome"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+func (q *Queries) ListHeadToHeadVotes(ctx context.Context, arg ListHeadToHeadVotesParams) ([]ListHeadToHeadVotesRow, error) {
+	rows, err := q.db.Query(ctx, listHeadToHeadVotes, arg.CompanyA, arg.CompanyB, arg.PageLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListHeadToHeadVotesRow{}
+	for rows.Next() {
+		var i ListHeadToHeadVotesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.WinnerID,
+			&i.LoserID,
+			&i.Outcome,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listMatchupCandidates = `-- name: ListMatchupCandidates :many
+SELECT id, rating, rating_deviation, rating_volatility, total_votes
+FROM companies
+`
+
+type ListMatchupCandidatesRow struct {
+	ID               int32   `json:"id"`
+	Rating           float64 `json:"rating"`
+	RatingDeviation  float64 `json:"rating_deviation"`
+	RatingVolatility float64 `json:"rating_volatility"`
+	TotalVotes       int32   `json:"total_votes"`
+}
+
+func (q *Queries) ListMatchupCandidates(ctx context.Context) ([]ListMatchupCandidatesRow, error) {
+	rows, err := q.db.Query(ctx, listMatchupCandidates)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListMatchupCandidatesRow{}
+	for rows.Next() {
+		var i ListMatchupCandidatesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Rating,
+			&i.RatingDeviation,
+			&i.RatingVolatility,
+			&i.TotalVotes,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listOutboxEventsAfter = `-- name: ListOutboxEventsAfter :many
+SELECT id, aggregate_type, aggregate_id, kind, payload, created_at, position
+FROM outbox_events
+WHERE (position, id) > ($1::bigint, $2::bigint)
+  AND position < pg_snapshot_xmin(pg_current_snapshot())::text::bigint
+ORDER BY position, id
+LIMIT $3
+`
+
+type ListOutboxEventsAfterParams struct {
+	AfterPosition int64 `json:"after_position"`
+	AfterID       int64 `json:"after_id"`
+	PageLimit     int32 `json:"page_limit"`
+}
+
+// The events after the given position and id, in delivery order. Only
+// positions below the oldest running transaction are read: every event
+// committed later is positioned at or above it, so none is skipped.
+func (q *Queries) ListOutboxEventsAfter(ctx context.Context, arg ListOutboxEventsAfterParams) ([]OutboxEvent, error) {
+	rows, err := q.db.Query(ctx, listOutboxEventsAfter, arg.AfterPosition, arg.AfterID, arg.PageLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []OutboxEvent{}
+	for rows.Next() {
+		var i OutboxEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.AggregateType,
+			&i.AggregateID,
+			&i.Kind,
+			&i.Payload,
+			&i.CreatedAt,
+			&i.Position,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listRatingDecaysForReplay = `-- name: ListRatingDecaysForReplay :many
+SELECT id, company_id, rate, deviation_increase, created_at
+FROM rating_decays
+ORDER BY created_at, id
+`
+
+func (q *Queries) ListRatingDecaysForReplay(ctx context.Context) ([]RatingDecay, error) {
+	rows, err := q.db.Query(ctx, listRatingDecaysForReplay)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []RatingDecay{}
+	for rows.Next() {
+		var i RatingDecay
+		if err := rows.Scan(
+			&i.ID,
+			&i.CompanyID,
+			&i.Rate,
+			&i.DeviationIncrease,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listRivals = `-- name: ListRivals :many
+SELECT (CASE WHEN winner_id = $1::int THEN loser_id ELSE winner_id END)::int AS opponent_id,
+       COUNT(*) AS matchups,
+       COUNT(*) FILTER (WHERE outcome = 'win' AND winner_id = $1::int) AS wins,
+       COUNT(*) FILTER (WHERE outcome = 'win' AND loser_id = $1::int) AS losses,
+       COUNT(*) FILTER (WHERE outcome = 'draw') AS draws,
+       MAX(created_at)::timestamptz AS last_met_at
+FROM votes
+WHERE status = 'counted' AND outcome <> 'skip'
+  AND (winner_id = $1::int OR loser_id = $1::int)
+GROUP BY 1
+ORDER BY matchups DESC, last_met_at DESC
+LIMIT $2
+`
+
+type ListRivalsParams struct {
+	CompanyID int32 `json:"company_id"`
+	PageLimit int32 `json:"page_limit"`
+}
+
+type ListRivalsRow struct {
+	OpponentID int32              `json:"opponent_id"`
+	Matchups   int64              `json:"matchups"`
+	Wins       int64              `json:"wins"`
+	Losses     int64              `json:"losses"`
+	Draws      int64              `json:"draws"`
+	LastMetAt  pgtype.Timestamptz `json:"last_met_at"`
+}
+
+// Opponents a company met most often in counted votes, from its side.
+func (q *Queries) ListRivals(ctx context.Context, arg ListRivalsParams) ([]ListRivalsRow, error) {
+	rows, err := q.db.Query(ctx, listRivals, arg.CompanyID, arg.PageLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListRivalsRow{}
+	for rows.Next() {
+		var i ListRivalsRow
+		if err := rows.Scan(
+			&i.OpponentID,
+			&i.Matchups,
+			&i.Wins,
+			&i.Losses,
+			&i.Draws,
+			&i.LastMetAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUndatedVotesForReplay = `-- name: ListUndatedVotesForReplay :many
+SELECT id, winner_id, loser_id, outcome, strength, category, created_at
+FROM votes
+WHERE status = 'counted' AND created_at IS NULL AND id > $1::int
+ORDER BY id
+LIMIT $2
+`
+
+type ListUndatedVotesForReplayParams struct {
+	AfterID   int32 `json:"after_id"`
+	PageLimit int32 `json:"page_limit"`
+}
+
+type ListUndatedVotesForReplayRow struct {
+	ID        int32              `json:"id"`
+	WinnerID  int32              `json:"winner_id"`
+	LoserID   int32              `json:"loser_id"`
+	Outcome   string             `json:"outcome"`
+	Strength  string             `json:"strength"`
+	Category  *string            `json:"category"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+// A page of the counted votes without a timestamp after after_id, which
+// replay after every dated vote.
+func (q *Queries) ListUndatedVotesForReplay(ctx context.Context, arg ListUndatedVotesForReplayParams) ([]ListUndatedVotesForReplayRow, error) {
+	rows, err := q.db.Query(ctx, listUndatedVotesForReplay, arg.AfterID, arg.PageLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListUndatedVotesForReplayRow{}
+	for rows.Next() {
+		var i ListUndatedVotesForReplayRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.WinnerID,
+			&i.LoserID,
+			&i.Outcome,
+			&i.Strength,
+			&i.Category,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listVoteRatingDeltaVoteIDs = `-- name: ListVoteRatingDeltaVoteIDs :many
+SELECT DISTINCT vote_id FROM vote_rating_deltas ORDER BY vote_id
+`
+
+// Lists the votes whose rating changes are kept for undo.
+func (q *Queries) ListVoteRatingDeltaVoteIDs(ctx context.Context) ([]int32, error) {
+	rows, err := q.db.Query(ctx, listVoteRatingDeltaVoteIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []int32{}
+	for rows.Next() {
+		var vote_id int32
+		if err := rows.Scan(&vote_id); err != nil {
+			return nil, err
+		}
+		items = append(items, vote_id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
-const getCompanyComments = `-- name: GetCompanyComments :many
-SELECT id, company_id, content, is_current_employee, session_id, upvotes, created_at
-FROM company_comments
-WHERE company_id = $1
-ORDER BY upvotes DESC, created_at DESC
-LIMIT 100
+const listVoteRatingDeltas = `-- name: ListVoteRatingDeltas :many
+SELECT vote_id, company_id, category, won, rating_delta, deviation_delta, volatility_delta, created_at
+FROM vote_rating_deltas
+WHERE vote_id = $1
 `
 
-func (q *Queries) GetCompanyComments(ctx context.Context, companyID int32) ([]CompanyComment, error) {
-	rows, err := q.db.Query(ctx, getCompanyComments, companyID)
+func (q *Queries) ListVoteRatingDeltas(ctx context.Context, voteID int32) ([]VoteRatingDelta, error) {
+	rows, err := q.db.Query(ctx, listVoteRatingDeltas, voteID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	items := []CompanyComment{}
+	items := []VoteRatingDelta{}
 	for rows.Next() {
-		var i CompanyComment
+		var i VoteRatingDelta
 		if err := rows.Scan(
-			&i.ID,
+			&i.VoteID,
 			&i.CompanyID,
-			&i.Content,
-			&i.IsCurrentEmployee,
-			&i.SessionID,
-			&i.Upvotes,
+			&i.Category,
+			&i.Won,
+			&i.RatingDelta,
+			&i.DeviationDelta,
+			&i.VolatilityDelta,
 			&i.CreatedAt,
 		); err != nil {
 			return nil, err
@@ -363,81 +2793,51 @@ func (q *Queries) GetCompanyComments(ctx context.Context, companyID int32) ([]Co
 	return items, nil
 }
 
-const getCompanyEloRating = `-- name: GetCompanyEloRating :one
-SELECT elo_rating FROM companies WHERE id = $1
-`
-
-func (q *Queries) GetCompanyEloRating(ctx context.Context, id int32) (int32, error) {
-	row := q.db.QueryRow(ctx, getCompanyEloRating, id)
-	var elo_rating int32
-	err := row.Scan(&elo_rating)
-	return elo_rating, err
-}
-
-const getCompanyIDBySlug = `-- name: GetCompanyIDBySlug :one
-SELECT id FROM companies WHERE slug = $1
-`
-
-func (q *Queries) GetCompanyIDBySlug(ctx context.Context, slug string) (int32, error) {
-	row := q.db.QueryRow(ctx, getCompanyIDBySlug, slug)
-	var id int32
-	err := row.Scan(&id)
-	return id, err
-}
-
-const getCompanyRank = `-- name: GetCompanyRank :one
-SELECT COUNT(*) + 1 FROM companies WHERE elo_rating > $1
+const listVotesForReplay = `-- name: ListVotesForReplay :many
+SELECT id, winner_id, loser_id, outcome, strength, category, created_at
+FROM votes
+WHERE status = 'counted' AND created_at >= $1
+  AND (created_at, id) > ($1, $2::int)
+ORDER BY created_at, id
+LIMIT $3
 `
 
-func (q *Queries) GetCompanyRank(ctx context.Context, eloRating int32) (int32, error) {
-	row := q.db.QueryRow(ctx, getCompanyRank, eloRating)
-	var column_1 int32
-	err := row.Scan(&column_1)
-	return column_1, err
+type ListVotesForReplayParams struct {
+	AfterCreatedAt pgtype.Timestamptz `json:"after_created_at"`
+	AfterID        int32              `json:"after_id"`
+	PageLimit      int32              `json:"page_limit"`
 }
 
-const getLeaderboard = `-- name: GetLeaderboard :many
-SELECT id, name, slug, logo_url, description, website, category, tags,
-       founded_year, hq_location, employee_range, funding_stage,
-       elo_rating, total_votes, wins, losses, created_at, updated_at
-FROM companies
-ORDER BY elo_rating DESC, total_votes DESC
-LIMIT $1 OFFSET $2
-`
-
-type GetLeaderboardParams struct {
-	Limit  int32 `json:"limit"`
-	Offset int32 `json:"offset"`
+type ListVotesForReplayRow struct {
+	ID        int32              `json:"id"`
+	WinnerID  int32              `json:"winner_id"`
+	LoserID   int32              `json:"loser_id"`
+	Outcome   string             `json:"outcome"`
+	Strength  string             `json:"strength"`
+	Category  *string            `json:"category"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
 }
 
-func (q *Queries) GetLeaderboard(ctx context.Context, arg GetLeaderboardParams) ([]Company, error) {
-	rows, err := q.db.Query(ctx, getLeaderboard, arg.Limit, arg.Offset)
+// A page of the counted votes in replay order, after the vote at
+// after_created_at and after_id. Votes without a timestamp are listed by
+// ListUndatedVotesForReplay.
+func (q *Queries) ListVotesForReplay(ctx context.Context, arg ListVotesForReplayParams) ([]ListVotesForReplayRow, error) {
+	rows, err := q.db.Query(ctx, listVotesForReplay, arg.AfterCreatedAt, arg.AfterID, arg.PageLimit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	items := []Company{}
+	items := []ListVotesForReplayRow{}
 	for rows.Next() {
-		var i Company
+		var i ListVotesForReplayRow
 		if err := rows.Scan(
 			&i.ID,
-			&i.Name,
-			&i.Slug,
-			&i.LogoUrl,
-			&i.Description,
-			&i.Website,
+			&i.WinnerID,
+			&i.LoserID,
+			&i.Outcome,
+			&i.Strength,
 			&i.Category,
-			&i.Tags,
-			&i.FoundedYear,
-			&i.HqLocation,
-			&i.EmployeeRange,
-			&i.FundingStage,
-			&i.EloRating,
-			&i.TotalVotes,
-			&i.Wins,
-			&i.Losses,
 			&i.CreatedAt,
-			&i.UpdatedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -449,50 +2849,51 @@ func (q *Queries) GetLeaderboard(ctx context.Context, arg GetLeaderboardParams)
 	return items, nil
 }
 
-const getLeaderboardByCategory = `-- name: GetLeaderboardByCategory :many
-SELECT id, name, slug, logo_url, description, website, category, tags,
-       founded_year, hq_location, employee_range, funding_stage,
-       elo_rating, total_votes, wins, losses, created_at, updated_at
-FROM companies
-WHERE category = $1
-ORDER BY elo_rating DESC, total_votes DESC
-LIMIT $2 OFFSET $3
+const listWebhookDeliveries = `-- name: ListWebhookDeliveries :many
+SELECT id, subscription_id, event_id, event_type, payload, status, attempts,
+       next_attempt_at, last_attempt_at, response_status, last_error, created_at, delivered_at
+FROM webhook_deliveries
+WHERE subscription_id = $1
+  AND ($2::text IS NULL OR status = $2)
+ORDER BY id DESC
+LIMIT $4 OFFSET $3
 `
 
-type GetLeaderboardByCategoryParams struct {
-	Category string `json:"category"`
-	Limit    int32  `json:"limit"`
-	Offset   int32  `json:"offset"`
+type ListWebhookDeliveriesParams struct {
+	SubscriptionID int32   `json:"subscription_id"`
+	Status         *string `json:"status"`
+	PageOffset     int32   `json:"page_offset"`
+	PageLimit      int32   `json:"page_limit"`
 }
 
-func (q *Queries) GetLeaderboardByCategory(ctx context.Context, arg GetLeaderboardByCategoryParams) ([]Company, error) {
-	rows, err := q.db.Query(ctx, getLeaderboardByCategory, arg.Category, arg.Limit, arg.Offset)
+func (q *Queries) ListWebhookDeliveries(ctx context.Context, arg ListWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.Query(ctx, listWebhookDeliveries,
+		arg.SubscriptionID,
+		arg.Status,
+		arg.PageOffset,
+		arg.PageLimit,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	items := []Company{}
+	items := []WebhookDelivery{}
 	for rows.Next() {
-		var i Company
+		var i WebhookDelivery
 		if err := rows.Scan(
 			&i.ID,
-			&i.Name,
-			&i.Slug,
-			&i.LogoUrl,
-			&i.Description,
-			&i.Website,
-			&i.Category,
-			&i.Tags,
-			&i.FoundedYear,
-			&i.HqLocation,
-			&i.EmployeeRange,
-			&i.FundingStage,
-			&i.EloRating,
-			&i.TotalVotes,
-			&i.Wins,
-			&i.Losses,
+			&i.SubscriptionID,
+			&i.EventID,
+			&i.EventType,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttemptAt,
+			&i.LastAttemptAt,
+			&i.ResponseStatus,
+			&i.LastError,
 			&i.CreatedAt,
-			&i.UpdatedAt,
+			&i.DeliveredAt,
 		); err != nil {
 			return nil, err
 		}
@@ -504,41 +2905,30 @@ func (q *Queries) GetLeaderboardByCategory(ctx context.Context, arg GetLeaderboa
 	return items, nil
 }
 
-const getRandomMatchup = `-- name: GetRandomMatchup :many
-SELECT id, name, slug, logo_url, description, website, category, tags,
-       founded_year, hq_location, employee_range, funding_stage,
-       elo_rating, total_votes, wins, losses, created_at, updated_at
-FROM companies
-ORDER BY RANDOM()
-LIMIT 2
+const listWebhookSubscriptions = `-- name: ListWebhookSubscriptions :many
+SELECT id, url, secret, previous_secret, previous_secret_expires_at, event_types,
+       description, created_at, updated_at
+FROM webhook_subscriptions
+ORDER BY id
 `
 
-func (q *Queries) GetRandomMatchup(ctx context.Context) ([]Company, error) {
-	rows, err := q.db.Query(ctx, getRandomMatchup)
+func (q *Queries) ListWebhookSubscriptions(ctx context.Context) ([]WebhookSubscription, error) {
+	rows, err := q.db.Query(ctx, listWebhookSubscriptions)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	items := []Company{}
+	items := []WebhookSubscription{}
 	for rows.Next() {
-		var i Company
+		var i WebhookSubscription
 		if err := rows.Scan(
 			&i.ID,
-			&i.Name,
-			&i.Slug,
-			&i.LogoUrl,
+			&i.Url,
+			&i.Secret,
+			&i.PreviousSecret,
+			&i.PreviousSecretExpiresAt,
+			&i.EventTypes,
 			&i.Description,
-			&i.Website,
-			&i.Category,
-			&i.Tags,
-			&i.FoundedYear,
-			&i.HqLocation,
-			&i.EmployeeRange,
-			&i.FundingStage,
-			&i.EloRating,
-			&i.TotalVotes,
-			&i.Wins,
-			&i.Losses,
 			&i.CreatedAt,
 			&i.UpdatedAt,
 		); err != nil {
@@ -552,43 +2942,40 @@ func (q *Queries) GetRandomMatchup(ctx context.Context) ([]Company, error) {
 	return items, nil
 }
 
-const getRandomMatchupByCategory = `-- name: GetRandomMatchupByCategory :many
-SELECT id, name, slug, logo_url, description, website, category, tags,
-       founded_year, hq_location, employee_range, funding_stage,
-       elo_rating, total_votes, wins, losses, created_at, updated_at
-FROM companies
-WHERE category = $1
-ORDER BY RANDOM()
-LIMIT 2
+const lockCategoryRatingsForUpdate = `-- name: LockCategoryRatingsForUpdate :many
+SELECT company_id, category, rating, elo_rating, rating_deviation, rating_volatility,
+       total_votes, wins, losses, updated_at
+FROM company_category_ratings
+WHERE category = $1 AND company_id = ANY($2::int[])
+ORDER BY company_id
+FOR UPDATE
 `
 
-func (q *Queries) GetRandomMatchupByCategory(ctx context.Context, category string) ([]Company, error) {
-	rows, err := q.db.Query(ctx, getRandomMatchupByCategory, category)
+type LockCategoryRatingsForUpdateParams struct {
+	Category   string  `json:"category"`
+	CompanyIds []int32 `json:"company_ids"`
+}
+
+// Same lock ordering as LockCompaniesForUpdate; must be called after it.
+func (q *Queries) LockCategoryRatingsForUpdate(ctx context.Context, arg LockCategoryRatingsForUpdateParams) ([]CompanyCategoryRating, error) {
+	rows, err := q.db.Query(ctx, lockCategoryRatingsForUpdate, arg.Category, arg.CompanyIds)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	items := []Company{}
+	items := []CompanyCategoryRating{}
 	for rows.Next() {
-		var i Company
+		var i CompanyCategoryRating
 		if err := rows.Scan(
-			&i.ID,
-			&i.Name,
-			&i.Slug,
-			&i.LogoUrl,
-			&i.Description,
-			&i.Website,
+			&i.CompanyID,
 			&i.Category,
-			&i.Tags,
-			&i.FoundedYear,
-			&i.HqLocation,
-			&i.EmployeeRange,
-			&i.FundingStage,
+			&i.Rating,
 			&i.EloRating,
+			&i.RatingDeviation,
+			&i.RatingVolatility,
 			&i.TotalVotes,
 			&i.Wins,
 			&i.Losses,
-			&i.CreatedAt,
 			&i.UpdatedAt,
 		); err != nil {
 			return nil, err
@@ -601,35 +2988,51 @@ func (q *Queries) GetRandomMatchupByCategory(ctx context.Context, category strin
 	return items, nil
 }
 
-const getUserLeaderboard = `-- name: GetUserLeaderboard :many
-SELECT user_id, COUNT(*) as total_votes
-FROM votes
-WHERE user_id IS NOT NULL AND user_id != ''
-GROUP BY user_id
-ORDER BY total_votes DESC
-LIMIT $1 OFFSET $2
+const lockCompaniesExclusive = `-- name: LockCompaniesExclusive :exec
+LOCK TABLE companies IN EXCLUSIVE MODE
 `
 
-type GetUserLeaderboardParams struct {
-	Limit  int32 `json:"limit"`
-	Offset int32 `json:"offset"`
+// Blocks concurrent votes (which take row locks on companies) until the
+// surrounding transaction ends.
+func (q *Queries) LockCompaniesExclusive(ctx context.Context) error {
+	_, err := q.db.Exec(ctx, lockCompaniesExclusive)
+	return err
 }
 
-type GetUserLeaderboardRow struct {
-	UserID     *string `json:"user_id"`
-	TotalVotes int64   `json:"total_votes"`
+const lockCompaniesForUpdate = `-- name: LockCompaniesForUpdate :many
+SELECT id, category, rating, rating_deviation, rating_volatility
+FROM companies
+WHERE id = ANY($1::int[])
+ORDER BY id
+FOR UPDATE
+`
+
+type LockCompaniesForUpdateRow struct {
+	ID               int32   `json:"id"`
+	Category         string  `json:"category"`
+	Rating           float64 `json:"rating"`
+	RatingDeviation  float64 `json:"rating_deviation"`
+	RatingVolatility float64 `json:"rating_volatility"`
 }
 
-func (q *Queries) GetUserLeaderboard(ctx context.Context, arg GetUserLeaderboardParams) ([]GetUserLeaderboardRow, error) {
-	rows, err := q.db.Query(ctx, getUserLeaderboard, arg.Limit, arg.Offset)
+// Locks the given companies in ascending id order so that concurrent
+// transactions touching the same rows always acquire locks in the same order.
+func (q *Queries) LockCompaniesForUpdate(ctx context.Context, ids []int32) ([]LockCompaniesForUpdateRow, error) {
+	rows, err := q.db.Query(ctx, lockCompaniesForUpdate, ids)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	items := []GetUserLeaderboardRow{}
+	items := []LockCompaniesForUpdateRow{}
 	for rows.Next() {
-		var i GetUserLeaderboardRow
-		if err := rows.Scan(&i.UserID, &i.TotalVotes); err != nil {
+		var i LockCompaniesForUpdateRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Category,
+			&i.Rating,
+			&i.RatingDeviation,
+			&i.RatingVolatility,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -640,105 +3043,413 @@ func (q *Queries) GetUserLeaderboard(ctx context.Context, arg GetUserLeaderboard
 	return items, nil
 }
 
-const listCompanies = `-- name: ListCompanies :many
-SELECT id, name, slug, logo_url, description, website, category, tags,
-       founded_year, hq_location, employee_range, funding_stage,
-       elo_rating, total_votes, wins, losses, created_at, updated_at
-FROM companies
-ORDER BY elo_rating DESC, total_votes DESC
+const lockCompanyActivity = `-- name: LockCompanyActivity :exec
+LOCK TABLE company_activity_hourly IN EXCLUSIVE MODE
+`
+
+// Blocks other rollups until the surrounding transaction ends.
+func (q *Queries) LockCompanyActivity(ctx context.Context) error {
+	_, err := q.db.Exec(ctx, lockCompanyActivity)
+	return err
+}
+
+const lockOutboxOffset = `-- name: LockOutboxOffset :one
+SELECT last_position, last_event_id FROM outbox_offsets
+WHERE sink = $1
+FOR UPDATE SKIP LOCKED
+`
+
+type LockOutboxOffsetRow struct {
+	LastPosition int64 `json:"last_position"`
+	LastEventID  int64 `json:"last_event_id"`
+}
+
+// Returns nothing while another instance is delivering to the sink.
+func (q *Queries) LockOutboxOffset(ctx context.Context, sink string) (LockOutboxOffsetRow, error) {
+	row := q.db.QueryRow(ctx, lockOutboxOffset, sink)
+	var i LockOutboxOffsetRow
+	err := row.Scan(&i.LastPosition, &i.LastEventID)
+	return i, err
+}
+
+const lockRatingDecays = `-- name: LockRatingDecays :exec
+LOCK TABLE rating_decays IN EXCLUSIVE MODE
+`
+
+// Blocks other decay runs until the surrounding transaction ends, so two
+// runs do not both decay the same company.
+func (q *Queries) LockRatingDecays(ctx context.Context) error {
+	_, err := q.db.Exec(ctx, lockRatingDecays)
+	return err
+}
+
+const notifyEvent = `-- name: NotifyEvent :exec
+SELECT pg_notify($1::text, $2::text)
+`
+
+type NotifyEventParams struct {
+	Channel string `json:"channel"`
+	Payload string `json:"payload"`
+}
+
+// Sends payload to every connection listening on channel once the
+// surrounding transaction commits.
+func (q *Queries) NotifyEvent(ctx context.Context, arg NotifyEventParams) error {
+	_, err := q.db.Exec(ctx, notifyEvent, arg.Channel, arg.Payload)
+	return err
+}
+
+const recordCompanySkips = `-- name: RecordCompanySkips :exec
+UPDATE companies SET skips = skips + 1, updated_at = NOW()
+WHERE id = ANY($1::int[])
+`
+
+// Records that a matchup between the given companies was skipped. Skips
+// change no rating and are not part of total_votes.
+func (q *Queries) RecordCompanySkips(ctx context.Context, ids []int32) error {
+	_, err := q.db.Exec(ctx, recordCompanySkips, ids)
+	return err
+}
+
+const recordPairVote = `-- name: RecordPairVote :execrows
+INSERT INTO voter_pair_votes AS p (voter, company_low, company_high, winner_id, voted_at)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (voter, company_low, company_high) DO UPDATE
+SET winner_id = EXCLUDED.winner_id, voted_at = EXCLUDED.voted_at
+WHERE p.voted_at <= $6
+`
+
+type RecordPairVoteParams struct {
+	Voter         string             `json:"voter"`
+	CompanyLow    int32              `json:"company_low"`
+	CompanyHigh   int32              `json:"company_high"`
+	WinnerID      *int32             `json:"winner_id"`
+	VotedAt       pgtype.Timestamptz `json:"voted_at"`
+	CooldownStart pgtype.Timestamptz `json:"cooldown_start"`
+}
+
+// Records a vote on an unordered pair unless the voter's last vote on the
+// pair was cast after cooldown_start. Returns 0 rows affected for repeats.
+func (q *Queries) RecordPairVote(ctx context.Context, arg RecordPairVoteParams) (int64, error) {
+	result, err := q.db.Exec(ctx, recordPairVote,
+		arg.Voter,
+		arg.CompanyLow,
+		arg.CompanyHigh,
+		arg.WinnerID,
+		arg.VotedAt,
+		arg.CooldownStart,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const recordRatingHistory = `-- name: RecordRatingHistory :exec
+INSERT INTO rating_history (company_id, vote_id, rating, elo_rating, rating_deviation,
+                            rank, wins, losses, total_votes)
+SELECT c.id, $1, c.rating, c.elo_rating, c.rating_deviation,
+       (SELECT COUNT(*) + 1 FROM companies r WHERE r.elo_rating > c.elo_rating),
+       c.wins, c.losses, c.total_votes
+FROM companies c
+WHERE c.id = ANY($2::int[])
+`
+
+type RecordRatingHistoryParams struct {
+	VoteID     *int32  `json:"vote_id"`
+	CompanyIds []int32 `json:"company_ids"`
+}
+
+// Appends the current state and rank of the given companies to their history.
+func (q *Queries) RecordRatingHistory(ctx context.Context, arg RecordRatingHistoryParams) error {
+	_, err := q.db.Exec(ctx, recordRatingHistory, arg.VoteID, arg.CompanyIds)
+	return err
+}
+
+const recordWebhookAttempt = `-- name: RecordWebhookAttempt :exec
+UPDATE webhook_deliveries
+SET status = $1, attempts = attempts + 1, last_attempt_at = NOW(),
+    next_attempt_at = $2,
+    response_status = $3, last_error = $4,
+    delivered_at = CASE WHEN $1 = 'delivered' THEN NOW() END
+WHERE id = $5
+`
+
+type RecordWebhookAttemptParams struct {
+	Status         string             `json:"status"`
+	NextAttemptAt  pgtype.Timestamptz `json:"next_attempt_at"`
+	ResponseStatus *int32             `json:"response_status"`
+	LastError      *string            `json:"last_error"`
+	ID             int64              `json:"id"`
+}
+
+func (q *Queries) RecordWebhookAttempt(ctx context.Context, arg RecordWebhookAttemptParams) error {
+	_, err := q.db.Exec(ctx, recordWebhookAttempt,
+		arg.Status,
+		arg.NextAttemptAt,
+		arg.ResponseStatus,
+		arg.LastError,
+		arg.ID,
+	)
+	return err
+}
+
+const resetCategoryRatings = `-- name: ResetCategoryRatings :exec
+UPDATE company_category_ratings
+SET rating = 1500, elo_rating = 1500, rating_deviation = 350, rating_volatility = 0.06,
+    total_votes = 0, wins = 0, losses = 0, updated_at = NOW()
+`
+
+func (q *Queries) ResetCategoryRatings(ctx context.Context) error {
+	_, err := q.db.Exec(ctx, resetCategoryRatings)
+	return err
+}
+
+const revertCategoryRating = `-- name: RevertCategoryRating :exec
+UPDATE company_category_ratings
+SET rating = rating - $1::float8, elo_rating = ROUND(rating - $1::float8)::int,
+    rating_deviation = rating_deviation - $2::float8,
+    rating_volatility = rating_volatility - $3::float8,
+    total_votes = total_votes - 1,
+    wins = wins - CASE WHEN $4::bool THEN 1 ELSE 0 END,
+    losses = losses - CASE WHEN $4::bool OR $5::bool THEN 0 ELSE 1 END,
+    updated_at = NOW()
+WHERE company_id = $6 AND category = $7
+`
+
+type RevertCategoryRatingParams struct {
+	RatingDelta     float64 `json:"rating_delta"`
+	DeviationDelta  float64 `json:"deviation_delta"`
+	VolatilityDelta float64 `json:"volatility_delta"`
+	Won             bool    `json:"won"`
+	Drawn           bool    `json:"drawn"`
+	CompanyID       int32   `json:"company_id"`
+	Category        string  `json:"category"`
+}
+
+func (q *Queries) RevertCategoryRating(ctx context.Context, arg RevertCategoryRatingParams) error {
+	_, err := q.db.Exec(ctx, revertCategoryRating,
+		arg.RatingDelta,
+		arg.DeviationDelta,
+		arg.VolatilityDelta,
+		arg.Won,
+		arg.Drawn,
+		arg.CompanyID,
+		arg.Category,
+	)
+	return err
+}
+
+const revertCompanyRating = `-- name: RevertCompanyRating :exec
+UPDATE companies
+SET rating = rating - $1::float8, elo_rating = ROUND(rating - $1::float8)::int,
+    rating_deviation = rating_deviation - $2::float8,
+    rating_volatility = rating_volatility - $3::float8,
+    total_votes = total_votes - 1,
+    wins = wins - CASE WHEN $4::bool THEN 1 ELSE 0 END,
+    losses = losses - CASE WHEN $4::bool OR $5::bool THEN 0 ELSE 1 END,
+    draws = draws - CASE WHEN $5::bool THEN 1 ELSE 0 END,
+    updated_at = NOW()
+WHERE id = $6
+`
+
+type RevertCompanyRatingParams struct {
+	RatingDelta     float64 `json:"rating_delta"`
+	DeviationDelta  float64 `json:"deviation_delta"`
+	VolatilityDelta float64 `json:"volatility_delta"`
+	Won             bool    `json:"won"`
+	Drawn           bool    `json:"drawn"`
+	ID              int32   `json:"id"`
+}
+
+// Subtracts a vote's rating delta from the company and takes the vote off
+// its record.
+func (q *Queries) RevertCompanyRating(ctx context.Context, arg RevertCompanyRatingParams) error {
+	_, err := q.db.Exec(ctx, revertCompanyRating,
+		arg.RatingDelta,
+		arg.DeviationDelta,
+		arg.VolatilityDelta,
+		arg.Won,
+		arg.Drawn,
+		arg.ID,
+	)
+	return err
+}
+
+const revertCompanySkips = `-- name: RevertCompanySkips :exec
+UPDATE companies SET skips = skips - 1, updated_at = NOW()
+WHERE id = ANY($1::int[])
+`
+
+func (q *Queries) RevertCompanySkips(ctx context.Context, ids []int32) error {
+	_, err := q.db.Exec(ctx, revertCompanySkips, ids)
+	return err
+}
+
+const reviewFraudFlag = `-- name: ReviewFraudFlag :one
+UPDATE fraud_flags
+SET status = $2, review_note = $3, reviewed_at = NOW(), updated_at = NOW()
+WHERE id = $1
+RETURNING id, kind, session_id, company_id, details, vote_count, window_start, window_end,
+          status, review_note, created_at, updated_at, reviewed_at
+`
+
+type ReviewFraudFlagParams struct {
+	ID         int32   `json:"id"`
+	Status     string  `json:"status"`
+	ReviewNote *string `json:"review_note"`
+}
+
+func (q *Queries) ReviewFraudFlag(ctx context.Context, arg ReviewFraudFlagParams) (FraudFlag, error) {
+	row := q.db.QueryRow(ctx, reviewFraudFlag, arg.ID, arg.Status, arg.ReviewNote)
+	var i FraudFlag
+	err := row.Scan(
+		&i.ID,
+		&i.Kind,
+		&i.SessionID,
+		&i.CompanyID,
+		&i.Details,
+		&i.VoteCount,
+		&i.WindowStart,
+		&i.WindowEnd,
+		&i.Status,
+		&i.ReviewNote,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.ReviewedAt,
+	)
+	return i, err
+}
+
+const revokeFraudFlagSessions = `-- name: RevokeFraudFlagSessions :execrows
+UPDATE sessions SET revoked_at = NOW()
+WHERE revoked_at IS NULL AND id IN (
+    SELECT v.session_id
+    FROM fraud_flag_votes fv
+    JOIN votes v ON v.id = fv.vote_id
+    WHERE fv.flag_id = $1
+)
 `
 
-func (q *Queries) ListCompanies(ctx context.Context) ([]Company, error) {
-	rows, err := q.db.Query(ctx, listCompanies)
+// Revokes every session that cast one of the flag's votes.
+func (q *Queries) RevokeFraudFlagSessions(ctx context.Context, flagID int32) (int64, error) {
+	result, err := q.db.Exec(ctx, revokeFraudFlagSessions, flagID)
 	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	items := []Company{}
-	for rows.Next() {
-		var i Company
-		if err := rows.Scan(
-			&i.ID,
-			&i.Name,
-			&i.Slug,
-			&i.LogoUrl,
-			&i.Description,
-			&i.Website,
-			&i.Category,
-			&i.Tags,
-			&i.FoundedYear,
-			&i.HqLocation,
-			&i.EmployeeRange,
-			&i.FundingStage,
-			&i.EloRating,
-			&i.TotalVotes,
-			&i.Wins,
-			&i.Losses,
-			&i.CreatedAt,
-			&i.UpdatedAt,
-		); err != nil {
-			return nil, err
-		}
-		items = append(items, i)
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
+		return 0, err
 	}
-	return items, nil
+	return result.RowsAffected(), nil
 }
 
-const listCompaniesByCategory = `-- name: ListCompaniesByCategory :many
-SELECT id, name, slug, logo_url, description, website, category, tags,
-       founded_year, hq_location, employee_range, funding_stage,
-       elo_rating, total_votes, wins, losses, created_at, updated_at
-FROM companies
-WHERE category = $1
-ORDER BY elo_rating DESC, total_votes DESC
+const revokeSession = `-- name: RevokeSession :execrows
+UPDATE sessions SET revoked_at = NOW()
+WHERE id = $1 AND revoked_at IS NULL
 `
 
-func (q *Queries) ListCompaniesByCategory(ctx context.Context, category string) ([]Company, error) {
-	rows, err := q.db.Query(ctx, listCompaniesByCategory, category)
+func (q *Queries) RevokeSession(ctx context.Context, id string) (int64, error) {
+	result, err := q.db.Exec(ctx, revokeSession, id)
 	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	items := []Company{}
-	for rows.Next() {
-		var i Company
-		if err := rows.Scan(
-			&i.ID,
-			&i.Name,
-			&i.Slug,
-			&i.LogoUrl,
-			&i.Description,
-			&i.Website,
-			&i.Category,
-			&i.Tags,
-			&i.FoundedYear,
-			&i.HqLocation,
-			&i.EmployeeRange,
-			&i.FundingStage,
-			&i.EloRating,
-			&i.TotalVotes,
-			&i.Wins,
-			&i.Losses,
-			&i.CreatedAt,
-			&i.UpdatedAt,
-		); err != nil {
-			return nil, err
-		}
-		items = append(items, i)
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
+		return 0, err
 	}
-	return items, nil
+	return result.RowsAffected(), nil
+}
+
+const rollUpCompanyActivity = `-- name: RollUpCompanyActivity :exec
+INSERT INTO company_activity_hourly (company_id, hour, wins, losses, draws, rating_change)
+SELECT company_id, hour, SUM(wins)::int, SUM(losses)::int, SUM(draws)::int, SUM(rating_change)::float8
+FROM (
+  SELECT v.winner_id AS company_id,
+         date_bin('1 hour', v.created_at, TIMESTAMPTZ '2000-01-01 00:00:00+00') AS hour,
+         COUNT(*) FILTER (WHERE v.outcome = 'win') AS wins,
+         0 AS losses,
+         COUNT(*) FILTER (WHERE v.outcome = 'draw') AS draws,
+         0::float8 AS rating_change
+  FROM votes v
+  WHERE v.status = 'counted' AND v.outcome <> 'skip' AND v.created_at >= $1
+  GROUP BY 1, 2
+  UNION ALL
+  SELECT v.loser_id,
+         date_bin('1 hour', v.created_at, TIMESTAMPTZ '2000-01-01 00:00:00+00'),
+         0,
+         COUNT(*) FILTER (WHERE v.outcome = 'win'),
+         COUNT(*) FILTER (WHERE v.outcome = 'draw'),
+         0::float8
+  FROM votes v
+  WHERE v.status = 'counted' AND v.outcome <> 'skip' AND v.created_at >= $1
+  GROUP BY 1, 2
+  UNION ALL
+  SELECT h.company_id,
+         date_bin('1 hour', h.created_at, TIMESTAMPTZ '2000-01-01 00:00:00+00'),
+         0, 0, 0,
+         SUM(h.rating - COALESCE(h.previous_rating, h.rating))
+  FROM (
+    SELECT company_id, created_at, rating,
+           LAG(rating) OVER (PARTITION BY company_id ORDER BY created_at, id) AS previous_rating
+    FROM rating_history
+    WHERE created_at >= $1
+       OR id IN (
+         SELECT last.id
+         FROM companies c
+         CROSS JOIN LATERAL (
+           SELECT rh.id FROM rating_history rh
+           WHERE rh.company_id = c.id AND rh.created_at < $1
+           ORDER BY rh.created_at DESC, rh.id DESC
+           LIMIT 1
+         ) last
+       )
+  ) h
+  WHERE h.created_at >= $1
+  GROUP BY 1, 2
+) activity
+GROUP BY company_id, hour
+`
+
+// Aggregates counted votes and rating history from since, which must be on
+// the hour, into hourly buckets. The buckets from since on must have been
+// deleted first. A rating change is measured from the company's previous
+// history row, which may lie before since.
+func (q *Queries) RollUpCompanyActivity(ctx context.Context, since pgtype.Timestamptz) error {
+	_, err := q.db.Exec(ctx, rollUpCompanyActivity, since)
+	return err
+}
+
+const rotateWebhookSecret = `-- name: RotateWebhookSecret :one
+UPDATE webhook_subscriptions
+SET previous_secret = secret, previous_secret_expires_at = $1,
+    secret = $2, updated_at = NOW()
+WHERE id = $3
+RETURNING id, url, secret, previous_secret, previous_secret_expires_at, event_types,
+          description, created_at, updated_at
+`
+
+type RotateWebhookSecretParams struct {
+	PreviousSecretExpiresAt pgtype.Timestamptz `json:"previous_secret_expires_at"`
+	Secret                  string             `json:"secret"`
+	ID                      int32              `json:"id"`
+}
+
+// Replaces the secret, keeping the old one valid until previous_secret_expires_at.
+func (q *Queries) RotateWebhookSecret(ctx context.Context, arg RotateWebhookSecretParams) (WebhookSubscription, error) {
+	row := q.db.QueryRow(ctx, rotateWebhookSecret, arg.PreviousSecretExpiresAt, arg.Secret, arg.ID)
+	var i WebhookSubscription
+	err := row.Scan(
+		&i.ID,
+		&i.Url,
+		&i.Secret,
+		&i.PreviousSecret,
+		&i.PreviousSecretExpiresAt,
+		&i.EventTypes,
+		&i.Description,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
 }
 
 const searchCompanies = `-- name: SearchCompanies :many
 SELECT id, name, slug, logo_url, description, website, category, tags,
        founded_year, hq_location, employee_range, funding_stage,
-       elo_rating, total_votes, wins, losses, created_at, updated_at
+       elo_rating, total_votes, wins, losses, created_at, updated_at,
+       rating, rating_deviation, rating_volatility, draws, skips
 FROM companies
 WHERE LOWER(name) LIKE $1 OR LOWER(description) LIKE $1
 ORDER BY elo_rating DESC, total_votes DESC
@@ -772,6 +3483,11 @@ func (q *Queries) SearchCompanies(ctx context.Context, name string) ([]Company,
 			&i.Losses,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.Rating,
+			&i.RatingDeviation,
+			&i.RatingVolatility,
+			&i.Draws,
+			&i.Skips,
 		); err != nil {
 			return nil, err
 		}
@@ -786,7 +3502,8 @@ func (q *Queries) SearchCompanies(ctx context.Context, name string) ([]Company,
 const searchCompaniesByCategory = `-- name: SearchCompaniesByCategory :many
 SELECT id, name, slug, logo_url, description, website, category, tags,
        founded_year, hq_location, employee_range, funding_stage,
-       elo_rating, total_votes, wins, losses, created_at, updated_at
+       elo_rating, total_votes, wins, losses, created_at, updated_at,
+       rating, rating_deviation, rating_volatility, draws, skips
 FROM companies
 WHERE category = $1 AND (LOWER(name) LIKE $2 OR LOWER(description) LIKE $2)
 ORDER BY elo_rating DESC, total_votes DESC
@@ -825,6 +3542,11 @@ func (q *Queries) SearchCompaniesByCategory(ctx context.Context, arg SearchCompa
 			&i.Losses,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.Rating,
+			&i.RatingDeviation,
+			&i.RatingVolatility,
+			&i.Draws,
+			&i.Skips,
 		); err != nil {
 			return nil, err
 		}
@@ -836,43 +3558,443 @@ func (q *Queries) SearchCompaniesByCategory(ctx context.Context, arg SearchCompa
 	return items, nil
 }
 
+const setCategoryRatingState = `-- name: SetCategoryRatingState :exec
+INSERT INTO company_category_ratings (company_id, category, rating, elo_rating,
+                                      rating_deviation, rating_volatility,
+                                      wins, losses, total_votes)
+VALUES ($1, $2, $3::float8, ROUND($3::float8)::int,
+        $4, $5, $6, $7, $8)
+ON CONFLICT (company_id, category) DO UPDATE
+SET rating = EXCLUDED.rating, elo_rating = EXCLUDED.elo_rating,
+    rating_deviation = EXCLUDED.rating_deviation, rating_volatility = EXCLUDED.rating_volatility,
+    wins = EXCLUDED.wins, losses = EXCLUDED.losses, total_votes = EXCLUDED.total_votes,
+    updated_at = NOW()
+`
+
+type SetCategoryRatingStateParams struct {
+	CompanyID        int32   `json:"company_id"`
+	Category         string  `json:"category"`
+	Rating           float64 `json:"rating"`
+	RatingDeviation  float64 `json:"rating_deviation"`
+	RatingVolatility float64 `json:"rating_volatility"`
+	Wins             int32   `json:"wins"`
+	Losses           int32   `json:"losses"`
+	TotalVotes       int32   `json:"total_votes"`
+}
+
+func (q *Queries) SetCategoryRatingState(ctx context.Context, arg SetCategoryRatingStateParams) error {
+	_, err := q.db.Exec(ctx, setCategoryRatingState,
+		arg.CompanyID,
+		arg.Category,
+		arg.Rating,
+		arg.RatingDeviation,
+		arg.RatingVolatility,
+		arg.Wins,
+		arg.Losses,
+		arg.TotalVotes,
+	)
+	return err
+}
+
+const setCompanyRatingState = `-- name: SetCompanyRatingState :exec
+UPDATE companies
+SET rating = $1::float8, elo_rating = ROUND($1::float8)::int,
+    rating_deviation = $2, rating_volatility = $3,
+    wins = $4, losses = $5, draws = $6, skips = $7,
+    total_votes = $8, updated_at = NOW()
+WHERE id = $9
+`
+
+type SetCompanyRatingStateParams struct {
+	Rating           float64 `json:"rating"`
+	RatingDeviation  float64 `json:"rating_deviation"`
+	RatingVolatility float64 `json:"rating_volatility"`
+	Wins             int32   `json:"wins"`
+	Losses           int32   `json:"losses"`
+	Draws            int32   `json:"draws"`
+	Skips            int32   `json:"skips"`
+	TotalVotes       int32   `json:"total_votes"`
+	ID               int32   `json:"id"`
+}
+
+func (q *Queries) SetCompanyRatingState(ctx context.Context, arg SetCompanyRatingStateParams) error {
+	_, err := q.db.Exec(ctx, setCompanyRatingState,
+		arg.Rating,
+		arg.RatingDeviation,
+		arg.RatingVolatility,
+		arg.Wins,
+		arg.Losses,
+		arg.Draws,
+		arg.Skips,
+		arg.TotalVotes,
+		arg.ID,
+	)
+	return err
+}
+
+const setOutboxOffset = `-- name: SetOutboxOffset :exec
+INSERT INTO outbox_offsets (sink, last_position, last_event_id)
+VALUES ($1, $2, $3)
+ON CONFLICT (sink) DO UPDATE
+SET last_position = EXCLUDED.last_position, last_event_id = EXCLUDED.last_event_id,
+    updated_at = NOW()
+`
+
+type SetOutboxOffsetParams struct {
+	Sink         string `json:"sink"`
+	LastPosition int64  `json:"last_position"`
+	LastEventID  int64  `json:"last_event_id"`
+}
+
+func (q *Queries) SetOutboxOffset(ctx context.Context, arg SetOutboxOffsetParams) error {
+	_, err := q.db.Exec(ctx, setOutboxOffset, arg.Sink, arg.LastPosition, arg.LastEventID)
+	return err
+}
+
+const setSetting = `-- name: SetSetting :exec
+INSERT INTO settings (key, value)
+VALUES ($1, $2)
+ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updated_at = NOW()
+`
+
+type SetSettingParams struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+func (q *Queries) SetSetting(ctx context.Context, arg SetSettingParams) error {
+	_, err := q.db.Exec(ctx, setSetting, arg.Key, arg.Value)
+	return err
+}
+
+const setVoteRatingDelta = `-- name: SetVoteRatingDelta :exec
+UPDATE vote_rating_deltas
+SET rating_delta = $1, deviation_delta = $2,
+    volatility_delta = $3
+WHERE vote_id = $4 AND company_id = $5 AND category = $6
+`
+
+type SetVoteRatingDeltaParams struct {
+	RatingDelta     float64 `json:"rating_delta"`
+	DeviationDelta  float64 `json:"deviation_delta"`
+	VolatilityDelta float64 `json:"volatility_delta"`
+	VoteID          int32   `json:"vote_id"`
+	CompanyID       int32   `json:"company_id"`
+	Category        string  `json:"category"`
+}
+
+// Replaces what a vote changed, as a recompute replayed it.
+func (q *Queries) SetVoteRatingDelta(ctx context.Context, arg SetVoteRatingDeltaParams) error {
+	_, err := q.db.Exec(ctx, setVoteRatingDelta,
+		arg.RatingDelta,
+		arg.DeviationDelta,
+		arg.VolatilityDelta,
+		arg.VoteID,
+		arg.CompanyID,
+		arg.Category,
+	)
+	return err
+}
+
+const setVoteStatus = `-- name: SetVoteStatus :exec
+UPDATE votes SET status = $2 WHERE id = $1
+`
+
+type SetVoteStatusParams struct {
+	ID     int32  `json:"id"`
+	Status string `json:"status"`
+}
+
+func (q *Queries) SetVoteStatus(ctx context.Context, arg SetVoteStatusParams) error {
+	_, err := q.db.Exec(ctx, setVoteStatus, arg.ID, arg.Status)
+	return err
+}
+
+const setWebhookCompanyRanks = `-- name: SetWebhookCompanyRanks :exec
+INSERT INTO webhook_company_ranks (company_id, rank)
+SELECT unnest($1::int[]), unnest($2::int[])
+ON CONFLICT (company_id) DO UPDATE SET rank = EXCLUDED.rank, updated_at = NOW()
+`
+
+type SetWebhookCompanyRanksParams struct {
+	CompanyIds []int32 `json:"company_ids"`
+	Ranks      []int32 `json:"ranks"`
+}
+
+func (q *Queries) SetWebhookCompanyRanks(ctx context.Context, arg SetWebhookCompanyRanksParams) error {
+	_, err := q.db.Exec(ctx, setWebhookCompanyRanks, arg.CompanyIds, arg.Ranks)
+	return err
+}
+
+const snapshotRatingsForDay = `-- name: SnapshotRatingsForDay :exec
+INSERT INTO rating_snapshots (company_id, day, rating, elo_rating, rating_deviation,
+                              rank, wins, losses, total_votes)
+SELECT h.company_id, $1::date, h.rating, h.elo_rating, h.rating_deviation,
+       RANK() OVER (ORDER BY h.elo_rating DESC),
+       h.wins, h.losses, h.total_votes
+FROM companies c
+CROSS JOIN LATERAL (
+    SELECT rh.company_id, rh.rating, rh.elo_rating, rh.rating_deviation, rh.wins, rh.losses, rh.total_votes
+    FROM rating_history rh
+    WHERE rh.company_id = c.id AND rh.created_at < $1::date + 1
+    ORDER BY rh.created_at DESC, rh.id DESC
+    LIMIT 1
+) h
+ON CONFLICT (company_id, day) DO UPDATE
+SET rating = EXCLUDED.rating, elo_rating = EXCLUDED.elo_rating,
+    rating_deviation = EXCLUDED.rating_deviation, rank = EXCLUDED.rank,
+    wins = EXCLUDED.wins, losses = EXCLUDED.losses, total_votes = EXCLUDED.total_votes
+`
+
+// Stores each company's last recorded state on or before the given day,
+// ranked against every other company at that point in time.
+func (q *Queries) SnapshotRatingsForDay(ctx context.Context, day pgtype.Date) error {
+	_, err := q.db.Exec(ctx, snapshotRatingsForDay, day)
+	return err
+}
+
+const takeRateLimitToken = `-- name: TakeRateLimitToken :one
+INSERT INTO rate_limit_buckets AS b (key, tokens, updated_at)
+VALUES ($1, $2::double precision - 1, NOW())
+ON CONFLICT (key) DO UPDATE
+SET tokens = LEAST($2::double precision, b.tokens + EXTRACT(EPOCH FROM NOW() - b.updated_at)::double precision * $3::double precision) - 1,
+    updated_at = NOW()
+WHERE LEAST($2::double precision, b.tokens + EXTRACT(EPOCH FROM NOW() - b.updated_at)::double precision * $3::double precision) >= 1
+RETURNING tokens
+`
+
+type TakeRateLimitTokenParams struct {
+	Key   string  `json:"key"`
+	Burst float64 `json:"burst"`
+	Rate  float64 `json:"rate"`
+}
+
+// Refills the bucket for the time since it was last updated and takes one
+// token. Returns no rows when the bucket holds less than one token.
+func (q *Queries) TakeRateLimitToken(ctx context.Context, arg TakeRateLimitTokenParams) (float64, error) {
+	row := q.db.QueryRow(ctx, takeRateLimitToken, arg.Key, arg.Burst, arg.Rate)
+	var tokens float64
+	err := row.Scan(&tokens)
+	return tokens, err
+}
+
+const updateCategoryRatingAfterDraw = `-- name: UpdateCategoryRatingAfterDraw :exec
+UPDATE company_category_ratings
+SET rating = $1::float8, elo_rating = ROUND($1::float8)::int,
+    rating_deviation = $2, rating_volatility = $3,
+    total_votes = total_votes + 1, updated_at = NOW()
+WHERE company_id = $4 AND category = $5
+`
+
+type UpdateCategoryRatingAfterDrawParams struct {
+	Rating           float64 `json:"rating"`
+	RatingDeviation  float64 `json:"rating_deviation"`
+	RatingVolatility float64 `json:"rating_volatility"`
+	CompanyID        int32   `json:"company_id"`
+	Category         string  `json:"category"`
+}
+
+func (q *Queries) UpdateCategoryRatingAfterDraw(ctx context.Context, arg UpdateCategoryRatingAfterDrawParams) error {
+	_, err := q.db.Exec(ctx, updateCategoryRatingAfterDraw,
+		arg.Rating,
+		arg.RatingDeviation,
+		arg.RatingVolatility,
+		arg.CompanyID,
+		arg.Category,
+	)
+	return err
+}
+
+const updateCategoryRatingAfterLoss = `-- name: UpdateCategoryRatingAfterLoss :exec
+UPDATE company_category_ratings
+SET rating = $1::float8, elo_rating = ROUND($1::float8)::int,
+    rating_deviation = $2, rating_volatility = $3,
+    total_votes = total_votes + 1, losses = losses + 1, updated_at = NOW()
+WHERE company_id = $4 AND category = $5
+`
+
+type UpdateCategoryRatingAfterLossParams struct {
+	Rating           float64 `json:"rating"`
+	RatingDeviation  float64 `json:"rating_deviation"`
+	RatingVolatility float64 `json:"rating_volatility"`
+	CompanyID        int32   `json:"company_id"`
+	Category         string  `json:"category"`
+}
+
+func (q *Queries) UpdateCategoryRatingAfterLoss(ctx context.Context, arg UpdateCategoryRatingAfterLossParams) error {
+	_, err := q.db.Exec(ctx, updateCategoryRatingAfterLoss,
+		arg.Rating,
+		arg.RatingDeviation,
+		arg.RatingVolatility,
+		arg.CompanyID,
+		arg.Category,
+	)
+	return err
+}
+
+const updateCategoryRatingAfterWin = `-- name: UpdateCategoryRatingAfterWin :exec
+UPDATE company_category_ratings
+SET rating = $1::float8, elo_rating = ROUND($1::float8)::int,
+    rating_deviation = $2, rating_volatility = $3,
+    total_votes = total_votes + 1, wins = wins + 1, updated_at = NOW()
+WHERE company_id = $4 AND category = $5
+`
+
+type UpdateCategoryRatingAfterWinParams struct {
+	Rating           float64 `json:"rating"`
+	RatingDeviation  float64 `json:"rating_deviation"`
+	RatingVolatility float64 `json:"rating_volatility"`
+	CompanyID        int32   `json:"company_id"`
+	Category         string  `json:"category"`
+}
+
+func (q *Queries) UpdateCategoryRatingAfterWin(ctx context.Context, arg UpdateCategoryRatingAfterWinParams) error {
+	_, err := q.db.Exec(ctx, updateCategoryRatingAfterWin,
+		arg.Rating,
+		arg.RatingDeviation,
+		arg.RatingVolatility,
+		arg.CompanyID,
+		arg.Category,
+	)
+	return err
+}
+
+const updateCompanyAfterDraw = `-- name: UpdateCompanyAfterDraw :exec
+UPDATE companies
+SET rating = $1::float8, elo_rating = ROUND($1::float8)::int,
+    rating_deviation = $2, rating_volatility = $3,
+    total_votes = total_votes + 1, draws = draws + 1, updated_at = NOW()
+WHERE id = $4
+`
+
+type UpdateCompanyAfterDrawParams struct {
+	Rating           float64 `json:"rating"`
+	RatingDeviation  float64 `json:"rating_deviation"`
+	RatingVolatility float64 `json:"rating_volatility"`
+	ID               int32   `json:"id"`
+}
+
+func (q *Queries) UpdateCompanyAfterDraw(ctx context.Context, arg UpdateCompanyAfterDrawParams) error {
+	_, err := q.db.Exec(ctx, updateCompanyAfterDraw,
+		arg.Rating,
+		arg.RatingDeviation,
+		arg.RatingVolatility,
+		arg.ID,
+	)
+	return err
+}
+
 const updateCompanyAfterLoss = `-- name: UpdateCompanyAfterLoss :exec
 UPDATE companies 
-SET elo_rating = $2, total_votes = total_votes + 1, losses = losses + 1, updated_at = NOW()
-WHERE id = $1
+SET rating = $1::float8, elo_rating = ROUND($1::float8)::int,
+    rating_deviation = $2, rating_volatility = $3,
+    total_votes = total_votes + 1, losses = losses + 1, updated_at = NOW()
+WHERE id = $4
 `
 
 type UpdateCompanyAfterLossParams struct {
-	ID        int32 `json:"id"`
-	EloRating int32 `json:"elo_rating"`
+	Rating           float64 `json:"rating"`
+	RatingDeviation  float64 `json:"rating_deviation"`
+	RatingVolatility float64 `json:"rating_volatility"`
+	ID               int32   `json:"id"`
 }
 
 func (q *Queries) UpdateCompanyAfterLoss(ctx context.Context, arg UpdateCompanyAfterLossParams) error {
-	_, err := q.db.Exec(ctx, updateCompanyAfterLoss, arg.ID, arg.EloRating)
+	_, err := q.db.Exec(ctx, updateCompanyAfterLoss,
+		arg.Rating,
+		arg.RatingDeviation,
+		arg.RatingVolatility,
+		arg.ID,
+	)
 	return err
 }
 
 const updateCompanyAfterWin = `-- name: UpdateCompanyAfterWin :exec
 UPDATE companies 
-SET elo_rating = $2, total_votes = total_votes + 1, wins = wins + 1, updated_at = NOW()
-WHERE id = $1
+SET rating = $1::float8, elo_rating = ROUND($1::float8)::int,
+    rating_deviation = $2, rating_volatility = $3,
+    total_votes = total_votes + 1, wins = wins + 1, updated_at = NOW()
+WHERE id = $4
 `
 
 type UpdateCompanyAfterWinParams struct {
-	ID        int32 `json:"id"`
-	EloRating int32 `json:"elo_rating"`
+	Rating           float64 `json:"rating"`
+	RatingDeviation  float64 `json:"rating_deviation"`
+	RatingVolatility float64 `json:"rating_volatility"`
+	ID               int32   `json:"id"`
 }
 
 func (q *Queries) UpdateCompanyAfterWin(ctx context.Context, arg UpdateCompanyAfterWinParams) error {
-	_, err := q.db.Exec(ctx, updateCompanyAfterWin, arg.ID, arg.EloRating)
+	_, err := q.db.Exec(ctx, updateCompanyAfterWin,
+		arg.Rating,
+		arg.RatingDeviation,
+		arg.RatingVolatility,
+		arg.ID,
+	)
 	return err
 }
 
+const updateUserProfile = `-- name: UpdateUserProfile :one
+UPDATE users
+SET name = $1, avatar_url = $2, updated_at = NOW()
+WHERE id = $3
+RETURNING id, name, email, created_at, subject, avatar_url, updated_at
+`
+
+type UpdateUserProfileParams struct {
+	Name      string  `json:"name"`
+	AvatarUrl *string `json:"avatar_url"`
+	ID        int32   `json:"id"`
+}
+
+func (q *Queries) UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) (User, error) {
+	row := q.db.QueryRow(ctx, updateUserProfile, arg.Name, arg.AvatarUrl, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Email,
+		&i.CreatedAt,
+		&i.Subject,
+		&i.AvatarUrl,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const upsertOpenFraudFlag = `-- name: UpsertOpenFraudFlag :one
+INSERT INTO fraud_flags (kind, session_id, company_id, details)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (kind, COALESCE(session_id, ''), COALESCE(company_id, 0)) WHERE status = 'open'
+DO UPDATE SET details = EXCLUDED.details, updated_at = NOW()
+RETURNING id
+`
+
+type UpsertOpenFraudFlagParams struct {
+	Kind      string  `json:"kind"`
+	SessionID *string `json:"session_id"`
+	CompanyID *int32  `json:"company_id"`
+	Details   string  `json:"details"`
+}
+
+// Returns the open flag of the kind for the subject, creating it if needed.
+func (q *Queries) UpsertOpenFraudFlag(ctx context.Context, arg UpsertOpenFraudFlagParams) (int32, error) {
+	row := q.db.QueryRow(ctx, upsertOpenFraudFlag,
+		arg.Kind,
+		arg.SessionID,
+		arg.CompanyID,
+		arg.Details,
+	)
+	var id int32
+	err := row.Scan(&id)
+	return id, err
+}
+
 const upvoteComment = `-- name: UpvoteComment :one
 UPDATE company_comments
 SET upvotes = upvotes + 1
 WHERE id = $1
-RETURNING id, company_id, content, is_current_employee, session_id, upvotes, created_at
+RETURNING id, company_id, content, is_current_employee, session_id, upvotes, created_at, user_id
 `
 
 func (q *Queries) UpvoteComment(ctx context.Context, id int32) (CompanyComment, error) {
@@ -886,6 +4008,38 @@ func (q *Queries) UpvoteComment(ctx context.Context, id int32) (CompanyComment,
 		&i.SessionID,
 		&i.Upvotes,
 		&i.CreatedAt,
+		&i.UserID,
 	)
 	return i, err
 }
+
+const useMatchupToken = `-- name: UseMatchupToken :execrows
+INSERT INTO used_matchup_tokens (id, expires_at)
+VALUES ($1, $2)
+ON CONFLICT (id) DO NOTHING
+`
+
+type UseMatchupTokenParams struct {
+	ID        string             `json:"id"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+}
+
+// Returns 0 rows affected if the token has already been used.
+func (q *Queries) UseMatchupToken(ctx context.Context, arg UseMatchupTokenParams) (int64, error) {
+	result, err := q.db.Exec(ctx, useMatchupToken, arg.ID, arg.ExpiresAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const webhookSubscriptionExists = `-- name: WebhookSubscriptionExists :one
+SELECT EXISTS(SELECT 1 FROM webhook_subscriptions WHERE id = $1)
+`
+
+func (q *Queries) WebhookSubscriptionExists(ctx context.Context, id int32) (bool, error) {
+	row := q.db.QueryRow(ctx, webhookSubscriptionExists, id)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
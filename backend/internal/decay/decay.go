@@ -0,0 +1,183 @@
+// Package decay pulls the ratings of companies that have stopped getting
+// votes back toward the default rating, so a rating earned early does not
+// stand unchallenged forever.
+package decay
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/cloutdotgg/backend/internal/db/sqlc"
+	"github.com/cloutdotgg/backend/internal/events"
+	"github.com/cloutdotgg/backend/internal/outbox"
+	"github.com/cloutdotgg/backend/internal/rating"
+)
+
+// Config controls when and how much ratings decay.
+type Config struct {
+	// InactiveAfter is how long a company must go without a counted vote
+	// before its rating decays. Zero disables decay.
+	InactiveAfter time.Duration
+	// Interval is how often an inactive company decays again.
+	Interval time.Duration
+	// Rate is the fraction of the distance to the default rating removed by
+	// each decay.
+	Rate float64
+	// DeviationIncrease is added to the rating deviation by each decay, up
+	// to the deviation of an unrated company.
+	DeviationIncrease float64
+}
+
+// DefaultConfig returns settings that slowly fade companies that have gone
+// unvoted for a quarter.
+func DefaultConfig() Config {
+	return Config{
+		InactiveAfter:     90 * 24 * time.Hour,
+		Interval:          7 * 24 * time.Hour,
+		Rate:              0.05,
+		DeviationIncrease: 15,
+	}
+}
+
+// Enabled reports whether cfg changes any rating.
+func (cfg Config) Enabled() bool {
+	return cfg.InactiveAfter > 0 && cfg.Interval > 0 && (cfg.Rate > 0 || cfg.DeviationIncrease > 0)
+}
+
+// ParseConfig parses an inactivity threshold such as "2160h", a decay rate
+// between 0 and 1 and a deviation increase in rating points. Empty values
+// select the defaults.
+func ParseConfig(inactiveAfter, rate, deviationIncrease string) (Config, error) {
+	cfg := DefaultConfig()
+
+	if inactiveAfter = strings.TrimSpace(inactiveAfter); inactiveAfter != "" {
+		d, err := time.ParseDuration(inactiveAfter)
+		if err != nil || d < 0 {
+			return Config{}, fmt.Errorf("invalid decay inactivity threshold %q", inactiveAfter)
+		}
+		cfg.InactiveAfter = d
+	}
+
+	if rate = strings.TrimSpace(rate); rate != "" {
+		r, err := strconv.ParseFloat(rate, 64)
+		if err != nil || r < 0 || r > 1 {
+			return Config{}, fmt.Errorf("invalid decay rate %q (want a number from 0 to 1)", rate)
+		}
+		cfg.Rate = r
+	}
+
+	if deviationIncrease = strings.TrimSpace(deviationIncrease); deviationIncrease != "" {
+		d, err := strconv.ParseFloat(deviationIncrease, 64)
+		if err != nil || d < 0 {
+			return Config{}, fmt.Errorf("invalid decay deviation increase %q", deviationIncrease)
+		}
+		cfg.DeviationIncrease = d
+	}
+	return cfg, nil
+}
+
+// Run decays every company that has had no counted vote for
+// cfg.InactiveAfter and has not decayed within cfg.Interval, and returns how
+// many companies decayed. Each decay is stored with its parameters and
+// recorded in the rating history, so charts show it and recomputes replay
+// it, and announced through the outbox with the decays.
+func Run(ctx context.Context, pool *pgxpool.Pool, cfg Config, now time.Time) (int, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	q := sqlc.New(tx)
+
+	// Lock before listing, so a run waiting on another one sees its decays
+	// and does not repeat them
+	if err := q.LockRatingDecays(ctx); err != nil {
+		return 0, fmt.Errorf("failed to lock rating decays: %w", err)
+	}
+
+	candidates := sqlc.ListDecayCandidatesParams{
+		InactiveSince: pgtype.Timestamptz{Time: now.Add(-cfg.InactiveAfter), Valid: true},
+		DecayedBefore: pgtype.Timestamptz{Time: now.Add(-cfg.Interval), Valid: true},
+	}
+	ids, err := q.ListDecayCandidates(ctx, candidates)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list inactive companies: %w", err)
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	// Lock the companies like a vote does, so a vote cast meanwhile is not
+	// overwritten
+	locked, err := q.LockCompaniesForUpdate(ctx, ids)
+	if err != nil {
+		return 0, fmt.Errorf("failed to lock companies: %w", err)
+	}
+
+	// A vote committed between listing and locking makes its companies
+	// active again. Votes on the locked companies now wait for this run, so
+	// listing again settles which are still inactive.
+	ids, err = q.ListDecayCandidates(ctx, candidates)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list inactive companies: %w", err)
+	}
+	inactive := make(map[int32]bool, len(ids))
+	for _, id := range ids {
+		inactive[id] = true
+	}
+
+	var decayed []int32
+	for _, c := range locked {
+		if !inactive[c.ID] {
+			continue
+		}
+		before := rating.Rating{
+			Value:      c.Rating,
+			Deviation:  c.RatingDeviation,
+			Volatility: c.RatingVolatility,
+		}
+		after := rating.Decay(before, cfg.Rate, cfg.DeviationIncrease)
+		if after == before {
+			continue
+		}
+
+		if err := q.DecayCompanyRating(ctx, sqlc.DecayCompanyRatingParams{
+			Rating:          after.Value,
+			RatingDeviation: after.Deviation,
+			ID:              c.ID,
+		}); err != nil {
+			return 0, fmt.Errorf("failed to decay company %d: %w", c.ID, err)
+		}
+		if err := q.CreateRatingDecay(ctx, sqlc.CreateRatingDecayParams{
+			CompanyID:         c.ID,
+			Rate:              cfg.Rate,
+			DeviationIncrease: cfg.DeviationIncrease,
+		}); err != nil {
+			return 0, fmt.Errorf("failed to record decay of company %d: %w", c.ID, err)
+		}
+		decayed = append(decayed, c.ID)
+	}
+	if len(decayed) == 0 {
+		return 0, nil
+	}
+
+	if err := q.RecordRatingHistory(ctx, sqlc.RecordRatingHistoryParams{
+		CompanyIds: decayed,
+	}); err != nil {
+		return 0, fmt.Errorf("failed to record rating history: %w", err)
+	}
+	if err := outbox.Record(ctx, q, events.Event{Kind: events.KindRatingsChanged, CompanyIDs: decayed}); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit decay: %w", err)
+	}
+	return len(decayed), nil
+}
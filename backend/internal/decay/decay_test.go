@@ -0,0 +1,162 @@
+package decay
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/cloutdotgg/backend/internal/dbtest"
+)
+
+// inactiveCompanies returns n companies created long ago with a rating away
+// from the default, so that a decay run changes them.
+func inactiveCompanies(t *testing.T, pool *pgxpool.Pool, n int) []int32 {
+	t.Helper()
+	ctx := context.Background()
+	rows, err := pool.Query(ctx, "SELECT id FROM companies ORDER BY id LIMIT $1", n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int32
+	for rows.Next() {
+		var id int32
+		if err := rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) < n {
+		t.Fatalf("need %d companies, have %d", n, len(ids))
+	}
+
+	if _, err := pool.Exec(ctx, `UPDATE companies
+		SET rating = 1700, elo_rating = 1700, rating_deviation = 100,
+		    created_at = now() - interval '200 days'
+		WHERE id = ANY($1)`, ids); err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
+
+func countDecays(t *testing.T, pool *pgxpool.Pool) int {
+	t.Helper()
+	var n int
+	if err := pool.QueryRow(context.Background(), "SELECT count(*) FROM rating_decays").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestConcurrentRunsDecayOnce(t *testing.T) {
+	pool := dbtest.New(t)
+	ids := inactiveCompanies(t, pool, 3)
+	now := time.Now()
+
+	const runs = 4
+	type result struct {
+		decayed int
+		err     error
+	}
+	results := make(chan result, runs)
+	for i := 0; i < runs; i++ {
+		go func() {
+			decayed, err := Run(context.Background(), pool, DefaultConfig(), now)
+			results <- result{decayed, err}
+		}()
+	}
+	var total int
+	for i := 0; i < runs; i++ {
+		r := <-results
+		if r.err != nil {
+			t.Fatal(r.err)
+		}
+		total += r.decayed
+	}
+
+	if total != len(ids) {
+		t.Errorf("runs decayed %d companies in total, want %d", total, len(ids))
+	}
+	if n := countDecays(t, pool); n != len(ids) {
+		t.Errorf("recorded %d decays, want %d", n, len(ids))
+	}
+	var announced int
+	if err := pool.QueryRow(context.Background(),
+		"SELECT count(*) FROM outbox_events WHERE kind = 'ratings_changed'",
+	).Scan(&announced); err != nil {
+		t.Fatal(err)
+	}
+	if announced != 1 {
+		t.Errorf("recorded %d rating change events, want 1", announced)
+	}
+}
+
+func TestVoteBeforeLockCancelsDecay(t *testing.T) {
+	pool := dbtest.New(t)
+	ids := inactiveCompanies(t, pool, 2)
+	ctx := context.Background()
+
+	// Hold the companies the way a vote does while the run lists them
+	vote, err := pool.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vote.Rollback(ctx)
+	var pid int32
+	if err := vote.QueryRow(ctx, "SELECT pg_backend_pid()").Scan(&pid); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vote.Exec(ctx, "SELECT id FROM companies WHERE id = ANY($1) ORDER BY id FOR UPDATE", ids); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vote.Exec(ctx, "INSERT INTO votes (winner_id, loser_id) VALUES ($1, $2)", ids[0], ids[1]); err != nil {
+		t.Fatal(err)
+	}
+
+	type result struct {
+		decayed int
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		decayed, err := Run(ctx, pool, DefaultConfig(), time.Now())
+		done <- result{decayed, err}
+	}()
+
+	// Commit the vote once the run has listed the companies and is waiting
+	// for their locks
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		var waiting int
+		if err := pool.QueryRow(ctx,
+			"SELECT count(*) FROM pg_stat_activity WHERE $1::int = ANY(pg_blocking_pids(pid))", pid,
+		).Scan(&waiting); err != nil {
+			t.Fatal(err)
+		}
+		if waiting > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("decay run never waited for the vote")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := vote.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	r := <-done
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	if r.decayed != 0 {
+		t.Errorf("decayed %d companies that had just been voted on", r.decayed)
+	}
+	if n := countDecays(t, pool); n != 0 {
+		t.Errorf("recorded %d decays, want none", n)
+	}
+}
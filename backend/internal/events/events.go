@@ -0,0 +1,82 @@
+// Package events carries domain events, such as votes cast and comments
+// created, to every subscriber on every backend instance. Leaderboard
+// streams and other in-process state subscribe to a Bus instead of being
+// called by the code that changed the data.
+package events
+
+import (
+	"context"
+	"time"
+)
+
+// Kind says what happened.
+type Kind string
+
+const (
+	// KindVoteCast is a vote counted on a matchup. ID is the vote.
+	KindVoteCast Kind = "vote_cast"
+	// KindVoteUndone is a counted vote retracted by its voter. ID is the vote.
+	KindVoteUndone Kind = "vote_undone"
+	// KindRankingSubmitted is a ranking with at least one counted vote. ID
+	// is the ranking.
+	KindRankingSubmitted Kind = "ranking_submitted"
+	// KindRatingsChanged is a change to the ratings of many companies at
+	// once, such as a recompute, a fraud confirmation or a rating decay.
+	KindRatingsChanged Kind = "ratings_changed"
+	// KindCommentCreated is a comment posted on a company. ID is the comment.
+	KindCommentCreated Kind = "comment_created"
+	// KindCommentUpvoted is an upvote added to a comment. ID is the comment.
+	KindCommentUpvoted Kind = "comment_upvoted"
+	// KindRatingSubmitted is a 1–5 rating submitted for a company. ID is the
+	// company rating.
+	KindRatingSubmitted Kind = "rating_submitted"
+	// KindResync is published by a bus itself when it may have missed
+	// events, such as after losing its database connection. Subscribers
+	// should reload whatever state they derive from events.
+	KindResync Kind = "resync"
+)
+
+// Event is something that happened to one or more companies. Events are
+// published after the change they describe has been committed.
+type Event struct {
+	Kind Kind `json:"kind"`
+	// ID identifies the vote, ranking, comment or rating the event is
+	// about, as documented on its Kind. It is zero for other kinds.
+	ID int32 `json:"id,omitempty"`
+	// CompanyIDs lists the companies affected. It is empty when any
+	// company may have been, as after a recompute.
+	CompanyIDs []int32 `json:"company_ids,omitempty"`
+	// Category is the category a vote or ranking was cast in, if any.
+	Category string `json:"category,omitempty"`
+	// At is when the event was published.
+	At time.Time `json:"at"`
+}
+
+// ChangesRatings reports whether e may have changed company ratings, ranks
+// or records, or whether a subscriber may have missed such a change.
+func (e Event) ChangesRatings() bool {
+	switch e.Kind {
+	case KindVoteCast, KindVoteUndone, KindRankingSubmitted, KindRatingsChanged, KindResync:
+		return true
+	default:
+		return false
+	}
+}
+
+// Bus delivers events to every subscriber.
+type Bus interface {
+	// Publish announces e to the subscribers on every instance sharing the
+	// bus. At is set to the current time when zero.
+	Publish(ctx context.Context, e Event) error
+	// Subscribe starts a subscription to the events published after it. It
+	// must be closed when no longer read.
+	Subscribe() *Subscription
+}
+
+// stamp sets the publication time of e when the publisher did not.
+func stamp(e Event) Event {
+	if e.At.IsZero() {
+		e.At = time.Now()
+	}
+	return e
+}
@@ -0,0 +1,86 @@
+package events
+
+import (
+	"context"
+	"sync"
+)
+
+// DefaultBuffer is how many events a subscriber can fall behind by before
+// it is dropped.
+const DefaultBuffer = 64
+
+// Local is a Bus within a single process, for tests and for running one
+// instance. It also fans events out to the subscribers of a Postgres bus.
+//
+// Publish never blocks: a subscriber whose buffer is full is dropped and its
+// channel closed, so a slow consumer holds up neither the publisher nor
+// other subscribers.
+type Local struct {
+	buffer int
+
+	mu   sync.Mutex
+	subs map[*Subscription]struct{}
+}
+
+// NewLocal creates a bus whose subscribers buffer up to buffer events.
+func NewLocal(buffer int) *Local {
+	return &Local{
+		buffer: buffer,
+		subs:   make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscription receives the events published after it was created.
+type Subscription struct {
+	// C delivers the events. It is closed when the subscription is closed
+	// or dropped for falling behind; a dropped subscriber has missed events
+	// and should resynchronize.
+	C <-chan Event
+
+	c   chan Event
+	bus *Local
+}
+
+// Subscribe starts a subscription. It must be closed when no longer read.
+func (l *Local) Subscribe() *Subscription {
+	c := make(chan Event, l.buffer)
+	sub := &Subscription{C: c, c: c, bus: l}
+
+	l.mu.Lock()
+	l.subs[sub] = struct{}{}
+	l.mu.Unlock()
+	return sub
+}
+
+// Publish delivers e to every subscriber that has room for it and drops
+// the others. It never fails.
+func (l *Local) Publish(_ context.Context, e Event) error {
+	l.deliver(stamp(e))
+	return nil
+}
+
+func (l *Local) deliver(e Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for sub := range l.subs {
+		select {
+		case sub.c <- e:
+		default:
+			delete(l.subs, sub)
+			close(sub.c)
+		}
+	}
+}
+
+// Close ends the subscription. It is safe to call after the subscription
+// was dropped and more than once.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	if _, ok := s.bus.subs[s]; ok {
+		delete(s.bus.subs, s)
+		close(s.c)
+	}
+}
@@ -0,0 +1,49 @@
+package events
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLocalDeliversToEverySubscriber(t *testing.T) {
+	bus := NewLocal(DefaultBuffer)
+	first, second := bus.Subscribe(), bus.Subscribe()
+	defer first.Close()
+	defer second.Close()
+
+	if err := bus.Publish(context.Background(), Event{Kind: KindVoteCast, ID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	for _, sub := range []*Subscription{first, second} {
+		e := <-sub.C
+		if e.Kind != KindVoteCast || e.ID != 1 {
+			t.Fatalf("got %+v", e)
+		}
+	}
+}
+
+func TestLocalDropsSlowSubscriber(t *testing.T) {
+	bus := NewLocal(2)
+	slow, fast := bus.Subscribe(), bus.Subscribe()
+	defer fast.Close()
+
+	for id := int32(1); id <= 3; id++ {
+		if err := bus.Publish(context.Background(), Event{Kind: KindVoteCast, ID: id}); err != nil {
+			t.Fatal(err)
+		}
+		<-fast.C
+	}
+
+	// The slow subscriber keeps what it buffered, then sees its channel
+	// closed
+	var got []int32
+	for e := range slow.C {
+		got = append(got, e.ID)
+	}
+	if len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Fatalf("slow subscriber got %v, want [1 2]", got)
+	}
+	// Closing a dropped subscription is harmless
+	slow.Close()
+	slow.Close()
+}
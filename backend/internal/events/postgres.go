@@ -0,0 +1,119 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/cloutdotgg/backend/internal/db/sqlc"
+)
+
+// Channel is the Postgres notification channel events are sent on.
+const Channel = "clout_events"
+
+const (
+	minReconnectDelay = time.Second
+	maxReconnectDelay = 30 * time.Second
+)
+
+// Postgres is a Bus shared by every instance connected to the same
+// database. Events are sent with NOTIFY and received by each instance's
+// Listen loop, which hands them to the local subscribers, so an instance
+// receives its own events the same way as everyone else's.
+type Postgres struct {
+	pool  *pgxpool.Pool
+	local *Local
+}
+
+// NewPostgres creates a bus over pool whose subscribers buffer up to
+// buffer events. Nothing is received until Listen runs.
+func NewPostgres(pool *pgxpool.Pool, buffer int) *Postgres {
+	return &Postgres{
+		pool:  pool,
+		local: NewLocal(buffer),
+	}
+}
+
+// Publish sends e to every listening instance, including this one.
+func (b *Postgres) Publish(ctx context.Context, e Event) error {
+	payload, err := json.Marshal(stamp(e))
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", e.Kind, err)
+	}
+	if err := sqlc.New(b.pool).NotifyEvent(ctx, sqlc.NotifyEventParams{
+		Channel: Channel,
+		Payload: string(payload),
+	}); err != nil {
+		return fmt.Errorf("failed to publish %s event: %w", e.Kind, err)
+	}
+	return nil
+}
+
+// Subscribe starts a subscription to the events of every instance.
+func (b *Postgres) Subscribe() *Subscription {
+	return b.local.Subscribe()
+}
+
+// Listen receives events until ctx is cancelled. It holds a dedicated
+// connection outside the pool, since LISTEN is bound to a session, and
+// reconnects with exponential backoff when it is lost. Notifications sent
+// while disconnected are not delivered later, so once listening again it
+// publishes a KindResync event to the local subscribers.
+func (b *Postgres) Listen(ctx context.Context) {
+	delay := minReconnectDelay
+	resync := false
+	for {
+		err := b.listen(ctx, func() {
+			if resync {
+				b.local.deliver(stamp(Event{Kind: KindResync}))
+			}
+			delay = minReconnectDelay
+		})
+		if ctx.Err() != nil {
+			return
+		}
+
+		log.Printf("Event bus disconnected, reconnecting in %s: %v", delay, err)
+		resync = true
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxReconnectDelay)
+	}
+}
+
+// listen connects, starts listening, calls listening and then delivers
+// notifications until the connection fails or ctx is cancelled.
+func (b *Postgres) listen(ctx context.Context, listening func()) error {
+	conn, err := pgx.ConnectConfig(ctx, b.pool.Config().ConnConfig.Copy())
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{Channel}.Sanitize()); err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+	listening()
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		var e Event
+		if err := json.Unmarshal([]byte(n.Payload), &e); err != nil {
+			log.Printf("Ignoring malformed event %q: %v", n.Payload, err)
+			continue
+		}
+		b.local.deliver(e)
+	}
+}
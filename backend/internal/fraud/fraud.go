@@ -0,0 +1,243 @@
+// Package fraud looks for suspicious voting patterns in the votes log and
+// records them as flags for an admin to review.
+package fraud
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/cloutdotgg/backend/internal/db/sqlc"
+)
+
+// Kinds of suspicious patterns.
+const (
+	// KindWinBurst is a company winning far more votes than usual in a short
+	// window, as when a link to the vote page is shared with a brigade.
+	KindWinBurst = "win_burst"
+	// KindOneSidedSession is a session that picks the same company in nearly
+	// every matchup it appears in.
+	KindOneSidedSession = "one_sided_session"
+	// KindHighRateSession is a session voting faster than a person reads
+	// matchups.
+	KindHighRateSession = "high_rate_session"
+)
+
+// Statuses of a flag.
+const (
+	StatusOpen      = "open"
+	StatusConfirmed = "confirmed"
+	StatusDismissed = "dismissed"
+)
+
+// Config holds the thresholds of each pattern.
+type Config struct {
+	// BurstWindow is the window in which a company's wins are counted, and
+	// BurstBaseline the period before it they are compared against.
+	BurstWindow   time.Duration
+	BurstBaseline time.Duration
+	// BurstMinWins is the fewest wins in BurstWindow that can be a burst.
+	BurstMinWins int
+	// BurstFactor is how many times its usual rate a company must win at.
+	BurstFactor float64
+
+	// SessionLookback is how far back session patterns are looked for.
+	SessionLookback time.Duration
+	// OneSidedMinVotes is the fewest matchups with a company a session must
+	// vote on before it can be one-sided, and OneSidedShare the share of
+	// them the company must win.
+	OneSidedMinVotes int
+	OneSidedShare    float64
+	// RateBucket and RateMaxVotes flag sessions casting at least
+	// RateMaxVotes votes within one RateBucket.
+	RateBucket   time.Duration
+	RateMaxVotes int
+}
+
+// DefaultConfig returns thresholds that leave ordinary voting alone.
+func DefaultConfig() Config {
+	return Config{
+		BurstWindow:      time.Hour,
+		BurstBaseline:    7 * 24 * time.Hour,
+		BurstMinWins:     30,
+		BurstFactor:      5,
+		SessionLookback:  24 * time.Hour,
+		OneSidedMinVotes: 10,
+		OneSidedShare:    0.95,
+		RateBucket:       5 * time.Minute,
+		RateMaxVotes:     100,
+	}
+}
+
+// Result summarizes an analysis run.
+type Result struct {
+	// FlagsRaised is the number of open flags that gained votes.
+	FlagsRaised int
+	// VotesFlagged is the number of votes newly added to flags.
+	VotesFlagged int
+}
+
+// candidate is a suspicious group of votes found by one pattern.
+type candidate struct {
+	kind      string
+	sessionID *string
+	companyID *int32
+	details   string
+	voteIDs   []int32
+}
+
+// Analyze looks for every pattern among recent counted votes and adds the
+// suspicious votes to the open flag of their pattern and subject, creating
+// it if needed. Votes already covered by a flag of the same pattern, even a
+// dismissed one, are not flagged again.
+func Analyze(ctx context.Context, pool *pgxpool.Pool, cfg Config, now time.Time) (Result, error) {
+	q := sqlc.New(pool)
+
+	var candidates []candidate
+	for _, find := range []func(context.Context, *sqlc.Queries, Config, time.Time) ([]candidate, error){
+		findWinBursts,
+		findOneSidedSessions,
+		findHighRateSessions,
+	} {
+		found, err := find(ctx, q, cfg, now)
+		if err != nil {
+			return Result{}, err
+		}
+		candidates = append(candidates, found...)
+	}
+
+	var result Result
+	for _, c := range candidates {
+		flagged, err := raise(ctx, pool, c)
+		if err != nil {
+			return result, err
+		}
+		if flagged > 0 {
+			result.FlagsRaised++
+			result.VotesFlagged += flagged
+		}
+	}
+	return result, nil
+}
+
+// raise adds the candidate's unflagged votes to its open flag and returns
+// how many were added.
+func raise(ctx context.Context, pool *pgxpool.Pool, c candidate) (int, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	q := sqlc.New(tx)
+
+	voteIDs, err := q.FilterUnflaggedVotes(ctx, sqlc.FilterUnflaggedVotesParams{
+		VoteIds: c.voteIDs,
+		Kind:    c.kind,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to filter flagged votes: %w", err)
+	}
+	if len(voteIDs) == 0 {
+		return 0, nil
+	}
+
+	flagID, err := q.UpsertOpenFraudFlag(ctx, sqlc.UpsertOpenFraudFlagParams{
+		Kind:      c.kind,
+		SessionID: c.sessionID,
+		CompanyID: c.companyID,
+		Details:   c.details,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to raise %s flag: %w", c.kind, err)
+	}
+	if err := q.AddFraudFlagVotes(ctx, sqlc.AddFraudFlagVotesParams{
+		FlagID:  flagID,
+		VoteIds: voteIDs,
+	}); err != nil {
+		return 0, fmt.Errorf("failed to add votes to flag %d: %w", flagID, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit flag %d: %w", flagID, err)
+	}
+	return len(voteIDs), nil
+}
+
+func findWinBursts(ctx context.Context, q *sqlc.Queries, cfg Config, now time.Time) ([]candidate, error) {
+	windowStart := now.Add(-cfg.BurstWindow)
+	rows, err := q.FindWinBursts(ctx, sqlc.FindWinBurstsParams{
+		WindowStart:   pgtype.Timestamptz{Time: windowStart, Valid: true},
+		BaselineStart: pgtype.Timestamptz{Time: windowStart.Add(-cfg.BurstBaseline), Valid: true},
+		MinWins:       int64(cfg.BurstMinWins),
+		// Scale the baseline count to the length of the window
+		BaselineMultiplier: cfg.BurstFactor * cfg.BurstWindow.Seconds() / cfg.BurstBaseline.Seconds(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find win bursts: %w", err)
+	}
+
+	candidates := make([]candidate, len(rows))
+	for i, r := range rows {
+		companyID := r.WinnerID
+		candidates[i] = candidate{
+			kind:      KindWinBurst,
+			companyID: &companyID,
+			details: fmt.Sprintf("%d wins in the last %s against %d in the %s before",
+				r.Wins, cfg.BurstWindow, r.BaselineWins, cfg.BurstBaseline),
+			voteIDs: r.VoteIds,
+		}
+	}
+	return candidates, nil
+}
+
+func findOneSidedSessions(ctx context.Context, q *sqlc.Queries, cfg Config, now time.Time) ([]candidate, error) {
+	rows, err := q.FindOneSidedSessions(ctx, sqlc.FindOneSidedSessionsParams{
+		Since:    pgtype.Timestamptz{Time: now.Add(-cfg.SessionLookback), Valid: true},
+		MinVotes: int64(cfg.OneSidedMinVotes),
+		MinShare: cfg.OneSidedShare,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find one-sided sessions: %w", err)
+	}
+
+	candidates := make([]candidate, len(rows))
+	for i, r := range rows {
+		sessionID, companyID := r.SessionID, r.CompanyID
+		candidates[i] = candidate{
+			kind:      KindOneSidedSession,
+			sessionID: &sessionID,
+			companyID: &companyID,
+			details: fmt.Sprintf("picked the company in %d of %d matchups in the last %s",
+				r.Wins, r.Appearances, cfg.SessionLookback),
+			voteIDs: r.VoteIds,
+		}
+	}
+	return candidates, nil
+}
+
+func findHighRateSessions(ctx context.Context, q *sqlc.Queries, cfg Config, now time.Time) ([]candidate, error) {
+	rows, err := q.FindHighRateSessions(ctx, sqlc.FindHighRateSessionsParams{
+		BucketSeconds: cfg.RateBucket.Seconds(),
+		Since:         pgtype.Timestamptz{Time: now.Add(-cfg.SessionLookback), Valid: true},
+		MinVotes:      int64(cfg.RateMaxVotes),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find high-rate sessions: %w", err)
+	}
+
+	candidates := make([]candidate, len(rows))
+	for i, r := range rows {
+		sessionID := r.SessionID
+		candidates[i] = candidate{
+			kind:      KindHighRateSession,
+			sessionID: &sessionID,
+			details: fmt.Sprintf("%d votes within %s from %s",
+				r.Votes, cfg.RateBucket, r.BucketStart.Time.UTC().Format(time.RFC3339)),
+			voteIDs: r.VoteIds,
+		}
+	}
+	return candidates, nil
+}
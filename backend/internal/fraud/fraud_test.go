@@ -0,0 +1,138 @@
+package fraud
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/cloutdotgg/backend/internal/db/sqlc"
+	"github.com/cloutdotgg/backend/internal/dbtest"
+)
+
+// companies returns the ids of the first n companies.
+func companies(t *testing.T, pool *pgxpool.Pool, n int) []int32 {
+	t.Helper()
+	rows, err := pool.Query(context.Background(), "SELECT id FROM companies ORDER BY id LIMIT $1", n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int32
+	for rows.Next() {
+		var id int32
+		if err := rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) < n {
+		t.Fatalf("need %d companies, have %d", n, len(ids))
+	}
+	return ids
+}
+
+// vote inserts a counted win cast at the given time. An empty session
+// leaves the vote without one.
+func vote(t *testing.T, pool *pgxpool.Pool, winner, loser int32, session string, at time.Time) {
+	t.Helper()
+	if _, err := pool.Exec(context.Background(),
+		"INSERT INTO votes (winner_id, loser_id, session_id, created_at) VALUES ($1, $2, NULLIF($3, ''), $4)",
+		winner, loser, session, at,
+	); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	pool := dbtest.New(t)
+	ctx := context.Background()
+	cfg := DefaultConfig()
+	now := time.Now()
+	ids := companies(t, pool, 8)
+	bursting, favoured, fastA, fastB := ids[0], ids[1], ids[2], ids[3]
+	others := ids[4:]
+
+	// A brigade arriving without sessions, so that only the burst shows
+	for i := 0; i < cfg.BurstMinWins; i++ {
+		vote(t, pool, bursting, others[i%len(others)], "", now.Add(-time.Duration(i+1)*time.Minute))
+	}
+	// A session that always picks the same company, slowly, and outside the
+	// burst window
+	for i := 0; i < cfg.OneSidedMinVotes; i++ {
+		vote(t, pool, favoured, others[i%len(others)], "one-sided", now.Add(-2*time.Hour-time.Duration(i)*10*time.Minute))
+	}
+	// A session voting every second on one pair, picking each side half the
+	// time, within one bucket
+	bucket := now.Add(-3 * time.Hour).Truncate(cfg.RateBucket)
+	for i := 0; i < cfg.RateMaxVotes; i++ {
+		winner, loser := fastA, fastB
+		if i%2 == 1 {
+			winner, loser = loser, winner
+		}
+		vote(t, pool, winner, loser, "fast", bucket.Add(time.Duration(i)*time.Second))
+	}
+
+	result, err := Analyze(ctx, pool, cfg, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantVotes := cfg.BurstMinWins + cfg.OneSidedMinVotes + cfg.RateMaxVotes
+	if result.FlagsRaised != 3 || result.VotesFlagged != wantVotes {
+		t.Errorf("raised %d flags over %d votes, want 3 over %d", result.FlagsRaised, result.VotesFlagged, wantVotes)
+	}
+
+	type subject struct {
+		kind      string
+		sessionID string
+		companyID int32
+	}
+	want := map[subject]int32{
+		{kind: KindWinBurst, companyID: bursting}:                                int32(cfg.BurstMinWins),
+		{kind: KindOneSidedSession, sessionID: "one-sided", companyID: favoured}: int32(cfg.OneSidedMinVotes),
+		{kind: KindHighRateSession, sessionID: "fast"}:                           int32(cfg.RateMaxVotes),
+	}
+	checkFlags := func() {
+		t.Helper()
+		flags, err := sqlc.New(pool).ListFraudFlags(ctx, sqlc.ListFraudFlagsParams{PageLimit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[subject]int32)
+		for _, f := range flags {
+			s := subject{kind: f.Kind}
+			if f.SessionID != nil {
+				s.sessionID = *f.SessionID
+			}
+			if f.CompanyID != nil {
+				s.companyID = *f.CompanyID
+			}
+			if f.Status != StatusOpen {
+				t.Errorf("%+v: status %s", s, f.Status)
+			}
+			got[s] = f.VoteCount
+		}
+		if len(got) != len(want) || len(flags) != len(want) {
+			t.Fatalf("flags %v, want %v", got, want)
+		}
+		for s, n := range want {
+			if got[s] != n {
+				t.Errorf("%+v: %d votes, want %d", s, got[s], n)
+			}
+		}
+	}
+	checkFlags()
+
+	// Nothing new has been cast, so a second run flags nothing again
+	result, err = Analyze(ctx, pool, cfg, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != (Result{}) {
+		t.Errorf("second run raised %+v", result)
+	}
+	checkFlags()
+}
@@ -0,0 +1,26 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/cloutdotgg/backend/internal/decay"
+)
+
+// DecayInactiveRatings returns a job that pulls the ratings of companies
+// without recent votes back toward the default rating.
+func DecayInactiveRatings(pool *pgxpool.Pool, cfg decay.Config) func(context.Context) error {
+	return func(ctx context.Context) error {
+		decayed, err := decay.Run(ctx, pool, cfg, time.Now())
+		if err != nil {
+			return err
+		}
+		if decayed > 0 {
+			log.Printf("Decayed the ratings of %d inactive companies", decayed)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,26 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/cloutdotgg/backend/internal/fraud"
+)
+
+// AnalyzeVotes returns a job that flags suspicious voting patterns for
+// review.
+func AnalyzeVotes(pool *pgxpool.Pool, cfg fraud.Config) func(context.Context) error {
+	return func(ctx context.Context) error {
+		result, err := fraud.Analyze(ctx, pool, cfg, time.Now())
+		if err != nil {
+			return err
+		}
+		if result.VotesFlagged > 0 {
+			log.Printf("Flagged %d suspicious votes in %d fraud flags", result.VotesFlagged, result.FlagsRaised)
+		}
+		return nil
+	}
+}
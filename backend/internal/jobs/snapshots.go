@@ -0,0 +1,26 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/cloutdotgg/backend/internal/db/sqlc"
+)
+
+// SnapshotRatings returns a job that refreshes the daily rating snapshots
+// for yesterday and today. Yesterday is included so votes cast shortly
+// before midnight still make it into its final snapshot.
+func SnapshotRatings(q *sqlc.Queries) func(context.Context) error {
+	return func(ctx context.Context) error {
+		today := time.Now().UTC().Truncate(24 * time.Hour)
+		for _, day := range []time.Time{today.AddDate(0, 0, -1), today} {
+			if err := q.SnapshotRatingsForDay(ctx, pgtype.Date{Time: day, Valid: true}); err != nil {
+				return fmt.Errorf("failed to snapshot %s: %w", day.Format(time.DateOnly), err)
+			}
+		}
+		return nil
+	}
+}
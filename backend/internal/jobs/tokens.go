@@ -0,0 +1,41 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cloutdotgg/backend/internal/db/sqlc"
+)
+
+// DeleteExpiredMatchupTokens returns a job that forgets spent matchup tokens
+// once they have expired, since expired tokens are rejected anyway.
+func DeleteExpiredMatchupTokens(q *sqlc.Queries) func(context.Context) error {
+	return func(ctx context.Context) error {
+		if _, err := q.DeleteExpiredMatchupTokens(ctx); err != nil {
+			return fmt.Errorf("failed to delete expired matchup tokens: %w", err)
+		}
+		return nil
+	}
+}
+
+// DeleteExpiredSessions returns a job that removes sessions whose tokens
+// have expired.
+func DeleteExpiredSessions(q *sqlc.Queries) func(context.Context) error {
+	return func(ctx context.Context) error {
+		if _, err := q.DeleteExpiredSessions(ctx); err != nil {
+			return fmt.Errorf("failed to delete expired sessions: %w", err)
+		}
+		return nil
+	}
+}
+
+// DeleteIdleRateLimitBuckets returns a job that removes rate limit buckets
+// that have not been used for long enough to have refilled.
+func DeleteIdleRateLimitBuckets(q *sqlc.Queries) func(context.Context) error {
+	return func(ctx context.Context) error {
+		if _, err := q.DeleteIdleRateLimitBuckets(ctx); err != nil {
+			return fmt.Errorf("failed to delete idle rate limit buckets: %w", err)
+		}
+		return nil
+	}
+}
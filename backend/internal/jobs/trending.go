@@ -0,0 +1,18 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/cloutdotgg/backend/internal/trending"
+)
+
+// RollUpActivity returns a job that rolls recent votes and rating changes
+// up into the hourly buckets trending leaderboards read.
+func RollUpActivity(pool *pgxpool.Pool) func(context.Context) error {
+	return func(ctx context.Context) error {
+		return trending.Run(ctx, pool, time.Now())
+	}
+}
@@ -0,0 +1,37 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/cloutdotgg/backend/internal/db/sqlc"
+)
+
+// DeleteExpiredPairVotes returns a job that forgets each voter's last vote
+// on a pair once it is older than cooldown and can no longer make a new
+// vote a repeat.
+func DeleteExpiredPairVotes(q *sqlc.Queries, cooldown time.Duration) func(context.Context) error {
+	return func(ctx context.Context) error {
+		before := pgtype.Timestamptz{Time: time.Now().Add(-cooldown), Valid: true}
+		if _, err := q.DeletePairVotesBefore(ctx, before); err != nil {
+			return fmt.Errorf("failed to delete expired pair votes: %w", err)
+		}
+		return nil
+	}
+}
+
+// DeleteExpiredVoteRatingDeltas returns a job that drops the rating changes
+// kept for undoing votes once the votes are older than window and can no
+// longer be undone.
+func DeleteExpiredVoteRatingDeltas(q *sqlc.Queries, window time.Duration) func(context.Context) error {
+	return func(ctx context.Context) error {
+		before := pgtype.Timestamptz{Time: time.Now().Add(-window), Valid: true}
+		if _, err := q.DeleteVoteRatingDeltasBefore(ctx, before); err != nil {
+			return fmt.Errorf("failed to delete expired vote rating deltas: %w", err)
+		}
+		return nil
+	}
+}
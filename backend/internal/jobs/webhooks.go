@@ -0,0 +1,42 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/cloutdotgg/backend/internal/db/sqlc"
+	"github.com/cloutdotgg/backend/internal/webhook"
+)
+
+// DeliverWebhooks returns a job that attempts the webhook deliveries that
+// are due.
+func DeliverWebhooks(pool *pgxpool.Pool, cfg webhook.Config) func(context.Context) error {
+	client := webhook.NewClient(cfg)
+	return func(ctx context.Context) error {
+		result, err := webhook.Dispatch(ctx, pool, client, cfg, time.Now())
+		if err != nil {
+			return err
+		}
+		if result.Failed > 0 || result.Dead > 0 {
+			log.Printf("Delivered %d webhooks, %d failed and will be retried, %d gave up", result.Delivered, result.Failed, result.Dead)
+		}
+		return nil
+	}
+}
+
+// DeleteOldWebhookDeliveries returns a job that drops delivered and dead
+// webhook deliveries from the log once they are older than retention.
+func DeleteOldWebhookDeliveries(q *sqlc.Queries, retention time.Duration) func(context.Context) error {
+	return func(ctx context.Context) error {
+		before := pgtype.Timestamptz{Time: time.Now().Add(-retention), Valid: true}
+		if _, err := q.DeleteOldWebhookDeliveries(ctx, before); err != nil {
+			return fmt.Errorf("failed to delete old webhook deliveries: %w", err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,42 @@
+package matchmaking
+
+import (
+	"testing"
+
+	"github.com/cloutdotgg/backend/internal/rating"
+)
+
+// TestInformationGainConvergesFaster checks, under every rating engine, that
+// information gain ranks simulated populations correctly in fewer votes than
+// random pairing. The target is above the rank correlation at which Elo's
+// fixed K-factor lets random pairing hover, so reaching it is not down to a
+// lucky check.
+func TestInformationGainConvergesFaster(t *testing.T) {
+	const runs = 8
+	for _, engine := range []string{"elo", "glicko2"} {
+		t.Run(engine, func(t *testing.T) {
+			rater, err := rating.New(engine)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			votes := func(strategy Strategy) int {
+				var total int
+				for run := 0; run < runs; run++ {
+					cfg := DefaultSimulationConfig()
+					cfg.Target = 0.97
+					cfg.MaxVotes = 20000
+					cfg.Seed = int64(run + 1)
+					total += Simulate(strategy, rater, cfg).Votes
+				}
+				return total / runs
+			}
+			infoGain := votes(NewInformationGain(DefaultMinExposure, DefaultTopK))
+			random := votes(Random{})
+			if infoGain >= random {
+				t.Errorf("information gain needed %d votes on average, random %d", infoGain, random)
+			}
+			t.Logf("information gain %d votes, random %d", infoGain, random)
+		})
+	}
+}
@@ -0,0 +1,70 @@
+// Package matchmaking decides which two companies are shown against each
+// other in a matchup.
+package matchmaking
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/cloutdotgg/backend/internal/rating"
+)
+
+// ErrNotEnoughCandidates is returned when fewer than two companies are
+// available to pair.
+var ErrNotEnoughCandidates = errors.New("matchmaking needs at least two candidates")
+
+// Candidate is a company that can be put into a matchup.
+type Candidate struct {
+	ID         int32
+	Rating     rating.Rating
+	TotalVotes int32
+}
+
+// Source is the randomness a strategy draws from. *rand.Rand satisfies it,
+// which lets simulations use a seeded source.
+type Source interface {
+	Intn(n int) int
+	Float64() float64
+}
+
+// GlobalSource draws from the shared math/rand source and is safe for
+// concurrent use.
+var GlobalSource Source = globalSource{}
+
+type globalSource struct{}
+
+func (globalSource) Intn(n int) int   { return rand.Intn(n) }
+func (globalSource) Float64() float64 { return rand.Float64() }
+
+// Strategy picks a pair of distinct candidates for the next matchup.
+type Strategy interface {
+	// Name returns the identifier used to select the strategy.
+	Name() string
+	// Pick returns two distinct candidates in no particular order.
+	Pick(candidates []Candidate, src Source) (Candidate, Candidate, error)
+}
+
+// DefaultStrategy is used when no strategy has been configured.
+const DefaultStrategy = "information-gain"
+
+// Names lists the identifiers accepted by New.
+func Names() []string {
+	return []string{"random", "information-gain"}
+}
+
+// New returns the strategy registered under name. An empty name selects
+// DefaultStrategy.
+func New(name string) (Strategy, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "":
+		return New(DefaultStrategy)
+	case "random":
+		return Random{}, nil
+	case "information-gain", "infogain":
+		return NewInformationGain(DefaultMinExposure, DefaultTopK), nil
+	default:
+		return nil, fmt.Errorf("unknown matchmaking strategy %q", name)
+	}
+}
@@ -0,0 +1,23 @@
+package matchmaking
+
+// Random pairs two candidates uniformly at random.
+type Random struct{}
+
+// Name implements Strategy.
+func (Random) Name() string {
+	return "random"
+}
+
+// Pick implements Strategy.
+func (Random) Pick(candidates []Candidate, src Source) (Candidate, Candidate, error) {
+	if len(candidates) < 2 {
+		return Candidate{}, Candidate{}, ErrNotEnoughCandidates
+	}
+
+	i := src.Intn(len(candidates))
+	j := src.Intn(len(candidates) - 1)
+	if j >= i {
+		j++
+	}
+	return candidates[i], candidates[j], nil
+}
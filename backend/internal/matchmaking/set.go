@@ -0,0 +1,40 @@
+package matchmaking
+
+// PickSet returns size distinct candidates for a multi-way ranking. The
+// first two are the pair strategy would serve as a matchup; each further
+// company is drawn by strategy from the candidates not picked yet, so a set
+// favours the same companies as matchups do.
+func PickSet(strategy Strategy, candidates []Candidate, size int, src Source) ([]Candidate, error) {
+	if size < 2 || len(candidates) < size {
+		return nil, ErrNotEnoughCandidates
+	}
+
+	first, second, err := strategy.Pick(candidates, src)
+	if err != nil {
+		return nil, err
+	}
+	set := []Candidate{first, second}
+
+	remaining := make([]Candidate, 0, len(candidates)-2)
+	for _, c := range candidates {
+		if c.ID != first.ID && c.ID != second.ID {
+			remaining = append(remaining, c)
+		}
+	}
+	for len(set) < size {
+		next := remaining[0]
+		if len(remaining) > 1 {
+			if next, _, err = strategy.Pick(remaining, src); err != nil {
+				return nil, err
+			}
+		}
+		set = append(set, next)
+		for i, c := range remaining {
+			if c.ID == next.ID {
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				break
+			}
+		}
+	}
+	return set, nil
+}
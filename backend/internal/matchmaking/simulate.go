@@ -0,0 +1,149 @@
+package matchmaking
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/cloutdotgg/backend/internal/rating"
+)
+
+// SimulationConfig describes a synthetic population to run a strategy on.
+type SimulationConfig struct {
+	// Companies is the number of simulated companies.
+	Companies int
+	// MaxVotes is the number of votes after which the run gives up.
+	MaxVotes int
+	// Spread is the standard deviation of the hidden true ratings around
+	// rating.DefaultRating.
+	Spread float64
+	// Target is the Spearman correlation between the estimated and the true
+	// ranking at which the leaderboard counts as converged.
+	Target float64
+	// CheckEvery is how many votes pass between convergence checks.
+	CheckEvery int
+	// Seed makes the run reproducible. Runs with the same seed share the
+	// same hidden true ratings regardless of strategy.
+	Seed int64
+}
+
+// DefaultSimulationConfig returns a population roughly the size of the
+// production leaderboard.
+func DefaultSimulationConfig() SimulationConfig {
+	return SimulationConfig{
+		Companies:  100,
+		MaxVotes:   50000,
+		Spread:     200,
+		Target:     0.95,
+		CheckEvery: 100,
+		Seed:       1,
+	}
+}
+
+// SimulationResult is the outcome of one simulated run.
+type SimulationResult struct {
+	// Converged reports whether Target was reached within MaxVotes.
+	Converged bool
+	// Votes is the number of votes cast until convergence, or MaxVotes.
+	Votes int
+	// Correlation is the rank correlation when the run stopped.
+	Correlation float64
+	// MinVotes is the smallest number of votes any company received.
+	MinVotes int32
+}
+
+// Simulate feeds votes between companies with hidden true ratings through
+// strategy and rater, with outcomes drawn from the Elo win probability of
+// the true ratings, until the estimated leaderboard matches the true one.
+func Simulate(strategy Strategy, rater rating.Rater, cfg SimulationConfig) SimulationResult {
+	population := rand.New(rand.NewSource(cfg.Seed))
+	truth := make([]float64, cfg.Companies)
+	for i := range truth {
+		truth[i] = rating.DefaultRating + population.NormFloat64()*cfg.Spread
+	}
+
+	src := rand.New(rand.NewSource(cfg.Seed + 1))
+	candidates := make([]Candidate, cfg.Companies)
+	index := make(map[int32]int, cfg.Companies)
+	for i := range candidates {
+		candidates[i] = Candidate{ID: int32(i), Rating: rating.Initial()}
+		index[int32(i)] = i
+	}
+
+	result := SimulationResult{Votes: cfg.MaxVotes}
+	for vote := 1; vote <= cfg.MaxVotes; vote++ {
+		a, b, err := strategy.Pick(candidates, src)
+		if err != nil {
+			break
+		}
+		ia, ib := index[a.ID], index[b.ID]
+
+		winProbability := 1 / (1 + math.Pow(10, (truth[ib]-truth[ia])/400))
+		winner, loser := ia, ib
+		if src.Float64() >= winProbability {
+			winner, loser = ib, ia
+		}
+		candidates[winner].Rating, candidates[loser].Rating = rater.Rate(candidates[winner].Rating, candidates[loser].Rating, 1)
+		candidates[winner].TotalVotes++
+		candidates[loser].TotalVotes++
+
+		if cfg.CheckEvery > 0 && vote%cfg.CheckEvery == 0 {
+			result.Correlation = spearman(candidates, truth)
+			if result.Correlation >= cfg.Target {
+				result.Converged = true
+				result.Votes = vote
+				break
+			}
+		}
+	}
+	if !result.Converged {
+		result.Correlation = spearman(candidates, truth)
+	}
+
+	result.MinVotes = math.MaxInt32
+	for _, c := range candidates {
+		if c.TotalVotes < result.MinVotes {
+			result.MinVotes = c.TotalVotes
+		}
+	}
+	return result
+}
+
+// spearman returns the rank correlation between the estimated ratings and
+// the true ratings.
+func spearman(candidates []Candidate, truth []float64) float64 {
+	n := len(candidates)
+	if n < 2 {
+		return 1
+	}
+
+	estimated := make([]float64, n)
+	for i, c := range candidates {
+		estimated[i] = c.Rating.Value
+	}
+	re, rt := ranks(estimated), ranks(truth)
+
+	var d2 float64
+	for i := range re {
+		d := re[i] - rt[i]
+		d2 += d * d
+	}
+	return 1 - 6*d2/float64(n*(n*n-1))
+}
+
+// ranks returns the 1-based rank of every value, highest first.
+func ranks(values []float64) []float64 {
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return values[order[i]] > values[order[j]]
+	})
+
+	r := make([]float64, len(values))
+	for rank, i := range order {
+		r[i] = float64(rank + 1)
+	}
+	return r
+}
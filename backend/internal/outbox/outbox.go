@@ -0,0 +1,256 @@
+// Package outbox records domain events in the same transaction as the
+// change they describe and relays them to sinks at least once.
+//
+// Events are delivered in the order of their positions, which follow the
+// transactions that wrote them: an event is positioned at its transaction's
+// id, or after the previous event of its aggregate if that is later. Each
+// sink follows the outbox by the position and id of the last event it was
+// delivered. The relay only reads events positioned below the oldest
+// running transaction, so an event committed late is never skipped, and the
+// events of each aggregate, such as the cast and undo of one vote, reach
+// every sink in the order they happened.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/cloutdotgg/backend/internal/db/sqlc"
+	"github.com/cloutdotgg/backend/internal/events"
+)
+
+// Types of the aggregates events are about, as stored in
+// outbox_events.aggregate_type. The aggregate id is Event.ID.
+const (
+	AggregateVote          = "vote"
+	AggregateRanking       = "ranking"
+	AggregateComment       = "comment"
+	AggregateCompanyRating = "company_rating"
+	// AggregateRatings is the ratings of every company, changed together by
+	// recomputes, fraud confirmations and decays. Its id is always 0.
+	AggregateRatings = "ratings"
+)
+
+// DefaultBatchSize is how many events the relay reads at a time.
+const DefaultBatchSize = 100
+
+// aggregateType returns the type of the aggregate events of kind are about.
+// Kinds without one, such as a bus's own resyncs, are not recorded.
+func aggregateType(kind events.Kind) (string, error) {
+	switch kind {
+	case events.KindVoteCast, events.KindVoteUndone:
+		return AggregateVote, nil
+	case events.KindRankingSubmitted:
+		return AggregateRanking, nil
+	case events.KindCommentCreated, events.KindCommentUpvoted:
+		return AggregateComment, nil
+	case events.KindRatingSubmitted:
+		return AggregateCompanyRating, nil
+	case events.KindRatingsChanged:
+		return AggregateRatings, nil
+	default:
+		return "", fmt.Errorf("%s events are not recorded in the outbox", kind)
+	}
+}
+
+// Record adds e to the outbox in the transaction of q, so it is delivered
+// if and only if the transaction commits. It locks the event's aggregate
+// until the transaction ends; call it after the transaction's other writes.
+func Record(ctx context.Context, q *sqlc.Queries, e events.Event) error {
+	aggregate, err := aggregateType(e.Kind)
+	if err != nil {
+		return err
+	}
+	if e.At.IsZero() {
+		e.At = time.Now()
+	}
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", e.Kind, err)
+	}
+
+	position, err := q.ClaimOutboxPosition(ctx, sqlc.ClaimOutboxPositionParams{
+		AggregateType: aggregate,
+		AggregateID:   e.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to position %s event: %w", e.Kind, err)
+	}
+	if err := q.CreateOutboxEvent(ctx, sqlc.CreateOutboxEventParams{
+		AggregateType: aggregate,
+		AggregateID:   e.ID,
+		Kind:          string(e.Kind),
+		Payload:       payload,
+		Position:      position,
+	}); err != nil {
+		return fmt.Errorf("failed to record %s event: %w", e.Kind, err)
+	}
+	return nil
+}
+
+// Offset is a place in the delivery order of the outbox: the position of an
+// event and its id, which orders the events of one position.
+type Offset struct {
+	Position int64
+	EventID  int64
+}
+
+// Message is an event read back from the outbox.
+type Message struct {
+	// Offset is where the event is delivered, from which Replay can
+	// redeliver the outbox. Offset.EventID is unique to the event.
+	Offset        Offset
+	AggregateType string
+	AggregateID   int32
+	Event         events.Event
+}
+
+// Sink receives the events of the outbox.
+type Sink interface {
+	// Name identifies the sink's offset. Renaming a sink starts it over
+	// at the end of the outbox.
+	Name() string
+	// Deliver handles one message. A message whose delivery fails is
+	// retried before any later one, and a message may be delivered again
+	// after a crash, so Deliver must be idempotent.
+	Deliver(ctx context.Context, m Message) error
+}
+
+// Relay delivers the outbox to its sinks. Every instance can run one: an
+// instance delivering to a sink holds its offset's row lock, and the others
+// skip that sink until it is done.
+type Relay struct {
+	pool      *pgxpool.Pool
+	sinks     []Sink
+	batchSize int32
+}
+
+// NewRelay creates a relay delivering to sinks. A sink seen for the first
+// time starts at the end of the outbox; use Replay to backfill it.
+func NewRelay(pool *pgxpool.Pool, sinks ...Sink) *Relay {
+	return &Relay{
+		pool:      pool,
+		sinks:     sinks,
+		batchSize: DefaultBatchSize,
+	}
+}
+
+// Run delivers the pending events to every sink. A sink that fails keeps
+// its offset at the failed event and does not hold up the others.
+func (r *Relay) Run(ctx context.Context) error {
+	var errs []error
+	for _, sink := range r.sinks {
+		if err := r.deliver(ctx, sink); err != nil {
+			errs = append(errs, fmt.Errorf("sink %s: %w", sink.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// deliver delivers batches to sink until it has caught up.
+func (r *Relay) deliver(ctx context.Context, sink Sink) error {
+	if err := sqlc.New(r.pool).EnsureOutboxOffset(ctx, sink.Name()); err != nil {
+		return fmt.Errorf("failed to start offset: %w", err)
+	}
+	for {
+		delivered, err := r.deliverBatch(ctx, sink)
+		if err != nil || delivered < int(r.batchSize) {
+			return err
+		}
+	}
+}
+
+// deliverBatch delivers the next batch of events to sink and advances its
+// offset past the ones delivered, returning how many were.
+func (r *Relay) deliverBatch(ctx context.Context, sink Sink) (int, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	q := sqlc.New(tx)
+
+	offset, err := q.LockOutboxOffset(ctx, sink.Name())
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Another instance is delivering to this sink
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to lock offset: %w", err)
+	}
+
+	rows, err := q.ListOutboxEventsAfter(ctx, sqlc.ListOutboxEventsAfterParams{
+		AfterPosition: offset.LastPosition,
+		AfterID:       offset.LastEventID,
+		PageLimit:     r.batchSize,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list events: %w", err)
+	}
+
+	delivered := 0
+	var deliverErr error
+	for _, row := range rows {
+		m, err := messageFromRow(row)
+		if err == nil {
+			err = sink.Deliver(ctx, m)
+		}
+		if err != nil {
+			deliverErr = fmt.Errorf("failed to deliver event %d: %w", row.ID, err)
+			break
+		}
+		offset = sqlc.LockOutboxOffsetRow{LastPosition: row.Position, LastEventID: row.ID}
+		delivered++
+	}
+
+	if delivered > 0 {
+		if err := q.SetOutboxOffset(ctx, sqlc.SetOutboxOffsetParams{
+			Sink:         sink.Name(),
+			LastPosition: offset.LastPosition,
+			LastEventID:  offset.LastEventID,
+		}); err != nil {
+			return 0, fmt.Errorf("failed to advance offset: %w", err)
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return 0, fmt.Errorf("failed to commit offset: %w", err)
+		}
+	}
+	return delivered, deliverErr
+}
+
+// messageFromRow decodes an outbox row.
+func messageFromRow(row sqlc.OutboxEvent) (Message, error) {
+	var e events.Event
+	if err := json.Unmarshal(row.Payload, &e); err != nil {
+		return Message{}, fmt.Errorf("failed to decode event: %w", err)
+	}
+	return Message{
+		Offset:        Offset{Position: row.Position, EventID: row.ID},
+		AggregateType: row.AggregateType,
+		AggregateID:   row.AggregateID,
+		Event:         e,
+	}, nil
+}
+
+// Replay rewinds sink so that the relay delivers the event at from and every
+// event after it in delivery order again, or for the first time to a new
+// sink. An offset without an event id starts with the first event at its
+// position, and the zero Offset replays the whole outbox.
+func Replay(ctx context.Context, q *sqlc.Queries, sink string, from Offset) error {
+	// The stored offset is the last event delivered, which is just before
+	// from
+	if err := q.SetOutboxOffset(ctx, sqlc.SetOutboxOffsetParams{
+		Sink:         sink,
+		LastPosition: from.Position,
+		LastEventID:  from.EventID - 1,
+	}); err != nil {
+		return fmt.Errorf("failed to rewind %s: %w", sink, err)
+	}
+	return nil
+}
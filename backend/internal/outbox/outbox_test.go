@@ -0,0 +1,239 @@
+package outbox
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/cloutdotgg/backend/internal/db/sqlc"
+	"github.com/cloutdotgg/backend/internal/dbtest"
+	"github.com/cloutdotgg/backend/internal/events"
+)
+
+// recordingSink keeps the events delivered to it.
+type recordingSink struct {
+	mu        sync.Mutex
+	delivered []events.Event
+}
+
+func (s *recordingSink) Name() string { return "test" }
+
+func (s *recordingSink) Deliver(ctx context.Context, m Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delivered = append(s.delivered, m.Event)
+	return nil
+}
+
+func (s *recordingSink) events() []events.Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]events.Event(nil), s.delivered...)
+}
+
+func (s *recordingSink) ids() []int32 {
+	var ids []int32
+	for _, e := range s.events() {
+		ids = append(ids, e.ID)
+	}
+	return ids
+}
+
+// newRelay returns a relay delivering the whole outbox to a recording sink.
+func newRelay(t *testing.T, pool *pgxpool.Pool) (*Relay, *recordingSink) {
+	t.Helper()
+	sink := &recordingSink{}
+	if err := Replay(context.Background(), sqlc.New(pool), sink.Name(), Offset{}); err != nil {
+		t.Fatal(err)
+	}
+	return NewRelay(pool, sink), sink
+}
+
+// begin starts a transaction that is rolled back when the test ends unless
+// it was committed.
+func begin(t *testing.T, pool *pgxpool.Pool) pgx.Tx {
+	t.Helper()
+	tx, err := pool.Begin(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { tx.Rollback(context.Background()) })
+	return tx
+}
+
+func record(t *testing.T, tx pgx.Tx, voteID int32) {
+	t.Helper()
+	if err := Record(context.Background(), sqlc.New(tx), events.Event{Kind: events.KindVoteCast, ID: voteID}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func commit(t *testing.T, tx pgx.Tx) {
+	t.Helper()
+	if err := tx.Commit(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func run(t *testing.T, relay *Relay) {
+	t.Helper()
+	if err := relay.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// runUntil runs relay until sink has been delivered n events. Transactions
+// of other tests sharing the database hold back delivery until they end.
+func runUntil(t *testing.T, relay *Relay, sink *recordingSink, n int) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		run(t, relay)
+		if len(sink.events()) >= n || time.Now().After(deadline) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestWritersDoNotWaitForEachOther records events in two open transactions
+// at once, which a lock on the whole outbox would serialize.
+func TestWritersDoNotWaitForEachOther(t *testing.T) {
+	pool := dbtest.New(t)
+
+	first := begin(t, pool)
+	record(t, first, 1)
+
+	second := begin(t, pool)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := Record(ctx, sqlc.New(second), events.Event{Kind: events.KindVoteCast, ID: 2}); err != nil {
+		t.Fatalf("second writer: %v", err)
+	}
+	commit(t, second)
+	commit(t, first)
+}
+
+// TestLateCommitIsNotSkipped commits an event recorded first after one
+// recorded later, and checks that the relay waits for it instead of moving
+// past it.
+func TestLateCommitIsNotSkipped(t *testing.T) {
+	pool := dbtest.New(t)
+	relay, sink := newRelay(t, pool)
+
+	slow := begin(t, pool)
+	record(t, slow, 1)
+	fast := begin(t, pool)
+	record(t, fast, 2)
+	commit(t, fast)
+
+	run(t, relay)
+	if got := sink.ids(); len(got) != 0 {
+		t.Fatalf("delivered %v while an earlier writer was still running", got)
+	}
+
+	commit(t, slow)
+	runUntil(t, relay, sink, 2)
+	if got := sink.ids(); !slices.Equal(got, []int32{1, 2}) {
+		t.Fatalf("delivered %v, want [1 2]", got)
+	}
+}
+
+// TestAggregateEventsKeepCommitOrder records the second event of an
+// aggregate in a transaction that started writing before the first one did,
+// and checks that the events are still delivered in the order they
+// committed.
+func TestAggregateEventsKeepCommitOrder(t *testing.T) {
+	pool := dbtest.New(t)
+	relay, sink := newRelay(t, pool)
+	ctx := context.Background()
+
+	later := begin(t, pool)
+	if _, err := later.Exec(ctx, "SELECT pg_current_xact_id()"); err != nil {
+		t.Fatal(err)
+	}
+
+	earlier := begin(t, pool)
+	if err := Record(ctx, sqlc.New(earlier), events.Event{Kind: events.KindVoteCast, ID: 7}); err != nil {
+		t.Fatal(err)
+	}
+	commit(t, earlier)
+	if err := Record(ctx, sqlc.New(later), events.Event{Kind: events.KindVoteUndone, ID: 7}); err != nil {
+		t.Fatal(err)
+	}
+	commit(t, later)
+
+	runUntil(t, relay, sink, 2)
+	var kinds []events.Kind
+	for _, e := range sink.events() {
+		kinds = append(kinds, e.Kind)
+	}
+	if len(kinds) != 2 || kinds[0] != events.KindVoteCast || kinds[1] != events.KindVoteUndone {
+		t.Fatalf("delivered %v, want the cast then the undo", kinds)
+	}
+}
+
+// TestReplayFromOffset redelivers the outbox from the middle, where two
+// events share the position of their transaction.
+func TestReplayFromOffset(t *testing.T) {
+	pool := dbtest.New(t)
+	relay, sink := newRelay(t, pool)
+	ctx := context.Background()
+
+	tx := begin(t, pool)
+	record(t, tx, 1)
+	commit(t, tx)
+	tx = begin(t, pool)
+	record(t, tx, 2)
+	record(t, tx, 3)
+	commit(t, tx)
+	tx = begin(t, pool)
+	record(t, tx, 4)
+	commit(t, tx)
+	runUntil(t, relay, sink, 4)
+
+	offsets := make(map[int32]Offset)
+	rows, err := pool.Query(ctx, "SELECT (payload->>'id')::int, position, id FROM outbox_events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var voteID int32
+		var o Offset
+		if err := rows.Scan(&voteID, &o.Position, &o.EventID); err != nil {
+			t.Fatal(err)
+		}
+		offsets[voteID] = o
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if offsets[2].Position != offsets[3].Position {
+		t.Fatalf("events of one transaction at %+v and %+v", offsets[2], offsets[3])
+	}
+
+	tests := []struct {
+		name string
+		from Offset
+		want []int32
+	}{
+		{name: "event", from: offsets[3], want: []int32{3, 4}},
+		{name: "position", from: Offset{Position: offsets[3].Position}, want: []int32{2, 3, 4}},
+		{name: "whole outbox", want: []int32{1, 2, 3, 4}},
+	}
+	for _, tt := range tests {
+		delivered := len(sink.events())
+		if err := Replay(ctx, sqlc.New(pool), sink.Name(), tt.from); err != nil {
+			t.Fatal(err)
+		}
+		runUntil(t, relay, sink, delivered+len(tt.want))
+		if got := sink.ids()[delivered:]; !slices.Equal(got, tt.want) {
+			t.Errorf("%s: redelivered %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
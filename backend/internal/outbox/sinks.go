@@ -0,0 +1,27 @@
+package outbox
+
+import (
+	"context"
+
+	"github.com/cloutdotgg/backend/internal/events"
+)
+
+// busSink publishes the outbox on an event bus.
+type busSink struct {
+	bus events.Bus
+}
+
+// NewBusSink returns a sink publishing every outbox event on bus, so that
+// the subscribers of every instance see committed changes. Subscribers
+// tolerate an event published twice.
+func NewBusSink(bus events.Bus) Sink {
+	return busSink{bus: bus}
+}
+
+func (s busSink) Name() string {
+	return "events"
+}
+
+func (s busSink) Deliver(ctx context.Context, m Message) error {
+	return s.bus.Publish(ctx, m.Event)
+}
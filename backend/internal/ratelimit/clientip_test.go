@@ -0,0 +1,161 @@
+package ratelimit
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	tests := []struct {
+		list    string
+		want    []string
+		wantErr bool
+	}{
+		{list: "", want: nil},
+		{list: "10.0.0.1", want: []string{"10.0.0.1/32"}},
+		{list: " 10.0.0.0/8 , 2001:db8::/32 ", want: []string{"10.0.0.0/8", "2001:db8::/32"}},
+		{list: "10.1.2.3/8", want: []string{"10.0.0.0/8"}},
+		{list: "::ffff:10.0.0.1", want: []string{"10.0.0.1/32"}},
+		{list: "::1", want: []string{"::1/128"}},
+		{list: "10.0.0.1,,", want: []string{"10.0.0.1/32"}},
+		{list: "proxy.internal", wantErr: true},
+		{list: "10.0.0.0/33", wantErr: true},
+	}
+	for _, tt := range tests {
+		proxies, err := ParseTrustedProxies(tt.list)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseTrustedProxies(%q) = %v, want an error", tt.list, proxies)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseTrustedProxies(%q): %v", tt.list, err)
+			continue
+		}
+		var got []string
+		for _, p := range proxies {
+			got = append(got, p.String())
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("ParseTrustedProxies(%q) = %v, want %v", tt.list, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("ParseTrustedProxies(%q) = %v, want %v", tt.list, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies("10.0.0.0/8, 2001:db8:ffff::1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		peer string
+		// forwarded are the X-Forwarded-For headers, one value each
+		forwarded []string
+		want      string
+	}{
+		{name: "direct", peer: "203.0.113.7:51234", want: "203.0.113.7"},
+		{name: "bare peer address", peer: "203.0.113.7", want: "203.0.113.7"},
+		{
+			name:      "untrusted peer with spoofed header",
+			peer:      "203.0.113.7:51234",
+			forwarded: []string{"198.51.100.1"},
+			want:      "203.0.113.7",
+		},
+		{
+			name:      "trusted proxy",
+			peer:      "10.0.0.5:443",
+			forwarded: []string{"198.51.100.1"},
+			want:      "198.51.100.1",
+		},
+		{
+			name:      "chain of trusted proxies",
+			peer:      "10.0.0.5:443",
+			forwarded: []string{"198.51.100.1, 10.1.0.1, 10.2.0.1"},
+			want:      "198.51.100.1",
+		},
+		{
+			name:      "chain split over headers",
+			peer:      "10.0.0.5:443",
+			forwarded: []string{"198.51.100.1", "10.1.0.1"},
+			want:      "198.51.100.1",
+		},
+		{
+			name:      "forged left-most hop",
+			peer:      "10.0.0.5:443",
+			forwarded: []string{"192.0.2.66, 198.51.100.1"},
+			want:      "198.51.100.1",
+		},
+		{
+			name:      "forged hop posing as a proxy",
+			peer:      "10.0.0.5:443",
+			forwarded: []string{"10.9.9.9, 198.51.100.1, 10.1.0.1"},
+			want:      "198.51.100.1",
+		},
+		{
+			name:      "only trusted hops",
+			peer:      "10.0.0.5:443",
+			forwarded: []string{"10.1.0.1"},
+			want:      "10.1.0.1",
+		},
+		{
+			name: "trusted proxy without header",
+			peer: "10.0.0.5:443",
+			want: "10.0.0.5",
+		},
+		{
+			name:      "malformed nearest hop",
+			peer:      "10.0.0.5:443",
+			forwarded: []string{"198.51.100.1, not-an-ip"},
+			want:      "10.0.0.5",
+		},
+		{
+			name:      "malformed hop behind the client",
+			peer:      "10.0.0.5:443",
+			forwarded: []string{"not-an-ip, 198.51.100.1"},
+			want:      "198.51.100.1",
+		},
+		{
+			name:      "empty hops",
+			peer:      "10.0.0.5:443",
+			forwarded: []string{" , 198.51.100.1 ,"},
+			want:      "198.51.100.1",
+		},
+		{
+			name:      "hop with port",
+			peer:      "10.0.0.5:443",
+			forwarded: []string{"198.51.100.1:8080"},
+			want:      "198.51.100.1",
+		},
+		{name: "IPv6 keyed by /64", peer: "[2001:db8:1:2:3:4:5:6]:443", want: "2001:db8:1:2::/64"},
+		{
+			name:      "IPv6 client behind IPv6 proxy",
+			peer:      "[2001:db8:ffff::1]:443",
+			forwarded: []string{"2001:db8:aaaa:bbbb:1::2"},
+			want:      "2001:db8:aaaa:bbbb::/64",
+		},
+		{name: "IPv4-mapped IPv6 peer", peer: "[::ffff:203.0.113.7]:443", want: "203.0.113.7"},
+		{name: "malformed peer", peer: "somewhere", want: ""},
+		{name: "empty peer", peer: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			for _, v := range tt.forwarded {
+				header.Add("X-Forwarded-For", v)
+			}
+			if got := proxies.ClientIP(tt.peer, header); got != tt.want {
+				t.Fatalf("ClientIP(%q, %v) = %q, want %q", tt.peer, tt.forwarded, got, tt.want)
+			}
+		})
+	}
+}
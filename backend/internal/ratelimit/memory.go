@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often full buckets are dropped from a Memory limiter.
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	policy  Policy
+}
+
+// refill returns the tokens the bucket holds at now.
+func (b *bucket) refill(now time.Time) float64 {
+	elapsed := now.Sub(b.updated).Seconds()
+	return math.Min(float64(b.policy.Burst), b.tokens+elapsed*b.policy.Rate)
+}
+
+// Memory keeps buckets in process memory. It is safe for concurrent use.
+type Memory struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	// now is the clock buckets refill by
+	now func() time.Time
+}
+
+// NewMemory creates an empty in-memory limiter.
+func NewMemory() *Memory {
+	return &Memory{
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// Name implements Limiter.
+func (m *Memory) Name() string { return "memory" }
+
+// Take implements Limiter.
+func (m *Memory) Take(_ context.Context, key string, policy Policy) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.sweep(now)
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(policy.Burst), updated: now}
+		m.buckets[key] = b
+	}
+	b.policy = policy
+
+	tokens := b.refill(now)
+	if tokens < 1 {
+		return Result{RetryAfter: retryAfter(tokens, policy)}, nil
+	}
+	b.tokens = tokens - 1
+	b.updated = now
+	return Result{Allowed: true}, nil
+}
+
+// sweep drops buckets that have refilled completely, since a missing bucket
+// behaves the same as a full one. The caller must hold m.mu.
+func (m *Memory) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < sweepInterval {
+		return
+	}
+	m.lastSweep = now
+	for key, b := range m.buckets {
+		if b.refill(now) >= float64(b.policy.Burst) {
+			delete(m.buckets, key)
+		}
+	}
+}
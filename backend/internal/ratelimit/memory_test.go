@@ -0,0 +1,138 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// testMemory returns a limiter whose clock only moves when advanced.
+func testMemory() (*Memory, func(time.Duration)) {
+	m := NewMemory()
+	now := time.Now()
+	m.now = func() time.Time { return now }
+	return m, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestMemoryTake(t *testing.T) {
+	policy := PerMinute(60, 3) // a token a second, up to 3
+
+	// Each step advances the clock, then takes a token
+	type step struct {
+		advance    time.Duration
+		allowed    bool
+		retryAfter time.Duration
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name:  "burst",
+			steps: []step{{allowed: true}, {allowed: true}, {allowed: true}, {retryAfter: time.Second}},
+		},
+		{
+			name: "partly refilled",
+			steps: []step{
+				{allowed: true}, {allowed: true}, {allowed: true},
+				{advance: 250 * time.Millisecond, retryAfter: 750 * time.Millisecond},
+				{advance: 500 * time.Millisecond, retryAfter: 250 * time.Millisecond},
+			},
+		},
+		{
+			name: "refill over time",
+			steps: []step{
+				{allowed: true}, {allowed: true}, {allowed: true},
+				{advance: time.Second, allowed: true},
+				{retryAfter: time.Second},
+				{advance: 2 * time.Second, allowed: true},
+				{allowed: true},
+				{retryAfter: time.Second},
+			},
+		},
+		{
+			name: "refill stops at the burst",
+			steps: []step{
+				{allowed: true},
+				{advance: time.Hour, allowed: true},
+				{allowed: true}, {allowed: true},
+				{retryAfter: time.Second},
+			},
+		},
+		{
+			name: "rejections take no token",
+			steps: []step{
+				{allowed: true}, {allowed: true}, {allowed: true},
+				{retryAfter: time.Second}, {retryAfter: time.Second}, {retryAfter: time.Second},
+				{advance: time.Second, allowed: true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, advance := testMemory()
+			for i, s := range tt.steps {
+				advance(s.advance)
+				result, err := m.Take(context.Background(), "key", policy)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if result.Allowed != s.allowed || result.RetryAfter != s.retryAfter {
+					t.Fatalf("step %d: got %+v, want allowed %v, retry after %v", i, result, s.allowed, s.retryAfter)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryKeysAreSeparate(t *testing.T) {
+	m, _ := testMemory()
+	policy := Policy{Rate: 1, Burst: 1}
+	ctx := context.Background()
+
+	if r, _ := m.Take(ctx, "a", policy); !r.Allowed {
+		t.Fatal("first token of a refused")
+	}
+	if r, _ := m.Take(ctx, "a", policy); r.Allowed {
+		t.Fatal("second token of a allowed")
+	}
+	if r, _ := m.Take(ctx, "b", policy); !r.Allowed {
+		t.Fatal("b was limited by a")
+	}
+}
+
+func TestMemorySweepsFullBuckets(t *testing.T) {
+	m, advance := testMemory()
+	policy := Policy{Rate: 1, Burst: 2}
+	ctx := context.Background()
+
+	m.Take(ctx, "idle", policy)
+	advance(sweepInterval)
+	m.Take(ctx, "busy", policy)
+	if _, ok := m.buckets["idle"]; ok {
+		t.Fatal("a refilled bucket was kept")
+	}
+	if _, ok := m.buckets["busy"]; !ok {
+		t.Fatal("a bucket in use was dropped")
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		tokens float64
+		policy Policy
+		want   time.Duration
+	}{
+		{tokens: 0, policy: PerMinute(60, 1), want: time.Second},
+		{tokens: 0.5, policy: PerMinute(60, 1), want: 500 * time.Millisecond},
+		{tokens: 0, policy: PerHour(60, 1), want: time.Minute},
+		{tokens: 0.9999, policy: PerMinute(60, 1), want: time.Millisecond},
+		{tokens: 0, policy: Policy{Rate: 3, Burst: 1}, want: 334 * time.Millisecond},
+		{tokens: 0, policy: Policy{Burst: 1}, want: 0},
+	}
+	for _, tt := range tests {
+		if got := retryAfter(tt.tokens, tt.policy); got != tt.want {
+			t.Errorf("retryAfter(%v, %+v) = %v, want %v", tt.tokens, tt.policy, got, tt.want)
+		}
+	}
+}
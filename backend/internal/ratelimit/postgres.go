@@ -0,0 +1,51 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/cloutdotgg/backend/internal/db/sqlc"
+)
+
+// Postgres keeps buckets in the rate_limit_buckets table so that limits hold
+// across replicas. Each token is taken with a single atomic upsert.
+type Postgres struct {
+	queries *sqlc.Queries
+}
+
+// NewPostgres creates a limiter that stores buckets through q.
+func NewPostgres(q *sqlc.Queries) *Postgres {
+	return &Postgres{queries: q}
+}
+
+// Name implements Limiter.
+func (p *Postgres) Name() string { return "postgres" }
+
+// Take implements Limiter.
+func (p *Postgres) Take(ctx context.Context, key string, policy Policy) (Result, error) {
+	_, err := p.queries.TakeRateLimitToken(ctx, sqlc.TakeRateLimitTokenParams{
+		Key:   key,
+		Burst: float64(policy.Burst),
+		Rate:  policy.Rate,
+	})
+	if err == nil {
+		return Result{Allowed: true}, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return Result{}, fmt.Errorf("failed to take rate limit token: %w", err)
+	}
+
+	// The bucket is empty; look up how far it is from the next token
+	tokens, err := p.queries.GetRateLimitTokens(ctx, sqlc.GetRateLimitTokensParams{
+		Burst: float64(policy.Burst),
+		Rate:  policy.Rate,
+		Key:   key,
+	})
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to read rate limit bucket: %w", err)
+	}
+	return Result{RetryAfter: retryAfter(tokens, policy)}, nil
+}
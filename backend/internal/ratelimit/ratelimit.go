@@ -0,0 +1,74 @@
+// Package ratelimit limits how often a caller may perform an action using
+// token buckets that are kept either in memory or in Postgres.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/cloutdotgg/backend/internal/db/sqlc"
+)
+
+// Policy is a token bucket that holds up to Burst tokens and refills at
+// Rate tokens per second. Each request takes one token.
+type Policy struct {
+	Rate  float64
+	Burst int
+}
+
+// PerMinute returns a policy allowing n requests a minute on average and up
+// to burst in quick succession.
+func PerMinute(n, burst int) Policy {
+	return Policy{Rate: float64(n) / 60, Burst: burst}
+}
+
+// PerHour returns a policy allowing n requests an hour on average and up to
+// burst in quick succession.
+func PerHour(n, burst int) Policy {
+	return Policy{Rate: float64(n) / 3600, Burst: burst}
+}
+
+// Result is the outcome of taking a token.
+type Result struct {
+	Allowed bool
+	// RetryAfter is how long until a token is available when not allowed.
+	RetryAfter time.Duration
+}
+
+// Limiter takes tokens from the bucket identified by key.
+type Limiter interface {
+	// Name returns the identifier used to select the backend.
+	Name() string
+	// Take takes one token from key's bucket under policy.
+	Take(ctx context.Context, key string, policy Policy) (Result, error)
+}
+
+// DefaultBackend is used when no backend has been configured.
+const DefaultBackend = "memory"
+
+// New returns the limiter for backend ("memory" or "postgres"). An empty
+// name selects DefaultBackend. Memory limits are per process; Postgres
+// limits are shared by every replica using the same database.
+func New(backend string, q *sqlc.Queries) (Limiter, error) {
+	switch strings.ToLower(strings.TrimSpace(backend)) {
+	case "", "memory":
+		return NewMemory(), nil
+	case "postgres":
+		return NewPostgres(q), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit backend %q (want memory or postgres)", backend)
+	}
+}
+
+// retryAfter returns how long a bucket holding tokens takes to refill to one
+// token, rounded up to a whole millisecond.
+func retryAfter(tokens float64, policy Policy) time.Duration {
+	if policy.Rate <= 0 {
+		return 0
+	}
+	seconds := (1 - tokens) / policy.Rate
+	return time.Duration(math.Ceil(seconds*1000)) * time.Millisecond
+}
@@ -0,0 +1,44 @@
+package rating
+
+import "math"
+
+// DefaultKFactor is the maximum number of points a single vote can move an
+// Elo rating.
+const DefaultKFactor = 32.0
+
+// Elo is the classic Elo rating system with a fixed K-factor. It does not
+// track uncertainty, so Deviation and Volatility pass through unchanged.
+type Elo struct {
+	K float64
+}
+
+// NewElo creates an Elo rater with the given K-factor.
+func NewElo(k float64) *Elo {
+	return &Elo{K: k}
+}
+
+// Name implements Rater.
+func (e *Elo) Name() string {
+	return "elo"
+}
+
+// Rate implements Rater. The weight scales the K-factor.
+func (e *Elo) Rate(winner, loser Rating, weight float64) (Rating, Rating) {
+	return e.update(winner, loser, 1, weight)
+}
+
+// Draw implements Rater.
+func (e *Elo) Draw(a, b Rating) (Rating, Rating) {
+	return e.update(a, b, 0.5, 1)
+}
+
+// update returns the new ratings of a and b after a scored score against b.
+func (e *Elo) update(a, b Rating, score, weight float64) (Rating, Rating) {
+	expectedA := 1.0 / (1.0 + math.Pow(10, (b.Value-a.Value)/400))
+	expectedB := 1.0 - expectedA
+
+	k := e.K * weight
+	a.Value += k * (score - expectedA)
+	b.Value += k * ((1 - score) - expectedB)
+	return a, b
+}
@@ -0,0 +1,43 @@
+package rating
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEloExpectedScore(t *testing.T) {
+	e := NewElo(DefaultKFactor)
+	tests := []struct {
+		winner, loser float64
+		// expected is the winner's expected score before the vote
+		expected float64
+	}{
+		{winner: 1500, loser: 1500, expected: 0.5},
+		{winner: 1900, loser: 1500, expected: 10.0 / 11},
+		{winner: 1500, loser: 1900, expected: 1.0 / 11},
+		{winner: 2300, loser: 1500, expected: 100.0 / 101},
+	}
+	for _, tt := range tests {
+		a, b := Rating{Value: tt.winner}, Rating{Value: tt.loser}
+		if got := WinProbability(a, b); math.Abs(got-tt.expected) > 1e-12 {
+			t.Errorf("WinProbability(%v, %v) = %v, want %v", tt.winner, tt.loser, got, tt.expected)
+		}
+		winner, loser := e.Rate(a, b, 1)
+		gain := DefaultKFactor * (1 - tt.expected)
+		if math.Abs(winner.Value-(tt.winner+gain)) > 1e-9 || math.Abs(loser.Value-(tt.loser-gain)) > 1e-9 {
+			t.Errorf("%v beat %v: got %v and %v, want a move of %v", tt.winner, tt.loser, winner.Value, loser.Value, gain)
+		}
+	}
+}
+
+func TestEloWeight(t *testing.T) {
+	e := NewElo(DefaultKFactor)
+	a, b := Rating{Value: 1500}, Rating{Value: 1500}
+	for _, s := range []Strength{StrengthSlight, StrengthNormal, StrengthStrong} {
+		winner, loser := e.Rate(a, b, s.Weight())
+		want := DefaultKFactor / 2 * s.Weight()
+		if math.Abs(winner.Value-1500-want) > 1e-9 || math.Abs(1500-loser.Value-want) > 1e-9 {
+			t.Errorf("%s: got %v and %v, want a move of %v", s, winner.Value, loser.Value, want)
+		}
+	}
+}
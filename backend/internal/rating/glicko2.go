@@ -0,0 +1,131 @@
+package rating
+
+import "math"
+
+// DefaultTau constrains how much volatility can change per rating period.
+// Glickman recommends values between 0.3 and 1.2.
+const DefaultTau = 0.5
+
+const (
+	// glicko2Scale converts between the Elo scale and the internal Glicko-2
+	// scale.
+	glicko2Scale = 173.7178
+	// glicko2Epsilon is the convergence tolerance of the volatility iteration.
+	glicko2Epsilon = 0.000001
+)
+
+// Glicko2 implements Mark Glickman's Glicko-2 rating system. Every vote is
+// treated as its own rating period containing a single game, which lets
+// ratings update immediately instead of in batches.
+type Glicko2 struct {
+	Tau float64
+}
+
+// NewGlicko2 creates a Glicko-2 rater with the given system constant.
+func NewGlicko2(tau float64) *Glicko2 {
+	return &Glicko2{Tau: tau}
+}
+
+// Name implements Rater.
+func (g *Glicko2) Name() string {
+	return "glicko2"
+}
+
+// Rate implements Rater. The weight is the number of identical games the
+// vote counts as within its rating period.
+func (g *Glicko2) Rate(winner, loser Rating, weight float64) (Rating, Rating) {
+	return g.update(winner, loser, 1, weight), g.update(loser, winner, 0, weight)
+}
+
+// Draw implements Rater.
+func (g *Glicko2) Draw(a, b Rating) (Rating, Rating) {
+	return g.update(a, b, 0.5, 1), g.update(b, a, 0.5, 1)
+}
+
+// update returns the new rating of player after scoring score against
+// opponent in weight games.
+func (g *Glicko2) update(player, opponent Rating, score, weight float64) Rating {
+	return g.period(player, glicko2Game{opponent: opponent, score: score, weight: weight})
+}
+
+// glicko2Game is one result within a rating period.
+type glicko2Game struct {
+	opponent Rating
+	score    float64
+	weight   float64
+}
+
+// period returns the new rating of player after the games of one rating
+// period, following the steps in "Example of the Glicko-2 system".
+func (g *Glicko2) period(player Rating, games ...glicko2Game) Rating {
+	mu := (player.Value - DefaultRating) / glicko2Scale
+	phi := player.Deviation / glicko2Scale
+	sigma := player.Volatility
+
+	var vInv, improvement float64
+	for _, game := range games {
+		muJ := (game.opponent.Value - DefaultRating) / glicko2Scale
+		phiJ := game.opponent.Deviation / glicko2Scale
+
+		gJ := glicko2G(phiJ)
+		expected := 1 / (1 + math.Exp(-gJ*(mu-muJ)))
+		vInv += game.weight * gJ * gJ * expected * (1 - expected)
+		improvement += game.weight * gJ * (game.score - expected)
+	}
+	v := 1 / vInv
+	delta := v * improvement
+
+	sigma = g.volatility(phi, sigma, v, delta)
+
+	phiStar := math.Sqrt(phi*phi + sigma*sigma)
+	newPhi := 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	newMu := mu + newPhi*newPhi*improvement
+
+	return Rating{
+		Value:      newMu*glicko2Scale + DefaultRating,
+		Deviation:  math.Min(newPhi*glicko2Scale, DefaultDeviation),
+		Volatility: sigma,
+	}
+}
+
+// volatility solves for the new volatility using the Illinois algorithm.
+func (g *Glicko2) volatility(phi, sigma, v, delta float64) float64 {
+	a := math.Log(sigma * sigma)
+	tau2 := g.Tau * g.Tau
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+		return ex*(delta*delta-phi*phi-v-ex)/(2*d*d) - (x-a)/tau2
+	}
+
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*g.Tau) < 0 {
+			k++
+		}
+		B = a - k*g.Tau
+	}
+
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > glicko2Epsilon {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+
+	return math.Exp(A / 2)
+}
+
+// glicko2G reduces the impact of a game based on the opponent's deviation.
+func glicko2G(phi float64) float64 {
+	return 1 / math.Sqrt(1+3*phi*phi/(math.Pi*math.Pi))
+}
@@ -0,0 +1,50 @@
+package rating
+
+import (
+	"math"
+	"testing"
+)
+
+// TestGlicko2Example checks the engine against the worked example in
+// Glickman's "Example of the Glicko-2 system": one rating period in which a
+// 1500 player beats a 1400 opponent and loses to a 1550 and a 1700 one.
+func TestGlicko2Example(t *testing.T) {
+	g := NewGlicko2(0.5)
+	player := Rating{Value: 1500, Deviation: 200, Volatility: 0.06}
+	got := g.period(player,
+		glicko2Game{opponent: Rating{Value: 1400, Deviation: 30}, score: 1, weight: 1},
+		glicko2Game{opponent: Rating{Value: 1550, Deviation: 100}, score: 0, weight: 1},
+		glicko2Game{opponent: Rating{Value: 1700, Deviation: 300}, score: 0, weight: 1},
+	)
+
+	want := Rating{Value: 1464.06, Deviation: 151.52, Volatility: 0.05999}
+	if math.Abs(got.Value-want.Value) > 0.01 ||
+		math.Abs(got.Deviation-want.Deviation) > 0.01 ||
+		math.Abs(got.Volatility-want.Volatility) > 0.00001 {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+// TestGlicko2Weight checks that a vote of weight w moves a rating as far as w
+// identical games in one period.
+func TestGlicko2Weight(t *testing.T) {
+	g := NewGlicko2(DefaultTau)
+	a := Rating{Value: 1500, Deviation: 200, Volatility: DefaultVolatility}
+	b := Rating{Value: 1600, Deviation: 80, Volatility: DefaultVolatility}
+
+	winner, loser := g.Rate(a, b, 2)
+	game := glicko2Game{opponent: b, score: 1, weight: 1}
+	if want := g.period(a, game, game); !sameRating(winner, want) {
+		t.Errorf("winner %+v, want %+v", winner, want)
+	}
+	game = glicko2Game{opponent: a, score: 0, weight: 1}
+	if want := g.period(b, game, game); !sameRating(loser, want) {
+		t.Errorf("loser %+v, want %+v", loser, want)
+	}
+}
+
+func sameRating(a, b Rating) bool {
+	return math.Abs(a.Value-b.Value) < 1e-9 &&
+		math.Abs(a.Deviation-b.Deviation) < 1e-9 &&
+		math.Abs(a.Volatility-b.Volatility) < 1e-9
+}
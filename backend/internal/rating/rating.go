@@ -0,0 +1,102 @@
+// Package rating implements the rating engines used to turn head-to-head
+// votes into company ratings.
+package rating
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Default values for a company that has not been rated yet. Ratings are
+// always expressed on the familiar Elo scale regardless of the engine.
+const (
+	DefaultRating     = 1500.0
+	DefaultDeviation  = 350.0
+	DefaultVolatility = 0.06
+)
+
+// Rating is a company's current skill estimate.
+type Rating struct {
+	// Value is the point estimate on the Elo scale.
+	Value float64
+	// Deviation is the uncertainty of Value, also on the Elo scale. Engines
+	// that do not model uncertainty leave it unchanged.
+	Deviation float64
+	// Volatility is the expected fluctuation of Value over time. Only used by
+	// Glicko-2.
+	Volatility float64
+}
+
+// Initial returns the rating assigned to a newly added company.
+func Initial() Rating {
+	return Rating{
+		Value:      DefaultRating,
+		Deviation:  DefaultDeviation,
+		Volatility: DefaultVolatility,
+	}
+}
+
+// Rater updates ratings from the outcome of a single matchup.
+type Rater interface {
+	// Name returns the identifier used to select the engine in config.
+	Name() string
+	// Rate returns the new ratings of the winner and the loser of a matchup.
+	// weight scales the update, with 1 for an ordinary vote; see
+	// Strength.Weight.
+	Rate(winner, loser Rating, weight float64) (Rating, Rating)
+	// Draw returns the new ratings of two companies judged equal, each
+	// scoring half a win.
+	Draw(a, b Rating) (Rating, Rating)
+}
+
+// WinProbability returns the chance that a company rated a is preferred over
+// one rated b, from the Elo expected score of their current values.
+func WinProbability(a, b Rating) float64 {
+	return 1 / (1 + math.Pow(10, (b.Value-a.Value)/400))
+}
+
+// Decay moves r the fraction rate of the way back to DefaultRating and
+// widens its deviation by deviationIncrease, up to DefaultDeviation. It is
+// applied to companies that have gone without votes, whose ratings are
+// less certain the longer they go unchallenged.
+func Decay(r Rating, rate, deviationIncrease float64) Rating {
+	r.Value -= rate * (r.Value - DefaultRating)
+	r.Deviation = math.Min(r.Deviation+deviationIncrease, math.Max(r.Deviation, DefaultDeviation))
+	return r
+}
+
+// Strength is how clearly a voter preferred the winner of a matchup.
+type Strength string
+
+// Strengths a voter can choose from.
+const (
+	StrengthSlight Strength = "slight"
+	StrengthNormal Strength = "normal"
+	StrengthStrong Strength = "strong"
+)
+
+// Weight returns how many ordinary votes a vote of this strength counts as.
+// Unknown strengths count as ordinary votes.
+func (s Strength) Weight() float64 {
+	switch s {
+	case StrengthSlight:
+		return 0.5
+	case StrengthStrong:
+		return 1.5
+	default:
+		return 1
+	}
+}
+
+// New returns the rater registered under name. An empty name selects Elo.
+func New(name string) (Rater, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "elo":
+		return NewElo(DefaultKFactor), nil
+	case "glicko2", "glicko-2":
+		return NewGlicko2(DefaultTau), nil
+	default:
+		return nil, fmt.Errorf("unknown rating engine %q", name)
+	}
+}
@@ -0,0 +1,36 @@
+package rating
+
+import "testing"
+
+// TestDraw checks that a draw does not depend on which side is listed first
+// and that it leaves equal ratings equal.
+func TestDraw(t *testing.T) {
+	for _, engine := range []string{"elo", "glicko2"} {
+		t.Run(engine, func(t *testing.T) {
+			r, err := New(engine)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pairs := []struct{ a, b Rating }{
+				{a: Initial(), b: Initial()},
+				{
+					a: Rating{Value: 1700, Deviation: 80, Volatility: DefaultVolatility},
+					b: Rating{Value: 1450, Deviation: 250, Volatility: 0.07},
+				},
+			}
+			for _, p := range pairs {
+				a, b := r.Draw(p.a, p.b)
+				b2, a2 := r.Draw(p.b, p.a)
+				if !sameRating(a, a2) || !sameRating(b, b2) {
+					t.Errorf("Draw(%+v, %+v) = %+v, %+v; swapped %+v, %+v", p.a, p.b, a, b, a2, b2)
+				}
+				if p.a == p.b && !sameRating(a, b) {
+					t.Errorf("Draw of equals gave %+v and %+v", a, b)
+				}
+				if p.a.Value > p.b.Value && (a.Value >= p.a.Value || b.Value <= p.b.Value) {
+					t.Errorf("Draw(%+v, %+v) = %+v, %+v; want the gap to narrow", p.a, p.b, a, b)
+				}
+			}
+		})
+	}
+}
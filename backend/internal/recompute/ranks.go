@@ -0,0 +1,76 @@
+package recompute
+
+// ranks keeps the rounded Elo of every company in a Fenwick tree indexed by
+// Elo, so the rank of a company after each replayed vote costs a logarithmic
+// number of steps instead of a scan of every company.
+type ranks struct {
+	// lo is the Elo counted at tree[1]; tree covers lo to lo+len(tree)-2
+	lo   int32
+	tree []int32
+	elo  map[int32]int32
+}
+
+func newRanks() *ranks {
+	return &ranks{elo: make(map[int32]int32)}
+}
+
+// set records the rounded Elo of a company, replacing its previous one.
+func (r *ranks) set(companyID, elo int32) {
+	if old, ok := r.elo[companyID]; ok {
+		if old == elo {
+			return
+		}
+		r.add(old, -1)
+	}
+	r.elo[companyID] = elo
+	if len(r.tree) == 0 || elo < r.lo || elo > r.lo+int32(len(r.tree))-2 {
+		r.grow(elo)
+		return
+	}
+	r.add(elo, 1)
+}
+
+// rank returns one more than the number of companies with a higher Elo.
+func (r *ranks) rank(elo int32) int32 {
+	return 1 + int32(len(r.elo)) - r.atMost(elo)
+}
+
+// atMost counts the companies with an Elo of at most elo.
+func (r *ranks) atMost(elo int32) int32 {
+	if len(r.tree) == 0 || elo < r.lo {
+		return 0
+	}
+	i := int(elo-r.lo) + 1
+	if i >= len(r.tree) {
+		i = len(r.tree) - 1
+	}
+	var n int32
+	for ; i > 0; i -= i & -i {
+		n += r.tree[i]
+	}
+	return n
+}
+
+func (r *ranks) add(elo, delta int32) {
+	for i := int(elo-r.lo) + 1; i < len(r.tree); i += i & -i {
+		r.tree[i] += delta
+	}
+}
+
+// grow rebuilds the tree over a range wide enough for every recorded Elo
+// and elo, with room to spare so ratings drifting outward rebuild it rarely.
+func (r *ranks) grow(elo int32) {
+	lo, hi := elo, elo
+	for _, e := range r.elo {
+		lo = min(lo, e)
+		hi = max(hi, e)
+	}
+	const margin = 512
+	lo -= margin
+	hi += margin
+	r.lo = lo
+	r.tree = make([]int32, hi-lo+2)
+	for _, e := range r.elo {
+		r.add(e, 1)
+	}
+}
@@ -0,0 +1,31 @@
+package recompute
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestRanksMatchScan checks ranks against counting the higher ratings of
+// every company, including ratings far outside the tree's first range.
+func TestRanksMatchScan(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	r := newRanks()
+	elo := make(map[int32]int32)
+	for i := 0; i < 5000; i++ {
+		id := int32(rng.Intn(50))
+		e := 1500 + int32(rng.NormFloat64()*400)
+		r.set(id, e)
+		elo[id] = e
+
+		probe := 1500 + int32(rng.NormFloat64()*600)
+		want := int32(1)
+		for _, other := range elo {
+			if other > probe {
+				want++
+			}
+		}
+		if got := r.rank(probe); got != want {
+			t.Fatalf("step %d: rank(%d) = %d, want %d", i, probe, got, want)
+		}
+	}
+}
@@ -0,0 +1,516 @@
+// Package recompute rebuilds company ratings by replaying the votes log
+// through a rating engine.
+package recompute
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/cloutdotgg/backend/internal/db/sqlc"
+	"github.com/cloutdotgg/backend/internal/events"
+	"github.com/cloutdotgg/backend/internal/outbox"
+	"github.com/cloutdotgg/backend/internal/rating"
+	"github.com/cloutdotgg/backend/internal/trending"
+)
+
+// Outcomes of a vote other than a win, as stored in votes.outcome.
+const (
+	outcomeDraw = "draw"
+	outcomeSkip = "skip"
+)
+
+// State is the rating-related state of a single company.
+type State struct {
+	Rating     rating.Rating
+	Wins       int32
+	Losses     int32
+	Draws      int32
+	Skips      int32
+	TotalVotes int32
+}
+
+// Change describes how recomputing affected one company.
+type Change struct {
+	CompanyID int32
+	Name      string
+	Slug      string
+	Before    State
+	After     State
+}
+
+// EloDiff returns the change in rounded Elo points.
+func (c Change) EloDiff() int32 {
+	return int32(math.Round(c.After.Rating.Value)) - int32(math.Round(c.Before.Rating.Value))
+}
+
+// apply feeds a win of the given strength or a draw through rater and adds
+// it to both companies' records.
+func apply(rater rating.Rater, outcome string, strength rating.Strength, winner, loser *State) {
+	if outcome == outcomeDraw {
+		winner.Rating, loser.Rating = rater.Draw(winner.Rating, loser.Rating)
+		winner.Draws++
+		loser.Draws++
+	} else {
+		winner.Rating, loser.Rating = rater.Rate(winner.Rating, loser.Rating, strength.Weight())
+		winner.Wins++
+		loser.Losses++
+	}
+	winner.TotalVotes++
+	loser.TotalVotes++
+}
+
+// categoryKey identifies a company's rating within one category.
+type categoryKey struct {
+	companyID int32
+	category  string
+}
+
+// categoryState returns the replayed category state of a company, starting it
+// from the initial rating on first use.
+func categoryState(states map[categoryKey]*State, companyID int32, category string) *State {
+	key := categoryKey{companyID: companyID, category: category}
+	state, ok := states[key]
+	if !ok {
+		state = &State{Rating: rating.Initial()}
+		states[key] = state
+	}
+	return state
+}
+
+// Votes are replayed replayPageSize at a time, and the replayed history is
+// written historyBatchSize rows at a time.
+const (
+	replayPageSize   = 5000
+	historyBatchSize = 5000
+)
+
+// Options control a recompute run.
+type Options struct {
+	// DryRun only reports the differences without writing anything.
+	DryRun bool
+	// Since limits the rewritten rating history and snapshots to the time
+	// from which the votes log changed, such as the first vote excluded as
+	// fraud. History before it is identical in a full replay and is kept.
+	// The zero value rewrites everything.
+	Since time.Time
+}
+
+// Report summarizes a recompute run.
+type Report struct {
+	// VotesReplayed is the number of votes fed through the rating engine.
+	VotesReplayed int
+	// DecaysReplayed is the number of rating decays applied between them.
+	DecaysReplayed int
+	// Changes lists every company whose stored state differs from the
+	// recomputed one, in company id order.
+	Changes []Change
+	// Applied is true when the recomputed state was written to the database.
+	Applied bool
+}
+
+// Run replays every counted vote in created_at order through rater, starting
+// each company from the initial rating and applying the recorded rating
+// decays in between. With opts.DryRun set it only reports
+// the differences; otherwise the new state, the category ratings, the rating
+// history and the daily snapshots are written in the same transaction that
+// read the votes, while votes are blocked, so the swap is atomic. The rating
+// changes kept for undoing recent votes are rewritten to what the replay
+// changed, and a run that changes ratings records a KindRatingsChanged event
+// in the outbox.
+func Run(ctx context.Context, pool *pgxpool.Pool, rater rating.Rater, opts Options) (*Report, error) {
+	txOptions := pgx.TxOptions{}
+	if opts.DryRun {
+		txOptions = pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly}
+	}
+
+	tx, err := pool.BeginTx(ctx, txOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	report, err := Replay(ctx, tx, rater, opts)
+	if err != nil || opts.DryRun {
+		return report, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit recompute: %w", err)
+	}
+	report.Applied = true
+
+	return report, nil
+}
+
+// Replay does the work of Run inside a transaction owned by the caller, so
+// that changes to the votes log and the recompute they require are applied
+// together. Report.Applied is left for the caller to set once it commits.
+func Replay(ctx context.Context, tx pgx.Tx, rater rating.Rater, opts Options) (*Report, error) {
+	q := sqlc.New(tx)
+	if !opts.DryRun {
+		if err := q.LockCompaniesExclusive(ctx); err != nil {
+			return nil, fmt.Errorf("failed to lock companies: %w", err)
+		}
+	}
+
+	companies, err := q.ListCompanyRatingStates(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list companies: %w", err)
+	}
+
+	decays, err := q.ListRatingDecaysForReplay(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rating decays: %w", err)
+	}
+
+	// A dry run only compares ratings, so it writes no history
+	var history *historyWriter
+	// undoable holds the votes whose rating changes are kept for undo, which
+	// are rewritten to what the replay changed
+	undoable := make(map[int32]bool)
+	var deltas []sqlc.SetVoteRatingDeltaParams
+	if !opts.DryRun {
+		if history, err = newHistoryWriter(ctx, q, opts.Since); err != nil {
+			return nil, err
+		}
+		ids, err := q.ListVoteRatingDeltaVoteIDs(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list undoable votes: %w", err)
+		}
+		for _, id := range ids {
+			undoable[id] = true
+		}
+	}
+	delta := func(voteID, companyID int32, category string, before, after rating.Rating) {
+		deltas = append(deltas, sqlc.SetVoteRatingDeltaParams{
+			VoteID:          voteID,
+			CompanyID:       companyID,
+			Category:        category,
+			RatingDelta:     after.Value - before.Value,
+			DeviationDelta:  after.Deviation - before.Deviation,
+			VolatilityDelta: after.Volatility - before.Volatility,
+		})
+	}
+
+	states := make(map[int32]*State, len(companies))
+	categoryStates := make(map[categoryKey]*State)
+	ranked := newRanks()
+	for _, c := range companies {
+		states[c.ID] = &State{Rating: rating.Initial()}
+		ranked.set(c.ID, int32(math.Round(rating.Initial().Value)))
+	}
+	record := func(companyID int32, voteID *int32, at pgtype.Timestamptz) error {
+		if history == nil || (!opts.Since.IsZero() && at.Valid && at.Time.Before(opts.Since)) {
+			return nil
+		}
+		return history.add(ctx, historyRow(states[companyID], ranked, companyID, voteID, at))
+	}
+	for _, c := range companies {
+		if err := record(c.ID, nil, c.CreatedAt); err != nil {
+			return nil, err
+		}
+	}
+	// Decays are applied with the parameters they were recorded with, to
+	// whatever the replayed rating is at that point
+	nextDecay := 0
+	applyDecays := func(before pgtype.Timestamptz) error {
+		for ; nextDecay < len(decays); nextDecay++ {
+			d := decays[nextDecay]
+			if before.Valid && !d.CreatedAt.Time.Before(before.Time) {
+				return nil
+			}
+			state := states[d.CompanyID]
+			state.Rating = rating.Decay(state.Rating, d.Rate, d.DeviationIncrease)
+			ranked.set(d.CompanyID, int32(math.Round(state.Rating.Value)))
+			if err := record(d.CompanyID, nil, d.CreatedAt); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	votesReplayed := 0
+	replay := func(v sqlc.ListVotesForReplayRow) error {
+		votesReplayed++
+		if err := applyDecays(v.CreatedAt); err != nil {
+			return err
+		}
+
+		winner, loser := states[v.WinnerID], states[v.LoserID]
+		if v.Outcome == outcomeSkip {
+			// Skips change no rating, so they leave no history either
+			winner.Skips++
+			loser.Skips++
+			return nil
+		}
+		winnerBefore, loserBefore := winner.Rating, loser.Rating
+		apply(rater, v.Outcome, rating.Strength(v.Strength), winner, loser)
+		if undoable[v.ID] {
+			delta(v.ID, v.WinnerID, "", winnerBefore, winner.Rating)
+			delta(v.ID, v.LoserID, "", loserBefore, loser.Rating)
+		}
+		ranked.set(v.WinnerID, int32(math.Round(winner.Rating.Value)))
+		ranked.set(v.LoserID, int32(math.Round(loser.Rating.Value)))
+
+		if err := record(v.WinnerID, &v.ID, v.CreatedAt); err != nil {
+			return err
+		}
+		if err := record(v.LoserID, &v.ID, v.CreatedAt); err != nil {
+			return err
+		}
+
+		if v.Category != nil {
+			winner := categoryState(categoryStates, v.WinnerID, *v.Category)
+			loser := categoryState(categoryStates, v.LoserID, *v.Category)
+			winnerBefore, loserBefore := winner.Rating, loser.Rating
+			apply(rater, v.Outcome, rating.Strength(v.Strength), winner, loser)
+			if undoable[v.ID] {
+				delta(v.ID, v.WinnerID, *v.Category, winnerBefore, winner.Rating)
+				delta(v.ID, v.LoserID, *v.Category, loserBefore, loser.Rating)
+			}
+		}
+		return nil
+	}
+
+	// Votes are read a page at a time so the log never has to fit in memory.
+	// Votes without a timestamp sort after every dated one, as they would
+	// in ORDER BY created_at.
+	after := pgtype.Timestamptz{InfinityModifier: pgtype.NegativeInfinity, Valid: true}
+	var afterID int32
+	for {
+		votes, err := q.ListVotesForReplay(ctx, sqlc.ListVotesForReplayParams{
+			AfterCreatedAt: after,
+			AfterID:        afterID,
+			PageLimit:      replayPageSize,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list votes: %w", err)
+		}
+		for _, v := range votes {
+			if err := replay(v); err != nil {
+				return nil, err
+			}
+		}
+		if len(votes) < replayPageSize {
+			break
+		}
+		after, afterID = votes[len(votes)-1].CreatedAt, votes[len(votes)-1].ID
+	}
+	afterID = 0
+	for {
+		votes, err := q.ListUndatedVotesForReplay(ctx, sqlc.ListUndatedVotesForReplayParams{
+			AfterID:   afterID,
+			PageLimit: replayPageSize,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list votes: %w", err)
+		}
+		for _, v := range votes {
+			if err := replay(sqlc.ListVotesForReplayRow(v)); err != nil {
+				return nil, err
+			}
+		}
+		if len(votes) < replayPageSize {
+			break
+		}
+		afterID = votes[len(votes)-1].ID
+	}
+
+	if err := applyDecays(pgtype.Timestamptz{}); err != nil {
+		return nil, err
+	}
+
+	report := &Report{VotesReplayed: votesReplayed, DecaysReplayed: len(decays)}
+	for _, c := range companies {
+		before := State{
+			Rating: rating.Rating{
+				Value:      c.Rating,
+				Deviation:  c.RatingDeviation,
+				Volatility: c.RatingVolatility,
+			},
+			Wins:       c.Wins,
+			Losses:     c.Losses,
+			Draws:      c.Draws,
+			Skips:      c.Skips,
+			TotalVotes: c.TotalVotes,
+		}
+		after := *states[c.ID]
+		if before == after {
+			continue
+		}
+		report.Changes = append(report.Changes, Change{
+			CompanyID: c.ID,
+			Name:      c.Name,
+			Slug:      c.Slug,
+			Before:    before,
+			After:     after,
+		})
+	}
+
+	if opts.DryRun {
+		return report, nil
+	}
+
+	for _, c := range report.Changes {
+		if err := q.SetCompanyRatingState(ctx, sqlc.SetCompanyRatingStateParams{
+			ID:               c.CompanyID,
+			Rating:           c.After.Rating.Value,
+			RatingDeviation:  c.After.Rating.Deviation,
+			RatingVolatility: c.After.Rating.Volatility,
+			Wins:             c.After.Wins,
+			Losses:           c.After.Losses,
+			Draws:            c.After.Draws,
+			Skips:            c.After.Skips,
+			TotalVotes:       c.After.TotalVotes,
+		}); err != nil {
+			return nil, fmt.Errorf("failed to update company %d: %w", c.CompanyID, err)
+		}
+	}
+
+	if err := q.ResetCategoryRatings(ctx); err != nil {
+		return nil, fmt.Errorf("failed to reset category ratings: %w", err)
+	}
+	for key, state := range categoryStates {
+		if err := q.SetCategoryRatingState(ctx, sqlc.SetCategoryRatingStateParams{
+			CompanyID:        key.companyID,
+			Category:         key.category,
+			Rating:           state.Rating.Value,
+			RatingDeviation:  state.Rating.Deviation,
+			RatingVolatility: state.Rating.Volatility,
+			Wins:             state.Wins,
+			Losses:           state.Losses,
+			TotalVotes:       state.TotalVotes,
+		}); err != nil {
+			return nil, fmt.Errorf("failed to update company %d in %q: %w", key.companyID, key.category, err)
+		}
+	}
+
+	if err := history.finish(ctx); err != nil {
+		return nil, err
+	}
+
+	// An undo subtracts what the vote changed from the replayed ratings, so
+	// it must be what the replay changed rather than what the vote did when
+	// it was cast
+	for _, d := range deltas {
+		if err := q.SetVoteRatingDelta(ctx, d); err != nil {
+			return nil, fmt.Errorf("failed to rewrite the rating changes of vote %d: %w", d.VoteID, err)
+		}
+	}
+
+	if len(report.Changes) > 0 {
+		ids := make([]int32, len(report.Changes))
+		for i, c := range report.Changes {
+			ids[i] = c.CompanyID
+		}
+		if err := outbox.Record(ctx, q, events.Event{Kind: events.KindRatingsChanged, CompanyIDs: ids}); err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+// historyWriter writes the replayed rating history in batches as the replay
+// produces it, and regenerates the trending rollup and the daily snapshots
+// once it is complete.
+type historyWriter struct {
+	q     *sqlc.Queries
+	since time.Time
+	batch []sqlc.InsertRatingHistoryParams
+	// first is the earliest time written, from which snapshots are rebuilt
+	first time.Time
+}
+
+// newHistoryWriter clears the rating history from since onwards, and the
+// snapshots too when since is zero, so the replay can write it afresh.
+func newHistoryWriter(ctx context.Context, q *sqlc.Queries, since time.Time) (*historyWriter, error) {
+	if since.IsZero() {
+		if err := q.DeleteRatingHistory(ctx); err != nil {
+			return nil, fmt.Errorf("failed to clear rating history: %w", err)
+		}
+		if err := q.DeleteRatingSnapshots(ctx); err != nil {
+			return nil, fmt.Errorf("failed to clear rating snapshots: %w", err)
+		}
+	} else {
+		if err := q.DeleteRatingHistorySince(ctx, pgtype.Timestamptz{Time: since, Valid: true}); err != nil {
+			return nil, fmt.Errorf("failed to clear rating history: %w", err)
+		}
+	}
+	return &historyWriter{q: q, since: since}, nil
+}
+
+func (w *historyWriter) add(ctx context.Context, row sqlc.InsertRatingHistoryParams) error {
+	if row.CreatedAt.Valid && (w.first.IsZero() || row.CreatedAt.Time.Before(w.first)) {
+		w.first = row.CreatedAt.Time
+	}
+	w.batch = append(w.batch, row)
+	if len(w.batch) < historyBatchSize {
+		return nil
+	}
+	return w.flush(ctx)
+}
+
+func (w *historyWriter) flush(ctx context.Context) error {
+	if len(w.batch) == 0 {
+		return nil
+	}
+	if _, err := w.q.InsertRatingHistory(ctx, w.batch); err != nil {
+		return fmt.Errorf("failed to write rating history: %w", err)
+	}
+	w.batch = w.batch[:0]
+	return nil
+}
+
+// finish writes the rest of the history, then rebuilds the rollup and the
+// snapshots from it.
+func (w *historyWriter) finish(ctx context.Context) error {
+	if err := w.flush(ctx); err != nil {
+		return err
+	}
+
+	// Trending leaderboards read the rewritten history through the rollup
+	if err := trending.Roll(ctx, w.q, w.since); err != nil {
+		return err
+	}
+
+	// Snapshots are upserted, so days before since are left as they are
+	first := w.since.UTC()
+	if w.since.IsZero() {
+		if w.first.IsZero() {
+			return nil
+		}
+		first = w.first.UTC()
+	}
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	for day := first.Truncate(24 * time.Hour); !day.After(today); day = day.AddDate(0, 0, 1) {
+		if err := w.q.SnapshotRatingsForDay(ctx, pgtype.Date{Time: day, Valid: true}); err != nil {
+			return fmt.Errorf("failed to snapshot %s: %w", day.Format(time.DateOnly), err)
+		}
+	}
+
+	return nil
+}
+
+// historyRow captures the replayed state of a company after a vote, ranked
+// against the replayed state of every other company.
+func historyRow(state *State, ranked *ranks, companyID int32, voteID *int32, at pgtype.Timestamptz) sqlc.InsertRatingHistoryParams {
+	elo := int32(math.Round(state.Rating.Value))
+	return sqlc.InsertRatingHistoryParams{
+		CompanyID:       companyID,
+		VoteID:          voteID,
+		Rating:          state.Rating.Value,
+		EloRating:       elo,
+		RatingDeviation: state.Rating.Deviation,
+		Rank:            ranked.rank(elo),
+		Wins:            state.Wins,
+		Losses:          state.Losses,
+		TotalVotes:      state.TotalVotes,
+		CreatedAt:       at,
+	}
+}
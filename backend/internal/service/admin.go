@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"strings"
+
+	"connectrpc.com/connect"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/cloutdotgg/backend/internal/db/sqlc"
+	gen "github.com/cloutdotgg/backend/internal/gen/apiv1"
+	"github.com/cloutdotgg/backend/internal/matchmaking"
+	"github.com/cloutdotgg/backend/internal/rating"
+	"github.com/cloutdotgg/backend/internal/recompute"
+)
+
+// AdminService implements the AdminServiceHandler interface
+type AdminService struct {
+	db          *pgxpool.Pool
+	queries     *sqlc.Queries
+	rater       rating.Rater
+	matchmaking matchmaking.Strategy
+}
+
+// NewAdminService creates a new admin service. strategy is the matchmaking
+// strategy used when none has been selected.
+func NewAdminService(db *pgxpool.Pool, rater rating.Rater, strategy matchmaking.Strategy) *AdminService {
+	return &AdminService{
+		db:          db,
+		queries:     sqlc.New(db),
+		rater:       rater,
+		matchmaking: strategy,
+	}
+}
+
+// NewAdminAuthInterceptor rejects requests that do not carry the admin API
+// key as a bearer token
+func NewAdminAuthInterceptor(apiKey string) connect.Interceptor {
+	return connect.UnaryInterceptorFunc(func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			token, ok := strings.CutPrefix(req.Header().Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(apiKey)) != 1 {
+				return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("invalid admin credentials"))
+			}
+			return next(ctx, req)
+		}
+	})
+}
+
+// RecomputeRatings rebuilds every company's rating from the votes log
+func (s *AdminService) RecomputeRatings(
+	ctx context.Context,
+	req *connect.Request[gen.RecomputeRatingsRequest],
+) (*connect.Response[gen.RecomputeRatingsResponse], error) {
+	report, err := recompute.Run(ctx, s.db, s.rater, recompute.Options{DryRun: req.Msg.DryRun})
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	return connect.NewResponse(&gen.RecomputeRatingsResponse{
+		VotesReplayed:  int32(report.VotesReplayed),
+		Changes:        ratingChangesToProto(report.Changes),
+		Applied:        report.Applied,
+		DecaysReplayed: int32(report.DecaysReplayed),
+	}), nil
+}
+
+// ratingChangesToProto converts the changes of a recompute report
+func ratingChangesToProto(changes []recompute.Change) []*gen.RatingChange {
+	protoChanges := make([]*gen.RatingChange, len(changes))
+	for i, c := range changes {
+		protoChanges[i] = &gen.RatingChange{
+			CompanyId:          c.CompanyID,
+			Name:               c.Name,
+			Slug:               c.Slug,
+			OldRating:          c.Before.Rating.Value,
+			NewRating:          c.After.Rating.Value,
+			EloDiff:            c.EloDiff(),
+			OldRatingDeviation: c.Before.Rating.Deviation,
+			NewRatingDeviation: c.After.Rating.Deviation,
+			OldWins:            c.Before.Wins,
+			NewWins:            c.After.Wins,
+			OldLosses:          c.Before.Losses,
+			NewLosses:          c.After.Losses,
+			OldTotalVotes:      c.Before.TotalVotes,
+			NewTotalVotes:      c.After.TotalVotes,
+		}
+	}
+	return protoChanges
+}
+
+// GetMatchmakingStrategy returns the strategy currently used for matchups
+func (s *AdminService) GetMatchmakingStrategy(
+	ctx context.Context,
+	req *connect.Request[gen.GetMatchmakingStrategyRequest],
+) (*connect.Response[gen.GetMatchmakingStrategyResponse], error) {
+	strategy := matchmakingStrategy(ctx, s.queries, s.matchmaking)
+
+	return connect.NewResponse(&gen.GetMatchmakingStrategyResponse{
+		Strategy:            strategy.Name(),
+		DefaultStrategy:     s.matchmaking.Name(),
+		AvailableStrategies: matchmaking.Names(),
+	}), nil
+}
+
+// SetMatchmakingStrategy switches the strategy used for matchups on every
+// server instance, each of which picks it up within matchupCacheTTL
+func (s *AdminService) SetMatchmakingStrategy(
+	ctx context.Context,
+	req *connect.Request[gen.SetMatchmakingStrategyRequest],
+) (*connect.Response[gen.SetMatchmakingStrategyResponse], error) {
+	strategy, err := matchmaking.New(req.Msg.Strategy)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+
+	if err := s.queries.SetSetting(ctx, sqlc.SetSettingParams{
+		Key:   matchmakingStrategySetting,
+		Value: strategy.Name(),
+	}); err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	return connect.NewResponse(&gen.SetMatchmakingStrategyResponse{
+		Strategy: strategy.Name(),
+	}), nil
+}
+
+// RevokeSession stops a session's token from being accepted
+func (s *AdminService) RevokeSession(
+	ctx context.Context,
+	req *connect.Request[gen.RevokeSessionRequest],
+) (*connect.Response[gen.RevokeSessionResponse], error) {
+	revoked, err := s.queries.RevokeSession(ctx, req.Msg.SessionId)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	return connect.NewResponse(&gen.RevokeSessionResponse{
+		Revoked: revoked > 0,
+	}), nil
+}
@@ -0,0 +1,20 @@
+package service
+
+import (
+	"context"
+
+	"connectrpc.com/connect"
+
+	"github.com/cloutdotgg/backend/internal/db/sqlc"
+	"github.com/cloutdotgg/backend/internal/events"
+	"github.com/cloutdotgg/backend/internal/outbox"
+)
+
+// recordEvent adds e to the outbox in the transaction of q. The outbox
+// relay publishes it once the transaction commits.
+func recordEvent(ctx context.Context, q *sqlc.Queries, e events.Event) error {
+	if err := outbox.Record(ctx, q, e); err != nil {
+		return connect.NewError(connect.CodeInternal, err)
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/jackc/pgx/v5"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/cloutdotgg/backend/internal/db/sqlc"
+	"github.com/cloutdotgg/backend/internal/fraud"
+	gen "github.com/cloutdotgg/backend/internal/gen/apiv1"
+	"github.com/cloutdotgg/backend/internal/recompute"
+)
+
+// Helper to convert sqlc FraudFlag to proto FraudFlag
+func fraudFlagToProto(f sqlc.FraudFlag) *gen.FraudFlag {
+	pf := &gen.FraudFlag{
+		Id:         f.ID,
+		Kind:       f.Kind,
+		SessionId:  f.SessionID,
+		CompanyId:  f.CompanyID,
+		Details:    f.Details,
+		VoteCount:  f.VoteCount,
+		Status:     f.Status,
+		ReviewNote: f.ReviewNote,
+	}
+	if f.WindowStart.Valid {
+		pf.WindowStart = timestamppb.New(f.WindowStart.Time)
+	}
+	if f.WindowEnd.Valid {
+		pf.WindowEnd = timestamppb.New(f.WindowEnd.Time)
+	}
+	if f.CreatedAt.Valid {
+		pf.CreatedAt = timestamppb.New(f.CreatedAt.Time)
+	}
+	if f.ReviewedAt.Valid {
+		pf.ReviewedAt = timestamppb.New(f.ReviewedAt.Time)
+	}
+	return pf
+}
+
+// AnalyzeVotes runs the fraud analyzer now instead of waiting for the
+// background job
+func (s *AdminService) AnalyzeVotes(
+	ctx context.Context,
+	req *connect.Request[gen.AnalyzeVotesRequest],
+) (*connect.Response[gen.AnalyzeVotesResponse], error) {
+	result, err := fraud.Analyze(ctx, s.db, fraud.DefaultConfig(), time.Now())
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	return connect.NewResponse(&gen.AnalyzeVotesResponse{
+		FlagsRaised:  int32(result.FlagsRaised),
+		VotesFlagged: int32(result.VotesFlagged),
+	}), nil
+}
+
+// ListFraudFlags returns flags, newest first
+func (s *AdminService) ListFraudFlags(
+	ctx context.Context,
+	req *connect.Request[gen.ListFraudFlagsRequest],
+) (*connect.Response[gen.ListFraudFlagsResponse], error) {
+	if status := req.Msg.Status; status != nil {
+		switch *status {
+		case fraud.StatusOpen, fraud.StatusConfirmed, fraud.StatusDismissed:
+		default:
+			return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("unknown flag status %q", *status))
+		}
+	}
+
+	page := req.Msg.Page
+	if page < 1 {
+		page = 1
+	}
+	pageSize := req.Msg.PageSize
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 25
+	}
+	offset := (page - 1) * pageSize
+
+	flags, err := s.queries.ListFraudFlags(ctx, sqlc.ListFraudFlagsParams{
+		Status:     req.Msg.Status,
+		PageLimit:  pageSize,
+		PageOffset: offset,
+	})
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	totalCount, err := s.queries.CountFraudFlags(ctx, req.Msg.Status)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	protoFlags := make([]*gen.FraudFlag, len(flags))
+	for i, f := range flags {
+		protoFlags[i] = fraudFlagToProto(f)
+	}
+
+	return connect.NewResponse(&gen.ListFraudFlagsResponse{
+		Flags:      protoFlags,
+		TotalCount: int32(totalCount),
+		Page:       page,
+		PageSize:   pageSize,
+	}), nil
+}
+
+// ConfirmFraudFlag marks a flag's votes as fraud and recomputes ratings
+// without them, from the earliest excluded vote onwards. Both happen in one
+// transaction, so ratings never reflect a half-applied exclusion.
+func (s *AdminService) ConfirmFraudFlag(
+	ctx context.Context,
+	req *connect.Request[gen.ConfirmFraudFlagRequest],
+) (*connect.Response[gen.ConfirmFraudFlagResponse], error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	defer tx.Rollback(ctx)
+	q := s.queries.WithTx(tx)
+
+	flag, err := q.GetFraudFlagForUpdate(ctx, req.Msg.FlagId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, connect.NewError(connect.CodeNotFound, errors.New("fraud flag not found"))
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	if flag.Status == fraud.StatusConfirmed {
+		return nil, connect.NewError(connect.CodeFailedPrecondition, errors.New("fraud flag has already been confirmed"))
+	}
+
+	excluded, err := q.ExcludeFraudFlagVotes(ctx, flag.ID)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	var sessionsRevoked int64
+	if req.Msg.RevokeSessions {
+		sessionsRevoked, err = q.RevokeFraudFlagSessions(ctx, flag.ID)
+		if err != nil {
+			return nil, connect.NewError(connect.CodeInternal, err)
+		}
+	}
+
+	// Votes before the first excluded one replay exactly as before, so only
+	// the history from there on is rewritten
+	report := &recompute.Report{}
+	if excluded.VotesExcluded > 0 {
+		report, err = recompute.Replay(ctx, tx, s.rater, recompute.Options{
+			Since: excluded.FirstExcludedAt.Time,
+		})
+		if err != nil {
+			return nil, connect.NewError(connect.CodeInternal, err)
+		}
+	}
+
+	flag, err = q.ReviewFraudFlag(ctx, sqlc.ReviewFraudFlagParams{
+		ID:         flag.ID,
+		Status:     fraud.StatusConfirmed,
+		ReviewNote: req.Msg.Note,
+	})
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	return connect.NewResponse(&gen.ConfirmFraudFlagResponse{
+		Flag:            fraudFlagToProto(flag),
+		VotesExcluded:   int32(excluded.VotesExcluded),
+		SessionsRevoked: int32(sessionsRevoked),
+		VotesReplayed:   int32(report.VotesReplayed),
+		Changes:         ratingChangesToProto(report.Changes),
+	}), nil
+}
+
+// DismissFraudFlag closes a flag without touching its votes. The votes are
+// not flagged for the same pattern again.
+func (s *AdminService) DismissFraudFlag(
+	ctx context.Context,
+	req *connect.Request[gen.DismissFraudFlagRequest],
+) (*connect.Response[gen.DismissFraudFlagResponse], error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	defer tx.Rollback(ctx)
+	q := s.queries.WithTx(tx)
+
+	flag, err := q.GetFraudFlagForUpdate(ctx, req.Msg.FlagId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, connect.NewError(connect.CodeNotFound, errors.New("fraud flag not found"))
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	if flag.Status == fraud.StatusConfirmed {
+		return nil, connect.NewError(connect.CodeFailedPrecondition, errors.New("fraud flag has already been confirmed"))
+	}
+
+	flag, err = q.ReviewFraudFlag(ctx, sqlc.ReviewFraudFlagParams{
+		ID:         flag.ID,
+		Status:     fraud.StatusDismissed,
+		ReviewNote: req.Msg.Note,
+	})
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	return connect.NewResponse(&gen.DismissFraudFlagResponse{
+		Flag: fraudFlagToProto(flag),
+	}), nil
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+
+	"github.com/cloutdotgg/backend/internal/db/sqlc"
+	"github.com/cloutdotgg/backend/internal/dbtest"
+	"github.com/cloutdotgg/backend/internal/fraud"
+	gen "github.com/cloutdotgg/backend/internal/gen/apiv1"
+	"github.com/cloutdotgg/backend/internal/matchmaking"
+	"github.com/cloutdotgg/backend/internal/rating"
+	"github.com/cloutdotgg/backend/internal/recompute"
+)
+
+// TestConfirmFraudFlag confirms the flag of a one-sided session whose votes
+// are interleaved with ordinary ones, and checks that the ratings it leaves
+// are those of a recompute without the session's votes.
+func TestConfirmFraudFlag(t *testing.T) {
+	for _, engine := range []string{"elo", "glicko2"} {
+		t.Run(engine, func(t *testing.T) {
+			pool := dbtest.New(t)
+			ctx := context.Background()
+			rater, err := rating.New(engine)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var ids []int32
+			rows, err := pool.Query(ctx, "SELECT id FROM companies ORDER BY id LIMIT 4")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for rows.Next() {
+				var id int32
+				if err := rows.Scan(&id); err != nil {
+					t.Fatal(err)
+				}
+				ids = append(ids, id)
+			}
+			if rows.Err() != nil || len(ids) < 4 {
+				t.Fatalf("listed companies %v, %v", ids, rows.Err())
+			}
+			favoured, others := ids[0], ids[1:]
+
+			// Ordinary votes without a session before, between and after
+			// the session's, so the replay has votes on both sides of the
+			// first excluded one
+			start := time.Now().Add(-5 * time.Hour)
+			cfg := fraud.DefaultConfig()
+			for i := 0; i < 2*cfg.OneSidedMinVotes+1; i++ {
+				at := start.Add(time.Duration(i) * 10 * time.Minute)
+				winner, loser, session := others[i%len(others)], favoured, ""
+				if i%2 == 1 {
+					winner, loser, session = favoured, others[i%len(others)], "brigade"
+				}
+				if _, err := pool.Exec(ctx,
+					"INSERT INTO votes (winner_id, loser_id, session_id, created_at) VALUES ($1, $2, NULLIF($3, ''), $4)",
+					winner, loser, session, at,
+				); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, err := recompute.Run(ctx, pool, rater, recompute.Options{}); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := fraud.Analyze(ctx, pool, cfg, time.Now()); err != nil {
+				t.Fatal(err)
+			}
+			flags, err := sqlc.New(pool).ListFraudFlags(ctx, sqlc.ListFraudFlagsParams{PageLimit: 10})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(flags) != 1 || flags[0].Kind != fraud.KindOneSidedSession {
+				t.Fatalf("raised %+v, want one one-sided session flag", flags)
+			}
+
+			admin := NewAdminService(pool, rater, matchmaking.Random{})
+			resp, err := admin.ConfirmFraudFlag(ctx, connect.NewRequest(&gen.ConfirmFraudFlagRequest{FlagId: flags[0].ID}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Msg.VotesExcluded != int32(cfg.OneSidedMinVotes) {
+				t.Errorf("excluded %d votes, want %d", resp.Msg.VotesExcluded, cfg.OneSidedMinVotes)
+			}
+			if resp.Msg.Flag.Status != fraud.StatusConfirmed {
+				t.Errorf("flag is %s", resp.Msg.Flag.Status)
+			}
+			if len(resp.Msg.Changes) == 0 {
+				t.Error("excluding the votes changed no rating")
+			}
+
+			var fraudulent, brigade, excludedBrigade int
+			if err := pool.QueryRow(ctx, `SELECT count(*) FILTER (WHERE status = 'fraud'),
+				       count(*) FILTER (WHERE session_id = 'brigade'),
+				       count(*) FILTER (WHERE status = 'fraud' AND session_id = 'brigade')
+				FROM votes`,
+			).Scan(&fraudulent, &brigade, &excludedBrigade); err != nil {
+				t.Fatal(err)
+			}
+			if fraudulent != brigade || excludedBrigade != brigade {
+				t.Errorf("%d votes excluded, %d of the session's %d", fraudulent, excludedBrigade, brigade)
+			}
+
+			// A full recompute replays only the counted votes, so it finds
+			// nothing to change if the confirmation left the right ratings
+			report, err := recompute.Run(ctx, pool, rater, recompute.Options{DryRun: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, c := range report.Changes {
+				t.Errorf("company %d: stored %+v, replay %+v", c.CompanyID, c.Before, c.After)
+			}
+
+			if _, err := admin.ConfirmFraudFlag(ctx, connect.NewRequest(&gen.ConfirmFraudFlagRequest{FlagId: flags[0].ID})); connect.CodeOf(err) != connect.CodeFailedPrecondition {
+				t.Errorf("confirming twice: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/cloutdotgg/backend/internal/db/sqlc"
+	gen "github.com/cloutdotgg/backend/internal/gen/apiv1"
+	"github.com/cloutdotgg/backend/internal/rating"
+)
+
+const (
+	defaultHeadToHeadLimit = 10
+	maxHeadToHeadLimit     = 50
+)
+
+// headToHeadLimit applies the default and the upper bound to a requested
+// number of rows
+func headToHeadLimit(limit int32) int32 {
+	if limit <= 0 {
+		return defaultHeadToHeadLimit
+	}
+	if limit > maxHeadToHeadLimit {
+		return maxHeadToHeadLimit
+	}
+	return limit
+}
+
+// GetHeadToHead returns the record between two companies, their most recent
+// matchups, how it developed week by week, and who current ratings favour
+func (s *RankingsService) GetHeadToHead(
+	ctx context.Context,
+	req *connect.Request[gen.GetHeadToHeadRequest],
+) (*connect.Response[gen.GetHeadToHeadResponse], error) {
+	if req.Msg.SlugA == req.Msg.SlugB {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("slugs must name two different companies"))
+	}
+
+	a, err := s.queries.GetCompanyBySlug(ctx, req.Msg.SlugA)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeNotFound, err)
+	}
+	b, err := s.queries.GetCompanyBySlug(ctx, req.Msg.SlugB)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeNotFound, err)
+	}
+
+	record, err := s.queries.GetHeadToHeadRecord(ctx, sqlc.GetHeadToHeadRecordParams{
+		CompanyA: a.ID,
+		CompanyB: b.ID,
+	})
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	votes, err := s.queries.ListHeadToHeadVotes(ctx, sqlc.ListHeadToHeadVotesParams{
+		CompanyA:  a.ID,
+		CompanyB:  b.ID,
+		PageLimit: headToHeadLimit(req.Msg.RecentLimit),
+	})
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	recent := make([]*gen.Vote, len(votes))
+	for i, v := range votes {
+		recent[i] = &gen.Vote{
+			Id:        v.ID,
+			WinnerId:  v.WinnerID,
+			LoserId:   v.LoserID,
+			CreatedAt: timestamppb.New(v.CreatedAt.Time),
+			Outcome:   voteOutcomeToProto(v.Outcome),
+		}
+	}
+
+	weeks, err := s.queries.GetHeadToHeadTrend(ctx, sqlc.GetHeadToHeadTrendParams{
+		CompanyA: a.ID,
+		CompanyB: b.ID,
+	})
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	trend := make([]*gen.HeadToHeadTrendPoint, len(weeks))
+	for i, w := range weeks {
+		trend[i] = &gen.HeadToHeadTrendPoint{
+			BucketStart: timestamppb.New(w.Week.Time),
+			AWins:       int32(w.AWins),
+			BWins:       int32(w.BWins),
+			Draws:       int32(w.Draws),
+		}
+	}
+
+	probability := rating.WinProbability(
+		rating.Rating{Value: a.Rating, Deviation: a.RatingDeviation, Volatility: a.RatingVolatility},
+		rating.Rating{Value: b.Rating, Deviation: b.RatingDeviation, Volatility: b.RatingVolatility},
+	)
+
+	return connect.NewResponse(&gen.GetHeadToHeadResponse{
+		CompanyA:        companyToProto(a, 0),
+		CompanyB:        companyToProto(b, 0),
+		AWins:           int32(record.AWins),
+		BWins:           int32(record.BWins),
+		Draws:           int32(record.Draws),
+		AWinProbability: probability,
+		Recent:          recent,
+		Trend:           trend,
+	}), nil
+}
+
+// ListRivals returns the opponents a company has met most often
+func (s *RankingsService) ListRivals(
+	ctx context.Context,
+	req *connect.Request[gen.ListRivalsRequest],
+) (*connect.Response[gen.ListRivalsResponse], error) {
+	companyID, err := s.queries.GetCompanyIDBySlug(ctx, req.Msg.Slug)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeNotFound, err)
+	}
+
+	rows, err := s.queries.ListRivals(ctx, sqlc.ListRivalsParams{
+		CompanyID: companyID,
+		PageLimit: headToHeadLimit(req.Msg.Limit),
+	})
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	rivals := make([]*gen.Rival, len(rows))
+	for i, row := range rows {
+		opponent, err := s.queries.GetCompanyByID(ctx, row.OpponentID)
+		if err != nil {
+			return nil, connect.NewError(connect.CodeInternal, err)
+		}
+		rivals[i] = &gen.Rival{
+			Company:   companyToProto(opponent, 0),
+			Matchups:  int32(row.Matchups),
+			Wins:      int32(row.Wins),
+			Losses:    int32(row.Losses),
+			Draws:     int32(row.Draws),
+			LastMetAt: timestamppb.New(row.LastMetAt.Time),
+		}
+	}
+
+	return connect.NewResponse(&gen.ListRivalsResponse{
+		Rivals: rivals,
+	}), nil
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/jackc/pgx/v5/pgtype"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/cloutdotgg/backend/internal/db/sqlc"
+	gen "github.com/cloutdotgg/backend/internal/gen/apiv1"
+)
+
+const (
+	defaultHistoryRange = 30 * 24 * time.Hour
+	// maxHourlyHistoryRange bounds hourly queries, which read raw history rows
+	maxHourlyHistoryRange = 31 * 24 * time.Hour
+)
+
+// GetCompanyHistory returns a company's rating, rank and record over time
+func (s *RankingsService) GetCompanyHistory(
+	ctx context.Context,
+	req *connect.Request[gen.GetCompanyHistoryRequest],
+) (*connect.Response[gen.GetCompanyHistoryResponse], error) {
+	companyID, err := s.queries.GetCompanyIDBySlug(ctx, req.Msg.Slug)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeNotFound, err)
+	}
+
+	to := time.Now()
+	if req.Msg.To != nil {
+		to = req.Msg.To.AsTime()
+	}
+	from := to.Add(-defaultHistoryRange)
+	if req.Msg.From != nil {
+		from = req.Msg.From.AsTime()
+	}
+	if !from.Before(to) {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("from must be before to"))
+	}
+
+	granularity := req.Msg.Granularity
+	if granularity == gen.HistoryGranularity_HISTORY_GRANULARITY_UNSPECIFIED {
+		granularity = gen.HistoryGranularity_HISTORY_GRANULARITY_DAY
+	}
+
+	var points []*gen.RatingHistoryPoint
+	switch granularity {
+	case gen.HistoryGranularity_HISTORY_GRANULARITY_HOUR:
+		if to.Sub(from) > maxHourlyHistoryRange {
+			return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("hourly history is limited to 31 days"))
+		}
+		rows, err := s.queries.GetHourlyRatingHistory(ctx, sqlc.GetHourlyRatingHistoryParams{
+			CompanyID: companyID,
+			FromTime:  pgtype.Timestamptz{Time: from, Valid: true},
+			ToTime:    pgtype.Timestamptz{Time: to, Valid: true},
+		})
+		if err != nil {
+			return nil, connect.NewError(connect.CodeInternal, err)
+		}
+		points = make([]*gen.RatingHistoryPoint, len(rows))
+		for i, row := range rows {
+			points[i] = &gen.RatingHistoryPoint{
+				BucketStart:     timestamppb.New(row.Bucket.Time),
+				EloRating:       row.EloRating,
+				Rating:          row.Rating,
+				RatingDeviation: row.RatingDeviation,
+				Rank:            row.Rank,
+				Wins:            row.Wins,
+				Losses:          row.Losses,
+				TotalVotes:      row.TotalVotes,
+			}
+		}
+
+	case gen.HistoryGranularity_HISTORY_GRANULARITY_DAY:
+		rows, err := s.queries.GetDailyRatingSnapshots(ctx, sqlc.GetDailyRatingSnapshotsParams{
+			CompanyID: companyID,
+			FromDay:   pgtype.Date{Time: from.UTC(), Valid: true},
+			ToDay:     pgtype.Date{Time: to.UTC(), Valid: true},
+		})
+		if err != nil {
+			return nil, connect.NewError(connect.CodeInternal, err)
+		}
+		points = make([]*gen.RatingHistoryPoint, len(rows))
+		for i, row := range rows {
+			points[i] = &gen.RatingHistoryPoint{
+				BucketStart:     timestamppb.New(row.Day.Time),
+				EloRating:       row.EloRating,
+				Rating:          row.Rating,
+				RatingDeviation: row.RatingDeviation,
+				Rank:            row.Rank,
+				Wins:            row.Wins,
+				Losses:          row.Losses,
+				TotalVotes:      row.TotalVotes,
+			}
+		}
+
+	case gen.HistoryGranularity_HISTORY_GRANULARITY_WEEK:
+		rows, err := s.queries.GetWeeklyRatingSnapshots(ctx, sqlc.GetWeeklyRatingSnapshotsParams{
+			CompanyID: companyID,
+			FromDay:   pgtype.Date{Time: from.UTC(), Valid: true},
+			ToDay:     pgtype.Date{Time: to.UTC(), Valid: true},
+		})
+		if err != nil {
+			return nil, connect.NewError(connect.CodeInternal, err)
+		}
+		points = make([]*gen.RatingHistoryPoint, len(rows))
+		for i, row := range rows {
+			points[i] = &gen.RatingHistoryPoint{
+				BucketStart:     timestamppb.New(row.Week.Time),
+				EloRating:       row.EloRating,
+				Rating:          row.Rating,
+				RatingDeviation: row.RatingDeviation,
+				Rank:            row.Rank,
+				Wins:            row.Wins,
+				Losses:          row.Losses,
+				TotalVotes:      row.TotalVotes,
+			}
+		}
+
+	default:
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("unknown granularity"))
+	}
+
+	return connect.NewResponse(&gen.GetCompanyHistoryResponse{
+		Points:      points,
+		Granularity: granularity,
+	}), nil
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	gen "github.com/cloutdotgg/backend/internal/gen/apiv1"
+	"github.com/cloutdotgg/backend/internal/gen/apiv1/apiv1connect"
+)
+
+const (
+	defaultWatchTopN = 10
+	maxWatchTopN     = 100
+	// leaderboardUpdateInterval bounds how often a stream re-reads the
+	// leaderboard, so a burst of votes costs one query per stream
+	leaderboardUpdateInterval = time.Second
+	// leaderboardHeartbeatInterval is well below the idle timeouts of
+	// common proxies and load balancers
+	leaderboardHeartbeatInterval = 15 * time.Second
+)
+
+// WatchLeaderboard streams the top of the leaderboard: a snapshot first, then
+// the rank and rating changes of its companies as votes land on any instance
+func (s *RankingsService) WatchLeaderboard(
+	ctx context.Context,
+	req *connect.Request[gen.WatchLeaderboardRequest],
+	stream *connect.ServerStream[gen.WatchLeaderboardResponse],
+) error {
+	topN := req.Msg.TopN
+	if topN < 1 {
+		topN = defaultWatchTopN
+	}
+	if topN > maxWatchTopN {
+		topN = maxWatchTopN
+	}
+	category := ""
+	if req.Msg.Category != nil {
+		category = *req.Msg.Category
+	}
+
+	// Subscribe before reading the snapshot so no change falls in between
+	sub := s.events.Subscribe()
+	defer func() { sub.Close() }()
+
+	board, err := s.sendLeaderboardSnapshot(ctx, stream, category, topN)
+	if err != nil {
+		return err
+	}
+
+	updates := time.NewTicker(leaderboardUpdateInterval)
+	defer updates.Stop()
+	heartbeats := time.NewTicker(leaderboardHeartbeatInterval)
+	defer heartbeats.Stop()
+
+	changed := false
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case e, ok := <-sub.C:
+			if ok {
+				changed = changed || e.ChangesRatings()
+				continue
+			}
+			// Dropped for falling behind; start over from a new snapshot
+			sub = s.events.Subscribe()
+			if board, err = s.sendLeaderboardSnapshot(ctx, stream, category, topN); err != nil {
+				return err
+			}
+			changed = false
+
+		case <-updates.C:
+			if !changed {
+				continue
+			}
+			changed = false
+
+			next, err := s.leaderboardPage(ctx, category, 0, topN)
+			if err != nil {
+				return err
+			}
+			deltas := leaderboardDeltas(board, next, category)
+			board = next
+			if len(deltas) == 0 {
+				continue
+			}
+			if err := stream.Send(&gen.WatchLeaderboardResponse{
+				Event: &gen.WatchLeaderboardResponse_Update{
+					Update: &gen.LeaderboardUpdate{Deltas: deltas},
+				},
+			}); err != nil {
+				return err
+			}
+			heartbeats.Reset(leaderboardHeartbeatInterval)
+
+		case <-heartbeats.C:
+			if err := stream.Send(&gen.WatchLeaderboardResponse{
+				Event: &gen.WatchLeaderboardResponse_Heartbeat{
+					Heartbeat: &gen.LeaderboardHeartbeat{SentAt: timestamppb.Now()},
+				},
+			}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// sendLeaderboardSnapshot sends the current top of the leaderboard and
+// returns it
+func (s *RankingsService) sendLeaderboardSnapshot(
+	ctx context.Context,
+	stream *connect.ServerStream[gen.WatchLeaderboardResponse],
+	category string,
+	topN int32,
+) ([]*gen.Company, error) {
+	board, err := s.leaderboardPage(ctx, category, 0, topN)
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.Send(&gen.WatchLeaderboardResponse{
+		Event: &gen.WatchLeaderboardResponse_Snapshot{
+			Snapshot: &gen.LeaderboardSnapshot{Companies: board},
+		},
+	}); err != nil {
+		return nil, err
+	}
+	return board, nil
+}
+
+// leaderboardPosition is a company's rank and rating on a leaderboard of
+// category, or of all companies when category is empty or "all"
+func leaderboardPosition(c *gen.Company, category string) (rank, elo int32) {
+	if category != "" && category != "all" && c.CategoryStanding != nil {
+		return c.CategoryRank, c.CategoryStanding.EloRating
+	}
+	return c.Rank, c.EloRating
+}
+
+// leaderboardDeltas lists how the companies on next moved since prev,
+// including those that entered or left it
+func leaderboardDeltas(prev, next []*gen.Company, category string) []*gen.LeaderboardDelta {
+	before := make(map[int32]*gen.Company, len(prev))
+	for _, c := range prev {
+		before[c.Id] = c
+	}
+
+	var deltas []*gen.LeaderboardDelta
+	for _, c := range next {
+		rank, elo := leaderboardPosition(c, category)
+		old, ok := before[c.Id]
+		if !ok {
+			deltas = append(deltas, &gen.LeaderboardDelta{
+				CompanyId: c.Id,
+				Rank:      rank,
+				EloRating: elo,
+				Company:   c,
+			})
+			continue
+		}
+		delete(before, c.Id)
+
+		oldRank, oldElo := leaderboardPosition(old, category)
+		if rank == oldRank && elo == oldElo {
+			continue
+		}
+		deltas = append(deltas, &gen.LeaderboardDelta{
+			CompanyId:    c.Id,
+			Rank:         rank,
+			PreviousRank: oldRank,
+			EloRating:    elo,
+			EloDiff:      elo - oldElo,
+		})
+	}
+
+	for _, old := range prev {
+		if _, ok := before[old.Id]; !ok {
+			continue
+		}
+		oldRank, oldElo := leaderboardPosition(old, category)
+		deltas = append(deltas, &gen.LeaderboardDelta{
+			CompanyId:    old.Id,
+			PreviousRank: oldRank,
+			EloRating:    oldElo,
+			Removed:      true,
+		})
+	}
+	return deltas
+}
+
+// streamingProcedures are the procedures whose responses stay open for as
+// long as the client listens
+var streamingProcedures = map[string]bool{
+	apiv1connect.RankingsServiceWatchLeaderboardProcedure: true,
+}
+
+// WithoutStreamTimeouts lifts the server's read and write timeouts for
+// streaming procedures, which would otherwise cut every stream off after
+// the write timeout. Other requests keep their timeouts.
+func WithoutStreamTimeouts(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if streamingProcedures[r.URL.Path] {
+			rc := http.NewResponseController(w)
+			_ = rc.SetReadDeadline(time.Time{})
+			_ = rc.SetWriteDeadline(time.Time{})
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/cloutdotgg/backend/internal/db/sqlc"
+	"github.com/cloutdotgg/backend/internal/matchmaking"
+)
+
+// matchmakingStrategySetting is the settings key holding the strategy
+// selected by an admin
+const matchmakingStrategySetting = "matchmaking_strategy"
+
+// matchmakingStrategy returns the strategy selected by an admin, or fallback
+// if none is selected. Matchups keep being served with fallback if the
+// setting cannot be read.
+func matchmakingStrategy(ctx context.Context, q *sqlc.Queries, fallback matchmaking.Strategy) matchmaking.Strategy {
+	name, err := q.GetSetting(ctx, matchmakingStrategySetting)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fallback
+	}
+	if err != nil {
+		log.Printf("Failed to read matchmaking strategy: %v", err)
+		return fallback
+	}
+
+	strategy, err := matchmaking.New(name)
+	if err != nil {
+		log.Printf("Ignoring matchmaking strategy setting: %v", err)
+		return fallback
+	}
+	return strategy
+}
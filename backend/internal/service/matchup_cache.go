@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+
+	"github.com/cloutdotgg/backend/internal/db/sqlc"
+	"github.com/cloutdotgg/backend/internal/events"
+	"github.com/cloutdotgg/backend/internal/matchmaking"
+	"github.com/cloutdotgg/backend/internal/rating"
+)
+
+// matchupCacheTTL bounds how stale the candidates and the strategy used to
+// pick matchups can be. A single vote moves two ratings by too little to
+// change which pairs are worth serving, so votes do not refresh the cache
+// before then; bulk rating changes do.
+const matchupCacheTTL = 10 * time.Second
+
+// matchupCache keeps the matchup candidates of each category and the
+// strategy selected by an admin, so that serving a matchup does not list
+// every company and read the settings each time
+type matchupCache struct {
+	queries  *sqlc.Queries
+	fallback matchmaking.Strategy
+	bus      events.Bus
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu sync.Mutex
+	// sub tells the cache about bulk rating changes
+	sub        *events.Subscription
+	candidates map[string]cachedCandidates
+	strategy   matchmaking.Strategy
+	strategyAt time.Time
+}
+
+type cachedCandidates struct {
+	list     []matchmaking.Candidate
+	loadedAt time.Time
+}
+
+func newMatchupCache(q *sqlc.Queries, fallback matchmaking.Strategy, bus events.Bus) *matchupCache {
+	return &matchupCache{
+		queries:    q,
+		fallback:   fallback,
+		bus:        bus,
+		ttl:        matchupCacheTTL,
+		now:        time.Now,
+		sub:        bus.Subscribe(),
+		candidates: make(map[string]cachedCandidates),
+	}
+}
+
+// matchupCandidates returns the companies that can be served in category,
+// or in any category when it is empty or "all". The list is shared between
+// callers and must not be modified.
+func (c *matchupCache) matchupCandidates(ctx context.Context, category string) ([]matchmaking.Candidate, error) {
+	if category == "all" {
+		category = ""
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.drain()
+
+	if cached, ok := c.candidates[category]; ok && c.now().Sub(cached.loadedAt) < c.ttl {
+		return cached.list, nil
+	}
+	list, err := listMatchupCandidates(ctx, c.queries, category)
+	if err != nil {
+		return nil, err
+	}
+	c.candidates[category] = cachedCandidates{list: list, loadedAt: c.now()}
+	return list, nil
+}
+
+// matchmakingStrategy returns the strategy selected by an admin, or the
+// fallback if none is selected. A newly selected strategy is picked up
+// within the cache's TTL.
+func (c *matchupCache) matchmakingStrategy(ctx context.Context) matchmaking.Strategy {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.strategy == nil || c.now().Sub(c.strategyAt) >= c.ttl {
+		c.strategy = matchmakingStrategy(ctx, c.queries, c.fallback)
+		c.strategyAt = c.now()
+	}
+	return c.strategy
+}
+
+// drain reads the events published since the last call and forgets the
+// candidates if ratings changed in bulk or the subscription missed events.
+// The caller must hold mu.
+func (c *matchupCache) drain() {
+	for {
+		select {
+		case e, ok := <-c.sub.C:
+			if !ok {
+				// Dropped for falling behind between matchups
+				c.sub = c.bus.Subscribe()
+				clear(c.candidates)
+				return
+			}
+			if e.Kind == events.KindRatingsChanged || e.Kind == events.KindResync {
+				clear(c.candidates)
+			}
+		default:
+			return
+		}
+	}
+}
+
+// listMatchupCandidates lists the companies that can be served in category,
+// or in any category when it is empty
+func listMatchupCandidates(ctx context.Context, q *sqlc.Queries, category string) ([]matchmaking.Candidate, error) {
+	var candidates []matchmaking.Candidate
+	if category != "" {
+		rows, err := q.ListCategoryMatchupCandidates(ctx, category)
+		if err != nil {
+			return nil, connect.NewError(connect.CodeInternal, err)
+		}
+		candidates = make([]matchmaking.Candidate, len(rows))
+		for i, row := range rows {
+			candidates[i] = matchmaking.Candidate{
+				ID: row.CompanyID,
+				Rating: rating.Rating{
+					Value:      row.Rating,
+					Deviation:  row.RatingDeviation,
+					Volatility: row.RatingVolatility,
+				},
+				TotalVotes: row.TotalVotes,
+			}
+		}
+	} else {
+		rows, err := q.ListMatchupCandidates(ctx)
+		if err != nil {
+			return nil, connect.NewError(connect.CodeInternal, err)
+		}
+		candidates = make([]matchmaking.Candidate, len(rows))
+		for i, row := range rows {
+			candidates[i] = matchmaking.Candidate{
+				ID: row.ID,
+				Rating: rating.Rating{
+					Value:      row.Rating,
+					Deviation:  row.RatingDeviation,
+					Volatility: row.RatingVolatility,
+				},
+				TotalVotes: row.TotalVotes,
+			}
+		}
+	}
+	return candidates, nil
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cloutdotgg/backend/internal/events"
+	"github.com/cloutdotgg/backend/internal/matchmaking"
+)
+
+// testMatchupCache returns a cache without a database, whose clock only
+// moves when advanced, holding candidates for the global leaderboard.
+func testMatchupCache(bus events.Bus) (*matchupCache, func(time.Duration)) {
+	c := newMatchupCache(nil, matchmaking.Random{}, bus)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	c.candidates[""] = cachedCandidates{list: []matchmaking.Candidate{{ID: 1}, {ID: 2}}, loadedAt: now}
+	return c, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestMatchupCacheServesFreshCandidates(t *testing.T) {
+	c, advance := testMatchupCache(events.NewLocal(events.DefaultBuffer))
+	advance(matchupCacheTTL - time.Millisecond)
+
+	// Without queries a load would fail, so these must come from the cache
+	for _, category := range []string{"", "all"} {
+		list, err := c.matchupCandidates(context.Background(), category)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 2 {
+			t.Fatalf("%q: got %v", category, list)
+		}
+	}
+}
+
+func TestMatchupCacheInvalidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		publish []events.Event
+		kept    bool
+	}{
+		{name: "votes", publish: []events.Event{{Kind: events.KindVoteCast}, {Kind: events.KindVoteUndone}}, kept: true},
+		{name: "comment", publish: []events.Event{{Kind: events.KindCommentCreated}}, kept: true},
+		{name: "bulk rating change", publish: []events.Event{{Kind: events.KindVoteCast}, {Kind: events.KindRatingsChanged}}},
+		{name: "resync", publish: []events.Event{{Kind: events.KindResync}}},
+		// More events than the subscription buffers drop it
+		{name: "missed events", publish: make([]events.Event, events.DefaultBuffer+1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bus := events.NewLocal(events.DefaultBuffer)
+			c, _ := testMatchupCache(bus)
+			for _, e := range tt.publish {
+				if e.Kind == "" {
+					e.Kind = events.KindVoteCast
+				}
+				bus.Publish(context.Background(), e)
+			}
+
+			c.mu.Lock()
+			c.drain()
+			_, kept := c.candidates[""]
+			c.mu.Unlock()
+			if kept != tt.kept {
+				t.Fatalf("candidates kept %v, want %v", kept, tt.kept)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"connectrpc.com/connect"
+
+	"github.com/cloutdotgg/backend/internal/token"
+)
+
+// matchupTokenTTL is how long a matchup can be voted on after it was served
+const matchupTokenTTL = 15 * time.Minute
+
+// matchupTokenPurpose separates matchup token keys from other tokens signed
+// with the same secret
+const matchupTokenPurpose = "matchup"
+
+// matchupClaims binds a vote to a matchup the server actually served
+type matchupClaims struct {
+	ID         string   `json:"jti"`
+	CompanyIDs [2]int32 `json:"cids"`
+	Category   string   `json:"cat,omitempty"`
+	SessionID  string   `json:"sid"`
+	ExpiresAt  int64    `json:"exp"`
+}
+
+// issueMatchupToken signs a token for a matchup served to sessionID
+func issueMatchupToken(signer *token.Signer, company1, company2 int32, category, sessionID string) (string, time.Time, error) {
+	id, err := token.NewID()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	expiresAt := time.Now().Add(matchupTokenTTL).Truncate(time.Second)
+	signed, err := signer.Sign(matchupClaims{
+		ID:         id,
+		CompanyIDs: [2]int32{company1, company2},
+		Category:   category,
+		SessionID:  sessionID,
+		ExpiresAt:  expiresAt.Unix(),
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signed, expiresAt, nil
+}
+
+// verifyMatchupToken checks that signed was issued to sessionID for a
+// matchup between winnerID and loserID and has not expired. It does not
+// check whether the token has been used before.
+func verifyMatchupToken(signer *token.Signer, signed, sessionID string, winnerID, loserID int32) (*matchupClaims, error) {
+	if signed == "" {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("matchup token is required"))
+	}
+
+	var claims matchupClaims
+	if err := signer.Verify(signed, &claims); err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return nil, connect.NewError(connect.CodeFailedPrecondition, errors.New("matchup token has expired"))
+	}
+	if claims.SessionID != sessionID {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("matchup token was issued to another session"))
+	}
+
+	ids := claims.CompanyIDs
+	if !(ids[0] == winnerID && ids[1] == loserID) && !(ids[0] == loserID && ids[1] == winnerID) {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("vote does not match the matchup token"))
+	}
+	return &claims, nil
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"connectrpc.com/connect"
+
+	"github.com/cloutdotgg/backend/internal/db/sqlc"
+	gen "github.com/cloutdotgg/backend/internal/gen/apiv1"
+	"github.com/cloutdotgg/backend/internal/rating"
+)
+
+// Outcomes of a vote, as stored in votes.outcome. For draws and skips the
+// winner and loser are only the two companies of the matchup.
+const (
+	voteOutcomeWin  = "win"
+	voteOutcomeDraw = "draw"
+	voteOutcomeSkip = "skip"
+)
+
+// voteOutcomeFromProto maps the requested outcome to its stored value
+func voteOutcomeFromProto(o gen.VoteOutcome) (string, error) {
+	switch o {
+	case gen.VoteOutcome_VOTE_OUTCOME_UNSPECIFIED, gen.VoteOutcome_VOTE_OUTCOME_WIN:
+		return voteOutcomeWin, nil
+	case gen.VoteOutcome_VOTE_OUTCOME_DRAW:
+		return voteOutcomeDraw, nil
+	case gen.VoteOutcome_VOTE_OUTCOME_SKIP:
+		return voteOutcomeSkip, nil
+	default:
+		return "", connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("unknown vote outcome %d", o))
+	}
+}
+
+// voteOutcomeToProto maps a stored outcome to its API value
+func voteOutcomeToProto(outcome string) gen.VoteOutcome {
+	switch outcome {
+	case voteOutcomeDraw:
+		return gen.VoteOutcome_VOTE_OUTCOME_DRAW
+	case voteOutcomeSkip:
+		return gen.VoteOutcome_VOTE_OUTCOME_SKIP
+	default:
+		return gen.VoteOutcome_VOTE_OUTCOME_WIN
+	}
+}
+
+// voteStrengthFromProto maps the requested strength of a vote with the given
+// outcome to its stored value. Only wins can be slight or strong.
+func voteStrengthFromProto(st gen.VoteStrength, outcome string) (rating.Strength, error) {
+	var strength rating.Strength
+	switch st {
+	case gen.VoteStrength_VOTE_STRENGTH_UNSPECIFIED, gen.VoteStrength_VOTE_STRENGTH_NORMAL:
+		return rating.StrengthNormal, nil
+	case gen.VoteStrength_VOTE_STRENGTH_SLIGHT:
+		strength = rating.StrengthSlight
+	case gen.VoteStrength_VOTE_STRENGTH_STRONG:
+		strength = rating.StrengthStrong
+	default:
+		return "", connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("unknown vote strength %d", st))
+	}
+	if outcome != voteOutcomeWin {
+		return "", connect.NewError(connect.CodeInvalidArgument, errors.New("only a win can be slight or strong"))
+	}
+	return strength, nil
+}
+
+// rate returns the new ratings of the winner and the loser, or of the two
+// companies of a draw
+func (s *RankingsService) rate(outcome string, strength rating.Strength, winner, loser rating.Rating) (rating.Rating, rating.Rating) {
+	if outcome == voteOutcomeDraw {
+		return s.rater.Draw(winner, loser)
+	}
+	return s.rater.Rate(winner, loser, strength.Weight())
+}
+
+// updateCompanyRatings stores the new global ratings of a win or a draw and
+// adds it to both companies' records
+func updateCompanyRatings(ctx context.Context, q *sqlc.Queries, outcome string, winnerID int32, winner rating.Rating, loserID int32, loser rating.Rating) error {
+	if outcome == voteOutcomeDraw {
+		for _, c := range []struct {
+			id int32
+			r  rating.Rating
+		}{{winnerID, winner}, {loserID, loser}} {
+			if err := q.UpdateCompanyAfterDraw(ctx, sqlc.UpdateCompanyAfterDrawParams{
+				ID:               c.id,
+				Rating:           c.r.Value,
+				RatingDeviation:  c.r.Deviation,
+				RatingVolatility: c.r.Volatility,
+			}); err != nil {
+				return connect.NewError(connect.CodeInternal, err)
+			}
+		}
+		return nil
+	}
+
+	if err := q.UpdateCompanyAfterWin(ctx, sqlc.UpdateCompanyAfterWinParams{
+		ID:               winnerID,
+		Rating:           winner.Value,
+		RatingDeviation:  winner.Deviation,
+		RatingVolatility: winner.Volatility,
+	}); err != nil {
+		return connect.NewError(connect.CodeInternal, err)
+	}
+
+	if err := q.UpdateCompanyAfterLoss(ctx, sqlc.UpdateCompanyAfterLossParams{
+		ID:               loserID,
+		Rating:           loser.Value,
+		RatingDeviation:  loser.Deviation,
+		RatingVolatility: loser.Volatility,
+	}); err != nil {
+		return connect.NewError(connect.CodeInternal, err)
+	}
+	return nil
+}
+
+// updateCategoryRatings is updateCompanyRatings for ratings within category
+func updateCategoryRatings(ctx context.Context, q *sqlc.Queries, category, outcome string, winnerID int32, winner rating.Rating, loserID int32, loser rating.Rating) error {
+	if outcome == voteOutcomeDraw {
+		for _, c := range []struct {
+			id int32
+			r  rating.Rating
+		}{{winnerID, winner}, {loserID, loser}} {
+			if err := q.UpdateCategoryRatingAfterDraw(ctx, sqlc.UpdateCategoryRatingAfterDrawParams{
+				Rating:           c.r.Value,
+				RatingDeviation:  c.r.Deviation,
+				RatingVolatility: c.r.Volatility,
+				CompanyID:        c.id,
+				Category:         category,
+			}); err != nil {
+				return connect.NewError(connect.CodeInternal, err)
+			}
+		}
+		return nil
+	}
+
+	if err := q.UpdateCategoryRatingAfterWin(ctx, sqlc.UpdateCategoryRatingAfterWinParams{
+		Rating:           winner.Value,
+		RatingDeviation:  winner.Deviation,
+		RatingVolatility: winner.Volatility,
+		CompanyID:        winnerID,
+		Category:         category,
+	}); err != nil {
+		return connect.NewError(connect.CodeInternal, err)
+	}
+
+	if err := q.UpdateCategoryRatingAfterLoss(ctx, sqlc.UpdateCategoryRatingAfterLossParams{
+		Rating:           loser.Value,
+		RatingDeviation:  loser.Deviation,
+		RatingVolatility: loser.Volatility,
+		CompanyID:        loserID,
+		Category:         category,
+	}); err != nil {
+		return connect.NewError(connect.CodeInternal, err)
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/jackc/pgx/v5/pgtype"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/cloutdotgg/backend/internal/db/sqlc"
+	"github.com/cloutdotgg/backend/internal/events"
+	gen "github.com/cloutdotgg/backend/internal/gen/apiv1"
+	"github.com/cloutdotgg/backend/internal/matchmaking"
+	"github.com/cloutdotgg/backend/internal/rating"
+)
+
+// Bounds on the number of companies in a ranking set
+const (
+	minRankingSetSize     = 3
+	maxRankingSetSize     = 5
+	defaultRankingSetSize = 4
+)
+
+// GetRankingSet serves a set of companies for the voter to put in order.
+// The set is picked by the same matchmaking strategy as matchups.
+func (s *RankingsService) GetRankingSet(
+	ctx context.Context,
+	req *connect.Request[gen.GetRankingSetRequest],
+) (*connect.Response[gen.GetRankingSetResponse], error) {
+	size := int(req.Msg.Size)
+	if size == 0 {
+		size = defaultRankingSetSize
+	}
+	if size < minRankingSetSize || size > maxRankingSetSize {
+		return nil, connect.NewError(connect.CodeInvalidArgument,
+			fmt.Errorf("size must be between %d and %d", minRankingSetSize, maxRankingSetSize))
+	}
+
+	category := ""
+	if req.Msg.Category != nil {
+		category = *req.Msg.Category
+	}
+
+	candidates, err := s.matchups.matchupCandidates(ctx, category)
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) < size {
+		size = len(candidates)
+	}
+	if size < minRankingSetSize {
+		return nil, connect.NewError(connect.CodeNotFound, errors.New("not enough companies to rank"))
+	}
+
+	strategy := s.matchups.matchmakingStrategy(ctx)
+	set, err := matchmaking.PickSet(strategy, candidates, size, matchmaking.GlobalSource)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	// Randomize order
+	rand.Shuffle(len(set), func(i, j int) { set[i], set[j] = set[j], set[i] })
+
+	companies := make([]*gen.Company, len(set))
+	ids := make([]int32, len(set))
+	for i, c := range set {
+		company, err := s.queries.GetCompanyByID(ctx, c.ID)
+		if err != nil {
+			return nil, connect.NewError(connect.CodeInternal, err)
+		}
+		companies[i] = companyToProto(company, 0)
+		ids[i] = company.ID
+	}
+
+	if category == "all" {
+		category = ""
+	}
+	sessionID, err := requireSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rankingToken, expiresAt, err := issueRankingToken(s.rankingTokens, ids, category, sessionID)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	return connect.NewResponse(&gen.GetRankingSetResponse{
+		Companies:             companies,
+		RankingToken:          rankingToken,
+		RankingTokenExpiresAt: timestamppb.New(expiresAt),
+	}), nil
+}
+
+// SubmitRanking records the voter's order of a set served by GetRankingSet.
+// A ranking of n companies counts as the n(n-1)/2 wins it implies, each
+// company beating every company ranked below it. The wins are cast as
+// ordinary votes, best-ranked pairs first, so they go through the same
+// rating updates, cooldown and history as votes on a matchup.
+func (s *RankingsService) SubmitRanking(
+	ctx context.Context,
+	req *connect.Request[gen.SubmitRankingRequest],
+) (*connect.Response[gen.SubmitRankingResponse], error) {
+	order := req.Msg.CompanyIds
+
+	sessionID, err := requireSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only rankings of a set this server served to this session count
+	set, err := verifyRankingToken(s.rankingTokens, req.Msg.RankingToken, sessionID, order)
+	if err != nil {
+		return nil, err
+	}
+	category := set.Category
+
+	var resp *gen.SubmitRankingResponse
+	err = s.inTx(ctx, func(q *sqlc.Queries) error {
+		// Ranking tokens are spent in the same table as matchup tokens
+		used, err := q.UseMatchupToken(ctx, sqlc.UseMatchupTokenParams{
+			ID:        set.ID,
+			ExpiresAt: pgtype.Timestamptz{Time: time.Unix(set.ExpiresAt, 0), Valid: true},
+		})
+		if err != nil {
+			return connect.NewError(connect.CodeInternal, err)
+		}
+		if used == 0 {
+			return connect.NewError(connect.CodeAlreadyExists, errors.New("ranking token has already been used"))
+		}
+
+		// Lock every company of the set up front, in id order, so the votes
+		// below never wait on each other's companies
+		locked, err := q.LockCompaniesForUpdate(ctx, order)
+		if err != nil {
+			return connect.NewError(connect.CodeInternal, err)
+		}
+		if len(locked) != len(order) {
+			return connect.NewError(connect.CodeNotFound, errors.New("company not found"))
+		}
+		before := make(map[int32]rating.Rating, len(locked))
+		for _, c := range locked {
+			before[c.ID] = rating.Rating{
+				Value:      c.Rating,
+				Deviation:  c.RatingDeviation,
+				Volatility: c.RatingVolatility,
+			}
+		}
+
+		var rankingCategory *string
+		if category != "" {
+			rankingCategory = &category
+		}
+		ranking, err := q.CreateRanking(ctx, sqlc.CreateRankingParams{
+			CompanyIds: order,
+			Category:   rankingCategory,
+		})
+		if err != nil {
+			return connect.NewError(connect.CodeInternal, err)
+		}
+
+		var counted int32
+		for i, winnerID := range order {
+			for _, loserID := range order[i+1:] {
+				cast, err := s.castVote(ctx, q, sessionID, pairVote{
+					winnerID:  winnerID,
+					loserID:   loserID,
+					outcome:   voteOutcomeWin,
+					strength:  rating.StrengthNormal,
+					category:  category,
+					rankingID: &ranking.ID,
+				})
+				if err != nil {
+					return err
+				}
+				if cast.vote.Status == voteStatusCounted {
+					counted++
+				}
+			}
+		}
+
+		resp = &gen.SubmitRankingResponse{
+			RankingId:    ranking.ID,
+			Companies:    make([]*gen.Company, len(order)),
+			EloDiffs:     make([]int32, len(order)),
+			VotesCounted: counted,
+		}
+		for i, id := range order {
+			company, err := q.GetCompanyByID(ctx, id)
+			if err != nil {
+				return connect.NewError(connect.CodeInternal, err)
+			}
+			pc := companyToProto(company, 0)
+			if category != "" {
+				if err := s.attachCategoryStanding(ctx, q, pc, category); err != nil {
+					return err
+				}
+			}
+			resp.Companies[i] = pc
+			resp.EloDiffs[i] = company.EloRating - eloPoints(before[id].Value)
+		}
+		if counted == 0 {
+			return nil
+		}
+		return recordEvent(ctx, q, events.Event{
+			Kind:       events.KindRankingSubmitted,
+			ID:         ranking.ID,
+			CompanyIDs: order,
+			Category:   category,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return connect.NewResponse(resp), nil
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"connectrpc.com/connect"
+
+	"github.com/cloutdotgg/backend/internal/token"
+)
+
+// rankingTokenPurpose separates ranking token keys from other tokens signed
+// with the same secret
+const rankingTokenPurpose = "ranking"
+
+// rankingClaims binds a ranking to a set of companies the server actually
+// served. Ranking tokens share the matchup tokens' lifetime and used-token
+// table.
+type rankingClaims struct {
+	ID         string  `json:"jti"`
+	CompanyIDs []int32 `json:"cids"`
+	Category   string  `json:"cat,omitempty"`
+	SessionID  string  `json:"sid"`
+	ExpiresAt  int64   `json:"exp"`
+}
+
+// issueRankingToken signs a token for a ranking set served to sessionID
+func issueRankingToken(signer *token.Signer, companyIDs []int32, category, sessionID string) (string, time.Time, error) {
+	id, err := token.NewID()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	expiresAt := time.Now().Add(matchupTokenTTL).Truncate(time.Second)
+	signed, err := signer.Sign(rankingClaims{
+		ID:         id,
+		CompanyIDs: companyIDs,
+		Category:   category,
+		SessionID:  sessionID,
+		ExpiresAt:  expiresAt.Unix(),
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signed, expiresAt, nil
+}
+
+// verifyRankingToken checks that signed was issued to sessionID for exactly
+// the companies in order and has not expired. It does not check whether the
+// token has been used before.
+func verifyRankingToken(signer *token.Signer, signed, sessionID string, order []int32) (*rankingClaims, error) {
+	if signed == "" {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("ranking token is required"))
+	}
+
+	var claims rankingClaims
+	if err := signer.Verify(signed, &claims); err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return nil, connect.NewError(connect.CodeFailedPrecondition, errors.New("ranking token has expired"))
+	}
+	if claims.SessionID != sessionID {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("ranking token was issued to another session"))
+	}
+
+	served, ranked := slices.Clone(claims.CompanyIDs), slices.Clone(order)
+	slices.Sort(served)
+	slices.Sort(ranked)
+	if !slices.Equal(served, ranked) {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("ranking does not match the ranking token"))
+	}
+	return &claims, nil
+}
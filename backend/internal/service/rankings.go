@@ -2,32 +2,79 @@ package service
 
 import (
 	"context"
+	"errors"
 	"math"
 	"math/rand"
 	"strings"
+	"time"
 
 	"connectrpc.com/connect"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"github.com/cloutdotgg/backend/internal/db/sqlc"
+	"github.com/cloutdotgg/backend/internal/events"
 	gen "github.com/cloutdotgg/backend/internal/gen/apiv1"
+	"github.com/cloutdotgg/backend/internal/matchmaking"
+	"github.com/cloutdotgg/backend/internal/rating"
+	"github.com/cloutdotgg/backend/internal/token"
 )
 
 // RankingsService implements the RankingsServiceHandler interface
 type RankingsService struct {
-	db      *pgxpool.Pool
-	queries *sqlc.Queries
+	db            *pgxpool.Pool
+	queries       *sqlc.Queries
+	rater         rating.Rater
+	matchups      *matchupCache
+	matchupTokens *token.Signer
+	rankingTokens *token.Signer
+	sessions      *sessionStore
+	repeatVotes   RepeatVotePolicy
+	events        events.Bus
 }
 
-// NewRankingsService creates a new rankings service
-func NewRankingsService(db *pgxpool.Pool) *RankingsService {
+// NewRankingsService creates a new rankings service. strategy is used for
+// matchups until an admin selects a different one, tokenSecret signs the
+// matchup, ranking and session tokens, and repeatVotes decides what happens
+// to repeated votes on the same pair. Leaderboard streams and the cache of
+// matchup candidates subscribe to bus.
+func NewRankingsService(db *pgxpool.Pool, rater rating.Rater, strategy matchmaking.Strategy, tokenSecret []byte, repeatVotes RepeatVotePolicy, bus events.Bus) *RankingsService {
+	queries := sqlc.New(db)
 	return &RankingsService{
-		db:      db,
-		queries: sqlc.New(db),
+		db:            db,
+		queries:       queries,
+		rater:         rater,
+		matchups:      newMatchupCache(queries, strategy, bus),
+		matchupTokens: token.NewSigner(tokenSecret, matchupTokenPurpose),
+		rankingTokens: token.NewSigner(tokenSecret, rankingTokenPurpose),
+		sessions:      newSessionStore(db, tokenSecret),
+		repeatVotes:   repeatVotes,
+		events:        bus,
 	}
 }
 
+// inTx runs fn inside a single database transaction. The transaction is
+// committed if fn returns nil and rolled back otherwise; the error from fn is
+// returned unchanged so handlers can return connect errors from inside it.
+func (s *RankingsService) inTx(ctx context.Context, fn func(q *sqlc.Queries) error) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return connect.NewError(connect.CodeInternal, err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := fn(s.queries.WithTx(tx)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return connect.NewError(connect.CodeInternal, err)
+	}
+	return nil
+}
+
 // Helper to convert sqlc Company to proto Company
 func companyToProto(c sqlc.Company, rank int32) *gen.Company {
 	pc := &gen.Company{
@@ -40,7 +87,13 @@ func companyToProto(c sqlc.Company, rank int32) *gen.Company {
 		TotalVotes: c.TotalVotes,
 		Wins:       c.Wins,
 		Losses:     c.Losses,
+		Draws:      c.Draws,
+		Skips:      c.Skips,
 		Rank:       rank,
+
+		Rating:           c.Rating,
+		RatingDeviation:  c.RatingDeviation,
+		RatingVolatility: c.RatingVolatility,
 	}
 
 	if c.LogoUrl != nil {
@@ -78,6 +131,11 @@ func companyToProto(c sqlc.Company, rank int32) *gen.Company {
 	return pc
 }
 
+// eloPoints rounds a precise rating to the integer shown as elo_rating
+func eloPoints(r float64) int32 {
+	return int32(math.Round(r))
+}
+
 // HealthCheck checks the health of the service
 func (s *RankingsService) HealthCheck(
 	ctx context.Context,
@@ -121,11 +179,18 @@ func (s *RankingsService) GetStats(
 		totalComments = 0
 	}
 
+	outcomes, err := s.queries.CountVoteOutcomes(ctx)
+	if err != nil {
+		outcomes = sqlc.CountVoteOutcomesRow{}
+	}
+
 	return connect.NewResponse(&gen.GetStatsResponse{
 		TotalCompanies: int32(totalCompanies),
 		TotalVotes:     int32(totalVotes),
 		TotalRatings:   int32(totalRatings),
 		TotalComments:  int32(totalComments),
+		TotalDraws:     int32(outcomes.Draws),
+		TotalSkips:     int32(outcomes.Skips),
 	}), nil
 }
 
@@ -217,46 +282,72 @@ func (s *RankingsService) GetCompany(
 		rank = 0
 	}
 
+	pc := companyToProto(company, int32(rank))
+	if err := s.attachCategoryStanding(ctx, s.queries, pc, company.Category); err != nil {
+		return nil, err
+	}
+
 	return connect.NewResponse(&gen.GetCompanyResponse{
-		Company: companyToProto(company, int32(rank)),
+		Company: pc,
 	}), nil
 }
 
-// GetMatchup returns a random matchup pair
+// GetMatchup returns the next matchup pair chosen by the configured
+// matchmaking strategy
 func (s *RankingsService) GetMatchup(
 	ctx context.Context,
 	req *connect.Request[gen.GetMatchupRequest],
 ) (*connect.Response[gen.GetMatchupResponse], error) {
-	var companies []sqlc.Company
-	var err error
-
 	category := ""
 	if req.Msg.Category != nil {
 		category = *req.Msg.Category
 	}
 
-	if category != "" && category != "all" {
-		companies, err = s.queries.GetRandomMatchupByCategory(ctx, category)
-	} else {
-		companies, err = s.queries.GetRandomMatchup(ctx)
+	candidates, err := s.matchups.matchupCandidates(ctx, category)
+	if err != nil {
+		return nil, err
 	}
 
+	strategy := s.matchups.matchmakingStrategy(ctx)
+	first, second, err := strategy.Pick(candidates, matchmaking.GlobalSource)
+	if errors.Is(err, matchmaking.ErrNotEnoughCandidates) {
+		return nil, connect.NewError(connect.CodeNotFound, err)
+	}
 	if err != nil {
 		return nil, connect.NewError(connect.CodeInternal, err)
 	}
 
-	if len(companies) < 2 {
-		return nil, connect.NewError(connect.CodeNotFound, nil)
-	}
-
 	// Randomize order
 	if rand.Float32() > 0.5 {
-		companies[0], companies[1] = companies[1], companies[0]
+		first, second = second, first
+	}
+
+	company1, err := s.queries.GetCompanyByID(ctx, first.ID)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	company2, err := s.queries.GetCompanyByID(ctx, second.ID)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	if category == "all" {
+		category = ""
+	}
+	sessionID, err := requireSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	matchupToken, expiresAt, err := issueMatchupToken(s.matchupTokens, company1.ID, company2.ID, category, sessionID)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
 	}
 
 	return connect.NewResponse(&gen.GetMatchupResponse{
-		Company1: companyToProto(companies[0], 0),
-		Company2: companyToProto(companies[1], 0),
+		Company1:              companyToProto(company1, 0),
+		Company2:              companyToProto(company2, 0),
+		MatchupToken:          matchupToken,
+		MatchupTokenExpiresAt: timestamppb.New(expiresAt),
 	}), nil
 }
 
@@ -268,70 +359,323 @@ func (s *RankingsService) SubmitVote(
 	if req.Msg.WinnerId == req.Msg.LoserId {
 		return nil, connect.NewError(connect.CodeInvalidArgument, nil)
 	}
+	outcome, err := voteOutcomeFromProto(req.Msg.Outcome)
+	if err != nil {
+		return nil, err
+	}
+	strength, err := voteStrengthFromProto(req.Msg.GetStrength(), outcome)
+	if err != nil {
+		return nil, err
+	}
 
-	// Get current ELO ratings
-	winnerElo, err := s.queries.GetCompanyEloRating(ctx, req.Msg.WinnerId)
+	sessionID, err := requireSession(ctx)
 	if err != nil {
-		return nil, connect.NewError(connect.CodeNotFound, err)
+		return nil, err
 	}
 
-	loserElo, err := s.queries.GetCompanyEloRating(ctx, req.Msg.LoserId)
+	// Only votes on a matchup this server served to this session count
+	matchup, err := verifyMatchupToken(s.matchupTokens, req.Msg.MatchupToken, sessionID, req.Msg.WinnerId, req.Msg.LoserId)
 	if err != nil {
-		return nil, connect.NewError(connect.CodeNotFound, err)
+		return nil, err
 	}
+	category := matchup.Category
 
-	// Calculate new ELO ratings
-	kFactor := 32.0
-	expectedWinner := 1.0 / (1.0 + math.Pow(10, float64(loserElo-winnerElo)/400))
-	expectedLoser := 1.0 - expectedWinner
+	var resp *gen.SubmitVoteResponse
+	err = s.inTx(ctx, func(q *sqlc.Queries) error {
+		// Spend the token first; a concurrent replay blocks on this insert
+		// and finds the token used once this transaction commits.
+		used, err := q.UseMatchupToken(ctx, sqlc.UseMatchupTokenParams{
+			ID:        matchup.ID,
+			ExpiresAt: pgtype.Timestamptz{Time: time.Unix(matchup.ExpiresAt, 0), Valid: true},
+		})
+		if err != nil {
+			return connect.NewError(connect.CodeInternal, err)
+		}
+		if used == 0 {
+			return connect.NewError(connect.CodeAlreadyExists, errors.New("matchup token has already been used"))
+		}
 
-	newWinnerElo := int32(float64(winnerElo) + kFactor*(1-expectedWinner))
-	newLoserElo := int32(float64(loserElo) + kFactor*(0-expectedLoser))
+		cast, err := s.castVote(ctx, q, sessionID, pairVote{
+			winnerID: req.Msg.WinnerId,
+			loserID:  req.Msg.LoserId,
+			outcome:  outcome,
+			strength: strength,
+			category: category,
+		})
+		if err != nil {
+			return err
+		}
 
-	winnerEloDiff := newWinnerElo - winnerElo
-	loserEloDiff := newLoserElo - loserElo
+		// Get updated companies
+		winner, err := q.GetCompanyByID(ctx, req.Msg.WinnerId)
+		if err != nil {
+			return connect.NewError(connect.CodeInternal, err)
+		}
+		loser, err := q.GetCompanyByID(ctx, req.Msg.LoserId)
+		if err != nil {
+			return connect.NewError(connect.CodeInternal, err)
+		}
 
-	// Update companies
-	if err := s.queries.UpdateCompanyAfterWin(ctx, sqlc.UpdateCompanyAfterWinParams{
-		ID:        req.Msg.WinnerId,
-		EloRating: newWinnerElo,
-	}); err != nil {
-		return nil, connect.NewError(connect.CodeInternal, err)
+		winnerProto, loserProto := companyToProto(winner, 0), companyToProto(loser, 0)
+		if category != "" {
+			if err := s.attachCategoryStanding(ctx, q, winnerProto, category); err != nil {
+				return err
+			}
+			if err := s.attachCategoryStanding(ctx, q, loserProto, category); err != nil {
+				return err
+			}
+		}
+
+		resp = &gen.SubmitVoteResponse{
+			Winner:        winnerProto,
+			Loser:         loserProto,
+			WinnerEloDiff: winner.EloRating - eloPoints(cast.winnerRating.Value),
+			LoserEloDiff:  loser.EloRating - eloPoints(cast.loserRating.Value),
+			Counted:       cast.vote.Status == voteStatusCounted,
+			VoteId:        cast.vote.ID,
+			UndoExpiresAt: timestamppb.New(cast.vote.CreatedAt.Time.Add(VoteUndoWindow)),
+		}
+		if !resp.Counted {
+			return nil
+		}
+		return recordEvent(ctx, q, events.Event{
+			Kind:       events.KindVoteCast,
+			ID:         cast.vote.ID,
+			CompanyIDs: []int32{req.Msg.WinnerId, req.Msg.LoserId},
+			Category:   category,
+		})
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	if err := s.queries.UpdateCompanyAfterLoss(ctx, sqlc.UpdateCompanyAfterLossParams{
-		ID:        req.Msg.LoserId,
-		EloRating: newLoserElo,
-	}); err != nil {
+	return connect.NewResponse(resp), nil
+}
+
+// pairVote is a vote between two companies, cast directly or implied by a
+// ranking
+type pairVote struct {
+	winnerID  int32
+	loserID   int32
+	outcome   string
+	strength  rating.Strength
+	category  string
+	rankingID *int32
+}
+
+// castVoteResult is the recorded vote and the companies' global ratings
+// before it
+type castVoteResult struct {
+	vote         sqlc.CreateVoteRow
+	winnerRating rating.Rating
+	loserRating  rating.Rating
+}
+
+// castVote records a vote by the voter of sessionID and applies it to the
+// ratings. It locks both companies, so callers casting several votes in one
+// transaction must lock all of their companies up front.
+func (s *RankingsService) castVote(ctx context.Context, q *sqlc.Queries, sessionID string, v pairVote) (*castVoteResult, error) {
+	// Lock both companies before reading their ratings so concurrent votes
+	// on the same company are serialized instead of overwriting each other.
+	locked, err := q.LockCompaniesForUpdate(ctx, []int32{v.winnerID, v.loserID})
+	if err != nil {
 		return nil, connect.NewError(connect.CodeInternal, err)
 	}
+	if len(locked) != 2 {
+		return nil, connect.NewError(connect.CodeNotFound, errors.New("company not found"))
+	}
 
-	// Record vote
-	sessionID := req.Msg.SessionId
-	var userID *string
-	if req.Msg.UserId != nil {
-		userID = req.Msg.UserId
+	var winnerRating, loserRating rating.Rating
+	for _, c := range locked {
+		if v.category != "" && c.Category != v.category {
+			return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("company is not in the vote's category"))
+		}
+		r := rating.Rating{
+			Value:      c.Rating,
+			Deviation:  c.RatingDeviation,
+			Volatility: c.RatingVolatility,
+		}
+		if c.ID == v.winnerID {
+			winnerRating = r
+		} else {
+			loserRating = r
+		}
+	}
+
+	// Repeats inside the cooldown window are rejected, or recorded without
+	// changing any rating. Skips change nothing, so they are never repeats.
+	// A ranking is never rejected for one of the pairs it implies.
+	status := voteStatusCounted
+	if v.outcome != voteOutcomeSkip {
+		repeat, err := s.recordPairVote(ctx, q, voterKey(ctx, sessionID), v.winnerID, v.loserID, v.outcome)
+		if err != nil {
+			return nil, err
+		}
+		if repeat {
+			if s.repeatVotes.Action == RepeatVoteReject && v.rankingID == nil {
+				return nil, connect.NewError(connect.CodeFailedPrecondition, errors.New("already voted on this matchup recently"))
+			}
+			status = voteStatusRepeat
+		}
+	}
+
+	var deltas []ratingDelta
+	switch {
+	case status != voteStatusCounted:
+	case v.outcome == voteOutcomeSkip:
+		// A skip only records that the matchup was shown
+		if err := q.RecordCompanySkips(ctx, []int32{v.winnerID, v.loserID}); err != nil {
+			return nil, connect.NewError(connect.CodeInternal, err)
+		}
+	default:
+		newWinnerRating, newLoserRating := s.rate(v.outcome, v.strength, winnerRating, loserRating)
+		deltas = append(deltas,
+			newRatingDelta(v.winnerID, "", v.outcome == voteOutcomeWin, winnerRating, newWinnerRating),
+			newRatingDelta(v.loserID, "", false, loserRating, newLoserRating),
+		)
+
+		if err := updateCompanyRatings(ctx, q, v.outcome, v.winnerID, newWinnerRating, v.loserID, newLoserRating); err != nil {
+			return nil, err
+		}
+
+		if v.category != "" {
+			categoryDeltas, err := s.rateInCategory(ctx, q, v.category, v.outcome, v.strength, v.winnerID, v.loserID)
+			if err != nil {
+				return nil, err
+			}
+			deltas = append(deltas, categoryDeltas...)
+		}
+	}
+
+	// Record vote, attributed only to a verified user
+	var voteCategory *string
+	if v.category != "" {
+		voteCategory = &v.category
+	}
+	var userID *int32
+	if id, ok := currentUserID(ctx); ok {
+		userID = &id
 	}
-	s.queries.CreateVote(ctx, sqlc.CreateVoteParams{
-		WinnerID:  req.Msg.WinnerId,
-		LoserID:   req.Msg.LoserId,
+	vote, err := q.CreateVote(ctx, sqlc.CreateVoteParams{
+		WinnerID:  v.winnerID,
+		LoserID:   v.loserID,
 		SessionID: &sessionID,
 		UserID:    userID,
+		Category:  voteCategory,
+		Status:    status,
+		Outcome:   v.outcome,
+		Strength:  string(v.strength),
+		RankingID: v.rankingID,
 	})
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
 
-	// Get updated companies
-	winner, _ := s.queries.GetCompanyByID(ctx, req.Msg.WinnerId)
-	loser, _ := s.queries.GetCompanyByID(ctx, req.Msg.LoserId)
+	if len(deltas) > 0 {
+		if err := q.RecordRatingHistory(ctx, sqlc.RecordRatingHistoryParams{
+			VoteID:     &vote.ID,
+			CompanyIds: []int32{v.winnerID, v.loserID},
+		}); err != nil {
+			return nil, connect.NewError(connect.CodeInternal, err)
+		}
+	}
 
-	return connect.NewResponse(&gen.SubmitVoteResponse{
-		Winner:        companyToProto(winner, 0),
-		Loser:         companyToProto(loser, 0),
-		WinnerEloDiff: winnerEloDiff,
-		LoserEloDiff:  loserEloDiff,
-	}), nil
+	// Keep what the vote changed so that it can be undone exactly
+	for _, d := range deltas {
+		if err := q.CreateVoteRatingDelta(ctx, sqlc.CreateVoteRatingDeltaParams{
+			VoteID:          vote.ID,
+			CompanyID:       d.companyID,
+			Category:        d.category,
+			Won:             d.won,
+			RatingDelta:     d.delta.Value,
+			DeviationDelta:  d.delta.Deviation,
+			VolatilityDelta: d.delta.Volatility,
+		}); err != nil {
+			return nil, connect.NewError(connect.CodeInternal, err)
+		}
+	}
+
+	return &castVoteResult{
+		vote:         vote,
+		winnerRating: winnerRating,
+		loserRating:  loserRating,
+	}, nil
 }
 
-// GetLeaderboard returns the leaderboard
+// rateInCategory applies a win or a draw to the winner's and loser's ratings
+// within category and returns the changes. The caller must already hold the
+// companies' row locks.
+func (s *RankingsService) rateInCategory(ctx context.Context, q *sqlc.Queries, category, outcome string, strength rating.Strength, winnerID, loserID int32) ([]ratingDelta, error) {
+	locked, err := q.LockCategoryRatingsForUpdate(ctx, sqlc.LockCategoryRatingsForUpdateParams{
+		Category:   category,
+		CompanyIds: []int32{winnerID, loserID},
+	})
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	if len(locked) != 2 {
+		return nil, connect.NewError(connect.CodeInternal, errors.New("missing category rating"))
+	}
+
+	var winnerRating, loserRating rating.Rating
+	for _, r := range locked {
+		cr := rating.Rating{
+			Value:      r.Rating,
+			Deviation:  r.RatingDeviation,
+			Volatility: r.RatingVolatility,
+		}
+		if r.CompanyID == winnerID {
+			winnerRating = cr
+		} else {
+			loserRating = cr
+		}
+	}
+
+	newWinnerRating, newLoserRating := s.rate(outcome, strength, winnerRating, loserRating)
+
+	if err := updateCategoryRatings(ctx, q, category, outcome, winnerID, newWinnerRating, loserID, newLoserRating); err != nil {
+		return nil, err
+	}
+
+	return []ratingDelta{
+		newRatingDelta(winnerID, category, outcome == voteOutcomeWin, winnerRating, newWinnerRating),
+		newRatingDelta(loserID, category, false, loserRating, newLoserRating),
+	}, nil
+}
+
+// attachCategoryStanding fills in the company's rating and rank within category
+func (s *RankingsService) attachCategoryStanding(ctx context.Context, q *sqlc.Queries, pc *gen.Company, category string) error {
+	standing, err := q.GetCompanyCategoryStanding(ctx, sqlc.GetCompanyCategoryStandingParams{
+		CompanyID: pc.Id,
+		Category:  category,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return connect.NewError(connect.CodeInternal, err)
+	}
+
+	pc.CategoryRank = standing.CategoryRank
+	pc.CategoryStanding = categoryStandingToProto(standing.CompanyCategoryRating)
+	return nil
+}
+
+// Helper to convert sqlc CompanyCategoryRating to proto CategoryStanding
+func categoryStandingToProto(r sqlc.CompanyCategoryRating) *gen.CategoryStanding {
+	return &gen.CategoryStanding{
+		Category:        r.Category,
+		EloRating:       r.EloRating,
+		Rating:          r.Rating,
+		RatingDeviation: r.RatingDeviation,
+		Wins:            r.Wins,
+		Losses:          r.Losses,
+		TotalVotes:      r.TotalVotes,
+	}
+}
+
+// GetLeaderboard returns the leaderboard, or the trending leaderboard of a
+// time window when one is requested
 func (s *RankingsService) GetLeaderboard(
 	ctx context.Context,
 	req *connect.Request[gen.GetLeaderboardRequest],
@@ -346,41 +690,25 @@ func (s *RankingsService) GetLeaderboard(
 	}
 	offset := (page - 1) * pageSize
 
-	var companies []sqlc.Company
-	var totalCount int64
-	var err error
-
 	category := ""
 	if req.Msg.Category != nil {
 		category = *req.Msg.Category
 	}
 
-	if category != "" && category != "all" {
-		companies, err = s.queries.GetLeaderboardByCategory(ctx, sqlc.GetLeaderboardByCategoryParams{
-			Category: category,
-			Limit:    pageSize,
-			Offset:   offset,
-		})
-		if err == nil {
-			totalCount, _ = s.queries.CountCompaniesByCategory(ctx, category)
-		}
-	} else {
-		companies, err = s.queries.GetLeaderboard(ctx, sqlc.GetLeaderboardParams{
-			Limit:  pageSize,
-			Offset: offset,
-		})
-		if err == nil {
-			totalCount, _ = s.queries.CountCompanies(ctx)
-		}
+	if req.Msg.Window != gen.LeaderboardWindow_LEADERBOARD_WINDOW_UNSPECIFIED {
+		return s.trendingLeaderboard(ctx, req.Msg, category, page, pageSize)
 	}
 
+	protoCompanies, err := s.leaderboardPage(ctx, category, offset, pageSize)
 	if err != nil {
-		return nil, connect.NewError(connect.CodeInternal, err)
+		return nil, err
 	}
 
-	protoCompanies := make([]*gen.Company, len(companies))
-	for i, c := range companies {
-		protoCompanies[i] = companyToProto(c, int32(offset)+int32(i)+1)
+	var totalCount int64
+	if category != "" && category != "all" {
+		totalCount, _ = s.queries.CountCompaniesByCategory(ctx, category)
+	} else {
+		totalCount, _ = s.queries.CountCompanies(ctx)
 	}
 
 	return connect.NewResponse(&gen.GetLeaderboardResponse{
@@ -391,6 +719,45 @@ func (s *RankingsService) GetLeaderboard(
 	}), nil
 }
 
+// leaderboardPage returns limit companies of the leaderboard starting at
+// offset. In a category they are ordered by category rating, with rank
+// staying the global rank and category_rank set.
+func (s *RankingsService) leaderboardPage(ctx context.Context, category string, offset, limit int32) ([]*gen.Company, error) {
+	if category != "" && category != "all" {
+		rows, err := s.queries.GetCategoryLeaderboard(ctx, sqlc.GetCategoryLeaderboardParams{
+			Category:   category,
+			PageOffset: offset,
+			PageLimit:  limit,
+		})
+		if err != nil {
+			return nil, connect.NewError(connect.CodeInternal, err)
+		}
+
+		protoCompanies := make([]*gen.Company, len(rows))
+		for i, row := range rows {
+			pc := companyToProto(row.Company, row.GlobalRank)
+			pc.CategoryRank = offset + int32(i) + 1
+			pc.CategoryStanding = categoryStandingToProto(row.CompanyCategoryRating)
+			protoCompanies[i] = pc
+		}
+		return protoCompanies, nil
+	}
+
+	companies, err := s.queries.GetLeaderboard(ctx, sqlc.GetLeaderboardParams{
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	protoCompanies := make([]*gen.Company, len(companies))
+	for i, c := range companies {
+		protoCompanies[i] = companyToProto(c, offset+int32(i)+1)
+	}
+	return protoCompanies, nil
+}
+
 // GetUserLeaderboard returns the user leaderboard ranked by total votes
 func (s *RankingsService) GetUserLeaderboard(
 	ctx context.Context,
@@ -421,14 +788,12 @@ func (s *RankingsService) GetUserLeaderboard(
 
 	protoUsers := make([]*gen.UserLeaderboardEntry, len(users))
 	for i, u := range users {
-		userId := ""
-		if u.UserID != nil {
-			userId = *u.UserID
-		}
 		protoUsers[i] = &gen.UserLeaderboardEntry{
-			UserId:     userId,
-			TotalVotes: int32(u.TotalVotes),
-			Rank:       int32(offset) + int32(i) + 1,
+			UserId:      publicUserID(u.ID),
+			TotalVotes:  int32(u.TotalVotes),
+			Rank:        int32(offset) + int32(i) + 1,
+			DisplayName: u.Name,
+			AvatarUrl:   u.AvatarUrl,
 		}
 	}
 
@@ -467,15 +832,34 @@ func (s *RankingsService) SubmitRating(
 		return nil, connect.NewError(connect.CodeNotFound, err)
 	}
 
-	sessionID := req.Msg.SessionId
-	rating, err := s.queries.CreateRating(ctx, sqlc.CreateRatingParams{
-		CompanyID: req.Msg.CompanyId,
-		Criterion: req.Msg.Criterion,
-		Score:     req.Msg.Score,
-		SessionID: &sessionID,
+	sessionID, err := requireSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var userID *int32
+	if id, ok := currentUserID(ctx); ok {
+		userID = &id
+	}
+	var rating sqlc.CompanyRating
+	err = s.inTx(ctx, func(q *sqlc.Queries) error {
+		rating, err = q.CreateRating(ctx, sqlc.CreateRatingParams{
+			CompanyID: req.Msg.CompanyId,
+			Criterion: req.Msg.Criterion,
+			Score:     req.Msg.Score,
+			SessionID: &sessionID,
+			UserID:    userID,
+		})
+		if err != nil {
+			return connect.NewError(connect.CodeInternal, err)
+		}
+		return recordEvent(ctx, q, events.Event{
+			Kind:       events.KindRatingSubmitted,
+			ID:         rating.ID,
+			CompanyIDs: []int32{rating.CompanyID},
+		})
 	})
 	if err != nil {
-		return nil, connect.NewError(connect.CodeInternal, err)
+		return nil, err
 	}
 
 	protoRating := &gen.CompanyRating{
@@ -543,15 +927,34 @@ func (s *RankingsService) SubmitComment(
 		return nil, connect.NewError(connect.CodeNotFound, err)
 	}
 
-	sessionID := req.Msg.SessionId
-	comment, err := s.queries.CreateComment(ctx, sqlc.CreateCommentParams{
-		CompanyID:         req.Msg.CompanyId,
-		Content:           content,
-		IsCurrentEmployee: &req.Msg.IsCurrentEmployee,
-		SessionID:         &sessionID,
+	sessionID, err := requireSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var userID *int32
+	if id, ok := currentUserID(ctx); ok {
+		userID = &id
+	}
+	var comment sqlc.CompanyComment
+	err = s.inTx(ctx, func(q *sqlc.Queries) error {
+		comment, err = q.CreateComment(ctx, sqlc.CreateCommentParams{
+			CompanyID:         req.Msg.CompanyId,
+			Content:           content,
+			IsCurrentEmployee: &req.Msg.IsCurrentEmployee,
+			SessionID:         &sessionID,
+			UserID:            userID,
+		})
+		if err != nil {
+			return connect.NewError(connect.CodeInternal, err)
+		}
+		return recordEvent(ctx, q, events.Event{
+			Kind:       events.KindCommentCreated,
+			ID:         comment.ID,
+			CompanyIDs: []int32{comment.CompanyID},
+		})
 	})
 	if err != nil {
-		return nil, connect.NewError(connect.CodeInternal, err)
+		return nil, err
 	}
 
 	protoComment := &gen.CompanyComment{
@@ -619,9 +1022,21 @@ func (s *RankingsService) UpvoteComment(
 	ctx context.Context,
 	req *connect.Request[gen.UpvoteCommentRequest],
 ) (*connect.Response[gen.UpvoteCommentResponse], error) {
-	comment, err := s.queries.UpvoteComment(ctx, req.Msg.CommentId)
+	var comment sqlc.CompanyComment
+	err := s.inTx(ctx, func(q *sqlc.Queries) error {
+		var err error
+		comment, err = q.UpvoteComment(ctx, req.Msg.CommentId)
+		if err != nil {
+			return connect.NewError(connect.CodeNotFound, err)
+		}
+		return recordEvent(ctx, q, events.Event{
+			Kind:       events.KindCommentUpvoted,
+			ID:         comment.ID,
+			CompanyIDs: []int32{comment.CompanyID},
+		})
+	})
 	if err != nil {
-		return nil, connect.NewError(connect.CodeNotFound, err)
+		return nil, err
 	}
 
 	protoComment := &gen.CompanyComment{
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"connectrpc.com/connect"
+
+	"github.com/cloutdotgg/backend/internal/dbtest"
+	"github.com/cloutdotgg/backend/internal/events"
+	gen "github.com/cloutdotgg/backend/internal/gen/apiv1"
+	"github.com/cloutdotgg/backend/internal/matchmaking"
+	"github.com/cloutdotgg/backend/internal/rating"
+	"github.com/cloutdotgg/backend/internal/recompute"
+)
+
+// TestConcurrentVotesMatchSerialReplay casts votes on overlapping pairs of
+// companies at the same time and checks that the stored ratings are the ones
+// a serial replay of the votes log produces.
+func TestConcurrentVotesMatchSerialReplay(t *testing.T) {
+	pool := dbtest.New(t)
+	ctx := context.Background()
+
+	for _, engine := range []string{"elo", "glicko2"} {
+		t.Run(engine, func(t *testing.T) {
+			rater, err := rating.New(engine)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Start from the ratings the replay starts from
+			if _, err := recompute.Run(ctx, pool, rater, recompute.Options{}); err != nil {
+				t.Fatal(err)
+			}
+
+			s := NewRankingsService(pool, rater, matchmaking.Random{}, []byte("test-token-secret"),
+				RepeatVotePolicy{}, events.NewLocal(events.DefaultBuffer))
+
+			rows, err := pool.Query(ctx, "SELECT id FROM companies ORDER BY id LIMIT 4")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []int32
+			for rows.Next() {
+				var id int32
+				if err := rows.Scan(&id); err != nil {
+					t.Fatal(err)
+				}
+				ids = append(ids, id)
+			}
+			if err := rows.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if len(ids) < 4 {
+				t.Fatalf("need 4 companies, have %d", len(ids))
+			}
+
+			// Every pair of the four companies shares a company with four
+			// of the five others
+			var pairs [][2]int32
+			for i := range ids {
+				for j := i + 1; j < len(ids); j++ {
+					pairs = append(pairs, [2]int32{ids[i], ids[j]})
+				}
+			}
+
+			const votes = 120
+			type vote struct {
+				ctx           context.Context
+				winner, loser int32
+				matchupToken  string
+			}
+			prepared := make([]vote, votes)
+			for i := range prepared {
+				sessionID, _, _, err := s.sessions.start(ctx)
+				if err != nil {
+					t.Fatal(err)
+				}
+				pair := pairs[i%len(pairs)]
+				winner, loser := pair[0], pair[1]
+				if i%3 == 0 {
+					winner, loser = loser, winner
+				}
+				matchupToken, _, err := issueMatchupToken(s.matchupTokens, pair[0], pair[1], "", sessionID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				prepared[i] = vote{
+					ctx:          context.WithValue(ctx, sessionIDKey{}, sessionID),
+					winner:       winner,
+					loser:        loser,
+					matchupToken: matchupToken,
+				}
+			}
+
+			var wg sync.WaitGroup
+			errs := make(chan error, votes)
+			for _, v := range prepared {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := s.SubmitVote(v.ctx, connect.NewRequest(&gen.SubmitVoteRequest{
+						WinnerId:     v.winner,
+						LoserId:      v.loser,
+						MatchupToken: v.matchupToken,
+					}))
+					errs <- err
+				}()
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				if err != nil {
+					t.Fatalf("SubmitVote: %v", err)
+				}
+			}
+
+			report, err := recompute.Run(ctx, pool, rater, recompute.Options{DryRun: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, c := range report.Changes {
+				t.Errorf("company %d: stored %+v, serial replay %+v", c.CompanyID, c.Before, c.After)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	gen "github.com/cloutdotgg/backend/internal/gen/apiv1"
+	"github.com/cloutdotgg/backend/internal/gen/apiv1/apiv1connect"
+	"github.com/cloutdotgg/backend/internal/ratelimit"
+)
+
+// rateLimit is the pair of buckets a procedure is limited by. A zero policy
+// is not enforced.
+type rateLimit struct {
+	// caller is keyed by the signed-in user, or by the session for anonymous
+	// visitors
+	caller ratelimit.Policy
+	// ip is keyed by client address. It is more generous than caller since
+	// many visitors can share an address, but it stops one client from
+	// dodging the caller limit by starting new sessions.
+	ip ratelimit.Policy
+}
+
+// rateLimits are the limits of every rate limited procedure
+var rateLimits = map[string]rateLimit{
+	apiv1connect.RankingsServiceGetMatchupProcedure: {
+		caller: ratelimit.PerMinute(60, 20),
+		ip:     ratelimit.PerMinute(300, 100),
+	},
+	apiv1connect.RankingsServiceSubmitVoteProcedure: {
+		caller: ratelimit.PerMinute(30, 10),
+		ip:     ratelimit.PerMinute(150, 50),
+	},
+	apiv1connect.RankingsServiceUndoVoteProcedure: {
+		caller: ratelimit.PerMinute(10, 5),
+		ip:     ratelimit.PerMinute(50, 20),
+	},
+	apiv1connect.RankingsServiceGetRankingSetProcedure: {
+		caller: ratelimit.PerMinute(20, 10),
+		ip:     ratelimit.PerMinute(100, 50),
+	},
+	apiv1connect.RankingsServiceSubmitRankingProcedure: {
+		caller: ratelimit.PerMinute(10, 5),
+		ip:     ratelimit.PerMinute(50, 20),
+	},
+	apiv1connect.RankingsServiceWatchLeaderboardProcedure: {
+		ip: ratelimit.PerMinute(20, 10),
+	},
+	apiv1connect.RankingsServiceSubmitRatingProcedure: {
+		caller: ratelimit.PerMinute(20, 10),
+		ip:     ratelimit.PerMinute(100, 50),
+	},
+	apiv1connect.RankingsServiceSubmitCommentProcedure: {
+		caller: ratelimit.PerHour(10, 3),
+		ip:     ratelimit.PerHour(50, 10),
+	},
+	apiv1connect.RankingsServiceUpvoteCommentProcedure: {
+		caller: ratelimit.PerMinute(20, 10),
+		ip:     ratelimit.PerMinute(100, 50),
+	},
+	apiv1connect.RankingsServiceUpdateMeProcedure: {
+		caller: ratelimit.PerMinute(10, 5),
+	},
+	apiv1connect.RankingsServiceStartSessionProcedure: {
+		ip: ratelimit.PerMinute(10, 10),
+	},
+	apiv1connect.RankingsServiceClaimSessionProcedure: {
+		caller: ratelimit.PerMinute(10, 5),
+		ip:     ratelimit.PerMinute(30, 10),
+	},
+}
+
+// RateLimitInterceptor enforces rateLimits. It must run after the session
+// and user interceptors so that callers are limited by who they are rather
+// than only by address.
+type RateLimitInterceptor struct {
+	limiter ratelimit.Limiter
+	proxies ratelimit.TrustedProxies
+}
+
+// NewRateLimitInterceptor creates an interceptor that keeps buckets in
+// limiter and believes X-Forwarded-For only from proxies
+func NewRateLimitInterceptor(limiter ratelimit.Limiter, proxies ratelimit.TrustedProxies) *RateLimitInterceptor {
+	return &RateLimitInterceptor{limiter: limiter, proxies: proxies}
+}
+
+// WrapUnary implements connect.Interceptor
+func (i *RateLimitInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		if err := i.limit(ctx, req.Spec().Procedure, req.Peer().Addr, req.Header()); err != nil {
+			return nil, err
+		}
+		return next(ctx, req)
+	}
+}
+
+// WrapStreamingClient implements connect.Interceptor
+func (i *RateLimitInterceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+// WrapStreamingHandler implements connect.Interceptor
+func (i *RateLimitInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		if err := i.limit(ctx, conn.Spec().Procedure, conn.Peer().Addr, conn.RequestHeader()); err != nil {
+			return err
+		}
+		return next(ctx, conn)
+	}
+}
+
+func (i *RateLimitInterceptor) limit(ctx context.Context, procedure, peer string, header http.Header) error {
+	limits, ok := rateLimits[procedure]
+	if !ok {
+		return nil
+	}
+
+	if limits.caller.Burst > 0 {
+		var caller string
+		if userID, ok := currentUserID(ctx); ok {
+			caller = fmt.Sprintf("user:%d", userID)
+		} else if sessionID, ok := currentSessionID(ctx); ok {
+			caller = "session:" + sessionID
+		}
+		if caller != "" {
+			if err := i.take(ctx, procedure+" "+caller, limits.caller); err != nil {
+				return err
+			}
+		}
+	}
+
+	if limits.ip.Burst > 0 {
+		if ip := i.proxies.ClientIP(peer, header); ip != "" {
+			if err := i.take(ctx, procedure+" ip:"+ip, limits.ip); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// take takes a token from key's bucket. Requests are let through when the
+// limiter fails, so that an outage of the rate limit store does not take the
+// whole API down with it.
+func (i *RateLimitInterceptor) take(ctx context.Context, key string, policy ratelimit.Policy) error {
+	result, err := i.limiter.Take(ctx, key, policy)
+	if err != nil {
+		log.Printf("Rate limiter failed, allowing request: %v", err)
+		return nil
+	}
+	if result.Allowed {
+		return nil
+	}
+	return rateLimitError(result.RetryAfter)
+}
+
+// rateLimitError reports when the request can be retried both as a RetryInfo
+// detail and as a Retry-After header
+func rateLimitError(retryAfter time.Duration) error {
+	err := connect.NewError(connect.CodeResourceExhausted, errors.New("rate limit exceeded, try again later"))
+	if detail, detailErr := connect.NewErrorDetail(&gen.RetryInfo{
+		RetryDelay: durationpb.New(retryAfter),
+	}); detailErr == nil {
+		err.AddDetail(detail)
+	}
+	err.Meta().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	return err
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+
+	gen "github.com/cloutdotgg/backend/internal/gen/apiv1"
+)
+
+func TestRateLimitError(t *testing.T) {
+	tests := []struct {
+		retryAfter time.Duration
+		// header is the Retry-After value, in whole seconds rounded up
+		header string
+	}{
+		{retryAfter: 0, header: "0"},
+		{retryAfter: time.Millisecond, header: "1"},
+		{retryAfter: time.Second, header: "1"},
+		{retryAfter: 1500 * time.Millisecond, header: "2"},
+		{retryAfter: time.Hour, header: "3600"},
+	}
+	for _, tt := range tests {
+		var err *connect.Error
+		if !errors.As(rateLimitError(tt.retryAfter), &err) {
+			t.Fatalf("%v: not a connect error", tt.retryAfter)
+		}
+		if err.Code() != connect.CodeResourceExhausted {
+			t.Errorf("%v: code %v", tt.retryAfter, err.Code())
+		}
+		if got := err.Meta().Get("Retry-After"); got != tt.header {
+			t.Errorf("%v: Retry-After %q, want %q", tt.retryAfter, got, tt.header)
+		}
+
+		var infos []*gen.RetryInfo
+		for _, detail := range err.Details() {
+			value, detailErr := detail.Value()
+			if detailErr != nil {
+				t.Fatal(detailErr)
+			}
+			if info, ok := value.(*gen.RetryInfo); ok {
+				infos = append(infos, info)
+			}
+		}
+		if len(infos) != 1 {
+			t.Fatalf("%v: %d RetryInfo details, want 1", tt.retryAfter, len(infos))
+		}
+		if got := infos[0].RetryDelay.AsDuration(); got != tt.retryAfter {
+			t.Errorf("%v: RetryInfo delay %v", tt.retryAfter, got)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/cloutdotgg/backend/internal/db/sqlc"
+)
+
+// Statuses of recorded votes. Only counted votes change ratings and appear
+// in vote totals. Retracted votes were undone by their voter.
+const (
+	voteStatusCounted   = "counted"
+	voteStatusRepeat    = "repeat"
+	voteStatusRetracted = "retracted"
+)
+
+// RepeatVoteAction is what happens to a vote on a pair that the same voter
+// already voted on inside the cooldown window
+type RepeatVoteAction string
+
+const (
+	// RepeatVoteIgnore records the vote with the repeat status without
+	// changing any rating
+	RepeatVoteIgnore RepeatVoteAction = "ignore"
+	// RepeatVoteReject fails the vote with FailedPrecondition
+	RepeatVoteReject RepeatVoteAction = "reject"
+)
+
+// RepeatVotePolicy allows each voter one counted vote per pair of companies
+// within Cooldown, whichever company they pick. A zero Cooldown counts every
+// vote.
+type RepeatVotePolicy struct {
+	Cooldown time.Duration
+	Action   RepeatVoteAction
+}
+
+// DefaultRepeatVotePolicy is used for settings that are not configured
+var DefaultRepeatVotePolicy = RepeatVotePolicy{
+	Cooldown: 24 * time.Hour,
+	Action:   RepeatVoteIgnore,
+}
+
+// ParseRepeatVotePolicy parses a cooldown duration such as "24h" and an
+// action ("ignore" or "reject"). Empty values select the defaults.
+func ParseRepeatVotePolicy(cooldown, action string) (RepeatVotePolicy, error) {
+	policy := DefaultRepeatVotePolicy
+
+	if cooldown = strings.TrimSpace(cooldown); cooldown != "" {
+		d, err := time.ParseDuration(cooldown)
+		if err != nil || d < 0 {
+			return RepeatVotePolicy{}, fmt.Errorf("invalid vote cooldown %q", cooldown)
+		}
+		policy.Cooldown = d
+	}
+
+	switch RepeatVoteAction(strings.ToLower(strings.TrimSpace(action))) {
+	case "":
+	case RepeatVoteIgnore:
+		policy.Action = RepeatVoteIgnore
+	case RepeatVoteReject:
+		policy.Action = RepeatVoteReject
+	default:
+		return RepeatVotePolicy{}, fmt.Errorf("unknown repeat vote action %q (want ignore or reject)", action)
+	}
+	return policy, nil
+}
+
+// voterKey identifies the voter for the cooldown: the signed-in user, or the
+// anonymous session otherwise
+func voterKey(ctx context.Context, sessionID string) string {
+	if userID, ok := currentUserID(ctx); ok {
+		return fmt.Sprintf("user:%d", userID)
+	}
+	return "session:" + sessionID
+}
+
+// recordPairVote remembers the voter's win or draw on the pair and reports
+// whether it repeats one cast inside the cooldown window. Concurrent votes by
+// the same voter on the same pair are serialized by the pair's row, so only
+// one of them counts.
+func (s *RankingsService) recordPairVote(ctx context.Context, q *sqlc.Queries, voter string, winnerID, loserID int32, outcome string) (bool, error) {
+	if s.repeatVotes.Cooldown <= 0 {
+		return false, nil
+	}
+
+	low, high := winnerID, loserID
+	if low > high {
+		low, high = high, low
+	}
+	var winner *int32
+	if outcome == voteOutcomeWin {
+		winner = &winnerID
+	}
+	now := time.Now()
+	recorded, err := q.RecordPairVote(ctx, sqlc.RecordPairVoteParams{
+		Voter:         voter,
+		CompanyLow:    low,
+		CompanyHigh:   high,
+		WinnerID:      winner,
+		VotedAt:       pgtype.Timestamptz{Time: now, Valid: true},
+		CooldownStart: pgtype.Timestamptz{Time: now.Add(-s.repeatVotes.Cooldown), Valid: true},
+	})
+	if err != nil {
+		return false, connect.NewError(connect.CodeInternal, err)
+	}
+	return recorded == 0, nil
+}
@@ -0,0 +1,265 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/cloutdotgg/backend/internal/db/sqlc"
+	gen "github.com/cloutdotgg/backend/internal/gen/apiv1"
+	"github.com/cloutdotgg/backend/internal/gen/apiv1/apiv1connect"
+	"github.com/cloutdotgg/backend/internal/token"
+)
+
+// sessionTokenTTL is how long an anonymous session lasts
+const sessionTokenTTL = 30 * 24 * time.Hour
+
+// sessionTokenPurpose separates session token keys from other tokens signed
+// with the same secret
+const sessionTokenPurpose = "session"
+
+// SessionTokenHeader carries the session token on requests
+const SessionTokenHeader = "X-Session-Token"
+
+// sessionClaims proves possession of an anonymous session
+type sessionClaims struct {
+	SessionID string `json:"sid"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// sessionStore issues session tokens and checks them against the sessions
+// table, so that revoked sessions stop working immediately
+type sessionStore struct {
+	queries *sqlc.Queries
+	signer  *token.Signer
+}
+
+func newSessionStore(db *pgxpool.Pool, tokenSecret []byte) *sessionStore {
+	return &sessionStore{
+		queries: sqlc.New(db),
+		signer:  token.NewSigner(tokenSecret, sessionTokenPurpose),
+	}
+}
+
+// start creates a session and returns its id, token and expiry
+func (s *sessionStore) start(ctx context.Context) (string, string, time.Time, error) {
+	id, err := token.NewID()
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+	sessionID := "session_" + id
+
+	expiresAt := time.Now().Add(sessionTokenTTL).Truncate(time.Second)
+	if _, err := s.queries.CreateSession(ctx, sqlc.CreateSessionParams{
+		ID:        sessionID,
+		ExpiresAt: pgtype.Timestamptz{Time: expiresAt, Valid: true},
+	}); err != nil {
+		return "", "", time.Time{}, err
+	}
+
+	sessionToken, err := s.signer.Sign(sessionClaims{
+		SessionID: sessionID,
+		ExpiresAt: expiresAt.Unix(),
+	})
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+	return sessionID, sessionToken, expiresAt, nil
+}
+
+// verify returns the session id of a valid, unexpired and unrevoked token
+func (s *sessionStore) verify(ctx context.Context, sessionToken string) (string, error) {
+	var claims sessionClaims
+	if err := s.signer.Verify(sessionToken, &claims); err != nil {
+		return "", connect.NewError(connect.CodeUnauthenticated, errors.New("invalid session token"))
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return "", connect.NewError(connect.CodeUnauthenticated, errors.New("session has expired"))
+	}
+
+	session, err := s.queries.GetSession(ctx, claims.SessionID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", connect.NewError(connect.CodeUnauthenticated, errors.New("unknown session"))
+	}
+	if err != nil {
+		return "", connect.NewError(connect.CodeInternal, err)
+	}
+	if session.RevokedAt.Valid {
+		return "", connect.NewError(connect.CodeUnauthenticated, errors.New("session has been revoked"))
+	}
+	return session.ID, nil
+}
+
+type sessionIDKey struct{}
+
+// currentSessionID returns the caller's verified anonymous session
+func currentSessionID(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(sessionIDKey{}).(string)
+	return id, ok
+}
+
+// sessionRequiredProcedures need a valid session token: everything that
+// writes on behalf of a visitor, and GetMatchup and GetRankingSet, whose
+// tokens are bound to the session
+var sessionRequiredProcedures = map[string]bool{
+	apiv1connect.RankingsServiceGetMatchupProcedure:    true,
+	apiv1connect.RankingsServiceSubmitVoteProcedure:    true,
+	apiv1connect.RankingsServiceUndoVoteProcedure:      true,
+	apiv1connect.RankingsServiceGetRankingSetProcedure: true,
+	apiv1connect.RankingsServiceSubmitRankingProcedure: true,
+	apiv1connect.RankingsServiceSubmitRatingProcedure:  true,
+	apiv1connect.RankingsServiceSubmitCommentProcedure: true,
+	apiv1connect.RankingsServiceUpvoteCommentProcedure: true,
+}
+
+// SessionInterceptor verifies the session token header and puts the session
+// id in the context. Procedures that act on behalf of a visitor reject
+// requests without a valid token.
+type SessionInterceptor struct {
+	sessions *sessionStore
+}
+
+// NewSessionInterceptor creates a session interceptor for tokens signed with
+// tokenSecret
+func NewSessionInterceptor(db *pgxpool.Pool, tokenSecret []byte) *SessionInterceptor {
+	return &SessionInterceptor{sessions: newSessionStore(db, tokenSecret)}
+}
+
+// WrapUnary implements connect.Interceptor
+func (i *SessionInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		ctx, err := i.authenticate(ctx, req.Spec().Procedure, req.Header())
+		if err != nil {
+			return nil, err
+		}
+		return next(ctx, req)
+	}
+}
+
+// WrapStreamingClient implements connect.Interceptor
+func (i *SessionInterceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+// WrapStreamingHandler implements connect.Interceptor
+func (i *SessionInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		ctx, err := i.authenticate(ctx, conn.Spec().Procedure, conn.RequestHeader())
+		if err != nil {
+			return err
+		}
+		return next(ctx, conn)
+	}
+}
+
+func (i *SessionInterceptor) authenticate(ctx context.Context, procedure string, header http.Header) (context.Context, error) {
+	sessionToken := header.Get(SessionTokenHeader)
+	if sessionToken == "" {
+		if sessionRequiredProcedures[procedure] {
+			return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("session token required"))
+		}
+		return ctx, nil
+	}
+
+	sessionID, err := i.sessions.verify(ctx, sessionToken)
+	if err != nil {
+		return nil, err
+	}
+	return context.WithValue(ctx, sessionIDKey{}, sessionID), nil
+}
+
+// requireSession returns the caller's session id or an unauthenticated error
+func requireSession(ctx context.Context) (string, error) {
+	id, ok := currentSessionID(ctx)
+	if !ok {
+		return "", connect.NewError(connect.CodeUnauthenticated, errors.New("session token required"))
+	}
+	return id, nil
+}
+
+// StartSession issues a new anonymous session and the token proving it
+func (s *RankingsService) StartSession(
+	ctx context.Context,
+	req *connect.Request[gen.StartSessionRequest],
+) (*connect.Response[gen.StartSessionResponse], error) {
+	sessionID, sessionToken, expiresAt, err := s.sessions.start(ctx)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	return connect.NewResponse(&gen.StartSessionResponse{
+		SessionId:    sessionID,
+		SessionToken: sessionToken,
+		ExpiresAt:    timestamppb.New(expiresAt),
+	}), nil
+}
+
+// ClaimSession moves the votes, ratings and comments of an anonymous session
+// to the signed-in user. A session can only be claimed by one user.
+func (s *RankingsService) ClaimSession(
+	ctx context.Context,
+	req *connect.Request[gen.ClaimSessionRequest],
+) (*connect.Response[gen.ClaimSessionResponse], error) {
+	userID, err := requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionID, err := s.sessions.verify(ctx, req.Msg.SessionToken)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &gen.ClaimSessionResponse{}
+	err = s.inTx(ctx, func(q *sqlc.Queries) error {
+		owner, err := q.ClaimSession(ctx, sqlc.ClaimSessionParams{
+			SessionID: sessionID,
+			UserID:    userID,
+		})
+		if err != nil {
+			return connect.NewError(connect.CodeInternal, err)
+		}
+		if owner != userID {
+			return connect.NewError(connect.CodePermissionDenied, errors.New("session has been claimed by another user"))
+		}
+
+		votes, err := q.ClaimSessionVotes(ctx, sqlc.ClaimSessionVotesParams{
+			UserID:    &userID,
+			SessionID: &sessionID,
+		})
+		if err != nil {
+			return connect.NewError(connect.CodeInternal, err)
+		}
+		ratings, err := q.ClaimSessionRatings(ctx, sqlc.ClaimSessionRatingsParams{
+			UserID:    &userID,
+			SessionID: &sessionID,
+		})
+		if err != nil {
+			return connect.NewError(connect.CodeInternal, err)
+		}
+		comments, err := q.ClaimSessionComments(ctx, sqlc.ClaimSessionCommentsParams{
+			UserID:    &userID,
+			SessionID: &sessionID,
+		})
+		if err != nil {
+			return connect.NewError(connect.CodeInternal, err)
+		}
+
+		resp.VotesClaimed = int32(votes)
+		resp.RatingsClaimed = int32(ratings)
+		resp.CommentsClaimed = int32(comments)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return connect.NewResponse(resp), nil
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/jackc/pgx/v5/pgtype"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/cloutdotgg/backend/internal/db/sqlc"
+	gen "github.com/cloutdotgg/backend/internal/gen/apiv1"
+	"github.com/cloutdotgg/backend/internal/trending"
+)
+
+// leaderboardWindow returns the hour-aligned bounds of the window requested
+// in req as of now.
+func leaderboardWindow(req *gen.GetLeaderboardRequest, now time.Time) (time.Time, time.Time, error) {
+	var length time.Duration
+	switch req.Window {
+	case gen.LeaderboardWindow_LEADERBOARD_WINDOW_24H:
+		length = trending.Day
+	case gen.LeaderboardWindow_LEADERBOARD_WINDOW_7D:
+		length = trending.Week
+	case gen.LeaderboardWindow_LEADERBOARD_WINDOW_30D:
+		length = trending.Month
+	case gen.LeaderboardWindow_LEADERBOARD_WINDOW_CUSTOM:
+		if req.WindowStart == nil {
+			return time.Time{}, time.Time{}, errors.New("window_start is required for a custom window")
+		}
+		end := now
+		if req.WindowEnd != nil {
+			end = req.WindowEnd.AsTime()
+		}
+		return trending.Bounds(req.WindowStart.AsTime(), end)
+	default:
+		return time.Time{}, time.Time{}, fmt.Errorf("unknown leaderboard window %v", req.Window)
+	}
+	if req.WindowStart != nil || req.WindowEnd != nil {
+		return time.Time{}, time.Time{}, errors.New("window_start and window_end are only allowed with a custom window")
+	}
+	return trending.Bounds(now.Add(-length), now)
+}
+
+// trendingSort maps a requested order to a GetTrendingLeaderboard sort and
+// the votes a company needs inside the window to be ranked by it.
+func trendingSort(sort gen.TrendingSort) (string, int32, error) {
+	switch sort {
+	case gen.TrendingSort_TRENDING_SORT_UNSPECIFIED, gen.TrendingSort_TRENDING_SORT_RATING_GAIN:
+		return trending.SortRatingGain, 1, nil
+	case gen.TrendingSort_TRENDING_SORT_WIN_RATE:
+		return trending.SortWinRate, trending.MinWinRateVotes, nil
+	case gen.TrendingSort_TRENDING_SORT_VOTES:
+		return trending.SortVotes, 1, nil
+	default:
+		return "", 0, fmt.Errorf("unknown trending sort %v", sort)
+	}
+}
+
+// trendingLeaderboard returns a page of the companies with counted votes
+// inside the requested window, ranked by their activity there. Rank stays
+// the global rank; the trending standing carries the rank in the window.
+func (s *RankingsService) trendingLeaderboard(
+	ctx context.Context,
+	req *gen.GetLeaderboardRequest,
+	category string,
+	page, pageSize int32,
+) (*connect.Response[gen.GetLeaderboardResponse], error) {
+	start, end, err := leaderboardWindow(req, time.Now())
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+	sort, minVotes, err := trendingSort(req.Sort)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+
+	var categoryFilter *string
+	if category != "" && category != "all" {
+		categoryFilter = &category
+	}
+	windowStart := pgtype.Timestamptz{Time: start, Valid: true}
+	windowEnd := pgtype.Timestamptz{Time: end, Valid: true}
+	offset := (page - 1) * pageSize
+
+	rows, err := s.queries.GetTrendingLeaderboard(ctx, sqlc.GetTrendingLeaderboardParams{
+		WindowStart: windowStart,
+		WindowEnd:   windowEnd,
+		MinVotes:    minVotes,
+		Category:    categoryFilter,
+		Sort:        sort,
+		PageLimit:   pageSize,
+		PageOffset:  offset,
+	})
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	totalCount, err := s.queries.CountTrendingCompanies(ctx, sqlc.CountTrendingCompaniesParams{
+		WindowStart: windowStart,
+		WindowEnd:   windowEnd,
+		MinVotes:    minVotes,
+		Category:    categoryFilter,
+	})
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	protoCompanies := make([]*gen.Company, len(rows))
+	for i, row := range rows {
+		pc := companyToProto(row.Company, row.GlobalRank)
+		pc.Trending = &gen.TrendingStanding{
+			Rank:       offset + int32(i) + 1,
+			RatingGain: row.RatingChange,
+			Wins:       row.Wins,
+			Losses:     row.Losses,
+			Draws:      row.Draws,
+			Votes:      row.Votes,
+			WinRate:    row.WinRate,
+		}
+		protoCompanies[i] = pc
+	}
+
+	return connect.NewResponse(&gen.GetLeaderboardResponse{
+		Companies:   protoCompanies,
+		TotalCount:  int32(totalCount),
+		Page:        page,
+		PageSize:    pageSize,
+		WindowStart: timestamppb.New(start),
+		WindowEnd:   timestamppb.New(end),
+	}), nil
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	gen "github.com/cloutdotgg/backend/internal/gen/apiv1"
+	"github.com/cloutdotgg/backend/internal/trending"
+)
+
+func TestLeaderboardWindow(t *testing.T) {
+	now := time.Date(2024, 3, 10, 15, 42, 7, 0, time.UTC)
+	hour := time.Date(2024, 3, 10, 15, 0, 0, 0, time.UTC)
+	ts := timestamppb.New
+
+	tests := []struct {
+		name      string
+		req       *gen.GetLeaderboardRequest
+		wantStart time.Time
+		wantEnd   time.Time
+		wantErr   bool
+	}{
+		{
+			name:      "24 hours",
+			req:       &gen.GetLeaderboardRequest{Window: gen.LeaderboardWindow_LEADERBOARD_WINDOW_24H},
+			wantStart: hour.Add(-trending.Day),
+			wantEnd:   hour.Add(time.Hour),
+		},
+		{
+			name:      "7 days",
+			req:       &gen.GetLeaderboardRequest{Window: gen.LeaderboardWindow_LEADERBOARD_WINDOW_7D},
+			wantStart: hour.Add(-trending.Week),
+			wantEnd:   hour.Add(time.Hour),
+		},
+		{
+			name:      "30 days",
+			req:       &gen.GetLeaderboardRequest{Window: gen.LeaderboardWindow_LEADERBOARD_WINDOW_30D},
+			wantStart: hour.Add(-trending.Month),
+			wantEnd:   hour.Add(time.Hour),
+		},
+		{
+			name: "custom",
+			req: &gen.GetLeaderboardRequest{
+				Window:      gen.LeaderboardWindow_LEADERBOARD_WINDOW_CUSTOM,
+				WindowStart: ts(time.Date(2024, 1, 1, 8, 30, 0, 0, time.UTC)),
+				WindowEnd:   ts(time.Date(2024, 1, 2, 8, 0, 0, 0, time.UTC)),
+			},
+			wantStart: time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "custom until now",
+			req: &gen.GetLeaderboardRequest{
+				Window:      gen.LeaderboardWindow_LEADERBOARD_WINDOW_CUSTOM,
+				WindowStart: ts(now.Add(-90 * time.Minute)),
+			},
+			wantStart: hour.Add(-time.Hour),
+			wantEnd:   hour.Add(time.Hour),
+		},
+		{
+			name:    "custom without start",
+			req:     &gen.GetLeaderboardRequest{Window: gen.LeaderboardWindow_LEADERBOARD_WINDOW_CUSTOM},
+			wantErr: true,
+		},
+		{
+			name: "custom ending before it starts",
+			req: &gen.GetLeaderboardRequest{
+				Window:      gen.LeaderboardWindow_LEADERBOARD_WINDOW_CUSTOM,
+				WindowStart: ts(now),
+				WindowEnd:   ts(now.Add(-time.Hour)),
+			},
+			wantErr: true,
+		},
+		{
+			name: "custom too long",
+			req: &gen.GetLeaderboardRequest{
+				Window:      gen.LeaderboardWindow_LEADERBOARD_WINDOW_CUSTOM,
+				WindowStart: ts(now.Add(-trending.MaxWindow - time.Hour)),
+			},
+			wantErr: true,
+		},
+		{
+			name: "start with a preset",
+			req: &gen.GetLeaderboardRequest{
+				Window:      gen.LeaderboardWindow_LEADERBOARD_WINDOW_7D,
+				WindowStart: ts(now.Add(-time.Hour)),
+			},
+			wantErr: true,
+		},
+		{
+			name: "end with a preset",
+			req: &gen.GetLeaderboardRequest{
+				Window:    gen.LeaderboardWindow_LEADERBOARD_WINDOW_24H,
+				WindowEnd: ts(now),
+			},
+			wantErr: true,
+		},
+		{
+			name:    "unknown window",
+			req:     &gen.GetLeaderboardRequest{Window: gen.LeaderboardWindow(99)},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, err := leaderboardWindow(tt.req, now)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got [%v, %v), want an error", start, end)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !start.Equal(tt.wantStart) || !end.Equal(tt.wantEnd) {
+				t.Fatalf("got [%v, %v), want [%v, %v)", start, end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestTrendingSort(t *testing.T) {
+	tests := []struct {
+		sort         gen.TrendingSort
+		want         string
+		wantMinVotes int32
+		wantErr      bool
+	}{
+		{sort: gen.TrendingSort_TRENDING_SORT_UNSPECIFIED, want: trending.SortRatingGain, wantMinVotes: 1},
+		{sort: gen.TrendingSort_TRENDING_SORT_RATING_GAIN, want: trending.SortRatingGain, wantMinVotes: 1},
+		{sort: gen.TrendingSort_TRENDING_SORT_WIN_RATE, want: trending.SortWinRate, wantMinVotes: trending.MinWinRateVotes},
+		{sort: gen.TrendingSort_TRENDING_SORT_VOTES, want: trending.SortVotes, wantMinVotes: 1},
+		{sort: gen.TrendingSort(99), wantErr: true},
+	}
+	for _, tt := range tests {
+		got, minVotes, err := trendingSort(tt.sort)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%v: got %q, want an error", tt.sort, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want || minVotes != tt.wantMinVotes {
+			t.Errorf("%v: got %q, %d, %v, want %q, %d", tt.sort, got, minVotes, err, tt.want, tt.wantMinVotes)
+		}
+	}
+}
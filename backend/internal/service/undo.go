@@ -0,0 +1,246 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/jackc/pgx/v5"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/cloutdotgg/backend/internal/db/sqlc"
+	"github.com/cloutdotgg/backend/internal/events"
+	gen "github.com/cloutdotgg/backend/internal/gen/apiv1"
+	"github.com/cloutdotgg/backend/internal/rating"
+)
+
+// VoteUndoWindow is how long after casting a vote the voter can undo it
+const VoteUndoWindow = 2 * time.Minute
+
+// ratingDelta is how much a vote changed one company's rating, globally
+// when category is empty or within category otherwise
+type ratingDelta struct {
+	companyID int32
+	category  string
+	won       bool
+	delta     rating.Rating
+}
+
+func newRatingDelta(companyID int32, category string, won bool, before, after rating.Rating) ratingDelta {
+	return ratingDelta{
+		companyID: companyID,
+		category:  category,
+		won:       won,
+		delta: rating.Rating{
+			Value:      after.Value - before.Value,
+			Deviation:  after.Deviation - before.Deviation,
+			Volatility: after.Volatility - before.Volatility,
+		},
+	}
+}
+
+// UndoVote retracts a vote cast by the caller within VoteUndoWindow. The
+// exact rating changes the vote made are subtracted, so votes cast on the
+// same companies in the meantime keep their effect. The vote stays in the
+// log with the retracted status, and a fresh token for the same matchup is
+// returned so the voter can vote on it again.
+func (s *RankingsService) UndoVote(
+	ctx context.Context,
+	req *connect.Request[gen.UndoVoteRequest],
+) (*connect.Response[gen.UndoVoteResponse], error) {
+	sessionID, err := requireSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var vote sqlc.Vote
+	var resp *gen.UndoVoteResponse
+	err = s.inTx(ctx, func(q *sqlc.Queries) error {
+		vote, err = q.GetVoteForUpdate(ctx, req.Msg.VoteId)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return connect.NewError(connect.CodeNotFound, errors.New("vote not found"))
+		}
+		if err != nil {
+			return connect.NewError(connect.CodeInternal, err)
+		}
+
+		owned := vote.SessionID != nil && *vote.SessionID == sessionID
+		if userID, ok := currentUserID(ctx); ok && vote.UserID != nil && *vote.UserID == userID {
+			owned = true
+		}
+		if !owned {
+			return connect.NewError(connect.CodePermissionDenied, errors.New("vote was cast by someone else"))
+		}
+
+		if vote.RankingID != nil {
+			return connect.NewError(connect.CodeFailedPrecondition, errors.New("votes of a ranking cannot be undone one at a time"))
+		}
+
+		switch vote.Status {
+		case voteStatusCounted, voteStatusRepeat:
+		case voteStatusRetracted:
+			return connect.NewError(connect.CodeFailedPrecondition, errors.New("vote has already been undone"))
+		default:
+			return connect.NewError(connect.CodeFailedPrecondition, errors.New("vote can no longer be undone"))
+		}
+		if time.Since(vote.CreatedAt.Time) > VoteUndoWindow {
+			return connect.NewError(connect.CodeFailedPrecondition, errors.New("vote can no longer be undone"))
+		}
+
+		// Lock in the same order as SubmitVote
+		locked, err := q.LockCompaniesForUpdate(ctx, []int32{vote.WinnerID, vote.LoserID})
+		if err != nil {
+			return connect.NewError(connect.CodeInternal, err)
+		}
+		if len(locked) != 2 {
+			return connect.NewError(connect.CodeNotFound, errors.New("company not found"))
+		}
+
+		rated := vote.Status == voteStatusCounted && vote.Outcome != voteOutcomeSkip
+		switch {
+		case vote.Status != voteStatusCounted:
+		case vote.Outcome == voteOutcomeSkip:
+			if err := q.RevertCompanySkips(ctx, []int32{vote.WinnerID, vote.LoserID}); err != nil {
+				return connect.NewError(connect.CodeInternal, err)
+			}
+		default:
+			if err := s.revertVote(ctx, q, vote); err != nil {
+				return err
+			}
+
+			// Let the voter vote on this pair again
+			low, high := vote.WinnerID, vote.LoserID
+			if low > high {
+				low, high = high, low
+			}
+			if err := q.DeletePairVote(ctx, sqlc.DeletePairVoteParams{
+				Voter:       voterKey(ctx, sessionID),
+				CompanyLow:  low,
+				CompanyHigh: high,
+			}); err != nil {
+				return connect.NewError(connect.CodeInternal, err)
+			}
+		}
+
+		if err := q.SetVoteStatus(ctx, sqlc.SetVoteStatusParams{
+			ID:     vote.ID,
+			Status: voteStatusRetracted,
+		}); err != nil {
+			return connect.NewError(connect.CodeInternal, err)
+		}
+
+		// The history records the reverted state against the retracted vote
+		if rated {
+			if err := q.RecordRatingHistory(ctx, sqlc.RecordRatingHistoryParams{
+				VoteID:     &vote.ID,
+				CompanyIds: []int32{vote.WinnerID, vote.LoserID},
+			}); err != nil {
+				return connect.NewError(connect.CodeInternal, err)
+			}
+		}
+
+		winner, err := q.GetCompanyByID(ctx, vote.WinnerID)
+		if err != nil {
+			return connect.NewError(connect.CodeInternal, err)
+		}
+		loser, err := q.GetCompanyByID(ctx, vote.LoserID)
+		if err != nil {
+			return connect.NewError(connect.CodeInternal, err)
+		}
+
+		winnerProto, loserProto := companyToProto(winner, 0), companyToProto(loser, 0)
+		if vote.Category != nil {
+			if err := s.attachCategoryStanding(ctx, q, winnerProto, *vote.Category); err != nil {
+				return err
+			}
+			if err := s.attachCategoryStanding(ctx, q, loserProto, *vote.Category); err != nil {
+				return err
+			}
+		}
+
+		resp = &gen.UndoVoteResponse{
+			Winner: winnerProto,
+			Loser:  loserProto,
+		}
+		if vote.Status != voteStatusCounted {
+			return nil
+		}
+		e := events.Event{
+			Kind:       events.KindVoteUndone,
+			ID:         vote.ID,
+			CompanyIDs: []int32{vote.WinnerID, vote.LoserID},
+		}
+		if vote.Category != nil {
+			e.Category = *vote.Category
+		}
+		return recordEvent(ctx, q, e)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	category := ""
+	if vote.Category != nil {
+		category = *vote.Category
+	}
+	matchupToken, expiresAt, err := issueMatchupToken(s.matchupTokens, vote.WinnerID, vote.LoserID, category, sessionID)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	resp.MatchupToken = matchupToken
+	resp.MatchupTokenExpiresAt = timestamppb.New(expiresAt)
+
+	return connect.NewResponse(resp), nil
+}
+
+// revertVote subtracts the rating changes recorded for a counted win or
+// draw. Recomputes rewrite those changes to what they replayed, and decays
+// never touch a company voted on inside the undo window, so the result is
+// what a replay without the vote gives when no later vote involves the two
+// companies. The caller must already hold the companies' row locks.
+func (s *RankingsService) revertVote(ctx context.Context, q *sqlc.Queries, vote sqlc.Vote) error {
+	deltas, err := q.ListVoteRatingDeltas(ctx, vote.ID)
+	if err != nil {
+		return connect.NewError(connect.CodeInternal, err)
+	}
+	if len(deltas) == 0 {
+		return connect.NewError(connect.CodeFailedPrecondition, errors.New("vote can no longer be undone"))
+	}
+
+	if vote.Category != nil {
+		if _, err := q.LockCategoryRatingsForUpdate(ctx, sqlc.LockCategoryRatingsForUpdateParams{
+			Category:   *vote.Category,
+			CompanyIds: []int32{vote.WinnerID, vote.LoserID},
+		}); err != nil {
+			return connect.NewError(connect.CodeInternal, err)
+		}
+	}
+
+	for _, d := range deltas {
+		if d.Category == "" {
+			err = q.RevertCompanyRating(ctx, sqlc.RevertCompanyRatingParams{
+				RatingDelta:     d.RatingDelta,
+				DeviationDelta:  d.DeviationDelta,
+				VolatilityDelta: d.VolatilityDelta,
+				Won:             d.Won,
+				Drawn:           vote.Outcome == voteOutcomeDraw,
+				ID:              d.CompanyID,
+			})
+		} else {
+			err = q.RevertCategoryRating(ctx, sqlc.RevertCategoryRatingParams{
+				RatingDelta:     d.RatingDelta,
+				DeviationDelta:  d.DeviationDelta,
+				VolatilityDelta: d.VolatilityDelta,
+				Won:             d.Won,
+				Drawn:           vote.Outcome == voteOutcomeDraw,
+				CompanyID:       d.CompanyID,
+				Category:        d.Category,
+			})
+		}
+		if err != nil {
+			return connect.NewError(connect.CodeInternal, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"connectrpc.com/connect"
+
+	"github.com/cloutdotgg/backend/internal/dbtest"
+	"github.com/cloutdotgg/backend/internal/events"
+	gen "github.com/cloutdotgg/backend/internal/gen/apiv1"
+	"github.com/cloutdotgg/backend/internal/matchmaking"
+	"github.com/cloutdotgg/backend/internal/rating"
+	"github.com/cloutdotgg/backend/internal/recompute"
+)
+
+// TestUndoAfterRecompute undoes a vote after a recompute has replayed it to
+// a different effect than it had when it was cast, and checks that the
+// ratings are those of a replay without it.
+func TestUndoAfterRecompute(t *testing.T) {
+	pool := dbtest.New(t)
+	ctx := context.Background()
+
+	for _, engine := range []string{"elo", "glicko2"} {
+		t.Run(engine, func(t *testing.T) {
+			rater, err := rating.New(engine)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := recompute.Run(ctx, pool, rater, recompute.Options{}); err != nil {
+				t.Fatal(err)
+			}
+			s := NewRankingsService(pool, rater, matchmaking.Random{}, []byte("test-token-secret"),
+				RepeatVotePolicy{}, events.NewLocal(events.DefaultBuffer))
+
+			var a, b int32
+			if err := pool.QueryRow(ctx, "SELECT min(id), max(id) FROM (SELECT id FROM companies ORDER BY id LIMIT 2) c").Scan(&a, &b); err != nil {
+				t.Fatal(err)
+			}
+
+			// An earlier vote the stored ratings do not reflect yet, as
+			// after a fraud flag is dismissed or the engine is switched
+			if _, err := pool.Exec(ctx,
+				"INSERT INTO votes (winner_id, loser_id, created_at) VALUES ($1, $2, now() - interval '1 hour')", a, b,
+			); err != nil {
+				t.Fatal(err)
+			}
+
+			sessionID, _, _, err := s.sessions.start(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			voterCtx := context.WithValue(ctx, sessionIDKey{}, sessionID)
+			matchupToken, _, err := issueMatchupToken(s.matchupTokens, a, b, "", sessionID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			vote, err := s.SubmitVote(voterCtx, connect.NewRequest(&gen.SubmitVoteRequest{
+				WinnerId:     a,
+				LoserId:      b,
+				MatchupToken: matchupToken,
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The recompute applies the earlier vote first, so the new one
+			// moves the ratings by less than it did when cast
+			if _, err := recompute.Run(ctx, pool, rater, recompute.Options{}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.UndoVote(voterCtx, connect.NewRequest(&gen.UndoVoteRequest{VoteId: vote.Msg.VoteId})); err != nil {
+				t.Fatal(err)
+			}
+
+			report, err := recompute.Run(ctx, pool, rater, recompute.Options{DryRun: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, c := range report.Changes {
+				t.Errorf("company %d: stored %+v, replay %+v", c.CompanyID, c.Before.Rating, c.After.Rating)
+			}
+		})
+	}
+}
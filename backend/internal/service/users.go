@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"connectrpc.com/connect"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/cloutdotgg/backend/internal/auth"
+	"github.com/cloutdotgg/backend/internal/db/sqlc"
+	gen "github.com/cloutdotgg/backend/internal/gen/apiv1"
+)
+
+const (
+	maxDisplayNameLength = 50
+	maxAvatarURLLength   = 512
+)
+
+type userIDKey struct{}
+
+// currentUserID returns the id of the signed-in caller's user row
+func currentUserID(ctx context.Context) (int32, bool) {
+	id, ok := ctx.Value(userIDKey{}).(int32)
+	return id, ok
+}
+
+// UserInterceptor provisions a user row for every verified caller on their
+// first authenticated request and puts the user's id in the context. It must
+// run after the auth interceptor.
+type UserInterceptor struct {
+	queries *sqlc.Queries
+	// ids caches subject to user id, since users are never deleted
+	ids sync.Map
+}
+
+// NewUserInterceptor creates a user provisioning interceptor
+func NewUserInterceptor(db *pgxpool.Pool) *UserInterceptor {
+	return &UserInterceptor{queries: sqlc.New(db)}
+}
+
+// WrapUnary implements connect.Interceptor
+func (i *UserInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		ctx, err := i.provision(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return next(ctx, req)
+	}
+}
+
+// WrapStreamingClient implements connect.Interceptor
+func (i *UserInterceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+// WrapStreamingHandler implements connect.Interceptor
+func (i *UserInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		ctx, err := i.provision(ctx)
+		if err != nil {
+			return err
+		}
+		return next(ctx, conn)
+	}
+}
+
+func (i *UserInterceptor) provision(ctx context.Context) (context.Context, error) {
+	identity, ok := auth.FromContext(ctx)
+	if !ok {
+		return ctx, nil
+	}
+
+	if id, ok := i.ids.Load(identity.Subject); ok {
+		return context.WithValue(ctx, userIDKey{}, id.(int32)), nil
+	}
+
+	user, err := i.queries.GetUserBySubject(ctx, &identity.Subject)
+	if errors.Is(err, pgx.ErrNoRows) {
+		user, err = i.queries.CreateUserFromIdentity(ctx, sqlc.CreateUserFromIdentityParams{
+			Subject:   identity.Subject,
+			Name:      defaultDisplayName(identity),
+			Email:     optionalString(identity.Email),
+			AvatarUrl: optionalString(identity.Picture),
+		})
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	i.ids.Store(identity.Subject, user.ID)
+	return context.WithValue(ctx, userIDKey{}, user.ID), nil
+}
+
+// defaultDisplayName picks the initial display name from the token profile,
+// falling back to a stable pseudonym derived from the subject
+func defaultDisplayName(identity *auth.Identity) string {
+	for _, name := range []string{identity.Name, identity.Nickname} {
+		if name = strings.TrimSpace(name); name != "" && utf8.RuneCountInString(name) <= maxDisplayNameLength {
+			return name
+		}
+	}
+	sum := md5.Sum([]byte(identity.Subject))
+	return "user-" + hex.EncodeToString(sum[:])[:8]
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// Helper to convert sqlc User to proto User
+func userToProto(u sqlc.User, totalVotes int64) *gen.User {
+	pu := &gen.User{
+		Id:          u.ID,
+		DisplayName: u.Name,
+		AvatarUrl:   u.AvatarUrl,
+		Email:       u.Email,
+		TotalVotes:  int32(totalVotes),
+	}
+	if u.CreatedAt.Valid {
+		pu.CreatedAt = timestamppb.New(u.CreatedAt.Time)
+	}
+	return pu
+}
+
+// requireUser returns the signed-in caller's user id or an unauthenticated error
+func requireUser(ctx context.Context) (int32, error) {
+	id, ok := currentUserID(ctx)
+	if !ok {
+		return 0, connect.NewError(connect.CodeUnauthenticated, errors.New("sign in required"))
+	}
+	return id, nil
+}
+
+// GetMe returns the signed-in user's profile
+func (s *RankingsService) GetMe(
+	ctx context.Context,
+	req *connect.Request[gen.GetMeRequest],
+) (*connect.Response[gen.GetMeResponse], error) {
+	userID, err := requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.queries.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	totalVotes, err := s.queries.CountUserVotes(ctx, &userID)
+	if err != nil {
+		totalVotes = 0
+	}
+
+	return connect.NewResponse(&gen.GetMeResponse{
+		User: userToProto(user, totalVotes),
+	}), nil
+}
+
+// UpdateMe changes the signed-in user's display name and avatar
+func (s *RankingsService) UpdateMe(
+	ctx context.Context,
+	req *connect.Request[gen.UpdateMeRequest],
+) (*connect.Response[gen.UpdateMeResponse], error) {
+	userID, err := requireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.queries.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	name := user.Name
+	if req.Msg.DisplayName != nil {
+		name = strings.TrimSpace(*req.Msg.DisplayName)
+		if name == "" || utf8.RuneCountInString(name) > maxDisplayNameLength {
+			return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("display name must be 1 to 50 characters"))
+		}
+	}
+
+	avatarURL := user.AvatarUrl
+	if req.Msg.AvatarUrl != nil {
+		avatarURL = nil
+		if raw := strings.TrimSpace(*req.Msg.AvatarUrl); raw != "" {
+			u, err := url.Parse(raw)
+			if err != nil || u.Scheme != "https" || u.Host == "" || len(raw) > maxAvatarURLLength {
+				return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("avatar url must be an https url"))
+			}
+			avatarURL = &raw
+		}
+	}
+
+	user, err = s.queries.UpdateUserProfile(ctx, sqlc.UpdateUserProfileParams{
+		Name:      name,
+		AvatarUrl: avatarURL,
+		ID:        userID,
+	})
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	totalVotes, err := s.queries.CountUserVotes(ctx, &userID)
+	if err != nil {
+		totalVotes = 0
+	}
+
+	return connect.NewResponse(&gen.UpdateMeResponse{
+		User: userToProto(user, totalVotes),
+	}), nil
+}
+
+// publicUserID is the user id exposed in public listings
+func publicUserID(id int32) string {
+	return strconv.Itoa(int(id))
+}
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/cloutdotgg/backend/internal/db/sqlc"
+	gen "github.com/cloutdotgg/backend/internal/gen/apiv1"
+	"github.com/cloutdotgg/backend/internal/webhook"
+)
+
+// Helper to convert sqlc WebhookSubscription to proto Webhook. The secrets
+// are never included.
+func webhookToProto(w sqlc.WebhookSubscription) *gen.Webhook {
+	pw := &gen.Webhook{
+		Id:          w.ID,
+		Url:         w.Url,
+		EventTypes:  w.EventTypes,
+		Description: w.Description,
+	}
+	if w.CreatedAt.Valid {
+		pw.CreatedAt = timestamppb.New(w.CreatedAt.Time)
+	}
+	if w.UpdatedAt.Valid {
+		pw.UpdatedAt = timestamppb.New(w.UpdatedAt.Time)
+	}
+	if w.PreviousSecretExpiresAt.Valid && time.Now().Before(w.PreviousSecretExpiresAt.Time) {
+		pw.PreviousSecretExpiresAt = timestamppb.New(w.PreviousSecretExpiresAt.Time)
+	}
+	return pw
+}
+
+// Helper to convert sqlc WebhookDelivery to proto WebhookDelivery
+func webhookDeliveryToProto(d sqlc.WebhookDelivery) *gen.WebhookDelivery {
+	pd := &gen.WebhookDelivery{
+		Id:             d.ID,
+		WebhookId:      d.SubscriptionID,
+		EventId:        d.EventID,
+		EventType:      d.EventType,
+		Payload:        string(d.Payload),
+		Status:         d.Status,
+		Attempts:       d.Attempts,
+		ResponseStatus: d.ResponseStatus,
+		LastError:      d.LastError,
+	}
+	if d.Status == webhook.StatusPending && d.NextAttemptAt.Valid {
+		pd.NextAttemptAt = timestamppb.New(d.NextAttemptAt.Time)
+	}
+	if d.LastAttemptAt.Valid {
+		pd.LastAttemptAt = timestamppb.New(d.LastAttemptAt.Time)
+	}
+	if d.CreatedAt.Valid {
+		pd.CreatedAt = timestamppb.New(d.CreatedAt.Time)
+	}
+	if d.DeliveredAt.Valid {
+		pd.DeliveredAt = timestamppb.New(d.DeliveredAt.Time)
+	}
+	return pd
+}
+
+// CreateWebhook subscribes an endpoint to events and returns its signing
+// secret
+func (s *AdminService) CreateWebhook(
+	ctx context.Context,
+	req *connect.Request[gen.CreateWebhookRequest],
+) (*connect.Response[gen.CreateWebhookResponse], error) {
+	url := strings.TrimSpace(req.Msg.Url)
+	if err := webhook.ValidateURL(url); err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+	if err := webhook.ValidateEventTypes(req.Msg.EventTypes); err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	eventTypes := req.Msg.EventTypes
+	if eventTypes == nil {
+		eventTypes = []string{}
+	}
+	subscription, err := s.queries.CreateWebhookSubscription(ctx, sqlc.CreateWebhookSubscriptionParams{
+		Url:         url,
+		Secret:      secret,
+		EventTypes:  eventTypes,
+		Description: req.Msg.Description,
+	})
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	return connect.NewResponse(&gen.CreateWebhookResponse{
+		Webhook: webhookToProto(subscription),
+		Secret:  secret,
+	}), nil
+}
+
+// ListWebhooks returns every webhook, without secrets
+func (s *AdminService) ListWebhooks(
+	ctx context.Context,
+	req *connect.Request[gen.ListWebhooksRequest],
+) (*connect.Response[gen.ListWebhooksResponse], error) {
+	subscriptions, err := s.queries.ListWebhookSubscriptions(ctx)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	webhooks := make([]*gen.Webhook, len(subscriptions))
+	for i, w := range subscriptions {
+		webhooks[i] = webhookToProto(w)
+	}
+
+	return connect.NewResponse(&gen.ListWebhooksResponse{
+		Webhooks: webhooks,
+	}), nil
+}
+
+// DeleteWebhook removes a webhook along with its pending deliveries and
+// delivery log
+func (s *AdminService) DeleteWebhook(
+	ctx context.Context,
+	req *connect.Request[gen.DeleteWebhookRequest],
+) (*connect.Response[gen.DeleteWebhookResponse], error) {
+	deleted, err := s.queries.DeleteWebhookSubscription(ctx, req.Msg.WebhookId)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	if deleted == 0 {
+		return nil, connect.NewError(connect.CodeNotFound, errors.New("webhook not found"))
+	}
+
+	return connect.NewResponse(&gen.DeleteWebhookResponse{}), nil
+}
+
+// RotateWebhookSecret replaces a webhook's signing secret. The old secret
+// keeps signing deliveries alongside the new one for webhook.SecretOverlap.
+func (s *AdminService) RotateWebhookSecret(
+	ctx context.Context,
+	req *connect.Request[gen.RotateWebhookSecretRequest],
+) (*connect.Response[gen.RotateWebhookSecretResponse], error) {
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	subscription, err := s.queries.RotateWebhookSecret(ctx, sqlc.RotateWebhookSecretParams{
+		PreviousSecretExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(webhook.SecretOverlap), Valid: true},
+		Secret:                  secret,
+		ID:                      req.Msg.WebhookId,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, connect.NewError(connect.CodeNotFound, errors.New("webhook not found"))
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	return connect.NewResponse(&gen.RotateWebhookSecretResponse{
+		Webhook: webhookToProto(subscription),
+		Secret:  secret,
+	}), nil
+}
+
+// ListWebhookDeliveries returns a webhook's delivery log, newest first
+func (s *AdminService) ListWebhookDeliveries(
+	ctx context.Context,
+	req *connect.Request[gen.ListWebhookDeliveriesRequest],
+) (*connect.Response[gen.ListWebhookDeliveriesResponse], error) {
+	if status := req.Msg.Status; status != nil {
+		switch *status {
+		case webhook.StatusPending, webhook.StatusDelivered, webhook.StatusDead:
+		default:
+			return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("unknown delivery status %q", *status))
+		}
+	}
+
+	exists, err := s.queries.WebhookSubscriptionExists(ctx, req.Msg.WebhookId)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	if !exists {
+		return nil, connect.NewError(connect.CodeNotFound, errors.New("webhook not found"))
+	}
+
+	page := req.Msg.Page
+	if page < 1 {
+		page = 1
+	}
+	pageSize := req.Msg.PageSize
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 25
+	}
+	offset := (page - 1) * pageSize
+
+	deliveries, err := s.queries.ListWebhookDeliveries(ctx, sqlc.ListWebhookDeliveriesParams{
+		SubscriptionID: req.Msg.WebhookId,
+		Status:         req.Msg.Status,
+		PageLimit:      pageSize,
+		PageOffset:     offset,
+	})
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	totalCount, err := s.queries.CountWebhookDeliveries(ctx, sqlc.CountWebhookDeliveriesParams{
+		SubscriptionID: req.Msg.WebhookId,
+		Status:         req.Msg.Status,
+	})
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	protoDeliveries := make([]*gen.WebhookDelivery, len(deliveries))
+	for i, d := range deliveries {
+		protoDeliveries[i] = webhookDeliveryToProto(d)
+	}
+
+	return connect.NewResponse(&gen.ListWebhookDeliveriesResponse{
+		Deliveries: protoDeliveries,
+		TotalCount: int32(totalCount),
+		Page:       page,
+		PageSize:   pageSize,
+	}), nil
+}
@@ -0,0 +1,104 @@
+// Package trending ranks companies by their activity inside a time window
+// rather than by lifetime rating. Counted votes and rating history are
+// rolled up into hourly buckets per company, so a window of any length sums
+// at most a few hundred rows per company.
+package trending
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/cloutdotgg/backend/internal/db/sqlc"
+)
+
+// Orders of a trending leaderboard, as understood by GetTrendingLeaderboard.
+const (
+	SortRatingGain = "rating_gain"
+	SortWinRate    = "win_rate"
+	SortVotes      = "votes"
+)
+
+// Preset window lengths.
+const (
+	Day   = 24 * time.Hour
+	Week  = 7 * Day
+	Month = 30 * Day
+)
+
+// MaxWindow bounds custom windows.
+const MaxWindow = 366 * Day
+
+// MinWinRateVotes is how many votes inside the window a company needs to be
+// ranked by win rate, so a single lucky vote does not top the board.
+const MinWinRateVotes = 5
+
+// Bounds returns the hourly buckets covering start to end: start rounded
+// down to the hour, and the end of the hour containing end.
+func Bounds(start, end time.Time) (time.Time, time.Time, error) {
+	if !start.Before(end) {
+		return time.Time{}, time.Time{}, errors.New("window start must be before its end")
+	}
+	if end.Sub(start) > MaxWindow {
+		return time.Time{}, time.Time{}, fmt.Errorf("window must not be longer than %d days", MaxWindow/Day)
+	}
+	return start.Truncate(time.Hour), end.Truncate(time.Hour).Add(time.Hour), nil
+}
+
+// Roll rebuilds the hourly buckets from since, rounded down to the hour, to
+// now. Rebuilding from the zero time rebuilds every bucket.
+func Roll(ctx context.Context, q *sqlc.Queries, since time.Time) error {
+	if err := q.LockCompanyActivity(ctx); err != nil {
+		return fmt.Errorf("failed to lock company activity: %w", err)
+	}
+	hour := pgtype.Timestamptz{Time: since.Truncate(time.Hour), Valid: true}
+	if err := q.DeleteCompanyActivitySince(ctx, hour); err != nil {
+		return fmt.Errorf("failed to clear company activity: %w", err)
+	}
+	if err := q.RollUpCompanyActivity(ctx, hour); err != nil {
+		return fmt.Errorf("failed to roll up company activity: %w", err)
+	}
+	return nil
+}
+
+// Run rolls up the activity since the last rolled hour. The previous hour is
+// always rebuilt as well, so votes undone shortly after an hour ends leave
+// its bucket.
+func Run(ctx context.Context, pool *pgxpool.Pool, now time.Time) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	q := sqlc.New(tx)
+
+	// Lock before reading the latest hour, so two runs do not both skip the
+	// hours the other is rebuilding
+	if err := q.LockCompanyActivity(ctx); err != nil {
+		return fmt.Errorf("failed to lock company activity: %w", err)
+	}
+	latest, err := q.GetLatestCompanyActivityHour(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get latest rolled hour: %w", err)
+	}
+
+	var since time.Time
+	if latest.Valid {
+		since = now.Truncate(time.Hour).Add(-time.Hour)
+		if latest.Time.Before(since) {
+			since = latest.Time
+		}
+	}
+	if err := Roll(ctx, q, since); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit company activity: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+package trending
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/cloutdotgg/backend/internal/db/sqlc"
+	"github.com/cloutdotgg/backend/internal/dbtest"
+)
+
+func TestBounds(t *testing.T) {
+	at := func(s string) time.Time {
+		tm, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+	tests := []struct {
+		name               string
+		start, end         time.Time
+		wantStart, wantEnd time.Time
+		wantErr            bool
+	}{
+		{
+			name:      "whole hours",
+			start:     at("2024-03-01T10:00:00Z"),
+			end:       at("2024-03-01T12:00:00Z"),
+			wantStart: at("2024-03-01T10:00:00Z"),
+			wantEnd:   at("2024-03-01T13:00:00Z"),
+		},
+		{
+			name:      "inside hours",
+			start:     at("2024-03-01T10:59:59Z"),
+			end:       at("2024-03-01T12:00:01Z"),
+			wantStart: at("2024-03-01T10:00:00Z"),
+			wantEnd:   at("2024-03-01T13:00:00Z"),
+		},
+		{
+			name:      "inside one hour",
+			start:     at("2024-03-01T10:15:00Z"),
+			end:       at("2024-03-01T10:45:00Z"),
+			wantStart: at("2024-03-01T10:00:00Z"),
+			wantEnd:   at("2024-03-01T11:00:00Z"),
+		},
+		{
+			name:      "day",
+			start:     at("2024-02-29T10:30:00Z"),
+			end:       at("2024-03-01T10:30:00Z"),
+			wantStart: at("2024-02-29T10:00:00Z"),
+			wantEnd:   at("2024-03-01T11:00:00Z"),
+		},
+		{
+			name:      "offset time zone",
+			start:     at("2024-03-01T10:30:00+05:30"),
+			end:       at("2024-03-01T11:30:00+05:30"),
+			wantStart: at("2024-03-01T05:00:00Z"),
+			wantEnd:   at("2024-03-01T07:00:00Z"),
+		},
+		{
+			name:      "longest window",
+			start:     at("2024-01-01T00:00:00Z"),
+			end:       at("2024-01-01T00:00:00Z").Add(MaxWindow),
+			wantStart: at("2024-01-01T00:00:00Z"),
+			wantEnd:   at("2024-01-01T01:00:00Z").Add(MaxWindow),
+		},
+		{name: "too long", start: at("2024-01-01T00:00:00Z"), end: at("2024-01-01T00:00:01Z").Add(MaxWindow), wantErr: true},
+		{name: "empty", start: at("2024-03-01T10:00:00Z"), end: at("2024-03-01T10:00:00Z"), wantErr: true},
+		{name: "reversed", start: at("2024-03-01T12:00:00Z"), end: at("2024-03-01T10:00:00Z"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, err := Bounds(tt.start, tt.end)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got [%v, %v), want an error", start, end)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !start.Equal(tt.wantStart) || !end.Equal(tt.wantEnd) {
+				t.Fatalf("got [%v, %v), want [%v, %v)", start, end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
+
+// activity seeds votes and rating history around an hour in the past, away
+// from anything else in the database.
+type activity struct {
+	pool *pgxpool.Pool
+	hour time.Time
+}
+
+func (a activity) vote(t *testing.T, winner, loser int32, outcome, status string, at time.Duration, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if _, err := a.pool.Exec(context.Background(),
+			"INSERT INTO votes (winner_id, loser_id, outcome, status, created_at) VALUES ($1, $2, $3, $4, $5)",
+			winner, loser, outcome, status, a.hour.Add(at),
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func (a activity) rating(t *testing.T, company int32, rating float64, at time.Duration) {
+	t.Helper()
+	if _, err := a.pool.Exec(context.Background(),
+		`INSERT INTO rating_history (company_id, rating, elo_rating, rating_deviation, rank, wins, losses, total_votes, created_at)
+		 VALUES ($1, $2, $2::int, 100, 1, 0, 0, 0, $3)`,
+		company, rating, a.hour.Add(at),
+	); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func companies(t *testing.T, pool *pgxpool.Pool, n int) []int32 {
+	t.Helper()
+	rows, err := pool.Query(context.Background(), "SELECT id FROM companies ORDER BY id LIMIT $1", n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int32
+	for rows.Next() {
+		var id int32
+		if err := rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) < n {
+		t.Fatalf("need %d companies, have %d", n, len(ids))
+	}
+	return ids
+}
+
+// TestRollupOrdering rolls up a window in which every sort ranks the same
+// four companies differently.
+func TestRollupOrdering(t *testing.T) {
+	pool := dbtest.New(t)
+	ctx := context.Background()
+	ids := companies(t, pool, 4)
+	a, b, c, d := ids[0], ids[1], ids[2], ids[3]
+	act := activity{pool: pool, hour: time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)}
+
+	// Inside the window, which covers two hours, every company plays d:
+	//   a: 5 wins, 1 loss                 6 votes, win rate 0.83,  +10
+	//   b: 5 wins, 5 losses              10 votes, win rate 0.50,  +30
+	//   c: 7 wins, 1 draw                 8 votes, win rate 0.94,   -5
+	//   d: 6 wins, 17 losses, 1 draw     24 votes, win rate 0.27, -100
+	act.vote(t, a, d, "win", "counted", 10*time.Minute, 5)
+	act.vote(t, d, a, "win", "counted", 70*time.Minute, 1)
+	act.vote(t, b, d, "win", "counted", 20*time.Minute, 5)
+	act.vote(t, d, b, "win", "counted", 80*time.Minute, 5)
+	act.vote(t, c, d, "win", "counted", 30*time.Minute, 7)
+	act.vote(t, c, d, "draw", "counted", 90*time.Minute, 1)
+	// None of these count
+	act.vote(t, a, d, "win", "counted", -time.Minute, 20)
+	act.vote(t, a, d, "win", "counted", 2*time.Hour, 20)
+	act.vote(t, a, d, "skip", "counted", 40*time.Minute, 20)
+	act.vote(t, a, d, "win", "fraud", 40*time.Minute, 20)
+
+	// Rating changes are measured from the history row before, even one
+	// before the window or the rolled hours
+	for _, id := range ids {
+		act.rating(t, id, 1500, -3*time.Hour)
+	}
+	act.rating(t, a, 1510, 50*time.Minute)
+	act.rating(t, b, 1520, 15*time.Minute)
+	act.rating(t, b, 1530, 75*time.Minute)
+	act.rating(t, c, 1495, 45*time.Minute)
+	act.rating(t, d, 1400, 55*time.Minute)
+	act.rating(t, a, 1600, 3*time.Hour)
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback(ctx)
+	if err := Roll(ctx, sqlc.New(tx), act.hour.Add(-2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		sort     string
+		minVotes int32
+		want     []int32
+	}{
+		{sort: SortRatingGain, minVotes: 1, want: []int32{b, a, c, d}},
+		{sort: SortWinRate, minVotes: MinWinRateVotes, want: []int32{c, a, b, d}},
+		{sort: SortWinRate, minVotes: 7, want: []int32{c, b, d}},
+		{sort: SortVotes, minVotes: 1, want: []int32{d, b, c, a}},
+	}
+	q := sqlc.New(pool)
+	for _, tt := range tests {
+		rows, err := q.GetTrendingLeaderboard(ctx, sqlc.GetTrendingLeaderboardParams{
+			WindowStart: pgtype.Timestamptz{Time: act.hour, Valid: true},
+			WindowEnd:   pgtype.Timestamptz{Time: act.hour.Add(2 * time.Hour), Valid: true},
+			MinVotes:    tt.minVotes,
+			Sort:        tt.sort,
+			PageLimit:   10,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []int32
+		for _, row := range rows {
+			got = append(got, row.Company.ID)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s with at least %d votes: got %v, want %v", tt.sort, tt.minVotes, got, tt.want)
+		}
+	}
+
+	// The totals behind the orders
+	rows, err := q.GetTrendingLeaderboard(ctx, sqlc.GetTrendingLeaderboardParams{
+		WindowStart: pgtype.Timestamptz{Time: act.hour, Valid: true},
+		WindowEnd:   pgtype.Timestamptz{Time: act.hour.Add(2 * time.Hour), Valid: true},
+		MinVotes:    1,
+		Sort:        SortRatingGain,
+		PageLimit:   10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	type totals struct {
+		wins, losses, draws int32
+		ratingChange        float64
+	}
+	want := map[int32]totals{
+		a: {5, 1, 0, 10},
+		b: {5, 5, 0, 30},
+		c: {7, 0, 1, -5},
+		d: {6, 17, 1, -100},
+	}
+	for _, row := range rows {
+		got := totals{row.Wins, row.Losses, row.Draws, row.RatingChange}
+		if got != want[row.Company.ID] {
+			t.Errorf("company %d: got %+v, want %+v", row.Company.ID, got, want[row.Company.ID])
+		}
+	}
+}
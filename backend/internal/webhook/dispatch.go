@@ -0,0 +1,127 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/cloutdotgg/backend/internal/db/sqlc"
+)
+
+// maxErrorBody bounds how much of a failed response is kept in the
+// delivery log.
+const maxErrorBody = 1 << 10
+
+// Result summarizes a dispatch run.
+type Result struct {
+	Delivered int
+	// Failed is the number of deliveries scheduled for another attempt,
+	// and Dead the number that ran out of attempts.
+	Failed int
+	Dead   int
+}
+
+// NewClient returns an HTTP client for deliveries. Redirects are not
+// followed, since they would turn a POST into a GET; they count as failures.
+func NewClient(cfg Config) *http.Client {
+	return &http.Client{
+		Timeout: cfg.Timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Dispatch attempts up to cfg.BatchSize due deliveries through client and
+// records the outcome of each. A delivery succeeds on a 2xx response; on
+// failure it is retried after cfg.Backoff, or marked dead after
+// cfg.MaxAttempts attempts.
+func Dispatch(ctx context.Context, pool *pgxpool.Pool, client *http.Client, cfg Config, now time.Time) (Result, error) {
+	q := sqlc.New(pool)
+	var result Result
+
+	// Attempts run one after another, so the lease covers a whole batch
+	lease := time.Duration(cfg.BatchSize)*cfg.Timeout + time.Minute
+	deliveries, err := q.ClaimWebhookDeliveries(ctx, sqlc.ClaimWebhookDeliveriesParams{
+		LeaseUntil: pgtype.Timestamptz{Time: now.Add(lease), Valid: true},
+		BatchSize:  cfg.BatchSize,
+	})
+	if err != nil {
+		return result, fmt.Errorf("failed to claim deliveries: %w", err)
+	}
+
+	for _, d := range deliveries {
+		secrets := []string{d.Secret}
+		if d.PreviousSecret != nil && d.PreviousSecretExpiresAt.Valid && now.Before(d.PreviousSecretExpiresAt.Time) {
+			secrets = append(secrets, *d.PreviousSecret)
+		}
+
+		status, err := attempt(ctx, client, d, secrets, time.Now())
+		params := sqlc.RecordWebhookAttemptParams{
+			Status:        StatusDelivered,
+			NextAttemptAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+			ID:            d.ID,
+		}
+		if status != 0 {
+			params.ResponseStatus = &status
+		}
+		switch {
+		case err == nil:
+			result.Delivered++
+		case d.Attempts+1 >= cfg.MaxAttempts:
+			params.Status = StatusDead
+			result.Dead++
+		default:
+			params.Status = StatusPending
+			params.NextAttemptAt.Time = time.Now().Add(cfg.Backoff(d.Attempts + 1))
+			result.Failed++
+		}
+		if err != nil {
+			msg := err.Error()
+			params.LastError = &msg
+		}
+
+		if err := q.RecordWebhookAttempt(ctx, params); err != nil {
+			return result, fmt.Errorf("failed to record delivery %d: %w", d.ID, err)
+		}
+	}
+
+	return result, nil
+}
+
+// attempt posts a delivery signed with secrets at timestamp and returns the
+// response status, or 0 if there was no response.
+func attempt(ctx context.Context, client *http.Client, d sqlc.ClaimWebhookDeliveriesRow, secrets []string, timestamp time.Time) (int32, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.Url, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "cloutgg-webhooks/1")
+	req.Header.Set(HeaderEvent, d.EventType)
+	req.Header.Set(HeaderEventID, d.EventID)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(d.ID, 10))
+	req.Header.Set(HeaderSignature, SignatureHeader(secrets, timestamp, d.Payload))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		if len(body) > 0 {
+			return int32(resp.StatusCode), fmt.Errorf("endpoint returned %s: %s", resp.Status, body)
+		}
+		return int32(resp.StatusCode), fmt.Errorf("endpoint returned %s", resp.Status)
+	}
+	return int32(resp.StatusCode), nil
+}
@@ -0,0 +1,406 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/cloutdotgg/backend/internal/db/sqlc"
+	"github.com/cloutdotgg/backend/internal/dbtest"
+)
+
+// receiver is an httptest endpoint that records the requests it gets and
+// answers them with handler.
+type receiver struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newReceiver(t *testing.T, handler http.HandlerFunc) *receiver {
+	t.Helper()
+	r := &receiver{}
+	r.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		r.requests = append(r.requests, req)
+		r.bodies = append(r.bodies, body)
+		r.mu.Unlock()
+		handler(w, req)
+	}))
+	t.Cleanup(r.server.Close)
+	return r
+}
+
+func (r *receiver) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.requests)
+}
+
+func (r *receiver) last() (*http.Request, []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.requests[len(r.requests)-1], r.bodies[len(r.bodies)-1]
+}
+
+func testDelivery(url string) sqlc.ClaimWebhookDeliveriesRow {
+	return sqlc.ClaimWebhookDeliveriesRow{
+		ID:        42,
+		EventID:   "evt_1",
+		EventType: EventCommentCreated,
+		Payload:   []byte(`{"id":"evt_1","type":"comment.created"}`),
+		Url:       url,
+		Secret:    "new",
+	}
+}
+
+func TestAttempt(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		status  int32
+		// wantErr is a substring of the error, or empty if the attempt
+		// succeeds
+		wantErr string
+	}{
+		{
+			name:    "ok",
+			handler: func(w http.ResponseWriter, r *http.Request) {},
+			status:  http.StatusOK,
+		},
+		{
+			name:    "accepted",
+			handler: func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusAccepted) },
+			status:  http.StatusAccepted,
+		},
+		{
+			name: "server error",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "database down", http.StatusInternalServerError)
+			},
+			status:  http.StatusInternalServerError,
+			wantErr: "500 Internal Server Error: database down",
+		},
+		{
+			name:    "client error without body",
+			handler: func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusGone) },
+			status:  http.StatusGone,
+			wantErr: "410 Gone",
+		},
+		{
+			name: "redirect",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, "/elsewhere", http.StatusFound)
+			},
+			status:  http.StatusFound,
+			wantErr: "302 Found",
+		},
+		{
+			name: "permanent redirect",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, "/elsewhere", http.StatusPermanentRedirect)
+			},
+			status:  http.StatusPermanentRedirect,
+			wantErr: "308 Permanent Redirect",
+		},
+		{
+			name: "large error body",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, strings.Repeat("x", 10*maxErrorBody), http.StatusBadGateway)
+			},
+			status:  http.StatusBadGateway,
+			wantErr: "502 Bad Gateway",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newReceiver(t, tt.handler)
+			d := testDelivery(r.server.URL)
+
+			status, err := attempt(context.Background(), NewClient(DefaultConfig()), d, []string{d.Secret}, time.Now())
+			if status != tt.status {
+				t.Errorf("status %d, want %d", status, tt.status)
+			}
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("got error %v, want %q", err, tt.wantErr)
+			case err != nil && len(err.Error()) > 2*maxErrorBody:
+				t.Errorf("error keeps %d bytes of the response", len(err.Error()))
+			}
+			// Redirects are not followed
+			if n := r.count(); n != 1 {
+				t.Errorf("endpoint got %d requests, want 1", n)
+			}
+		})
+	}
+}
+
+func TestAttemptRequest(t *testing.T) {
+	r := newReceiver(t, func(w http.ResponseWriter, r *http.Request) {})
+	d := testDelivery(r.server.URL)
+	now := time.Now()
+
+	if _, err := attempt(context.Background(), NewClient(DefaultConfig()), d, []string{"new", "old"}, now); err != nil {
+		t.Fatal(err)
+	}
+	req, body := r.last()
+	if req.Method != http.MethodPost {
+		t.Errorf("method %s, want POST", req.Method)
+	}
+	if string(body) != string(d.Payload) {
+		t.Errorf("body %s, want %s", body, d.Payload)
+	}
+	for header, want := range map[string]string{
+		"Content-Type": "application/json",
+		HeaderEvent:    EventCommentCreated,
+		HeaderEventID:  "evt_1",
+		HeaderDelivery: "42",
+	} {
+		if got := req.Header.Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+	// Receivers on either side of a rotation accept the delivery
+	for _, secret := range []string{"new", "old"} {
+		if !verify(req.Header.Get(HeaderSignature), secret, body, now, time.Minute) {
+			t.Errorf("signature does not verify with %q", secret)
+		}
+	}
+}
+
+func TestAttemptUnreachable(t *testing.T) {
+	r := newReceiver(t, func(w http.ResponseWriter, r *http.Request) {})
+	d := testDelivery(r.server.URL)
+	r.server.Close()
+
+	status, err := attempt(context.Background(), NewClient(DefaultConfig()), d, []string{d.Secret}, time.Now())
+	if err == nil {
+		t.Fatal("attempt against a closed endpoint succeeded")
+	}
+	if status != 0 {
+		t.Fatalf("status %d without a response", status)
+	}
+}
+
+// subscribe creates a subscription to every event at url and queues one
+// event for it.
+func subscribe(t *testing.T, pool *pgxpool.Pool, url, secret string) sqlc.WebhookSubscription {
+	t.Helper()
+	ctx := context.Background()
+	q := sqlc.New(pool)
+	sub, err := q.CreateWebhookSubscription(ctx, sqlc.CreateWebhookSubscriptionParams{
+		Url:        url,
+		Secret:     secret,
+		EventTypes: []string{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.CreateWebhookDeliveries(ctx, sqlc.CreateWebhookDeliveriesParams{
+		EventID:   "evt_1",
+		EventType: EventCommentCreated,
+		Payload:   []byte(`{"id":"evt_1"}`),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return sub
+}
+
+func delivery(t *testing.T, pool *pgxpool.Pool, subscriptionID int32) sqlc.WebhookDelivery {
+	t.Helper()
+	deliveries, err := sqlc.New(pool).ListWebhookDeliveries(context.Background(), sqlc.ListWebhookDeliveriesParams{
+		SubscriptionID: subscriptionID,
+		PageLimit:      10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 {
+		t.Fatalf("got %d deliveries, want 1", len(deliveries))
+	}
+	return deliveries[0]
+}
+
+// makeDue moves the retry of every pending delivery to now.
+func makeDue(t *testing.T, pool *pgxpool.Pool) {
+	t.Helper()
+	if _, err := pool.Exec(context.Background(), "UPDATE webhook_deliveries SET next_attempt_at = NOW() WHERE status = 'pending'"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDispatchBacksOffUntilDead(t *testing.T) {
+	pool := dbtest.New(t)
+	ctx := context.Background()
+	r := newReceiver(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	sub := subscribe(t, pool, r.server.URL, "new")
+
+	cfg := DefaultConfig()
+	cfg.MaxAttempts = 4
+	for attempts := int32(1); attempts <= cfg.MaxAttempts; attempts++ {
+		before := time.Now()
+		result, err := Dispatch(ctx, pool, NewClient(cfg), cfg, before)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := delivery(t, pool, sub.ID)
+		if d.Attempts != attempts {
+			t.Fatalf("attempts %d, want %d", d.Attempts, attempts)
+		}
+		if d.ResponseStatus == nil || *d.ResponseStatus != http.StatusServiceUnavailable {
+			t.Fatalf("response status %v, want 503", d.ResponseStatus)
+		}
+		if d.LastError == nil || !strings.Contains(*d.LastError, "503") {
+			t.Fatalf("last error %v", d.LastError)
+		}
+
+		if attempts == cfg.MaxAttempts {
+			if d.Status != StatusDead || result.Dead != 1 || result.Failed != 0 {
+				t.Fatalf("after %d attempts: status %s, result %+v, want dead", attempts, d.Status, result)
+			}
+			break
+		}
+		if d.Status != StatusPending || result.Failed != 1 {
+			t.Fatalf("after %d attempts: status %s, result %+v, want pending", attempts, d.Status, result)
+		}
+		// The retry is scheduled Backoff(attempts) after the attempt, give or
+		// take the database's timestamp precision
+		backoff := cfg.Backoff(attempts)
+		next := d.NextAttemptAt.Time
+		if next.Before(before.Add(backoff-time.Millisecond)) || next.After(time.Now().Add(backoff+time.Millisecond)) {
+			t.Fatalf("after %d attempts the retry is at %v, want %v after %v", attempts, next, backoff, before)
+		}
+
+		// Not due yet
+		if result, err := Dispatch(ctx, pool, NewClient(cfg), cfg, time.Now()); err != nil || result != (Result{}) {
+			t.Fatalf("dispatched %+v, %v before the retry was due", result, err)
+		}
+		makeDue(t, pool)
+	}
+
+	// Dead deliveries are not attempted again
+	if _, err := pool.Exec(ctx, "UPDATE webhook_deliveries SET next_attempt_at = NOW()"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Dispatch(ctx, pool, NewClient(cfg), cfg, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if n := r.count(); n != int(cfg.MaxAttempts) {
+		t.Fatalf("endpoint got %d requests, want %d", n, cfg.MaxAttempts)
+	}
+}
+
+func TestDispatchRedirectFails(t *testing.T) {
+	pool := dbtest.New(t)
+	ctx := context.Background()
+	target := newReceiver(t, func(w http.ResponseWriter, r *http.Request) {})
+	r := newReceiver(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.server.URL, http.StatusMovedPermanently)
+	})
+	sub := subscribe(t, pool, r.server.URL, "new")
+
+	cfg := DefaultConfig()
+	result, err := Dispatch(ctx, pool, NewClient(cfg), cfg, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Failed != 1 {
+		t.Fatalf("result %+v, want one failure", result)
+	}
+	d := delivery(t, pool, sub.ID)
+	if d.Status != StatusPending || d.ResponseStatus == nil || *d.ResponseStatus != http.StatusMovedPermanently {
+		t.Fatalf("status %s, response %v", d.Status, d.ResponseStatus)
+	}
+	if n := target.count(); n != 0 {
+		t.Fatalf("redirect was followed %d times", n)
+	}
+}
+
+func TestDispatchDelivers(t *testing.T) {
+	pool := dbtest.New(t)
+	ctx := context.Background()
+	r := newReceiver(t, func(w http.ResponseWriter, r *http.Request) {})
+	sub := subscribe(t, pool, r.server.URL, "new")
+
+	cfg := DefaultConfig()
+	result, err := Dispatch(ctx, pool, NewClient(cfg), cfg, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Delivered != 1 {
+		t.Fatalf("result %+v, want one delivery", result)
+	}
+	d := delivery(t, pool, sub.ID)
+	if d.Status != StatusDelivered || !d.DeliveredAt.Valid || d.LastError != nil {
+		t.Fatalf("delivery %+v", d)
+	}
+}
+
+// TestDispatchSignsWithBothSecretsDuringOverlap rotates the secret and
+// checks that receivers holding either secret accept deliveries until the
+// overlap ends, and only the new secret after.
+func TestDispatchSignsWithBothSecretsDuringOverlap(t *testing.T) {
+	pool := dbtest.New(t)
+	ctx := context.Background()
+	r := newReceiver(t, func(w http.ResponseWriter, r *http.Request) {})
+	sub := subscribe(t, pool, r.server.URL, "old")
+
+	rotatedAt := time.Now()
+	if _, err := sqlc.New(pool).RotateWebhookSecret(ctx, sqlc.RotateWebhookSecretParams{
+		ID:                      sub.ID,
+		Secret:                  "new",
+		PreviousSecretExpiresAt: pgtype.Timestamptz{Time: rotatedAt.Add(SecretOverlap), Valid: true},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		now        time.Time
+		signatures int
+		// accepted says which receiver secrets verify the delivery
+		accepted map[string]bool
+	}{
+		{name: "during overlap", now: rotatedAt.Add(time.Hour), signatures: 2, accepted: map[string]bool{"new": true, "old": true}},
+		{name: "after overlap", now: rotatedAt.Add(SecretOverlap + time.Hour), signatures: 1, accepted: map[string]bool{"new": true, "old": false}},
+	}
+	cfg := DefaultConfig()
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := pool.Exec(ctx, "UPDATE webhook_deliveries SET status = 'pending', next_attempt_at = NOW()"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Dispatch(ctx, pool, NewClient(cfg), cfg, tt.now); err != nil {
+				t.Fatal(err)
+			}
+			if n := r.count(); n != i+1 {
+				t.Fatalf("endpoint got %d requests, want %d", n, i+1)
+			}
+			req, body := r.last()
+			header := req.Header.Get(HeaderSignature)
+			if n := strings.Count(header, "v1="); n != tt.signatures {
+				t.Errorf("header %q has %d signatures, want %d", header, n, tt.signatures)
+			}
+			for secret, want := range tt.accepted {
+				if got := verify(header, secret, body, time.Now(), time.Minute); got != want {
+					t.Errorf("receiver with %q: verified %v, want %v", secret, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/cloutdotgg/backend/internal/db/sqlc"
+	"github.com/cloutdotgg/backend/internal/events"
+	"github.com/cloutdotgg/backend/internal/outbox"
+)
+
+// sink turns outbox events into queued deliveries.
+type sink struct {
+	pool *pgxpool.Pool
+}
+
+// NewSink returns the outbox sink that queues deliveries for the
+// subscriptions to each event. Event ids are derived from the id of the
+// outbox event, so an event relayed twice is queued once.
+func NewSink(pool *pgxpool.Pool) outbox.Sink {
+	return sink{pool: pool}
+}
+
+func (s sink) Name() string {
+	return "webhooks"
+}
+
+func (s sink) Deliver(ctx context.Context, m outbox.Message) error {
+	switch {
+	case m.Event.Kind == events.KindCommentCreated:
+		return s.queueComment(ctx, m)
+	case m.Event.ChangesRatings():
+		return s.queueRankChanges(ctx, m)
+	default:
+		return nil
+	}
+}
+
+// queueComment queues an EventCommentCreated event.
+func (s sink) queueComment(ctx context.Context, m outbox.Message) error {
+	q := sqlc.New(s.pool)
+	comment, err := q.GetCommentByID(ctx, m.Event.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get comment %d: %w", m.Event.ID, err)
+	}
+	company, err := q.GetCompanyByID(ctx, comment.CompanyID)
+	if err != nil {
+		return fmt.Errorf("failed to get company %d: %w", comment.CompanyID, err)
+	}
+
+	data := Comment{
+		ID:          comment.ID,
+		CompanyID:   company.ID,
+		CompanySlug: company.Slug,
+		CompanyName: company.Name,
+		Content:     comment.Content,
+		CreatedAt:   comment.CreatedAt.Time,
+	}
+	if comment.IsCurrentEmployee != nil {
+		data.IsCurrentEmployee = *comment.IsCurrentEmployee
+	}
+	return queue(ctx, q, Payload{
+		ID:        "evt_" + strconv.FormatInt(m.Offset.EventID, 10),
+		Type:      EventCommentCreated,
+		CreatedAt: m.Event.At,
+		Data:      data,
+	})
+}
+
+// queueRankChanges queues an EventCompanyRankChanged event for every
+// company whose rank differs from the one last announced, whether it was
+// in the vote or was overtaken, and remembers the new ranks.
+func (s sink) queueRankChanges(ctx context.Context, m outbox.Message) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	q := sqlc.New(tx)
+
+	changes, err := q.ListCompanyRankChanges(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list rank changes: %w", err)
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	ids := make([]int32, len(changes))
+	ranks := make([]int32, len(changes))
+	for i, c := range changes {
+		ids[i], ranks[i] = c.CompanyID, c.Rank
+		if err := queue(ctx, q, Payload{
+			ID:        fmt.Sprintf("evt_%d_%d", m.Offset.EventID, c.CompanyID),
+			Type:      EventCompanyRankChanged,
+			CreatedAt: m.Event.At,
+			Data: RankChange{
+				CompanyID:    c.CompanyID,
+				Slug:         c.Slug,
+				Name:         c.Name,
+				Rank:         c.Rank,
+				PreviousRank: c.PreviousRank,
+				EloRating:    c.EloRating,
+			},
+		}); err != nil {
+			return err
+		}
+	}
+	if err := q.SetWebhookCompanyRanks(ctx, sqlc.SetWebhookCompanyRanksParams{
+		CompanyIds: ids,
+		Ranks:      ranks,
+	}); err != nil {
+		return fmt.Errorf("failed to save announced ranks: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit rank changes: %w", err)
+	}
+	return nil
+}
+
+// queue adds p to the deliveries of every subscription to its type.
+func queue(ctx context.Context, q *sqlc.Queries, p Payload) error {
+	body, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", p.Type, err)
+	}
+	if _, err := q.CreateWebhookDeliveries(ctx, sqlc.CreateWebhookDeliveriesParams{
+		EventID:   p.ID,
+		EventType: p.Type,
+		Payload:   body,
+	}); err != nil {
+		return fmt.Errorf("failed to queue %s event: %w", p.Type, err)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/cloutdotgg/backend/internal/db/sqlc"
+	"github.com/cloutdotgg/backend/internal/dbtest"
+	"github.com/cloutdotgg/backend/internal/decay"
+	"github.com/cloutdotgg/backend/internal/outbox"
+)
+
+// TestDecayQueuesRankChanges decays the top company below the runner-up and
+// checks that the outbox event of the decay queues a rank change for both.
+func TestDecayQueuesRankChanges(t *testing.T) {
+	pool := dbtest.New(t)
+	ctx := context.Background()
+	q := sqlc.New(pool)
+
+	// The top company has gone unvoted for long enough to decay
+	var top, second int32
+	if err := pool.QueryRow(ctx, "SELECT min(id), max(id) FROM (SELECT id FROM companies ORDER BY id LIMIT 2) c").Scan(&top, &second); err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []struct {
+		sql  string
+		args []any
+	}{
+		{sql: "UPDATE companies SET rating = 1500, elo_rating = 1500"},
+		{
+			sql:  "UPDATE companies SET rating = 1700, elo_rating = 1700, rating_deviation = 100, created_at = now() - interval '200 days' WHERE id = $1",
+			args: []any{top},
+		},
+		{sql: "UPDATE companies SET rating = 1650, elo_rating = 1650 WHERE id = $1", args: []any{second}},
+		// Announce the ranks as they are now
+		{sql: `INSERT INTO webhook_company_ranks (company_id, rank)
+			SELECT id, RANK() OVER (ORDER BY elo_rating DESC) FROM companies
+			ON CONFLICT (company_id) DO UPDATE SET rank = EXCLUDED.rank`},
+	} {
+		if _, err := pool.Exec(ctx, stmt.sql, stmt.args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sub, err := q.CreateWebhookSubscription(ctx, sqlc.CreateWebhookSubscriptionParams{
+		Url:        "https://example.com/hook",
+		Secret:     "secret",
+		EventTypes: []string{EventCompanyRankChanged},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	webhooks := NewSink(pool)
+	if err := outbox.Replay(ctx, q, webhooks.Name(), outbox.Offset{}); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := decay.DefaultConfig()
+	cfg.Rate = 0.5
+	if decayed, err := decay.Run(ctx, pool, cfg, time.Now()); err != nil || decayed != 1 {
+		t.Fatalf("decayed %d companies, %v", decayed, err)
+	}
+
+	// The relay only reads events once every older transaction has ended,
+	// which other tests sharing the database can hold back
+	relay := outbox.NewRelay(pool, webhooks)
+	var deliveries []sqlc.WebhookDelivery
+	deadline := time.Now().Add(10 * time.Second)
+	for len(deliveries) < 2 && time.Now().Before(deadline) {
+		if err := relay.Run(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if deliveries, err = q.ListWebhookDeliveries(ctx, sqlc.ListWebhookDeliveriesParams{
+			SubscriptionID: sub.ID,
+			PageLimit:      10,
+		}); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	type ranks struct{ rank, previous int32 }
+	want := map[int32]ranks{top: {2, 1}, second: {1, 2}}
+	got := make(map[int32]ranks)
+	for _, d := range deliveries {
+		var p struct {
+			Type string     `json:"type"`
+			Data RankChange `json:"data"`
+		}
+		if err := json.Unmarshal(d.Payload, &p); err != nil {
+			t.Fatal(err)
+		}
+		if p.Type != EventCompanyRankChanged {
+			t.Errorf("queued a %s event", p.Type)
+		}
+		got[p.Data.CompanyID] = ranks{p.Data.Rank, p.Data.PreviousRank}
+	}
+	if len(got) != len(want) {
+		t.Fatalf("queued rank changes %v, want %v", got, want)
+	}
+	for id, r := range want {
+		if got[id] != r {
+			t.Errorf("company %d: got rank %d from %d, want %d from %d", id, got[id].rank, got[id].previous, r.rank, r.previous)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+// Package webhook notifies partner endpoints of events over HTTP. Events
+// are queued per subscription from the outbox, signed with the
+// subscription's secret and retried with exponential backoff until they are
+// delivered or run out of attempts.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event types a subscription can filter on.
+const (
+	// EventCompanyRankChanged is a company moving on the global leaderboard.
+	EventCompanyRankChanged = "company.rank_changed"
+	// EventCommentCreated is a new comment on a company.
+	EventCommentCreated = "comment.created"
+)
+
+// EventTypes lists every event type.
+var EventTypes = []string{EventCompanyRankChanged, EventCommentCreated}
+
+// Statuses of a delivery.
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	// StatusDead is a delivery that failed MaxAttempts times and will not
+	// be retried.
+	StatusDead = "dead"
+)
+
+// Headers sent with every delivery.
+const (
+	HeaderEvent     = "X-Clout-Event"
+	HeaderEventID   = "X-Clout-Event-Id"
+	HeaderDelivery  = "X-Clout-Delivery"
+	HeaderSignature = "X-Clout-Signature"
+)
+
+// SecretOverlap is how long a rotated secret keeps signing deliveries next
+// to its replacement.
+const SecretOverlap = 24 * time.Hour
+
+// Config controls how deliveries are attempted.
+type Config struct {
+	// BatchSize is how many due deliveries one run attempts.
+	BatchSize int32
+	// MaxAttempts is how many times a delivery is attempted before it is
+	// dead.
+	MaxAttempts int32
+	// InitialBackoff is the wait after the first failed attempt. It doubles
+	// with every further failure up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Timeout bounds each attempt.
+	Timeout time.Duration
+}
+
+// DefaultConfig retries a failing endpoint for about eight and a half hours.
+func DefaultConfig() Config {
+	return Config{
+		BatchSize:      50,
+		MaxAttempts:    10,
+		InitialBackoff: time.Minute,
+		MaxBackoff:     6 * time.Hour,
+		Timeout:        10 * time.Second,
+	}
+}
+
+// Backoff returns the wait after the given number of failed attempts.
+func (c Config) Backoff(failures int32) time.Duration {
+	backoff := c.InitialBackoff
+	for i := int32(1); i < failures && backoff < c.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, c.MaxBackoff)
+}
+
+// Payload is the JSON body of a delivery.
+type Payload struct {
+	// ID identifies the event; a receiver that sees it twice can skip it.
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// RankChange is the data of an EventCompanyRankChanged event.
+type RankChange struct {
+	CompanyID int32  `json:"company_id"`
+	Slug      string `json:"slug"`
+	Name      string `json:"name"`
+	Rank      int32  `json:"rank"`
+	// PreviousRank is 0 for a company that was not ranked before.
+	PreviousRank int32 `json:"previous_rank"`
+	EloRating    int32 `json:"elo_rating"`
+}
+
+// Comment is the data of an EventCommentCreated event.
+type Comment struct {
+	ID                int32     `json:"id"`
+	CompanyID         int32     `json:"company_id"`
+	CompanySlug       string    `json:"company_slug"`
+	CompanyName       string    `json:"company_name"`
+	Content           string    `json:"content"`
+	IsCurrentEmployee bool      `json:"is_current_employee"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// ValidateEventTypes checks that every type is known.
+func ValidateEventTypes(types []string) error {
+	for _, t := range types {
+		if !slices.Contains(EventTypes, t) {
+			return fmt.Errorf("unknown event type %q, expected one of %s", t, strings.Join(EventTypes, ", "))
+		}
+	}
+	return nil
+}
+
+// ValidateURL checks that raw is an absolute https URL, or an http URL on
+// localhost for development.
+func ValidateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid webhook url: %w", err)
+	}
+	if u.Host == "" {
+		return errors.New("webhook url must be absolute")
+	}
+	switch {
+	case u.Scheme == "https":
+	case u.Scheme == "http" && (u.Hostname() == "localhost" || u.Hostname() == "127.0.0.1"):
+	default:
+		return errors.New("webhook url must use https")
+	}
+	return nil
+}
+
+// NewSecret generates a signing secret.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// Sign returns the hex HMAC-SHA256 of the timestamp and body under secret.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignatureHeader returns the HeaderSignature value for body signed at
+// timestamp, as "t=<unix seconds>,v1=<signature>" with one v1 per secret.
+// Receivers recompute Sign with their secret and accept the delivery if any
+// v1 matches and t is recent.
+func SignatureHeader(secrets []string, timestamp time.Time, body []byte) string {
+	parts := []string{"t=" + strconv.FormatInt(timestamp.Unix(), 10)}
+	for _, secret := range secrets {
+		parts = append(parts, "v1="+Sign(secret, timestamp, body))
+	}
+	return strings.Join(parts, ",")
+}
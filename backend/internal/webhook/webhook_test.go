@@ -0,0 +1,117 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// verify checks header the way a receiver holding secret would: some v1
+// must match the body signed at t, and t must be within tolerance of now.
+func verify(header, secret string, body []byte, now time.Time, tolerance time.Duration) bool {
+	var timestamp time.Time
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return false
+		}
+		switch key {
+		case "t":
+			unix, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return false
+			}
+			timestamp = time.Unix(unix, 0)
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if timestamp.IsZero() || now.Sub(timestamp).Abs() > tolerance {
+		return false
+	}
+	want := Sign(secret, timestamp, body)
+	for _, signature := range signatures {
+		if hmac.Equal([]byte(signature), []byte(want)) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSignatureHeader(t *testing.T) {
+	body := []byte(`{"id":"evt_1","type":"comment.created"}`)
+	now := time.Unix(1_700_000_000, 0)
+
+	tests := []struct {
+		name    string
+		secrets []string
+		// receiver is the secret the receiver verifies with
+		receiver string
+		body     []byte
+		at       time.Time
+		want     bool
+	}{
+		{name: "current secret", secrets: []string{"new"}, receiver: "new", body: body, at: now, want: true},
+		{name: "wrong secret", secrets: []string{"new"}, receiver: "old", body: body, at: now},
+		{name: "overlap, receiver not switched yet", secrets: []string{"new", "old"}, receiver: "old", body: body, at: now, want: true},
+		{name: "overlap, receiver switched", secrets: []string{"new", "old"}, receiver: "new", body: body, at: now, want: true},
+		{name: "after overlap", secrets: []string{"new"}, receiver: "old", body: body, at: now},
+		{name: "tampered body", secrets: []string{"new"}, receiver: "new", body: []byte(`{"id":"evt_2"}`), at: now},
+		{name: "replayed later", secrets: []string{"new"}, receiver: "new", body: body, at: now.Add(10 * time.Minute)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := SignatureHeader(tt.secrets, now, body)
+			if got := verify(header, tt.receiver, tt.body, tt.at, 5*time.Minute); got != tt.want {
+				t.Fatalf("verify(%q) = %v, want %v", header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignatureHeaderFormat(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	body := []byte("{}")
+	header := SignatureHeader([]string{"new", "old"}, now, body)
+	want := "t=1700000000,v1=" + Sign("new", now, body) + ",v1=" + Sign("old", now, body)
+	if header != want {
+		t.Fatalf("got %q, want %q", header, want)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	cfg := DefaultConfig()
+	tests := []struct {
+		failures int32
+		want     time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{6, 32 * time.Minute},
+		{9, 256 * time.Minute},
+		{10, 6 * time.Hour},
+		{1000, 6 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := cfg.Backoff(tt.failures); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+// TestDefaultConfigGivesUpAfterHours checks the retry window DefaultConfig
+// documents.
+func TestDefaultConfigGivesUpAfterHours(t *testing.T) {
+	cfg := DefaultConfig()
+	var total time.Duration
+	for failures := int32(1); failures < cfg.MaxAttempts; failures++ {
+		total += cfg.Backoff(failures)
+	}
+	if total < 8*time.Hour || total > 9*time.Hour {
+		t.Fatalf("deliveries are retried for %v", total)
+	}
+}
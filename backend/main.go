@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"log"
 	"net/http"
 	"os"
@@ -15,9 +16,20 @@ import (
 	"golang.org/x/net/http2"
 	"golang.org/x/net/http2/h2c"
 
+	"github.com/cloutdotgg/backend/internal/auth"
 	"github.com/cloutdotgg/backend/internal/db"
+	"github.com/cloutdotgg/backend/internal/db/sqlc"
+	"github.com/cloutdotgg/backend/internal/decay"
+	"github.com/cloutdotgg/backend/internal/events"
+	"github.com/cloutdotgg/backend/internal/fraud"
 	"github.com/cloutdotgg/backend/internal/gen/apiv1/apiv1connect"
+	"github.com/cloutdotgg/backend/internal/jobs"
+	"github.com/cloutdotgg/backend/internal/matchmaking"
+	"github.com/cloutdotgg/backend/internal/outbox"
+	"github.com/cloutdotgg/backend/internal/ratelimit"
+	"github.com/cloutdotgg/backend/internal/rating"
 	"github.com/cloutdotgg/backend/internal/service"
+	"github.com/cloutdotgg/backend/internal/webhook"
 	"github.com/joho/godotenv"
 )
 
@@ -46,18 +58,109 @@ func main() {
 
 	log.Println("Connected to database")
 
+	// Select the rating engine (elo or glicko2)
+	rater, err := rating.New(os.Getenv("RATING_ENGINE"))
+	if err != nil {
+		log.Fatalf("Invalid rating engine: %v", err)
+	}
+	log.Printf("Using %s rating engine", rater.Name())
+
+	// Select the default matchmaking strategy; admins can switch it at runtime
+	strategy, err := matchmaking.New(os.Getenv("MATCHMAKING_STRATEGY"))
+	if err != nil {
+		log.Fatalf("Invalid matchmaking strategy: %v", err)
+	}
+	log.Printf("Using %s matchmaking by default", strategy.Name())
+
+	// Secret for server-issued tokens; without one, tokens do not survive a
+	// restart and are not accepted by other instances
+	tokenSecret := []byte(os.Getenv("TOKEN_SECRET"))
+	if len(tokenSecret) == 0 {
+		tokenSecret = make([]byte, 32)
+		if _, err := rand.Read(tokenSecret); err != nil {
+			log.Fatalf("Failed to generate token secret: %v", err)
+		}
+		log.Println("TOKEN_SECRET not set, using a random secret for this process")
+	}
+
+	// Decide what happens to repeated votes by the same voter on the same pair
+	repeatVotes, err := service.ParseRepeatVotePolicy(os.Getenv("VOTE_COOLDOWN"), os.Getenv("REPEAT_VOTE_ACTION"))
+	if err != nil {
+		log.Fatalf("Invalid repeat vote policy: %v", err)
+	}
+	log.Printf("Repeat votes within %s are handled with %q", repeatVotes.Cooldown, repeatVotes.Action)
+
+	// Decide how the ratings of companies without recent votes decay
+	decayConfig, err := decay.ParseConfig(os.Getenv("RATING_DECAY_AFTER"), os.Getenv("RATING_DECAY_RATE"), os.Getenv("RATING_DECAY_DEVIATION"))
+	if err != nil {
+		log.Fatalf("Invalid rating decay settings: %v", err)
+	}
+	if decayConfig.Enabled() {
+		log.Printf("Ratings decay after %s without votes", decayConfig.InactiveAfter)
+	}
+
+	// Domain events reach the subscribers on every replica through Postgres
+	// notifications. Handlers record them in the outbox, and the relay
+	// publishes them once committed and queues them for webhooks.
+	bus := events.NewPostgres(pool, events.DefaultBuffer)
+	relay := outbox.NewRelay(pool, outbox.NewBusSink(bus), webhook.NewSink(pool))
+
 	// Create rankings service
-	rankingsService := service.NewRankingsService(pool)
+	rankingsService := service.NewRankingsService(pool, rater, strategy, tokenSecret, repeatVotes, bus)
 
 	// Create Connect handler
 	mux := http.NewServeMux()
 
+	// Verify the anonymous session token, and bearer tokens from the identity
+	// provider when configured; otherwise every caller is anonymous
+	interceptors := []connect.Interceptor{service.NewSessionInterceptor(pool, tokenSecret)}
+	if jwksURL := os.Getenv("AUTH_JWKS_URL"); jwksURL != "" {
+		verifier, err := auth.NewVerifier(auth.Config{
+			JWKSURL:  jwksURL,
+			Issuer:   os.Getenv("AUTH_ISSUER"),
+			Audience: os.Getenv("AUTH_AUDIENCE"),
+		})
+		if err != nil {
+			log.Fatalf("Invalid auth configuration: %v", err)
+		}
+		interceptors = append(interceptors, auth.NewInterceptor(verifier), service.NewUserInterceptor(pool))
+		log.Printf("Verifying bearer tokens against %s", jwksURL)
+	} else {
+		log.Println("AUTH_JWKS_URL not set, all requests are anonymous")
+	}
+
+	// Rate limit mutating procedures per caller and per client address, after
+	// the caller has been identified. Postgres-backed limits hold across
+	// replicas; in-memory limits are per process.
+	limiter, err := ratelimit.New(os.Getenv("RATE_LIMIT_BACKEND"), sqlc.New(pool))
+	if err != nil {
+		log.Fatalf("Invalid rate limit backend: %v", err)
+	}
+	trustedProxies, err := ratelimit.ParseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		log.Fatalf("Invalid trusted proxies: %v", err)
+	}
+	interceptors = append(interceptors, service.NewRateLimitInterceptor(limiter, trustedProxies))
+	log.Printf("Using %s rate limits, trusting X-Forwarded-For from %d proxy networks", limiter.Name(), len(trustedProxies))
+
 	// Register Connect service
 	path, handler := apiv1connect.NewRankingsServiceHandler(
 		rankingsService,
-		connect.WithInterceptors(),
+		connect.WithInterceptors(interceptors...),
 	)
-	mux.Handle(path, handler)
+	mux.Handle(path, service.WithoutStreamTimeouts(handler))
+
+	// Register admin service only when an API key is configured
+	if adminAPIKey := os.Getenv("ADMIN_API_KEY"); adminAPIKey != "" {
+		adminPath, adminHandler := apiv1connect.NewAdminServiceHandler(
+			service.NewAdminService(pool, rater, strategy),
+			connect.WithInterceptors(service.NewAdminAuthInterceptor(adminAPIKey)),
+		)
+		mux.Handle(adminPath, adminHandler)
+		log.Printf("Admin service available at %s", adminPath)
+	} else {
+		log.Println("ADMIN_API_KEY not set, admin service disabled")
+	}
 
 	// CORS configuration
 	corsHandler := cors.New(cors.Options{
@@ -93,6 +196,7 @@ func main() {
 			"Content-Encoding",
 			"Content-Type",
 			"Grpc-Timeout",
+			service.SessionTokenHeader,
 			"X-Grpc-Web",
 			"X-User-Agent",
 		},
@@ -102,6 +206,7 @@ func main() {
 			"Grpc-Message",
 			"Grpc-Status",
 			"Grpc-Status-Details-Bin",
+			"Retry-After",
 		},
 		AllowCredentials: true,
 		MaxAge:           7200,
@@ -119,6 +224,29 @@ func main() {
 		IdleTimeout:  60 * time.Second,
 	}
 
+	// Start background jobs
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	go bus.Listen(jobsCtx)
+	go jobs.Every(jobsCtx, "outbox relay", time.Second, relay.Run)
+	go jobs.Every(jobsCtx, "webhook delivery", 5*time.Second, jobs.DeliverWebhooks(pool, webhook.DefaultConfig()))
+	go jobs.Every(jobsCtx, "webhook delivery cleanup", time.Hour, jobs.DeleteOldWebhookDeliveries(sqlc.New(pool), 30*24*time.Hour))
+	go jobs.Every(jobsCtx, "rating snapshots", time.Hour, jobs.SnapshotRatings(sqlc.New(pool)))
+	go jobs.Every(jobsCtx, "activity rollup", time.Minute, jobs.RollUpActivity(pool))
+	go jobs.Every(jobsCtx, "matchup token cleanup", time.Hour, jobs.DeleteExpiredMatchupTokens(sqlc.New(pool)))
+	go jobs.Every(jobsCtx, "session cleanup", time.Hour, jobs.DeleteExpiredSessions(sqlc.New(pool)))
+	go jobs.Every(jobsCtx, "vote undo cleanup", time.Hour, jobs.DeleteExpiredVoteRatingDeltas(sqlc.New(pool), service.VoteUndoWindow))
+	go jobs.Every(jobsCtx, "fraud analysis", 10*time.Minute, jobs.AnalyzeVotes(pool, fraud.DefaultConfig()))
+	if repeatVotes.Cooldown > 0 {
+		go jobs.Every(jobsCtx, "pair vote cleanup", time.Hour, jobs.DeleteExpiredPairVotes(sqlc.New(pool), repeatVotes.Cooldown))
+	}
+	if decayConfig.Enabled() {
+		go jobs.Every(jobsCtx, "rating decay", time.Hour, jobs.DecayInactiveRatings(pool, decayConfig))
+	}
+	if limiter.Name() == "postgres" {
+		go jobs.Every(jobsCtx, "rate limit cleanup", time.Hour, jobs.DeleteIdleRateLimitBuckets(sqlc.New(pool)))
+	}
+
 	// Start server in goroutine
 	go func() {
 		log.Printf("Connect server starting on http://localhost:%s", port)
@@ -134,6 +262,7 @@ func main() {
 	<-quit
 
 	log.Println("Shutting down server...")
+	stopJobs()
 
 	// Graceful shutdown with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -2,7 +2,7 @@
 
 import { useState, useEffect, use } from "react";
 import Link from "next/link";
-import { api, getSessionId, Company, AggregatedRating, CompanyComment } from "@/lib/api";
+import { api, Company, AggregatedRating, CompanyComment } from "@/lib/api";
 import { timestampDate } from "@bufbuild/protobuf/wkt";
 
 const RATING_CRITERIA = [
@@ -61,7 +61,7 @@ export default function CompanyPage({ params }: Props) {
     if (!selectedCriterion || !selectedScore || !company) return;
     setSubmittingRating(true);
     try {
-      await api.submitRating({ companyId: company.id, criterion: selectedCriterion, score: selectedScore, sessionId: getSessionId() });
+      await api.submitRating({ companyId: company.id, criterion: selectedCriterion, score: selectedScore });
       const newRatings = await api.getCompanyRatings({ slug });
       setRatings(newRatings.ratings);
       setSelectedCriterion(null);
@@ -78,7 +78,7 @@ export default function CompanyPage({ params }: Props) {
     if (!newComment.trim() || !company) return;
     setSubmittingComment(true);
     try {
-      const result = await api.submitComment({ companyId: company.id, content: newComment, isCurrentEmployee, sessionId: getSessionId() });
+      const result = await api.submitComment({ companyId: company.id, content: newComment, isCurrentEmployee });
       if (result.comment) setComments([result.comment, ...comments]);
       setNewComment("");
       setIsCurrentEmployee(false);
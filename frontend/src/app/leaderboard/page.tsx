@@ -72,6 +72,37 @@ function LeaderboardContent() {
     }
   }, [activeTab, loadLeaderboard, loadUserLeaderboard]);
 
+  // Keep the first page live while it is shown
+  useEffect(() => {
+    if (activeTab !== "companies" || page !== 1) return;
+
+    const controller = new AbortController();
+    (async () => {
+      try {
+        const stream = api.watchLeaderboard(
+          {
+            category: selectedCategory !== "all" ? selectedCategory : undefined,
+            topN: 25,
+          },
+          { signal: controller.signal }
+        );
+        for await (const res of stream) {
+          if (res.event.case === "update") {
+            const data = await api.getLeaderboard({
+              category: selectedCategory !== "all" ? selectedCategory : undefined,
+              page: 1,
+              pageSize: 25,
+            });
+            setLeaderboard(data);
+          }
+        }
+      } catch (err) {
+        if (!controller.signal.aborted) console.error(err);
+      }
+    })();
+    return () => controller.abort();
+  }, [activeTab, selectedCategory, page]);
+
   const handleCategoryChange = (category: string) => {
     setSelectedCategory(category);
     setPage(1);
@@ -108,9 +139,7 @@ function LeaderboardContent() {
     : 0;
 
   const renderUserRow = (user: UserLeaderboardEntry, index: number) => {
-    const displayName = user.userId.includes("|")
-      ? `User ${user.userId.split("|")[1].slice(0, 8)}...`
-      : user.userId.slice(0, 12) + "...";
+    const displayName = user.displayName;
 
     return (
       <div
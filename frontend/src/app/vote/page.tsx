@@ -2,18 +2,24 @@
 
 import { useState, useEffect, useCallback } from "react";
 import Link from "next/link";
-import { useUser } from "@auth0/nextjs-auth0/client";
-import { api, getSessionId, Company, CategoryCount, GetMatchupResponse, SubmitVoteResponse } from "@/lib/api";
+import { api, Company, CategoryCount, GetMatchupResponse, SubmitVoteResponse, VoteOutcome, VoteStrength } from "@/lib/api";
 
-type VoteState = "idle" | "voting" | "voted";
+type VoteState = "idle" | "voting" | "voted" | "undoing";
+
+const STRENGTHS = [
+  { value: VoteStrength.SLIGHT, label: "Slightly better" },
+  { value: VoteStrength.NORMAL, label: "Better" },
+  { value: VoteStrength.STRONG, label: "Much better" },
+];
 
 export default function VotePage() {
-  const { user } = useUser();
   const [matchup, setMatchup] = useState<GetMatchupResponse | null>(null);
   const [categories, setCategories] = useState<CategoryCount[]>([]);
   const [selectedCategory, setSelectedCategory] = useState("all");
   const [voteState, setVoteState] = useState<VoteState>("idle");
   const [voteResult, setVoteResult] = useState<SubmitVoteResponse | null>(null);
+  const [voteOutcome, setVoteOutcome] = useState(VoteOutcome.WIN);
+  const [strength, setStrength] = useState(VoteStrength.NORMAL);
   const [selectedId, setSelectedId] = useState<number | null>(null);
   const [votesThisSession, setVotesThisSession] = useState(0);
   const [loading, setLoading] = useState(true);
@@ -45,20 +51,27 @@ export default function VotePage() {
     loadMatchup();
   }, [loadMatchup]);
 
-  const handleVote = async (winnerId: number, loserId: number) => {
+  const handleVote = async (winnerId: number, loserId: number, outcome = VoteOutcome.WIN) => {
     if (voteState !== "idle" || !matchup) return;
 
     setVoteState("voting");
-    setSelectedId(winnerId);
+    setSelectedId(outcome === VoteOutcome.WIN ? winnerId : null);
 
     try {
       const result = await api.submitVote({
         winnerId,
         loserId,
-        sessionId: getSessionId(),
-        userId: user?.sub,
+        matchupToken: matchup.matchupToken,
+        outcome,
+        strength: outcome === VoteOutcome.WIN ? strength : VoteStrength.NORMAL,
       });
+      if (outcome === VoteOutcome.SKIP) {
+        setVotesThisSession((prev) => prev + 1);
+        await loadMatchup();
+        return;
+      }
       setVoteResult(result);
+      setVoteOutcome(outcome);
       setVoteState("voted");
       setVotesThisSession((prev) => prev + 1);
     } catch (err) {
@@ -68,11 +81,38 @@ export default function VotePage() {
     }
   };
 
+  const handleUndo = async () => {
+    if (voteState !== "voted" || !matchup || !voteResult?.voteId) return;
+
+    setVoteState("undoing");
+
+    try {
+      const result = await api.undoVote({ voteId: voteResult.voteId });
+      const reverted = [result.winner, result.loser];
+      const update = (company?: Company) => reverted.find((c) => c && c.id === company?.id) ?? company;
+      setMatchup({
+        ...matchup,
+        company1: update(matchup.company1),
+        company2: update(matchup.company2),
+        matchupToken: result.matchupToken,
+        matchupTokenExpiresAt: result.matchupTokenExpiresAt,
+      });
+      setVoteResult(null);
+      setSelectedId(null);
+      setVoteState("idle");
+      setVotesThisSession((prev) => prev - 1);
+    } catch (err) {
+      setError(err instanceof Error ? err.message : "Failed to undo vote");
+      setVoteState("voted");
+    }
+  };
+
   const renderCompanyCard = (company: Company, isLeft: boolean) => {
-    const isWinner = voteResult?.winner?.id === company.id;
-    const isLoser = voteResult?.loser?.id === company.id;
+    const isDraw = voteResult !== null && voteOutcome === VoteOutcome.DRAW;
+    const isWinner = !isDraw && voteResult?.winner?.id === company.id;
+    const isLoser = !isDraw && voteResult?.loser?.id === company.id;
     const isSelected = selectedId === company.id;
-    const eloDiff = isWinner ? voteResult?.winnerEloDiff : isLoser ? voteResult?.loserEloDiff : null;
+    const eloDiff = voteResult?.winner?.id === company.id ? voteResult?.winnerEloDiff : voteResult?.loser?.id === company.id ? voteResult?.loserEloDiff : null;
     const otherCompany = isLeft ? matchup?.company2 : matchup?.company1;
 
     return (
@@ -81,12 +121,18 @@ export default function VotePage() {
         disabled={voteState !== "idle"}
         className={`vote-card w-full text-left ${isWinner ? "winner" : isLoser ? "loser" : ""} ${isSelected && voteState === "voting" ? "selected" : ""}`}
       >
-        {eloDiff !== null && eloDiff !== undefined && (
+        {voteResult?.counted && eloDiff !== null && eloDiff !== undefined && (
           <div className={`absolute top-4 right-4 text-2xl font-bold ${eloDiff > 0 ? "elo-up" : "elo-down"} animate-scaleIn`}>
             {eloDiff > 0 ? "+" : ""}{eloDiff}
           </div>
         )}
 
+        {isDraw && (
+          <div className="absolute top-4 left-4 px-3 py-1 rounded-full text-sm font-semibold animate-scaleIn bg-yellow-500/20 text-yellow-400 border border-yellow-500/30">
+            Tie
+          </div>
+        )}
+
         {(isWinner || isLoser) && (
           <div className={`absolute top-4 left-4 px-3 py-1 rounded-full text-sm font-semibold animate-scaleIn ${isWinner ? "bg-green-500/20 text-green-400 border border-green-500/30" : "bg-red-500/20 text-red-400 border border-red-500/30"}`}>
             {isWinner ? "Winner" : "Loser"}
@@ -181,6 +227,14 @@ export default function VotePage() {
 
         {matchup && matchup.company1 && matchup.company2 && !loading && !error && (
           <>
+            <div className="flex justify-center gap-2 mb-6">
+              {STRENGTHS.map((s) => (
+                <button key={s.value} onClick={() => setStrength(s.value)} disabled={voteState !== "idle"} className={`btn-sm rounded-full ${strength === s.value ? "btn-primary" : "btn-secondary"}`}>
+                  {s.label}
+                </button>
+              ))}
+            </div>
+
             <div className="grid md:grid-cols-2 gap-6 lg:gap-12 max-w-5xl mx-auto">
               {renderCompanyCard(matchup.company1, true)}
               <div className="hidden md:flex absolute left-1/2 top-1/2 -translate-x-1/2 -translate-y-1/2 z-10">
@@ -197,8 +251,8 @@ export default function VotePage() {
               </div>
             </div>
 
-            <div className="flex flex-col sm:flex-row items-center justify-center gap-4 mt-10">
-              {voteState === "voted" ? (
+            <div className="flex flex-col sm:flex-row sm:flex-wrap items-center justify-center gap-4 mt-10">
+              {voteState === "voted" || voteState === "undoing" ? (
                 <>
                   <button onClick={loadMatchup} className="btn-primary btn-lg">
                     <svg className="w-5 h-5" fill="none" viewBox="0 0 24 24" stroke="currentColor">
@@ -206,15 +260,30 @@ export default function VotePage() {
                     </svg>
                     Next Matchup
                   </button>
-                  <Link href={`/company/${voteResult?.winner?.slug}`} className="btn-secondary">View {voteResult?.winner?.name}</Link>
+                  {voteOutcome === VoteOutcome.WIN && (
+                    <Link href={`/company/${voteResult?.winner?.slug}`} className="btn-secondary">View {voteResult?.winner?.name}</Link>
+                  )}
+                  {voteResult?.voteId ? (
+                    <button onClick={handleUndo} className="btn-secondary" disabled={voteState === "undoing"}>
+                      Undo Vote
+                    </button>
+                  ) : null}
+                  {voteResult && !voteResult.counted && (
+                    <p className="w-full text-center text-sm text-[var(--text-muted)]">You already voted on this matchup recently, so this vote did not change the ratings.</p>
+                  )}
                 </>
               ) : (
-                <button onClick={loadMatchup} className="btn-secondary" disabled={voteState === "voting"}>
-                  <svg className="w-5 h-5" fill="none" viewBox="0 0 24 24" stroke="currentColor">
-                    <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M4 4v5h.582m15.356 2A8.001 8.001 0 004.582 9m0 0H9m11 11v-5h-.581m0 0a8.003 8.003 0 01-15.357-2m15.357 2H15" />
-                  </svg>
-                  Skip / New Matchup
-                </button>
+                <>
+                  <button onClick={() => handleVote(matchup.company1!.id, matchup.company2!.id, VoteOutcome.DRAW)} className="btn-secondary" disabled={voteState === "voting"}>
+                    It&apos;s a Tie
+                  </button>
+                  <button onClick={() => handleVote(matchup.company1!.id, matchup.company2!.id, VoteOutcome.SKIP)} className="btn-secondary" disabled={voteState === "voting"}>
+                    <svg className="w-5 h-5" fill="none" viewBox="0 0 24 24" stroke="currentColor">
+                      <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M4 4v5h.582m15.356 2A8.001 8.001 0 004.582 9m0 0H9m11 11v-5h-.581m0 0a8.003 8.003 0 01-15.357-2m15.357 2H15" />
+                    </svg>
+                    I Don&apos;t Know Either
+                  </button>
+                </>
               )}
             </div>
           </>
@@ -231,6 +300,8 @@ export default function VotePage() {
             <ul className="text-sm text-[var(--text-secondary)] space-y-2">
               <li className="flex items-start gap-2"><span className="text-accent">•</span>We use an ELO rating system (like chess) to rank companies</li>
               <li className="flex items-start gap-2"><span className="text-accent">•</span>Beating higher-ranked companies gives more points</li>
+              <li className="flex items-start gap-2"><span className="text-accent">•</span>Say how much better your pick is: close calls move ratings less than clear wins</li>
+              <li className="flex items-start gap-2"><span className="text-accent">•</span>Call it a tie if both are equally good, or skip if you don&apos;t know either</li>
               <li className="flex items-start gap-2"><span className="text-accent">•</span>Your votes are anonymous and help shape the community rankings</li>
             </ul>
           </div>
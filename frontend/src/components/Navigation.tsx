@@ -1,8 +1,10 @@
 "use client";
 
+import { useEffect } from "react";
 import Link from "next/link";
 import { usePathname } from "next/navigation";
 import { useUser } from "@auth0/nextjs-auth0/client";
+import { claimSession } from "@/lib/api";
 import Profile from "./Profile";
 import LoginButton from "./LoginButton";
 import LogoutButton from "./LogoutButton";
@@ -11,6 +13,11 @@ export default function Navigation() {
   const pathname = usePathname();
   const { user, isLoading } = useUser();
 
+  // Merge anything done before logging in into the account
+  useEffect(() => {
+    if (user) claimSession().catch(console.error);
+  }, [user]);
+
   const links = [
     { href: "/", label: "Home" },
     { href: "/vote", label: "Vote" },
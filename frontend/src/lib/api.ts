@@ -1,12 +1,44 @@
-import { createClient } from "@connectrpc/connect";
+import { Code, ConnectError, createClient, type Interceptor } from "@connectrpc/connect";
 import { createConnectTransport } from "@connectrpc/connect-web";
+import { getAccessToken } from "@auth0/nextjs-auth0";
 import { RankingsService } from "./gen/apiv1/api_pb";
 
 const API_URL = process.env.NEXT_PUBLIC_API_URL || "http://localhost:8080";
 
+// Send the logged-in user's access token so the backend can verify who they are
+const authInterceptor: Interceptor = (next) => async (req) => {
+  if (typeof window !== "undefined") {
+    try {
+      const token = await getAccessToken();
+      if (token) req.header.set("Authorization", `Bearer ${token}`);
+    } catch {
+      // Not logged in; call anonymously
+    }
+  }
+  return next(req);
+};
+
+// Send the anonymous session token, starting a session on first use. A
+// session the server no longer accepts is replaced once and the call retried.
+const sessionInterceptor: Interceptor = (next) => async (req) => {
+  if (typeof window === "undefined" || req.method.name === "StartSession") return next(req);
+
+  req.header.set("X-Session-Token", (await getSession()).sessionToken);
+  try {
+    return await next(req);
+  } catch (err) {
+    if (!(err instanceof ConnectError) || err.code !== Code.Unauthenticated || !err.rawMessage.includes("session")) throw err;
+    localStorage.removeItem(SESSION_KEY);
+    localStorage.removeItem(SESSION_TOKEN_KEY);
+    req.header.set("X-Session-Token", (await getSession()).sessionToken);
+    return next(req);
+  }
+};
+
 const transport = createConnectTransport({
   baseUrl: API_URL,
   useBinaryFormat: false,
+  interceptors: [authInterceptor, sessionInterceptor],
 });
 
 // Export the client directly - no wrapper functions needed
@@ -29,14 +61,39 @@ export type {
   GetCompanyRatingsResponse,
   GetCompanyCommentsResponse,
 } from "./gen/apiv1/api_pb";
+export { VoteOutcome, VoteStrength } from "./gen/apiv1/api_pb";
+
+const SESSION_KEY = "ai_rankings_session";
+const SESSION_TOKEN_KEY = "ai_rankings_session_token";
 
-// Session ID helper
-export function getSessionId(): string {
-  if (typeof window === "undefined") return "";
-  let sessionId = localStorage.getItem("ai_rankings_session");
-  if (!sessionId) {
-    sessionId = `session_${Date.now()}_${Math.random().toString(36).substr(2, 9)}`;
-    localStorage.setItem("ai_rankings_session", sessionId);
+let sessionPromise: Promise<{ sessionId: string; sessionToken: string }> | null = null;
+
+// Returns this browser's anonymous session, asking the server to start one on
+// first use. The token authenticates the session on every request.
+export function getSession(): Promise<{ sessionId: string; sessionToken: string }> {
+  if (typeof window === "undefined") return Promise.resolve({ sessionId: "", sessionToken: "" });
+
+  const sessionId = localStorage.getItem(SESSION_KEY);
+  const sessionToken = localStorage.getItem(SESSION_TOKEN_KEY);
+  if (sessionId && sessionToken) return Promise.resolve({ sessionId, sessionToken });
+
+  if (!sessionPromise) {
+    sessionPromise = api.startSession({}).then((res) => {
+      localStorage.setItem(SESSION_KEY, res.sessionId);
+      localStorage.setItem(SESSION_TOKEN_KEY, res.sessionToken);
+      return { sessionId: res.sessionId, sessionToken: res.sessionToken };
+    }).finally(() => {
+      sessionPromise = null;
+    });
   }
-  return sessionId;
+  return sessionPromise;
+}
+
+// Moves this browser's anonymous votes, ratings and comments to the signed-in
+// user. Safe to call repeatedly.
+export async function claimSession(): Promise<void> {
+  if (typeof window === "undefined") return;
+  const sessionToken = localStorage.getItem(SESSION_TOKEN_KEY);
+  if (!sessionToken) return;
+  await api.claimSession({ sessionToken });
 }